@@ -19,6 +19,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -29,28 +30,64 @@ import (
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/transparency-dev/tesseract"
+	"github.com/transparency-dev/tesseract/internal/logging"
 	"github.com/transparency-dev/tesseract/storage"
 	"github.com/transparency-dev/tesseract/storage/aws"
 	"github.com/transparency-dev/tessera"
 	taws "github.com/transparency-dev/tessera/storage/aws"
 	aws_as "github.com/transparency-dev/tessera/storage/aws/antispam"
 	"golang.org/x/mod/sumdb/note"
+	"golang.org/x/net/netutil"
 	"k8s.io/klog/v2"
 )
 
 func init() {
 	flag.Var(&notAfterStart, "not_after_start", "Start of the range of acceptable NotAfter values, inclusive. Leaving this unset implies no lower bound to the range. RFC3339 UTC format, e.g: 2024-01-02T15:04:05Z.")
 	flag.Var(&notAfterLimit, "not_after_limit", "Cut off point of notAfter dates - only notAfter dates strictly *before* notAfterLimit will be accepted. Leaving this unset means no upper bound on the accepted range. RFC3339 UTC format, e.g: 2024-01-02T15:04:05Z.")
+	flag.Var(&notBeforeCutoff, "not_before_cutoff", "Cut off point of notBefore dates - only notBefore dates at or after notBeforeCutoff will be accepted. Leaving this unset means no lower bound on notBefore dates. RFC3339 UTC format, e.g: 2024-01-02T15:04:05Z.")
 }
 
 // Global flags that affect all log instances.
 var (
-	notAfterStart timestampFlag
-	notAfterLimit timestampFlag
+	notAfterStart   timestampFlag
+	notAfterLimit   timestampFlag
+	notBeforeCutoff timestampFlag
 
 	httpEndpoint               = flag.String("http_endpoint", "localhost:6962", "Endpoint for HTTP (host:port).")
+	adminHTTPEndpoint          = flag.String("admin_http_endpoint", "", "If set, endpoint for admin HTTP endpoints (host:port), such as /tesseract/v1/admin/get-rejected-submissions. It must not be exposed publicly.")
 	httpDeadline               = flag.Duration("http_deadline", time.Second*10, "Deadline for HTTP requests.")
+	logFormat                  = flag.String("log_format", "text", "Format of log lines: 'text' for klog's default format, or 'json' for one JSON object per line, with structured fields such as the log origin and request details.")
+	maxConnections             = flag.Int("max_connections", 0, "Maximum number of concurrent TCP connections accepted by the HTTP server. Connections beyond this limit wait until others are closed. 0 means no limit.")
+	tlsCertFile                = flag.String("tls_cert_file", "", "If set, path to a PEM certificate chain, with which the HTTP server serves HTTPS. Requires --tls_key_file.")
+	tlsKeyFile                 = flag.String("tls_key_file", "", "Path to the PEM private key of --tls_cert_file.")
+	tlsClientCAFile            = flag.String("tls_client_ca_file", "", "If set, path to PEM CA certificates against which TLS client certificates are verified. add-chain and add-pre-chain then require a verified client certificate, while other endpoints remain open. Requires --tls_cert_file.")
 	maskInternalErrors         = flag.Bool("mask_internal_errors", false, "Don't return error strings with Internal Server Error HTTP responses.")
+	nodeName                   = flag.String("node_name", "", "If set, name of this node, returned in the X-CT-Node header of all responses, e.g. to find which node issued an SCT.")
+	securityContacts           = flag.String("security_contacts", "", "Comma separated list of URIs to report security issues and abuse to, e.g. mailto:abuse@example.com. If set, they are served in security.txt format at /.well-known/security.txt.")
+	securityPolicyURL          = flag.String("security_policy_url", "", "URL of the log's policy, served at /.well-known/security.txt. Requires --security_contacts.")
+	disableRequestLog          = flag.Bool("disable_request_log", false, "If true, requests are not logged, not even at high verbosity. Request metrics are still recorded.")
+	dedupHeader                = flag.Bool("dedup_header", false, "If true, add-chain and add-pre-chain responses carry an X-CT-Deduplicated header, set to true if the submission was already logged.")
+	maxNotAfterDrift           = flag.Duration("max_not_after_drift", 0, "If positive, add-chain rejects final certificates whose NotAfter differs by more than this from the NotAfter of their precertificate, if it was recently logged by this instance.")
+	requireSameShardAsPrecert  = flag.Bool("require_same_shard_as_precert", false, "If true, add-chain rejects final certificates whose NotAfter and the NotAfter of their precertificate, if it was recently logged by this instance, are on different sides of not_after_limit. Requires not_after_limit.")
+	verifyAfterWrite           = flag.Bool("verify_after_write", false, "If true, read back newly sequenced entries from storage and check them against submissions before returning SCTs. This waits for entries to be integrated.")
+	queueDir                   = flag.String("queue_dir", "", "If set, enables queue mode for maintenance: submissions are validated and durably queued in this directory, to be sequenced in the background, and add-chain and add-pre-chain return 202 with an ID to fetch SCTs with from /tesseract/v1/get-queued-sct.")
+	queueMaxSize               = flag.Int("queue_max_size", 0, "If positive, maximum number of submissions waiting in --queue_dir to be sequenced. Submissions beyond it fail with 503 Service Unavailable.")
+	queuePaused                = flag.Bool("queue_paused", false, "If true, queued submissions are not sequenced until resumed on the /tesseract/v1/admin/resume-queue admin endpoint, see --admin_http_endpoint.")
+	acceptDERChains            = flag.Bool("accept_der_chains", false, "If true, add-chain and add-pre-chain also accept binary chains of length-prefixed DER certificates, sent with the application/vnd.tesseract.der-chain content type.")
+	streamJSONChains           = flag.Bool("stream_json_chains", false, "If true, add-chain and add-pre-chain decode JSON chains one certificate at a time as requests are read, rather than buffering whole request bodies.")
+	maxChainCerts              = flag.Int("max_chain_certs", 0, "If positive, maximum number of certificates in a submitted chain. Streamed JSON chains are rejected as soon as they exceed it.")
+	maxChainBytes              = flag.Int("max_chain_bytes", 0, "If positive, maximum total size in bytes of the DER certificates of a submitted chain. Streamed JSON and binary chains are rejected as soon as they exceed it.")
+	maxRequestBytes            = flag.Int64("max_request_bytes", 0, "If positive, maximum size in bytes of add-chain and add-pre-chain request bodies. Larger requests fail with 413 Request Entity Too Large.")
+	submissionRate             = flag.Float64("submission_rate", 0, "If positive, maximum sustained number of add-chain and add-pre-chain requests per second. Requests beyond it fail with 429 Too Many Requests.")
+	submissionBurst            = flag.Int("submission_burst", 0, "Number of add-chain and add-pre-chain requests which can be served at once above --submission_rate. Defaults to --submission_rate rounded up.")
+	shedLoadThreshold          = flag.Int("shed_load_threshold", 0, "If positive, number of concurrent add-chain and add-pre-chain requests above which the log sheds load: requests whose chain is larger than --shed_chain_bytes fail with 503 Service Unavailable, while smaller ones keep being served.")
+	shedChainBytes             = flag.Int("shed_chain_bytes", 0, "Total size in bytes of the DER certificates of a chain above which requests are shed when --shed_load_threshold is exceeded. If 0, all requests above the threshold are shed.")
+	validationTimeout          = flag.Duration("validation_timeout", 0, "If positive, maximum time spent validating the chain of an add-chain or add-pre-chain request. Requests whose chain takes longer to validate fail with 503 Service Unavailable.")
+	maxValidations             = flag.Int("max_validations", 0, "Maximum number of chains being validated at once when --validation_timeout is set, including chains which timed out and are still being validated in the background. Requests beyond it fail with 503 Service Unavailable. Defaults to 1024 if 0.")
+	getRootsMaxAge             = flag.Duration("get_roots_max_age", 0, "If positive, get-roots responses can be cached for this long, and carry corresponding Cache-Control and Expires headers.")
+	getRootsIntermediates      = flag.Bool("get_roots_intermediates", false, "If true, get-roots responses also list each root with the intermediates chaining to it that this instance has stored, to help clients build full paths.")
+	rejectedSubmissionSamples  = flag.Int("rejected_submission_samples", 0, "If positive, number of recently rejected submissions kept in memory and served on the /tesseract/v1/admin/get-rejected-submissions admin endpoint. Requires --admin_http_endpoint.")
+	submissionCacheTTL         = flag.Duration("submission_cache_ttl", 0, "If positive, SCTs issued for add-chain and add-pre-chain submissions are kept in memory for this long, and returned to submissions of the same chain without validating and sequencing it again.")
 	origin                     = flag.String("origin", "", "Origin of the log, for checkpoints and the monitoring prefix.")
 	bucket                     = flag.String("bucket", "", "Name of the bucket to store the log in.")
 	dbName                     = flag.String("db_name", "", "AuroraDB name")
@@ -62,11 +99,49 @@ var (
 	dbMaxConns                 = flag.Int("db_max_conns", 0, "Maximum connections to the database, defaults to 0, i.e unlimited")
 	dbMaxIdle                  = flag.Int("db_max_idle_conns", 2, "Maximum idle database connections in the connection pool, defaults to 2")
 	inMemoryAntispamCacheSize  = flag.Uint("inmemory_antispam_cache_size", 256<<10, "Maximum number of entries to keep in the in-memory antispam cache.")
+	antispamFailOpen           = flag.Bool("antispam_fail_open", false, "If true, sequence submissions without deduplication when the antispam database fails, rather than rejecting them.")
+	issuerWriteConcurrency     = flag.Int("issuer_write_concurrency", 1, "Maximum number of issuer certificates of a chain written to storage in parallel.")
+	verifyTreeOnStartup        = flag.Bool("verify_tree_on_startup", false, "If true, check on startup that the latest checkpoint matches the stored tiles, and refuse to start if it doesn't.")
+	storageInitAttempts        = flag.Int("storage_init_attempts", 1, "Number of attempts to initialize the storage backend on startup before giving up, for backends which may not be ready yet.")
+	storageInitBackoff         = flag.Duration("storage_init_backoff", time.Second, "Wait after the first failed attempt to initialize the storage backend. It doubles after each following failure, up to a minute.")
+	coalesceMaxAge             = flag.Duration("coalesce_max_age", 0, "If positive, entries are buffered for up to this long before being handed to Tessera together, to be sequenced in the same append cycle.")
+	coalesceMaxSize            = flag.Int("coalesce_max_size", 0, "If positive, buffered entries are handed to Tessera as soon as there are this many of them, without waiting for --coalesce_max_age.")
+	maxPendingDedupReads       = flag.Int("max_pending_dedup_reads", 0, "If positive, the maximum number of duplicate submissions waiting for their original entry to be integrated and read back. Further duplicates are pushed back with 503s until some are resolved.")
+	antispamPushbackThreshold  = flag.Uint("antispam_pushback_threshold", 0, "Number of entries the antispam index can lag behind the log, waiting to be written, before submissions are pushed back with 503s. 0 uses the antispam storage's default.")
+	dedupCollisionThreshold    = flag.Int("dedup_collision_alert_threshold", 0, "Number of dedup collisions, submissions deduplicated to a different stored entry, after which each further one is logged as an alert. Collisions are always rejected and counted.")
 	rootsPemFile               = flag.String("roots_pem_file", "", "Path to the file containing root certificates that are acceptable to the log. The certs are served through get-roots endpoint.")
+	rootsURL                   = flag.String("roots_url", "", "If set, HTTPS URL serving PEM root certificates, e.g. a CCADB export. They replace the roots of --roots_pem_file once fetched, and are fetched again every --roots_refresh_interval.")
+	rootsRefreshInterval       = flag.Duration("roots_refresh_interval", time.Hour, "How often roots are fetched from --roots_url.")
+	maxRoots                   = flag.Int("max_roots", 0, "Maximum number of roots. Startup fails if --roots_pem_file contains more, and roots fetched from --roots_url are ignored if there are more. 0 means no limit.")
+	notAfterGrace              = flag.Duration("not_after_grace", 0, "Grace period added to --not_after_limit, so that certificates with a notAfter date at or shortly after the limit are still accepted. Requires --not_after_limit.")
 	rejectExpired              = flag.Bool("reject_expired", false, "If true then the certificate validity period will be checked against the current time during the validation of submissions. This will cause expired certificates to be rejected.")
-	rejectUnexpired            = flag.Bool("reject_unexpired", false, "If true then TesseraCT rejects certificates that are either currently valid or not yet valid.")
+	rejectExpiredChain         = flag.Bool("reject_expired_chain", false, "If true, --reject_expired also applies to intermediates and roots, and not only to leaf certificates.")
+	rejectUnexpired            = flag.Bool("reject_unexpired", false, "If true then TesseraCT rejects certificates that are either currently valid or not yet valid, and only accepts expired ones. --not_after_start and --not_after_limit still apply.")
 	extKeyUsages               = flag.String("ext_key_usages", "", "If set, will restrict the set of such usages that the server will accept. By default all are accepted. The values specified must be ones known to the x509 package.")
+	rejectCALeaves             = flag.Bool("reject_ca_leaves", false, "If true then TesseraCT rejects leaf certificates whose basicConstraints extension marks them as a CA, such as intermediates. Logs monitoring CAs can leave this unset to accept them.")
+	allowTrustedRootLeaves     = flag.Bool("allow_trusted_root_leaves", false, "If true then trusted roots submitted as leaves are accepted even when --reject_ca_leaves is set.")
+	maxSANs                    = flag.Int("max_sans", 0, "Maximum number of SubjectAltName entries a certificate can have. 0 means no limit.")
+	maxSANLength               = flag.Int("max_san_length", 0, "Maximum length in bytes of each SubjectAltName entry of a certificate. 0 means no limit.")
+	rejectDuplicateSANs        = flag.Bool("reject_duplicate_sans", false, "If true, reject certificates which have the same SubjectAltName entry more than once. DNS names are compared case-insensitively.")
+	allowedSANTypes            = flag.String("allowed_san_types", "", "If set, comma separated list of the only SubjectAltName types accepted in leaf certificates, with their RFC 5280 names, e.g. 'dNSName,iPAddress'. By default all are accepted, including otherName entries in critical SubjectAltName extensions.")
+	maxPrecertAge              = flag.Duration("max_precert_age", 0, "If positive, precertificates whose NotBefore date is older than this are rejected.")
+	minSerialNumberBits        = flag.Int("min_serial_number_bits", 0, "If positive, leaf certificates whose serial number is shorter than this many bits are rejected.")
+	rejectNonRandomSerials     = flag.Bool("reject_non_random_serials", false, "If true then TesseraCT rejects leaf certificates whose serial number does not look random: non-positive, or with a run of more than 4 identical bytes.")
+	requirePositiveSerials     = flag.Bool("require_positive_serials", false, "If true then TesseraCT rejects leaf certificates whose serial number is zero or negative.")
+	rejectPoisonLookalikes     = flag.Bool("reject_poison_lookalikes", false, "If true then TesseraCT rejects leaf certificates with an extension whose OID is in the RFC 6962 arc, 1.3.6.1.4.1.11129.2.4, but is not one RFC 6962 defines for certificates, such as a mistyped CT poison extension OID.")
+	poisonOID                  = flag.String("poison_oid", "", "If set, OID of the critical extension marking precertificates, instead of the CT poison extension 1.3.6.1.4.1.11129.2.4.3, for experimental variants of the static-ct-api. Certificates carrying the CT poison extension are then rejected.")
+	ignoreExtraCerts           = flag.Bool("ignore_extra_certs", false, "If true then TesseraCT accepts submitted chains containing certificates which are not part of the path to a trusted root, and leaves them out of the logged chain. By default, such chains are rejected.")
+	requireEmbeddedSCTs        = flag.Bool("require_embedded_scts", false, "If true then TesseraCT rejects final certificates submitted to add-chain without a well-formed embedded SCT list.")
+	rejectPrecertsWithSCTs     = flag.Bool("reject_precerts_with_scts", false, "If true then TesseraCT rejects precertificates submitted to add-pre-chain which carry an embedded SCT list extension.")
+	requireRevocationInfo      = flag.Bool("require_revocation_info", false, "If true then TesseraCT rejects leaf certificates which have neither a CRL distribution point nor an OCSP responder.")
+	requiredCPSURIPrefixes     = flag.String("required_cps_uri_prefixes", "", "Comma separated list of http or https URI prefixes. If set, TesseraCT rejects leaf certificates without a CPS URI policy qualifier, or with a CPS URI which is malformed or doesn't start with one of them.")
+	requireLeafSKI             = flag.Bool("require_leaf_ski", false, "If true then TesseraCT rejects leaf certificates without a SubjectKeyIdentifier extension.")
+	requireChainSKI            = flag.Bool("require_chain_ski", false, "If true then TesseraCT rejects chains whose leaf or intermediate certificates lack a SubjectKeyIdentifier extension. Trusted roots are not checked.")
 	rejectExtensions           = flag.String("reject_extension", "", "A list of X.509 extension OIDs, in dotted string form (e.g. '2.3.4.5') which, if present, should cause submissions to be rejected.")
+	deniedSPKIHashes           = flag.String("denied_spki_hashes", "", "A list of hex encoded SHA-256 hashes of SubjectPublicKeyInfos. Certificates whose public key matches one of them are rejected.")
+	revokedIntermediatesFile   = flag.String("revoked_intermediates_pem_file", "", "Path to the file containing revoked intermediate certificates. Chains going through one of them are rejected.")
+	revokedIntermediates       = flag.String("revoked_intermediates", "", "A list of hex encoded SHA-256 fingerprints of revoked intermediate certificates. Chains going through one of them are rejected.")
+	sha1Roots                  = flag.String("sha1_roots", "", "A list of hex encoded SHA-256 fingerprints of trusted roots. Certificates signed with SHA-1 are rejected, unless they chain to one of them.")
 	signerPublicKeySecretName  = flag.String("signer_public_key_secret_name", "", "Public key secret name for checkpoints and SCTs signer")
 	signerPrivateKeySecretName = flag.String("signer_private_key_secret_name", "", "Private key secret name for checkpoints and SCTs signer")
 )
@@ -75,6 +150,9 @@ var (
 func main() {
 	klog.InitFlags(nil)
 	flag.Parse()
+	if err := logging.SetFormat(*logFormat, os.Stderr); err != nil {
+		klog.Exitf("Invalid --log_format: %v", err)
+	}
 	ctx := context.Background()
 
 	signer, err := NewSecretsManagerSigner(ctx, *signerPublicKeySecretName, *signerPrivateKeySecretName)
@@ -83,16 +161,88 @@ func main() {
 	}
 
 	chainValidationConfig := tesseract.ChainValidationConfig{
-		RootsPEMFile:     *rootsPemFile,
-		RejectExpired:    *rejectExpired,
-		RejectUnexpired:  *rejectUnexpired,
-		ExtKeyUsages:     *extKeyUsages,
-		RejectExtensions: *rejectExtensions,
-		NotAfterStart:    notAfterStart.t,
-		NotAfterLimit:    notAfterLimit.t,
+		RootsPEMFile:                *rootsPemFile,
+		RootsURL:                    *rootsURL,
+		RootsRefreshInterval:        *rootsRefreshInterval,
+		MaxRoots:                    *maxRoots,
+		RejectExpired:               *rejectExpired,
+		RejectExpiredChain:          *rejectExpiredChain,
+		RejectUnexpired:             *rejectUnexpired,
+		ExtKeyUsages:                *extKeyUsages,
+		RejectExtensions:            *rejectExtensions,
+		DeniedSPKIHashes:            *deniedSPKIHashes,
+		RevokedIntermediatesPEMFile: *revokedIntermediatesFile,
+		RevokedIntermediates:        *revokedIntermediates,
+		SHA1Roots:                   *sha1Roots,
+		NotAfterStart:               notAfterStart.t,
+		NotAfterLimit:               notAfterLimit.t,
+		NotAfterGrace:               *notAfterGrace,
+		NotBeforeCutoff:             notBeforeCutoff.t,
+		RejectCALeaves:              *rejectCALeaves,
+		AllowTrustedRootLeaves:      *allowTrustedRootLeaves,
+		MaxSANs:                     *maxSANs,
+		MaxSANLength:                *maxSANLength,
+		RejectDuplicateSANs:         *rejectDuplicateSANs,
+		AllowedSANTypes:             *allowedSANTypes,
+		RequireEmbeddedSCTs:         *requireEmbeddedSCTs,
+		RejectPrecertsWithSCTs:      *rejectPrecertsWithSCTs,
+		RequireRevocationInfo:       *requireRevocationInfo,
+		RequiredCPSURIPrefixes:      *requiredCPSURIPrefixes,
+		RequireLeafSKI:              *requireLeafSKI,
+		RequireChainSKI:             *requireChainSKI,
+		MaxPrecertAge:               *maxPrecertAge,
+		MinSerialNumberBits:         *minSerialNumberBits,
+		RejectNonRandomSerials:      *rejectNonRandomSerials,
+		RequirePositiveSerials:      *requirePositiveSerials,
+		RejectPoisonLookalikes:      *rejectPoisonLookalikes,
+		PoisonOID:                   *poisonOID,
+		IgnoreExtraCerts:            *ignoreExtraCerts,
+	}
+
+	if (*tlsCertFile == "") != (*tlsKeyFile == "") {
+		klog.Exitf("--tls_cert_file and --tls_key_file must be set together")
+	}
+	if *tlsClientCAFile != "" && *tlsCertFile == "" {
+		klog.Exitf("--tls_client_ca_file requires --tls_cert_file")
+	}
+	if *securityPolicyURL != "" && *securityContacts == "" {
+		klog.Exitf("--security_policy_url requires --security_contacts")
+	}
+	if *rejectedSubmissionSamples > 0 && *adminHTTPEndpoint == "" {
+		klog.Exitf("--rejected_submission_samples requires --admin_http_endpoint")
 	}
 
-	logHandler, err := tesseract.NewLogHandler(ctx, *origin, signer, chainValidationConfig, newAWSStorage, *httpDeadline, *maskInternalErrors)
+	handlerConfig := tesseract.HandlerConfig{
+		VerifyAfterWrite:          *verifyAfterWrite,
+		QueueDir:                  *queueDir,
+		QueueMaxSize:              *queueMaxSize,
+		QueuePaused:               *queuePaused,
+		AcceptDERChains:           *acceptDERChains,
+		GetRootsMaxAge:            *getRootsMaxAge,
+		GetRootsIntermediates:     *getRootsIntermediates,
+		RejectedSubmissionSamples: *rejectedSubmissionSamples,
+		SubmissionCacheTTL:        *submissionCacheTTL,
+		StreamJSONChains:          *streamJSONChains,
+		MaxChainCerts:             *maxChainCerts,
+		MaxChainBytes:             *maxChainBytes,
+		ValidationTimeout:         *validationTimeout,
+		MaxValidations:            *maxValidations,
+		NodeName:                  *nodeName,
+		DisableRequestLog:         *disableRequestLog,
+		DedupHeader:               *dedupHeader,
+		MaxNotAfterDrift:          *maxNotAfterDrift,
+		RequireSameShardAsPrecert: *requireSameShardAsPrecert,
+		RequireClientCert:         *tlsClientCAFile != "",
+		RequestLimits: tesseract.RequestLimits{
+			MaxRequestBytes:   *maxRequestBytes,
+			SubmissionRate:    *submissionRate,
+			SubmissionBurst:   *submissionBurst,
+			ShedLoadThreshold: *shedLoadThreshold,
+			ShedChainBytes:    *shedChainBytes,
+		},
+	}
+
+	logHandler, err := tesseract.NewLogHandlerWithConfig(ctx, *origin, signer, chainValidationConfig, storage.RetryCreateStorage(newAWSStorage, *storageInitAttempts, *storageInitBackoff), *httpDeadline, *maskInternalErrors, handlerConfig)
 	if err != nil {
 		klog.Exitf("Can't initialize CT HTTP Server: %v", err)
 	}
@@ -100,9 +250,37 @@ func main() {
 	klog.CopyStandardLogTo("WARNING")
 	klog.Info("**** CT HTTP Server Starting ****")
 	http.Handle("/", logHandler)
+	if *securityContacts != "" {
+		securityTxt, err := tesseract.NewSecurityTxtHandler(tesseract.SecurityTxtConfig{
+			Contact: strings.Split(*securityContacts, ","),
+			Policy:  *securityPolicyURL,
+		})
+		if err != nil {
+			klog.Exitf("Can't initialize security.txt handler: %v", err)
+		}
+		http.Handle(tesseract.SecurityTxtPath, securityTxt)
+	}
+
+	// Serve admin endpoints on their own listener, which must not be
+	// exposed publicly.
+	var adminSrv *http.Server
+	if *adminHTTPEndpoint != "" {
+		adminSrv = &http.Server{Addr: *adminHTTPEndpoint, Handler: logHandler.AdminHandler()}
+		go func() {
+			if err := adminSrv.ListenAndServe(); err != http.ErrServerClosed {
+				klog.Exitf("Admin server exited: %v", err)
+			}
+		}()
+	}
 
 	// Bring up the HTTP server and serve until we get a signal not to.
 	srv := http.Server{Addr: *httpEndpoint}
+	if *tlsClientCAFile != "" {
+		srv.TLSConfig, err = tesseract.ClientCertTLSConfig(*tlsClientCAFile)
+		if err != nil {
+			klog.Exitf("Failed to configure TLS client certificate verification: %v", err)
+		}
+	}
 	shutdownWG := new(sync.WaitGroup)
 	go awaitSignal(func() {
 		shutdownWG.Add(1)
@@ -115,10 +293,27 @@ func main() {
 		if err := srv.Shutdown(ctx); err != nil {
 			klog.Errorf("srv.Shutdown(): %v", err)
 		}
+		if adminSrv != nil {
+			if err := adminSrv.Shutdown(ctx); err != nil {
+				klog.Errorf("adminSrv.Shutdown(): %v", err)
+			}
+		}
 		klog.Info("HTTP server shutdown")
 	})
 
-	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+	ln, err := net.Listen("tcp", *httpEndpoint)
+	if err != nil {
+		klog.Exitf("Failed to listen on %q: %v", *httpEndpoint, err)
+	}
+	if *maxConnections > 0 {
+		ln = netutil.LimitListener(ln, *maxConnections)
+	}
+	if *tlsCertFile != "" {
+		err = srv.ServeTLS(ln, *tlsCertFile, *tlsKeyFile)
+	} else {
+		err = srv.Serve(ln)
+	}
+	if err != http.ErrServerClosed {
 		klog.Warningf("Server exited: %v", err)
 	}
 	// Wait will only block if the function passed to awaitSignal was called,
@@ -142,7 +337,7 @@ func awaitSignal(doneFn func()) {
 	doneFn()
 }
 
-func newAWSStorage(ctx context.Context, signer note.Signer) (*storage.CTStorage, error) {
+func newAWSStorage(ctx context.Context, signer note.Signer, additionalSigners ...note.Signer) (*storage.CTStorage, error) {
 	awsCfg := storageConfigFromFlags()
 	driver, err := taws.New(ctx, awsCfg)
 	if err != nil {
@@ -151,14 +346,17 @@ func newAWSStorage(ctx context.Context, signer note.Signer) (*storage.CTStorage,
 
 	var antispam tessera.Antispam
 	if *antispamDBName != "" {
-		antispam, err = aws_as.NewAntispam(ctx, antispamMySQLConfig().FormatDSN(), aws_as.AntispamOpts{})
+		antispam, err = aws_as.NewAntispam(ctx, antispamMySQLConfig().FormatDSN(), aws_as.AntispamOpts{PushbackThreshold: *antispamPushbackThreshold})
 		if err != nil {
-			klog.Exitf("Failed to create new AWS antispam storage: %v", err)
+			return nil, fmt.Errorf("failed to create new AWS antispam storage: %v", err)
+		}
+		if *antispamFailOpen {
+			antispam = storage.FailOpenAntispam(antispam)
 		}
 	}
 
 	appender, _, reader, err := tessera.NewAppender(ctx, driver, tessera.NewAppendOptions().
-		WithCheckpointSigner(signer).
+		WithCheckpointSigner(signer, additionalSigners...).
 		WithCTLayout().
 		WithAntispam(*inMemoryAntispamCacheSize, antispam))
 	if err != nil {
@@ -170,7 +368,15 @@ func newAWSStorage(ctx context.Context, signer note.Signer) (*storage.CTStorage,
 		return nil, fmt.Errorf("failed to initialize AWS issuer storage: %v", err)
 	}
 
-	return storage.NewCTStorage(ctx, appender, issuerStorage, reader)
+	return storage.NewCTStorage(ctx, appender, issuerStorage, reader, storage.CTStorageOpts{
+		IssuerWriteConcurrency:       *issuerWriteConcurrency,
+		VerifyTreeOnStartup:          *verifyTreeOnStartup,
+		CoalesceMaxAge:               *coalesceMaxAge,
+		CoalesceMaxSize:              *coalesceMaxSize,
+		MaxPendingDedupReads:         *maxPendingDedupReads,
+		DedupCollisionAlertThreshold: *dedupCollisionThreshold,
+		Antispam:                     antispam,
+	})
 }
 
 type timestampFlag struct {
@@ -26,12 +26,14 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/transparency-dev/tessera"
 	"github.com/transparency-dev/tessera/api/layout"
 	"github.com/transparency-dev/tessera/client"
 	"github.com/transparency-dev/tessera/storage/gcp"
 	gcp_as "github.com/transparency-dev/tessera/storage/gcp/antispam"
+	"github.com/transparency-dev/tesseract/internal/migrate"
 	"k8s.io/klog/v2"
 )
 
@@ -42,6 +44,7 @@ var (
 	sourceURL          = flag.String("source_url", "", "Base URL for the source log.")
 	numWorkers         = flag.Uint("num_workers", 30, "Number of migration worker goroutines.")
 	persistentAntispam = flag.Bool("antispam", false, "EXPERIMENTAL: Set to true to enable GCP-based persistent antispam storage.")
+	maxTimestampSkew   = flag.Duration("max_timestamp_skew", 0, "If positive, entries whose timestamp is further than this from the import time are rejected, and the migration fails. It must be longer than the age of the source log.")
 	antispamBatchSize  = flag.Uint("antispam_batch_size", 1500, "EXPERIMENTAL: maximum number of antispam rows to insert in a batch (1500 gives good performance with 300 Spanner PU and above, smaller values may be required for smaller allocs).")
 )
 
@@ -108,6 +111,9 @@ func main() {
 	}
 
 	readEntryBundle := readCTEntryBundle(*sourceURL)
+	if *maxTimestampSkew > 0 {
+		readEntryBundle = migrate.CheckTimestampSkew(readEntryBundle, *maxTimestampSkew, time.Now)
+	}
 	if err := m.Migrate(context.Background(), *numWorkers, sourceSize, sourceRoot, readEntryBundle); err != nil {
 		klog.Exitf("Migrate failed: %v", err)
 	}
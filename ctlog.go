@@ -17,17 +17,25 @@ package tesseract
 import (
 	"context"
 	"crypto"
+	"crypto/sha256"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/asn1"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/transparency-dev/tessera/ctonly"
 	"github.com/transparency-dev/tesseract/internal/ct"
+	"github.com/transparency-dev/tesseract/internal/types/rfc6962"
 	"github.com/transparency-dev/tesseract/internal/x509util"
 	"github.com/transparency-dev/tesseract/storage"
+	"k8s.io/klog/v2"
 )
 
 // ChainValidationConfig contains parameters to configure chain validation.
@@ -35,14 +43,40 @@ type ChainValidationConfig struct {
 	// RootsPEMFile is the path to the file containing root certificates that
 	// are acceptable to the log. The certs are served through get-roots
 	// endpoint.
+	// When a submitted chain doesn't include its root, and chains up to
+	// several of these roots, for instance a root re-issued with the same
+	// key, the root which comes first in the file is logged.
 	RootsPEMFile string
+	// RootsURL is an HTTPS URL serving PEM root certificates, for
+	// instance a CCADB export. When set, the roots read from RootsPEMFile
+	// are replaced in the background by the roots fetched from RootsURL,
+	// on startup and then every RootsRefreshInterval. Roots which fail to be
+	// fetched or parsed are ignored, and the current roots stay in use.
+	RootsURL string
+	// RootsRefreshInterval is how often roots are fetched from RootsURL.
+	// It is required by RootsURL.
+	RootsRefreshInterval time.Duration
+	// MaxRoots is the maximum number of roots. Startup fails if RootsPEMFile
+	// contains more roots, and roots fetched from RootsURL are ignored if
+	// there are more. 0 means no limit.
+	MaxRoots int
 	// RejectExpired controls if true then the certificate validity period will be
 	// checked against the current time during the validation of submissions.
 	// This will cause expired certificates to be rejected.
 	RejectExpired bool
+	// RejectExpiredChain controls if RejectExpired applies to every
+	// certificate of the chain, up to and including the root, rather than to
+	// the leaf only. It requires RejectExpired.
+	RejectExpiredChain bool
 	// RejectUnexpired controls if TesseraCT rejects certificates that are
-	// either currently valid or not yet valid.
-	// TODO(phboneff): evaluate whether we need to keep this one.
+	// either currently valid or not yet valid, for instance for logs archiving
+	// expired certificates. Only certificates whose NotAfter date is strictly
+	// before the time of submission are then accepted.
+	// NotAfterStart and NotAfterLimit still apply on top of this: leaving them
+	// unset accepts expired certificates of any age. Setting NotAfterStart in
+	// the future rejects all certificates until then, and a NotAfterLimit in
+	// the future has no effect until then. ConfigWarnings reports these
+	// combinations.
 	RejectUnexpired bool
 	// ExtKeyUsages lists Extended Key Usage values that newly submitted
 	// certificates MUST contain. By default all are accepted. The
@@ -60,6 +94,335 @@ type ChainValidationConfig struct {
 	// exclusive.
 	// Leaving this unset implies no upper bound to the range.
 	NotAfterLimit *time.Time
+	// NotAfterGrace is added to NotAfterLimit during validation, so that
+	// certificates expiring at, or shortly after, a temporal shard boundary
+	// are still accepted. It requires NotAfterLimit.
+	NotAfterGrace time.Duration
+	// NotBeforeCutoff defines the earliest acceptable NotBefore value,
+	// inclusive. Certificates issued before this date are rejected.
+	// Leaving this unset implies no lower bound.
+	NotBeforeCutoff *time.Time
+	// RejectCALeaves controls if TesseraCT rejects leaf certificates whose
+	// basicConstraints extension marks them as a CA, such as intermediates
+	// chaining to a trusted root. Most logs reject them; logs monitoring CAs
+	// can leave this unset to accept them.
+	RejectCALeaves bool
+	// AllowTrustedRootLeaves controls if trusted roots can still be submitted
+	// as leaves when RejectCALeaves is set.
+	AllowTrustedRootLeaves bool
+	// MaxSANs is the maximum number of SubjectAltName entries that a
+	// certificate can have. Leaving this unset, or 0, implies no limit.
+	MaxSANs int
+	// MaxSANLength is the maximum length, in bytes, of the value of each
+	// SubjectAltName entry of a certificate, such as a DNS name. Abnormally
+	// long entries usually indicate malformed certificates. Leaving this
+	// unset, or 0, implies no limit.
+	MaxSANLength int
+	// RejectDuplicateSANs controls if TesseraCT rejects certificates which
+	// have the same SubjectAltName entry more than once. DNS names are
+	// compared case-insensitively.
+	RejectDuplicateSANs bool
+	// AllowedSANTypes lists the only GeneralName choices that the
+	// SubjectAltName entries of leaf certificates can use, comma separated,
+	// with their RFC 5280 names: otherName, rfc822Name, dNSName, x400Address,
+	// directoryName, ediPartyName, uniformResourceIdentifier, iPAddress or
+	// registeredID. For instance, "dNSName,iPAddress" rejects email and URI
+	// SANs. By default all are accepted, including otherName entries with
+	// private OIDs in critical SubjectAltName extensions, which strict X.509
+	// parsers reject as unhandled: leave otherName out to reject them.
+	AllowedSANTypes string
+	// RequireEmbeddedSCTs controls if TesseraCT rejects final certificates
+	// submitted to add-chain that do not carry a well-formed embedded SCT
+	// list, for logs which only accept precertificate / final certificate pairs.
+	RequireEmbeddedSCTs bool
+	// RejectPrecertsWithSCTs controls if TesseraCT rejects precertificates
+	// submitted to add-pre-chain which carry an embedded SCT list extension,
+	// since only final certificates embed SCTs.
+	RejectPrecertsWithSCTs bool
+	// RequireRevocationInfo controls if TesseraCT rejects leaf certificates
+	// which have neither a CRL distribution point nor an OCSP responder in
+	// their Authority Information Access extension.
+	RequireRevocationInfo bool
+	// RequiredCPSURIPrefixes contains a comma separated list of http or https
+	// URI prefixes. When set, TesseraCT rejects leaf certificates without a
+	// CPS URI policy qualifier in their certificatePolicies extension, or
+	// with a CPS URI which is malformed or doesn't start with one of these
+	// prefixes, e.g. "https://pki.example.com/cps".
+	RequiredCPSURIPrefixes string
+	// RequireLeafSKI controls if TesseraCT rejects leaf certificates without
+	// a SubjectKeyIdentifier extension.
+	RequireLeafSKI bool
+	// RequireChainSKI controls if TesseraCT rejects chains whose leaf or
+	// intermediate certificates lack a SubjectKeyIdentifier extension.
+	// Trusted roots are not checked.
+	RequireChainSKI bool
+	// DeniedSPKIHashes contains a comma separated list of hex encoded SHA-256
+	// hashes of SubjectPublicKeyInfos. Certificates whose public key matches
+	// one of them are rejected, e.g. to block a compromised key.
+	DeniedSPKIHashes string
+	// RevokedIntermediatesPEMFile is the path to a file containing revoked
+	// intermediate certificates. Chains whose path to a trusted root goes
+	// through one of them are rejected.
+	RevokedIntermediatesPEMFile string
+	// RevokedIntermediates contains a comma separated list of hex encoded
+	// SHA-256 fingerprints of DER intermediate certificates, rejected like
+	// the ones in RevokedIntermediatesPEMFile.
+	RevokedIntermediates string
+	// SHA1Roots contains a comma separated list of hex encoded SHA-256
+	// fingerprints of trusted roots, typically legacy ones. Certificates
+	// signed with SHA-1 are rejected, unless they chain to one of these
+	// roots.
+	SHA1Roots string
+	// MaxPrecertAge is the maximum time elapsed since the NotBefore date of
+	// submitted precertificates. Leaving this unset, or 0, implies no limit.
+	MaxPrecertAge time.Duration
+	// MinSerialNumberBits is the minimum bit length of the serial number of
+	// leaf certificates. The Baseline Requirements mandate serial numbers
+	// with at least 64 bits of entropy from a CSPRNG: CAs use longer serials
+	// to guarantee this, so 64 is a sensible value.
+	// Leaving this unset, or 0, implies no limit.
+	MinSerialNumberBits int
+	// RejectNonRandomSerials controls if TesseraCT rejects leaf certificates
+	// whose serial number does not look random: non-positive, or with a run
+	// of more than 4 identical bytes. This is a heuristic, which does not
+	// measure entropy.
+	RejectNonRandomSerials bool
+	// RequirePositiveSerials controls if TesseraCT rejects leaf certificates
+	// whose serial number is zero or negative, which RFC 5280 forbids.
+	// Certificates with negative serial numbers fail to parse anyway, unless
+	// the x509negativeserial GODEBUG setting is enabled.
+	RequirePositiveSerials bool
+	// RejectPoisonLookalikes controls if TesseraCT rejects leaf certificates
+	// with an extension whose OID is in the RFC 6962 arc,
+	// 1.3.6.1.4.1.11129.2.4, but is not one of the OIDs RFC 6962 defines for
+	// certificates. Such OIDs are likely mistyped CT poison extension OIDs,
+	// 1.3.6.1.4.1.11129.2.4.3, which would let precertificates be logged as
+	// final certificates.
+	RejectPoisonLookalikes bool
+	// PoisonOID is the OID, as a string of dot separated numbers, of the
+	// critical extension marking precertificates, for experimental variants
+	// of the static-ct-api. Precertificates are then marked with this
+	// extension instead of the CT poison extension, which is removed from
+	// their TBSCertificate to build log entries. Certificates carrying the CT
+	// poison extension are rejected. Entries are built with this extension
+	// unless HandlerConfig.EntryBuilder is set.
+	// Leaving this unset uses the CT poison extension, 1.3.6.1.4.1.11129.2.4.3.
+	PoisonOID string
+	// IgnoreExtraCerts controls if TesseraCT accepts submitted chains which
+	// contain certificates that are not part of the path to a trusted root.
+	// These certificates are left out of the logged chain. By default, such
+	// chains are rejected, since RFC 6962 requires each certificate of a
+	// chain to certify the one preceding it.
+	IgnoreExtraCerts bool
+}
+
+// EntryBuilder builds the entry to log for a validated chain.
+type EntryBuilder = ct.EntryBuilder
+
+// SCTSigner issues SCTs for leaves added to a log.
+type SCTSigner = ct.SCTSigner
+
+// Interceptor observes validated submissions before they are sequenced, and
+// can veto them.
+type Interceptor = ct.Interceptor
+
+// InterceptorFunc adapts a function to an Interceptor.
+type InterceptorFunc = ct.InterceptorFunc
+
+// Submission describes a validated submission, as seen by an Interceptor.
+type Submission = ct.Submission
+
+// Mirror is a log mirroring this one, which sequenced chains are submitted to.
+type Mirror = ct.Mirror
+
+// HandlerConfig contains optional parameters to configure the log handlers.
+type HandlerConfig struct {
+	// EntryBuilder overrides how log entries are built from validated chains,
+	// for instance to set a custom timestamp or custom extensions.
+	// Leaving this unset uses the standard static-ct-api entry construction.
+	EntryBuilder EntryBuilder
+	// Interceptors are called in order on submissions which passed
+	// validation, before they are sequenced, for instance to let a
+	// moderation layer veto them. A submission rejected by an interceptor
+	// fails with 400 Bad Request, and later interceptors are not called.
+	Interceptors []Interceptor
+	// SCTSigner overrides how SCTs are signed, for instance to experiment
+	// with non-RFC 6962 signature schemes. The log's checkpoints are still
+	// signed with the log's signer.
+	// Leaving this unset issues RFC 6962 SCTs signed with the log's signer.
+	SCTSigner SCTSigner
+	// VerifyAfterWrite controls if newly sequenced entries are read back from
+	// storage and compared to the submission before issuing an SCT.
+	// Enabling this waits for entries to be integrated before responding.
+	VerifyAfterWrite bool
+	// QueueDir enables queue mode, for maintenance: add-chain and add-pre-chain
+	// validate submissions and durably store them in this directory, rather
+	// than sequencing them, and return 202 Accepted with a queued submission
+	// ID. A background sequencer drains the queue, and clients fetch the SCTs
+	// of their submissions from the /tesseract/v1/get-queued-sct path, under
+	// the log's prefix, once they are sequenced, or the error they were
+	// dropped with. The sequencer is paused and resumed by POSTing to the
+	// /tesseract/v1/admin/pause-queue and /tesseract/v1/admin/resume-queue
+	// paths, under the log's prefix, on the admin handler of the log, see
+	// LogHandler.AdminHandler.
+	// In multi-log mode, each log queues submissions in a subdirectory named
+	// after its path-escaped origin.
+	QueueDir string
+	// QueueMaxSize, if positive, is the maximum number of submissions
+	// waiting in QueueDir to be sequenced. Submissions beyond it fail with
+	// 503 Service Unavailable. In multi-log mode, it applies to each log.
+	QueueMaxSize int
+	// QueuePaused starts queue mode with the sequencer paused: submissions
+	// are only queued until the sequencer is resumed on the admin handler.
+	QueuePaused bool
+	// AcceptDERChains controls if add-chain and add-pre-chain also accept
+	// binary chains, sent with the "application/vnd.tesseract.der-chain"
+	// content type: a concatenation of DER certificates, each prefixed by its
+	// length as a 3-byte big-endian integer.
+	AcceptDERChains bool
+	// Mirrors are logs mirroring this one. When set, chains sequenced by this
+	// log are also submitted to the mirrors' add-chain or add-pre-chain
+	// endpoints, and responses carry the SCTs they issue, over their own
+	// entries, in an additional_scts field. Submissions fail with 502 Bad
+	// Gateway if any mirror fails.
+	Mirrors []Mirror
+	// AdditionalCheckpointSigners also sign the log's checkpoints, alongside
+	// the log's signer, which still signs SCTs. This allows rotating the
+	// checkpoint signing key with continuity: during an overlap window,
+	// checkpoints carry signatures from both the old and new keys, so that
+	// they verify under either, until all clients trust the new key.
+	// In multi-log mode, they must be set per log, with
+	// LogConfig.AdditionalCheckpointSigners.
+	AdditionalCheckpointSigners []crypto.Signer
+	// GetRootsMaxAge is how long get-roots responses can be cached for, by
+	// clients or CDNs. Leaving this unset, or 0, disables caching headers.
+	GetRootsMaxAge time.Duration
+	// GetRootsIntermediates controls if get-roots responses also list each
+	// root with the intermediates chaining to it, that this instance has
+	// stored in issuer storage, under a "roots" field. The "certificates"
+	// field is unchanged.
+	GetRootsIntermediates bool
+	// RejectedSubmissionSamples is the number of recently rejected
+	// submissions, with their hash, rejection reason and client address,
+	// kept in memory for abuse analysis. They are served as JSON on the
+	// /tesseract/v1/admin/get-rejected-submissions path, under the log's
+	// prefix, by the admin handler of the log, see LogHandler.AdminHandler,
+	// which must not be exposed publicly.
+	// Leaving this unset, or 0, disables sampling and the endpoint.
+	RejectedSubmissionSamples int
+	// MinFreeDiskSpace is the minimum number of bytes which must be
+	// available on each of DiskSpacePaths, for instance the Tessera and
+	// issuer storage directories of POSIX-backed logs. Below this threshold,
+	// add-chain and add-pre-chain return 507 Insufficient Storage, rather
+	// than risk corrupting the log by running out of disk space.
+	// Leaving this unset, or 0, disables the check.
+	MinFreeDiskSpace uint64
+	// DiskSpacePaths are paths on the filesystems which MinFreeDiskSpace
+	// applies to.
+	DiskSpacePaths []string
+	// SubmissionCacheTTL is how long the SCTs issued for submissions are
+	// kept in memory, and returned to add-chain or add-pre-chain submissions
+	// of the same chain without validating and sequencing it again. This
+	// absorbs clients retrying submissions in a tight loop. Interceptors are
+	// still called on these submissions.
+	// Leaving this unset, or 0, disables caching.
+	SubmissionCacheTTL time.Duration
+	// StreamJSONChains controls if add-chain and add-pre-chain decode JSON
+	// chains one certificate at a time as requests are read, rather than
+	// buffering whole request bodies, to save memory with large submissions.
+	StreamJSONChains bool
+	// MaxChainCerts is the maximum number of certificates in a submitted
+	// chain. Streamed JSON chains are rejected as soon as they exceed it,
+	// without reading the rest of the request.
+	// Leaving this unset, or 0, implies no limit.
+	MaxChainCerts int
+	// MaxChainBytes is the maximum total size, in bytes, of the DER
+	// certificates of a submitted chain, to bound the memory and storage used
+	// by a submission. Streamed JSON and binary chains are rejected as soon
+	// as they exceed it, without reading the rest of the request.
+	// Leaving this unset, or 0, implies no limit.
+	MaxChainBytes int
+	// ValidationTimeout is the maximum time spent validating the chain of an
+	// add-chain or add-pre-chain request, for instance a large chain,
+	// independently of the HTTP deadline. Requests whose chain takes longer
+	// to validate fail with 503 Service Unavailable.
+	// Leaving this unset, or 0, implies no limit.
+	ValidationTimeout time.Duration
+	// MaxValidations is the maximum number of chains being validated at
+	// once when ValidationTimeout is set, including chains which timed out
+	// and are still being validated in the background. Requests beyond it
+	// fail with 503 Service Unavailable.
+	// Leaving this unset, or 0, uses a default of 1024.
+	MaxValidations int
+	// NodeName identifies this node in multi-node deployments. If set, it is
+	// returned in the X-CT-Node header of all responses, to help debugging,
+	// for instance to find which node issued an SCT. It is not signed.
+	NodeName string
+	// DisableRequestLog disables the logging of requests, for instance for
+	// privacy reasons. Request metrics are still recorded.
+	DisableRequestLog bool
+	// RequireClientCert controls if add-chain and add-pre-chain require a TLS
+	// client certificate, for mutually-authenticated private logs. Other
+	// endpoints, such as get-roots, remain open. The HTTP server's TLS
+	// configuration must verify client certificates, see ClientCertTLSConfig.
+	// Requests without a verified certificate fail with 403 Forbidden.
+	RequireClientCert bool
+	// DedupHeader controls if add-chain and add-pre-chain responses carry an
+	// X-CT-Deduplicated header, set to "true" if the SCT was returned for an
+	// entry which had already been logged, and to "false" otherwise. It is
+	// not signed.
+	DedupHeader bool
+	// CORSAllowedOrigins maps entrypoint names, as listed by the
+	// get-entrypoints endpoint, such as "GetRoots" or "AddChain", to the
+	// origins allowed to make cross-origin requests to them from browsers,
+	// or to "*" to allow any origin. These entrypoints return CORS headers
+	// and answer CORS preflight requests. Entrypoints which are not listed,
+	// such as submission endpoints, send no CORS headers.
+	CORSAllowedOrigins map[string][]string
+	// MaxNotAfterDrift is the maximum difference between the NotAfter of a
+	// final certificate submitted to add-chain and of its precertificate,
+	// with the same issuer and serial number, if it was recently logged by
+	// this instance. Larger differences fail with 400 Bad Request.
+	// Leaving this unset, or 0, disables the check.
+	MaxNotAfterDrift time.Duration
+	// RequireSameShardAsPrecert rejects final certificates submitted to
+	// add-chain whose NotAfter and the NotAfter of their precertificate, if
+	// it was recently logged by this instance, are on different sides of
+	// ChainValidationConfig.NotAfterLimit. One of them then belongs to the
+	// next shard, which usually means that the certificate was routed to the
+	// wrong log. It requires NotAfterLimit.
+	RequireSameShardAsPrecert bool
+	// RequestLimits limits the size and rate of add-chain and add-pre-chain
+	// requests. Each log has its own limits. In multi-log mode, they can be
+	// overridden per log with LogConfig.RequestLimits.
+	RequestLimits RequestLimits
+}
+
+// RequestLimits contains the limits applied to add-chain and add-pre-chain
+// requests to a log.
+type RequestLimits struct {
+	// MaxRequestBytes is the maximum size of a request body. Larger requests
+	// fail with 413 Request Entity Too Large.
+	// Leaving this unset, or 0, implies no limit.
+	MaxRequestBytes int64
+	// SubmissionRate is the maximum sustained number of requests per second.
+	// Requests beyond it fail with 429 Too Many Requests.
+	// Leaving this unset, or 0, implies no limit.
+	SubmissionRate float64
+	// SubmissionBurst is the number of requests which can be served at once
+	// above SubmissionRate. If unset, or 0, it is SubmissionRate rounded up.
+	SubmissionBurst int
+	// ShedLoadThreshold is the number of concurrent requests above which the
+	// log sheds load, to keep serving lightweight requests under extreme
+	// load: requests whose chain is larger than ShedChainBytes fail with
+	// 503 Service Unavailable before being validated, and interceptors are
+	// told to skip optional checks, see ct.Submission.Overloaded.
+	// Leaving this unset, or 0, disables load shedding.
+	ShedLoadThreshold int
+	// ShedChainBytes is the total size, in bytes, of the DER certificates of
+	// a chain above which requests are shed while the log is overloaded.
+	// Leaving this unset, or 0, sheds all requests above ShedLoadThreshold.
+	ShedChainBytes int
 }
 
 // systemTimeSource implements ct.TimeSource.
@@ -72,6 +435,10 @@ func (s systemTimeSource) Now() time.Time {
 
 var sysTimeSource = systemTimeSource{}
 
+// rootsFetchTimeout is the maximum time spent fetching roots from
+// ChainValidationConfig.RootsURL.
+const rootsFetchTimeout = time.Minute
+
 // newChainValidator checks that a chain validation config is valid,
 // parses it, and loads resources to validate chains.
 func newChainValidator(cfg ChainValidationConfig) (ct.ChainValidator, error) {
@@ -79,16 +446,66 @@ func newChainValidator(cfg ChainValidationConfig) (ct.ChainValidator, error) {
 	if cfg.RootsPEMFile == "" {
 		return nil, errors.New("empty rootsPemFile")
 	}
+	if cfg.MaxRoots < 0 {
+		return nil, fmt.Errorf("negative MaxRoots: %d", cfg.MaxRoots)
+	}
 	roots := x509util.NewPEMCertPool()
 	if err := roots.AppendCertsFromPEMFile(cfg.RootsPEMFile); err != nil {
 		return nil, fmt.Errorf("failed to read trusted roots: %v", err)
 	}
+	if n := len(roots.RawCertificates()); cfg.MaxRoots > 0 && n > cfg.MaxRoots {
+		return nil, fmt.Errorf("%q contains %d roots, more than MaxRoots %d", cfg.RootsPEMFile, n, cfg.MaxRoots)
+	}
+
+	if cfg.RootsURL != "" && cfg.RootsRefreshInterval <= 0 {
+		return nil, fmt.Errorf("RootsURL requires a positive RootsRefreshInterval, got %v", cfg.RootsRefreshInterval)
+	}
+	if cfg.RootsURL != "" {
+		// Roots fetched over plain HTTP could be tampered with.
+		u, err := url.Parse(cfg.RootsURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RootsURL: %v", err)
+		}
+		if u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("RootsURL must be an https URL, got %q", cfg.RootsURL)
+		}
+	}
 
 	if cfg.RejectExpired && cfg.RejectUnexpired {
 		return nil, errors.New("configuration would reject all certificates")
 	}
 
+	if cfg.RejectExpiredChain && !cfg.RejectExpired {
+		return nil, errors.New("RejectExpiredChain requires RejectExpired")
+	}
+
+	if cfg.AllowTrustedRootLeaves && !cfg.RejectCALeaves {
+		return nil, errors.New("AllowTrustedRootLeaves requires RejectCALeaves")
+	}
+
+	if cfg.MaxSANs < 0 {
+		return nil, fmt.Errorf("negative MaxSANs: %d", cfg.MaxSANs)
+	}
+
+	if cfg.MaxSANLength < 0 {
+		return nil, fmt.Errorf("negative MaxSANLength: %d", cfg.MaxSANLength)
+	}
+
+	if cfg.MaxPrecertAge < 0 {
+		return nil, fmt.Errorf("negative MaxPrecertAge: %v", cfg.MaxPrecertAge)
+	}
+
+	if cfg.MinSerialNumberBits < 0 {
+		return nil, fmt.Errorf("negative MinSerialNumberBits: %d", cfg.MinSerialNumberBits)
+	}
+
 	// Validate the time interval.
+	if cfg.NotAfterGrace < 0 {
+		return nil, fmt.Errorf("negative NotAfterGrace: %v", cfg.NotAfterGrace)
+	}
+	if cfg.NotAfterGrace != 0 && cfg.NotAfterLimit == nil {
+		return nil, errors.New("NotAfterGrace requires NotAfterLimit")
+	}
 	if cfg.NotAfterStart != nil && cfg.NotAfterLimit != nil && (cfg.NotAfterLimit).Before(*cfg.NotAfterStart) {
 		return nil, fmt.Errorf("'Not After' limit %q before start %q", cfg.NotAfterLimit.Format(time.RFC3339), cfg.NotAfterStart.Format(time.RFC3339))
 	}
@@ -114,28 +531,347 @@ func newChainValidator(cfg ChainValidationConfig) (ct.ChainValidator, error) {
 		}
 	}
 
-	cv := ct.NewChainValidator(roots, cfg.RejectExpired, cfg.RejectUnexpired, cfg.NotAfterStart, cfg.NotAfterLimit, extKeyUsages, rejectExtIds)
+	var allowedSANTypes []int
+	// Filter which SubjectAltName types are allowed.
+	if cfg.AllowedSANTypes != "" {
+		lAllowedSANTypes := strings.Split(cfg.AllowedSANTypes, ",")
+		allowedSANTypes, err = ct.ParseSANTypes(lAllowedSANTypes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse AllowedSANTypes: %v", err)
+		}
+	}
+
+	var requiredCPSURIPrefixes []string
+	// Filter which CPS URIs are required.
+	if cfg.RequiredCPSURIPrefixes != "" {
+		lRequiredCPSURIPrefixes := strings.Split(cfg.RequiredCPSURIPrefixes, ",")
+		requiredCPSURIPrefixes, err = ct.ParseCPSURIPrefixes(lRequiredCPSURIPrefixes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RequiredCPSURIPrefixes: %v", err)
+		}
+	}
+
+	var poisonOID asn1.ObjectIdentifier
+	if cfg.PoisonOID != "" {
+		poisonOID, err = ct.ParsePoisonOID(cfg.PoisonOID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PoisonOID: %v", err)
+		}
+	}
+
+	var deniedSPKIHashes [][sha256.Size]byte
+	// Filter which public keys are rejected.
+	if cfg.DeniedSPKIHashes != "" {
+		lDeniedSPKIHashes := strings.Split(cfg.DeniedSPKIHashes, ",")
+		deniedSPKIHashes, err = ct.ParseSPKIHashes(lDeniedSPKIHashes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DeniedSPKIHashes: %v", err)
+		}
+	}
+
+	var revokedIntermediates [][sha256.Size]byte
+	// Filter which intermediates chains must not go through.
+	if cfg.RevokedIntermediatesPEMFile != "" {
+		revoked := x509util.NewPEMCertPool()
+		if err := revoked.AppendCertsFromPEMFile(cfg.RevokedIntermediatesPEMFile); err != nil {
+			return nil, fmt.Errorf("failed to read revoked intermediates: %v", err)
+		}
+		for _, cert := range revoked.RawCertificates() {
+			revokedIntermediates = append(revokedIntermediates, sha256.Sum256(cert.Raw))
+		}
+	}
+	if cfg.RevokedIntermediates != "" {
+		lRevokedIntermediates := strings.Split(cfg.RevokedIntermediates, ",")
+		fingerprints, err := ct.ParseCertFingerprints(lRevokedIntermediates)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RevokedIntermediates: %v", err)
+		}
+		revokedIntermediates = append(revokedIntermediates, fingerprints...)
+	}
+
+	var sha1Roots [][sha256.Size]byte
+	// Filter which roots chains with SHA-1 signatures may lead to.
+	if cfg.SHA1Roots != "" {
+		lSHA1Roots := strings.Split(cfg.SHA1Roots, ",")
+		sha1Roots, err = ct.ParseCertFingerprints(lSHA1Roots)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SHA1Roots: %v", err)
+		}
+	}
+
+	cv := ct.NewChainValidator(roots, ct.ChainValidatorOpts{
+		RejectExpired:          cfg.RejectExpired,
+		RejectExpiredChain:     cfg.RejectExpiredChain,
+		RejectUnexpired:        cfg.RejectUnexpired,
+		NotAfterStart:          cfg.NotAfterStart,
+		NotAfterLimit:          cfg.NotAfterLimit,
+		NotAfterGrace:          cfg.NotAfterGrace,
+		NotBeforeCutoff:        cfg.NotBeforeCutoff,
+		ExtKeyUsages:           extKeyUsages,
+		RejectExtIds:           rejectExtIds,
+		RejectCALeaves:         cfg.RejectCALeaves,
+		AllowTrustedRootLeaves: cfg.AllowTrustedRootLeaves,
+		MaxSANs:                cfg.MaxSANs,
+		MaxSANLength:           cfg.MaxSANLength,
+		RejectDuplicateSANs:    cfg.RejectDuplicateSANs,
+		AllowedSANTypes:        allowedSANTypes,
+		RequireEmbeddedSCTs:    cfg.RequireEmbeddedSCTs,
+		RejectPrecertsWithSCTs: cfg.RejectPrecertsWithSCTs,
+		RequireRevocationInfo:  cfg.RequireRevocationInfo,
+		RequiredCPSURIPrefixes: requiredCPSURIPrefixes,
+		RequireLeafSKI:         cfg.RequireLeafSKI,
+		RequireChainSKI:        cfg.RequireChainSKI,
+		DeniedSPKIHashes:       deniedSPKIHashes,
+		RevokedIntermediates:   revokedIntermediates,
+		SHA1Roots:              sha1Roots,
+		MaxPrecertAge:          cfg.MaxPrecertAge,
+		MinSerialNumberBits:    cfg.MinSerialNumberBits,
+		RejectNonRandomSerials: cfg.RejectNonRandomSerials,
+		RequirePositiveSerials: cfg.RequirePositiveSerials,
+		RejectPoisonLookalikes: cfg.RejectPoisonLookalikes,
+		PoisonOID:              poisonOID,
+		IgnoreExtraCerts:       cfg.IgnoreExtraCerts,
+	})
 	return &cv, nil
 }
 
+// ValidateConfig runs all the checks that NewLogHandler runs on cfg, without
+// creating a log, for instance to validate configs in CI pipelines. Trusted
+// roots are read from cfg.RootsPEMFile, but never fetched from cfg.RootsURL.
+func ValidateConfig(cfg ChainValidationConfig) error {
+	_, err := newChainValidator(cfg)
+	return err
+}
+
+// ConfigWarnings returns descriptions of the combinations of settings of cfg
+// which are valid, but likely not what operators intend, for instance because
+// they currently reject all certificates. NewLogHandler logs them.
+func ConfigWarnings(cfg ChainValidationConfig) []string {
+	return configWarnings(cfg, time.Now())
+}
+
+// configWarnings returns the warnings of ConfigWarnings at time now.
+func configWarnings(cfg ChainValidationConfig, now time.Time) []string {
+	var warnings []string
+	if cfg.RejectUnexpired {
+		// Accepted certificates have a NotAfter date before now.
+		if cfg.NotAfterStart != nil && !cfg.NotAfterStart.Before(now) {
+			warnings = append(warnings, fmt.Sprintf("RejectUnexpired with NotAfterStart %s in the future rejects all certificates until then", cfg.NotAfterStart.Format(time.RFC3339)))
+		}
+		if cfg.NotAfterLimit != nil {
+			if limit := cfg.NotAfterLimit.Add(cfg.NotAfterGrace); limit.After(now) {
+				warnings = append(warnings, fmt.Sprintf("RejectUnexpired with NotAfterLimit %s in the future: the limit has no effect until then, since unexpired certificates are rejected", limit.Format(time.RFC3339)))
+			}
+		}
+	}
+	return warnings
+}
+
+// ValidateChain validates chain the same way as the log handlers, without
+// logging it. chain holds DER certificates, starting with the leaf. isPrecert
+// indicates whether the chain is submitted to add-pre-chain or to add-chain.
+//
+// It returns the verified chain, from the leaf up to a trusted root, and the
+// root that it terminates at. If chain doesn't include its root, and several
+// trusted roots share its issuer's name and key, for instance a re-issued
+// root, this is the one which comes first in cfg.RootsPEMFile.
+// Roots are read from cfg.RootsPEMFile on every call, and never fetched from
+// cfg.RootsURL.
+func ValidateChain(cfg ChainValidationConfig, chain [][]byte, isPrecert bool) ([]*x509.Certificate, *x509.Certificate, error) {
+	cv, err := newChainValidator(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("newCertValidationOpts(): %v", err)
+	}
+	path, err := cv.Validate(rfc6962.AddChainRequest{Chain: chain}, isPrecert)
+	if err != nil {
+		return nil, nil, err
+	}
+	return path, path[len(path)-1], nil
+}
+
+// LogHandler serves a Tessera based CT log over HTTP.
+type LogHandler struct {
+	http.Handler
+	admin http.Handler
+	roots func() []*x509.Certificate
+	logID [sha256.Size]byte
+}
+
+// AdminHandler returns the handler of the admin endpoints of the log, such
+// as get-rejected-submissions. Their responses contain client addresses:
+// it must only be served on a private listener, never with the log handler.
+func (h *LogHandler) AdminHandler() http.Handler {
+	return h.admin
+}
+
+// Roots returns a copy of the list of roots currently accepted by the log,
+// as served by the get-roots endpoint.
+func (h *LogHandler) Roots() []*x509.Certificate {
+	return h.roots()
+}
+
+// LogID returns the RFC 6962 log ID of the log, which is the SHA-256 hash of
+// its public key SPKI.
+func (h *LogHandler) LogID() [sha256.Size]byte {
+	return h.logID
+}
+
 // NewLogHandler creates a Tessera based CT log pluged into HTTP handlers.
 // The HTTP server handlers implement https://c2sp.org/static-ct-api write
 // endpoints.
+//
+// It uses the default HandlerConfig, see NewLogHandlerWithConfig.
 func NewLogHandler(ctx context.Context, origin string, signer crypto.Signer, cfg ChainValidationConfig, cs storage.CreateStorage, httpDeadline time.Duration, maskInternalErrors bool) (http.Handler, error) {
+	h, err := NewLogHandlerWithConfig(ctx, origin, signer, cfg, cs, httpDeadline, maskInternalErrors, HandlerConfig{})
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// NewLogHandlerWithConfig is like NewLogHandler, with optional parameters
+// in hCfg. The returned LogHandler also gives access to the log's admin
+// endpoints, roots and log ID.
+func NewLogHandlerWithConfig(ctx context.Context, origin string, signer crypto.Signer, cfg ChainValidationConfig, cs storage.CreateStorage, httpDeadline time.Duration, maskInternalErrors bool, hCfg HandlerConfig) (*LogHandler, error) {
+	if hCfg.GetRootsMaxAge < 0 {
+		return nil, fmt.Errorf("negative GetRootsMaxAge: %v", hCfg.GetRootsMaxAge)
+	}
+	if hCfg.RejectedSubmissionSamples < 0 {
+		return nil, fmt.Errorf("negative RejectedSubmissionSamples: %d", hCfg.RejectedSubmissionSamples)
+	}
+	if hCfg.SubmissionCacheTTL < 0 {
+		return nil, fmt.Errorf("negative SubmissionCacheTTL: %v", hCfg.SubmissionCacheTTL)
+	}
+	if hCfg.MaxChainCerts < 0 {
+		return nil, fmt.Errorf("negative MaxChainCerts: %d", hCfg.MaxChainCerts)
+	}
+	if hCfg.MaxChainBytes < 0 {
+		return nil, fmt.Errorf("negative MaxChainBytes: %d", hCfg.MaxChainBytes)
+	}
+	if hCfg.ValidationTimeout < 0 {
+		return nil, fmt.Errorf("negative ValidationTimeout: %v", hCfg.ValidationTimeout)
+	}
+	if hCfg.MaxValidations < 0 {
+		return nil, fmt.Errorf("negative MaxValidations: %d", hCfg.MaxValidations)
+	}
+	if hCfg.MaxNotAfterDrift < 0 {
+		return nil, fmt.Errorf("negative MaxNotAfterDrift: %v", hCfg.MaxNotAfterDrift)
+	}
+	if hCfg.QueueMaxSize < 0 {
+		return nil, fmt.Errorf("negative QueueMaxSize: %d", hCfg.QueueMaxSize)
+	}
+	if hCfg.RequireSameShardAsPrecert && cfg.NotAfterLimit == nil {
+		return nil, errors.New("RequireSameShardAsPrecert requires NotAfterLimit")
+	}
+	if hCfg.RequestLimits.MaxRequestBytes < 0 {
+		return nil, fmt.Errorf("negative MaxRequestBytes: %d", hCfg.RequestLimits.MaxRequestBytes)
+	}
+	if hCfg.RequestLimits.SubmissionRate < 0 {
+		return nil, fmt.Errorf("negative SubmissionRate: %v", hCfg.RequestLimits.SubmissionRate)
+	}
+	if hCfg.RequestLimits.SubmissionBurst < 0 {
+		return nil, fmt.Errorf("negative SubmissionBurst: %d", hCfg.RequestLimits.SubmissionBurst)
+	}
+	if hCfg.RequestLimits.ShedLoadThreshold < 0 {
+		return nil, fmt.Errorf("negative ShedLoadThreshold: %d", hCfg.RequestLimits.ShedLoadThreshold)
+	}
+	if hCfg.RequestLimits.ShedChainBytes < 0 {
+		return nil, fmt.Errorf("negative ShedChainBytes: %d", hCfg.RequestLimits.ShedChainBytes)
+	}
+	if err := ct.ValidateCORSAllowedOrigins(hCfg.CORSAllowedOrigins); err != nil {
+		return nil, fmt.Errorf("invalid CORSAllowedOrigins: %v", err)
+	}
+	if hCfg.MinFreeDiskSpace > 0 && len(hCfg.DiskSpacePaths) == 0 {
+		return nil, errors.New("MinFreeDiskSpace requires DiskSpacePaths")
+	}
+	if hCfg.QueuePaused && hCfg.QueueDir == "" {
+		return nil, errors.New("QueuePaused requires QueueDir")
+	}
+	if err := ct.ValidateMirrors(hCfg.Mirrors); err != nil {
+		return nil, fmt.Errorf("invalid Mirrors: %v", err)
+	}
+	if len(hCfg.AdditionalCheckpointSigners) > 0 {
+		signers := map[string]crypto.Signer{origin: signer}
+		for i, s := range hCfg.AdditionalCheckpointSigners {
+			signers[fmt.Sprintf("additional checkpoint signer %d", i)] = s
+		}
+		if err := ct.VerifySigners(signers); err != nil {
+			return nil, fmt.Errorf("invalid AdditionalCheckpointSigners: %v", err)
+		}
+	}
 	cv, err := newChainValidator(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("newCertValidationOpts(): %v", err)
 	}
-	log, err := ct.NewLog(ctx, origin, signer, cv, cs, sysTimeSource)
+	for _, w := range ConfigWarnings(cfg) {
+		klog.Warningf("%s: %s", origin, w)
+	}
+	entryBuilder := hCfg.EntryBuilder
+	if entryBuilder == nil && cfg.PoisonOID != "" {
+		poisonOID, err := ct.ParsePoisonOID(cfg.PoisonOID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PoisonOID: %v", err)
+		}
+		entryBuilder = func(chain []*x509.Certificate, isPrecert bool, timestamp uint64) (*ctonly.Entry, error) {
+			return x509util.EntryFromChainWithPoison(chain, isPrecert, timestamp, poisonOID)
+		}
+	}
+	if cfg.RootsURL != "" {
+		cv, err = ct.NewURLRootsValidator(ctx, cv, cfg.RootsURL, cfg.RootsRefreshInterval, cfg.MaxRoots, &http.Client{Timeout: rootsFetchTimeout})
+		if err != nil {
+			return nil, fmt.Errorf("NewURLRootsValidator(): %v", err)
+		}
+	}
+	log, err := ct.NewLog(ctx, origin, signer, cv, cs, sysTimeSource, hCfg.AdditionalCheckpointSigners...)
 	if err != nil {
 		return nil, fmt.Errorf("newLog(): %v", err)
 	}
 
 	opts := &ct.HandlerOptions{
-		Deadline:           httpDeadline,
-		RequestLog:         &ct.DefaultRequestLog{},
-		MaskInternalErrors: maskInternalErrors,
-		TimeSource:         sysTimeSource,
+		Deadline:                  httpDeadline,
+		RequestLog:                &ct.DefaultRequestLog{},
+		MaskInternalErrors:        maskInternalErrors,
+		TimeSource:                sysTimeSource,
+		EntryBuilder:              entryBuilder,
+		Interceptors:              hCfg.Interceptors,
+		SCTSigner:                 hCfg.SCTSigner,
+		VerifyAfterWrite:          hCfg.VerifyAfterWrite,
+		AcceptDERChains:           hCfg.AcceptDERChains,
+		GetRootsMaxAge:            hCfg.GetRootsMaxAge,
+		GetRootsIntermediates:     hCfg.GetRootsIntermediates,
+		Mirrors:                   hCfg.Mirrors,
+		RejectedSubmissionSamples: hCfg.RejectedSubmissionSamples,
+		MinFreeDiskSpace:          hCfg.MinFreeDiskSpace,
+		DiskSpacePaths:            hCfg.DiskSpacePaths,
+		SubmissionCacheTTL:        hCfg.SubmissionCacheTTL,
+		StreamJSONChains:          hCfg.StreamJSONChains,
+		MaxChainCerts:             hCfg.MaxChainCerts,
+		MaxChainBytes:             hCfg.MaxChainBytes,
+		ValidationTimeout:         hCfg.ValidationTimeout,
+		MaxValidations:            hCfg.MaxValidations,
+		NodeName:                  hCfg.NodeName,
+		RequireClientCert:         hCfg.RequireClientCert,
+		DedupHeader:               hCfg.DedupHeader,
+		CORSAllowedOrigins:        hCfg.CORSAllowedOrigins,
+		MaxNotAfterDrift:          hCfg.MaxNotAfterDrift,
+		MaxRequestBytes:           hCfg.RequestLimits.MaxRequestBytes,
+		SubmissionRate:            hCfg.RequestLimits.SubmissionRate,
+		SubmissionBurst:           hCfg.RequestLimits.SubmissionBurst,
+		ShedLoadThreshold:         hCfg.RequestLimits.ShedLoadThreshold,
+		ShedChainBytes:            hCfg.RequestLimits.ShedChainBytes,
+	}
+	if hCfg.RequireSameShardAsPrecert {
+		opts.ShardNotAfterLimit = cfg.NotAfterLimit
+	}
+	if hCfg.DisableRequestLog {
+		opts.RequestLog = &ct.NoOpRequestLog{}
+	}
+	if hCfg.QueueDir != "" {
+		opts.SubmissionQueue, err = ct.NewSubmissionQueue(hCfg.QueueDir, hCfg.QueueMaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("NewSubmissionQueue(): %v", err)
+		}
+		opts.SubmissionQueue.SetPaused(hCfg.QueuePaused)
 	}
 
 	handlers := ct.NewPathHandlers(ctx, opts, log)
@@ -144,6 +880,117 @@ func NewLogHandler(ctx context.Context, origin string, signer crypto.Signer, cfg
 	for path, handler := range handlers {
 		mux.Handle(path, handler)
 	}
+	adminMux := http.NewServeMux()
+	for path, handler := range ct.NewAdminPathHandlers(opts, log) {
+		adminMux.Handle(path, handler)
+	}
+
+	return &LogHandler{Handler: mux, admin: adminMux, roots: log.Roots, logID: log.LogID()}, nil
+}
+
+// ClientCertTLSConfig returns an HTTP server TLS configuration which verifies
+// the client certificates presented against the CAs in clientCAsPEMFile.
+// Client certificates are optional at the TLS layer, so that
+// HandlerConfig.RequireClientCert can require them on some endpoints only.
+func ClientCertTLSConfig(clientCAsPEMFile string) (*tls.Config, error) {
+	pemCAs, err := os.ReadFile(clientCAsPEMFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CAs: %v", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(pemCAs) {
+		return nil, fmt.Errorf("failed to parse client CAs from %q", clientCAsPEMFile)
+	}
+	return &tls.Config{
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  clientCAs,
+	}, nil
+}
+
+// LogConfig contains the parameters of a log served by a multi-log handler.
+type LogConfig struct {
+	// Origin is the origin of the log. Its endpoints are served under it.
+	Origin string
+	// Signer signs both the SCTs and the checkpoints of this log, and only
+	// this log.
+	Signer crypto.Signer
+	// ChainValidationConfig configures chain validation for this log.
+	ChainValidationConfig ChainValidationConfig
+	// CreateStorage creates the storage of this log.
+	CreateStorage storage.CreateStorage
+	// RequestLimits, if set, overrides HandlerConfig.RequestLimits for this
+	// log.
+	RequestLimits *RequestLimits
+	// AdditionalCheckpointSigners also sign the checkpoints of this log, see
+	// HandlerConfig.AdditionalCheckpointSigners.
+	AdditionalCheckpointSigners []crypto.Signer
+}
+
+// MultiLogHandler serves several Tessera based CT logs over HTTP.
+type MultiLogHandler struct {
+	http.Handler
+	admin http.Handler
+}
+
+// AdminHandler returns the handler of the admin endpoints of all the logs,
+// under their respective origin. See LogHandler.AdminHandler.
+func (h *MultiLogHandler) AdminHandler() http.Handler {
+	return h.admin
+}
+
+// NewMultiLogHandler creates Tessera based CT logs for each of the logs
+// configs, and serves all of them, under their respective origin, from a
+// single HTTP handler.
+//
+// Each log must have its own origin and its own signing key. Signers are
+// checked to be valid before any log is created.
+func NewMultiLogHandler(ctx context.Context, logs []LogConfig, httpDeadline time.Duration, maskInternalErrors bool, hCfg HandlerConfig) (*MultiLogHandler, error) {
+	if len(logs) == 0 {
+		return nil, errors.New("no logs configured")
+	}
+	signers := make(map[string]crypto.Signer, len(logs))
+	prefixes := make(map[string]bool, len(logs))
+	for _, l := range logs {
+		if _, ok := signers[l.Origin]; ok || prefixes[originPrefix(l.Origin)] {
+			return nil, fmt.Errorf("duplicate log origin: %q", l.Origin)
+		}
+		signers[l.Origin] = l.Signer
+		prefixes[originPrefix(l.Origin)] = true
+	}
+	if err := ct.VerifySigners(signers); err != nil {
+		return nil, fmt.Errorf("invalid signers: %v", err)
+	}
+	if len(hCfg.AdditionalCheckpointSigners) > 0 {
+		return nil, errors.New("AdditionalCheckpointSigners must be set per log, in LogConfig")
+	}
 
-	return mux, nil
+	mux := http.NewServeMux()
+	adminMux := http.NewServeMux()
+	for _, l := range logs {
+		lCfg := hCfg
+		if l.RequestLimits != nil {
+			lCfg.RequestLimits = *l.RequestLimits
+		}
+		lCfg.AdditionalCheckpointSigners = l.AdditionalCheckpointSigners
+		if hCfg.QueueDir != "" {
+			lCfg.QueueDir = filepath.Join(hCfg.QueueDir, url.PathEscape(l.Origin))
+		}
+		h, err := NewLogHandlerWithConfig(ctx, l.Origin, l.Signer, l.ChainValidationConfig, l.CreateStorage, httpDeadline, maskInternalErrors, lCfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", l.Origin, err)
+		}
+		mux.Handle(originPrefix(l.Origin)+"/", h)
+		adminMux.Handle(originPrefix(l.Origin)+"/", h.AdminHandler())
+	}
+	return &MultiLogHandler{Handler: mux, admin: adminMux}, nil
+}
+
+// originPrefix returns the path prefix under which the log endpoints of
+// origin are served.
+func originPrefix(origin string) string {
+	prefix := strings.TrimRight(origin, "/")
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix
 }
@@ -15,11 +15,30 @@
 package tesseract
 
 import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/transparency-dev/tesseract/internal/testdata"
+	"github.com/transparency-dev/tesseract/storage"
 )
 
+// NewLogHandler must keep its original signature, for existing callers.
+var _ func(context.Context, string, crypto.Signer, ChainValidationConfig, storage.CreateStorage, time.Duration, bool) (http.Handler, error) = NewLogHandler
+
 func TestNewCertValidationOpts(t *testing.T) {
 	t100 := time.Unix(100, 0)
 	t200 := time.Unix(200, 0)
@@ -88,12 +107,100 @@ func TestNewCertValidationOpts(t *testing.T) {
 				NotAfterLimit: &t100,
 			},
 		},
+		{
+			desc:    "allow-root-leaves-without-rejecting-ca-leaves",
+			wantErr: "AllowTrustedRootLeaves requires RejectCALeaves",
+			cvCfg: ChainValidationConfig{
+				RootsPEMFile:           "./internal/testdata/fake-ca.cert",
+				AllowTrustedRootLeaves: true,
+			},
+		},
+		{
+			desc:    "negative-max-sans",
+			wantErr: "negative MaxSANs",
+			cvCfg: ChainValidationConfig{
+				RootsPEMFile: "./internal/testdata/fake-ca.cert",
+				MaxSANs:      -1,
+			},
+		},
+		{
+			desc:    "negative-max-roots",
+			wantErr: "negative MaxRoots",
+			cvCfg: ChainValidationConfig{
+				RootsPEMFile: "./internal/testdata/fake-ca.cert",
+				MaxRoots:     -1,
+			},
+		},
+		{
+			desc:    "too-many-roots",
+			wantErr: "contains 4 roots, more than MaxRoots 3",
+			cvCfg: ChainValidationConfig{
+				RootsPEMFile: "./internal/testdata/subleaf.chain",
+				MaxRoots:     3,
+			},
+		},
+		{
+			desc:    "roots-url-without-refresh-interval",
+			wantErr: "RootsURL requires a positive RootsRefreshInterval",
+			cvCfg: ChainValidationConfig{
+				RootsPEMFile: "./internal/testdata/fake-ca.cert",
+				RootsURL:     "https://example.com/roots.pem",
+			},
+		},
+		{
+			desc:    "reject-expired-chain-without-reject-expired",
+			wantErr: "RejectExpiredChain requires RejectExpired",
+			cvCfg: ChainValidationConfig{
+				RootsPEMFile:       "./internal/testdata/fake-ca.cert",
+				RejectExpiredChain: true,
+			},
+		},
+		{
+			desc:    "negative-not-after-grace",
+			wantErr: "negative NotAfterGrace",
+			cvCfg: ChainValidationConfig{
+				RootsPEMFile:  "./internal/testdata/fake-ca.cert",
+				NotAfterLimit: &t200,
+				NotAfterGrace: -time.Hour,
+			},
+		},
+		{
+			desc:    "not-after-grace-without-limit",
+			wantErr: "NotAfterGrace requires NotAfterLimit",
+			cvCfg: ChainValidationConfig{
+				RootsPEMFile:  "./internal/testdata/fake-ca.cert",
+				NotAfterGrace: time.Hour,
+			},
+		},
+		{
+			desc:    "negative-max-precert-age",
+			wantErr: "negative MaxPrecertAge",
+			cvCfg: ChainValidationConfig{
+				RootsPEMFile:  "./internal/testdata/fake-ca.cert",
+				MaxPrecertAge: -time.Hour,
+			},
+		},
+		{
+			desc:    "invalid-denied-spki-hash",
+			wantErr: "failed to parse DeniedSPKIHashes",
+			cvCfg: ChainValidationConfig{
+				RootsPEMFile:     "./internal/testdata/fake-ca.cert",
+				DeniedSPKIHashes: "not-a-hash",
+			},
+		},
 		{
 			desc: "ok",
 			cvCfg: ChainValidationConfig{
 				RootsPEMFile: "./internal/testdata/fake-ca.cert",
 			},
 		},
+		{
+			desc: "ok-max-roots",
+			cvCfg: ChainValidationConfig{
+				RootsPEMFile: "./internal/testdata/subleaf.chain",
+				MaxRoots:     4,
+			},
+		},
 		{
 			desc: "ok-ext-key-usages",
 			cvCfg: ChainValidationConfig{
@@ -145,3 +252,491 @@ func TestNewCertValidationOpts(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateConfig(t *testing.T) {
+	const roots = "./internal/testdata/fake-ca.cert"
+	t100 := time.Unix(100, 0)
+	t200 := time.Unix(200, 0)
+
+	// Each failure mode must return a distinct error, so that operators can
+	// tell what to fix.
+	for _, tc := range []struct {
+		desc    string
+		cvCfg   ChainValidationConfig
+		wantErr string
+	}{
+		{
+			desc:    "ok",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots},
+			wantErr: "",
+		},
+		{
+			desc:    "no-roots",
+			wantErr: "empty rootsPemFile",
+		},
+		{
+			desc:    "unreadable-roots",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: "./internal/testdata/bogus.cert"},
+			wantErr: "failed to read trusted roots",
+		},
+		{
+			desc:    "negative-max-roots",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, MaxRoots: -1},
+			wantErr: "negative MaxRoots",
+		},
+		{
+			desc:    "too-many-roots",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: "./internal/testdata/subleaf.chain", MaxRoots: 1},
+			wantErr: "more than MaxRoots",
+		},
+		{
+			desc:    "roots-url-without-refresh-interval",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, RootsURL: "https://example.com/roots"},
+			wantErr: "RootsURL requires a positive RootsRefreshInterval",
+		},
+		{
+			desc:    "roots-url-not-https",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, RootsURL: "http://example.com/roots", RootsRefreshInterval: time.Hour},
+			wantErr: "RootsURL must be an https URL",
+		},
+		{
+			desc:    "rejecting-all",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, RejectExpired: true, RejectUnexpired: true},
+			wantErr: "configuration would reject all certificates",
+		},
+		{
+			desc:    "reject-expired-chain-without-reject-expired",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, RejectExpiredChain: true},
+			wantErr: "RejectExpiredChain requires RejectExpired",
+		},
+		{
+			desc:    "allow-root-leaves-without-rejecting-ca-leaves",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, AllowTrustedRootLeaves: true},
+			wantErr: "AllowTrustedRootLeaves requires RejectCALeaves",
+		},
+		{
+			desc:    "negative-max-sans",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, MaxSANs: -1},
+			wantErr: "negative MaxSANs",
+		},
+		{
+			desc:    "negative-max-san-length",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, MaxSANLength: -1},
+			wantErr: "negative MaxSANLength",
+		},
+		{
+			desc:    "invalid-required-cps-uri-prefix",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, RequiredCPSURIPrefixes: "https://pki.example.com/cps,pki.example.org"},
+			wantErr: "failed to parse RequiredCPSURIPrefixes",
+		},
+		{
+			desc:    "invalid-poison-oid",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, PoisonOID: "1.3.6.1.4.1.11129.2.4.x"},
+			wantErr: "failed to parse PoisonOID",
+		},
+		{
+			desc:    "negative-max-precert-age",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, MaxPrecertAge: -time.Hour},
+			wantErr: "negative MaxPrecertAge",
+		},
+		{
+			desc:    "negative-min-serial-number-bits",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, MinSerialNumberBits: -1},
+			wantErr: "negative MinSerialNumberBits",
+		},
+		{
+			desc:    "negative-not-after-grace",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, NotAfterLimit: &t200, NotAfterGrace: -time.Hour},
+			wantErr: "negative NotAfterGrace",
+		},
+		{
+			desc:    "not-after-grace-without-limit",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, NotAfterGrace: time.Hour},
+			wantErr: "NotAfterGrace requires NotAfterLimit",
+		},
+		{
+			desc:    "limit-before-start",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, NotAfterStart: &t200, NotAfterLimit: &t100},
+			wantErr: "before start",
+		},
+		{
+			desc:    "unknown-ext-key-usage",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, ExtKeyUsages: "ServerAuth,Bogus"},
+			wantErr: "failed to parse ExtKeyUsages",
+		},
+		{
+			desc:    "invalid-reject-extension",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, RejectExtensions: "1.2.3,bogus"},
+			wantErr: "failed to parse RejectExtensions",
+		},
+		{
+			desc:    "unknown-san-type",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, AllowedSANTypes: "dNSName,bogus"},
+			wantErr: "failed to parse AllowedSANTypes",
+		},
+		{
+			desc:    "invalid-denied-spki-hash",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, DeniedSPKIHashes: "bogus"},
+			wantErr: "failed to parse DeniedSPKIHashes",
+		},
+		{
+			desc:    "missing-revoked-intermediates-file",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, RevokedIntermediatesPEMFile: "./internal/testdata/bogus.cert"},
+			wantErr: "failed to read revoked intermediates",
+		},
+		{
+			desc:    "invalid-revoked-intermediate",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, RevokedIntermediates: "bogus"},
+			wantErr: "failed to parse RevokedIntermediates",
+		},
+		{
+			desc:    "invalid-sha1-root",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: roots, SHA1Roots: "bogus"},
+			wantErr: "failed to parse SHA1Roots",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := ValidateConfig(tc.cvCfg)
+			if len(tc.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("ValidateConfig()=%v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("ValidateConfig()=%v, want err containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigWarnings(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	for _, tc := range []struct {
+		desc         string
+		cvCfg        ChainValidationConfig
+		wantWarnings []string
+	}{
+		{
+			desc:  "no-bounds",
+			cvCfg: ChainValidationConfig{RejectUnexpired: true},
+		},
+		{
+			desc:  "bounds-without-reject-unexpired",
+			cvCfg: ChainValidationConfig{NotAfterStart: &future, NotAfterLimit: &future},
+		},
+		{
+			desc:  "past-bounds",
+			cvCfg: ChainValidationConfig{RejectUnexpired: true, NotAfterStart: &past, NotAfterLimit: &past},
+		},
+		{
+			desc:         "future-start",
+			cvCfg:        ChainValidationConfig{RejectUnexpired: true, NotAfterStart: &future},
+			wantWarnings: []string{"rejects all certificates"},
+		},
+		{
+			desc:         "future-limit",
+			cvCfg:        ChainValidationConfig{RejectUnexpired: true, NotAfterStart: &past, NotAfterLimit: &future},
+			wantWarnings: []string{"NotAfterLimit"},
+		},
+		{
+			desc:         "past-limit-with-grace",
+			cvCfg:        ChainValidationConfig{RejectUnexpired: true, NotAfterLimit: &past, NotAfterGrace: 2 * time.Hour},
+			wantWarnings: []string{"NotAfterLimit"},
+		},
+		{
+			desc:         "future-bounds",
+			cvCfg:        ChainValidationConfig{RejectUnexpired: true, NotAfterStart: &future, NotAfterLimit: &future},
+			wantWarnings: []string{"rejects all certificates", "NotAfterLimit"},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := configWarnings(tc.cvCfg, now)
+			if len(got) != len(tc.wantWarnings) {
+				t.Fatalf("configWarnings()=%q, want %d warnings", got, len(tc.wantWarnings))
+			}
+			for i, want := range tc.wantWarnings {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("configWarnings()[%d]=%q, want it to contain %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateChain(t *testing.T) {
+	// derChain returns the DER certificates of pems.
+	derChain := func(pems ...string) [][]byte {
+		t.Helper()
+		var chain [][]byte
+		for _, p := range pems {
+			b, _ := pem.Decode([]byte(p))
+			if b == nil {
+				t.Fatalf("pem.Decode(%q) failed", p)
+			}
+			chain = append(chain, b.Bytes)
+		}
+		return chain
+	}
+	cert := derChain(testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM)
+	precert := derChain(testdata.PreCertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM)
+	fp := sha256.Sum256(cert[1])
+	intermediateFP := hex.EncodeToString(fp[:])
+	// rootsFile holds an unrelated root before the root of cert and precert.
+	rootsFile := filepath.Join(t.TempDir(), "roots.pem")
+	if err := os.WriteFile(rootsFile, []byte(testdata.FakeCACertPEM+"\n"+testdata.CACertPEM), 0o644); err != nil {
+		t.Fatalf("os.WriteFile()=%v", err)
+	}
+	root := derChain(testdata.CACertPEM)[0]
+
+	for _, tc := range []struct {
+		desc      string
+		cvCfg     ChainValidationConfig
+		chain     [][]byte
+		isPrecert bool
+		wantLen   int
+		wantErr   string
+	}{
+		{
+			desc:    "valid-chain",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: "./internal/testdata/test_root_ca_cert.pem"},
+			chain:   cert,
+			wantLen: 3,
+		},
+		{
+			desc:      "valid-precert-chain",
+			cvCfg:     ChainValidationConfig{RootsPEMFile: "./internal/testdata/test_root_ca_cert.pem"},
+			chain:     precert,
+			isPrecert: true,
+			wantLen:   3,
+		},
+		{
+			desc:    "several-roots",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: rootsFile},
+			chain:   cert,
+			wantLen: 3,
+		},
+		{
+			desc:    "chain-without-root",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: rootsFile},
+			chain:   cert[:2],
+			wantLen: 3,
+		},
+		{
+			desc:    "missing-intermediate",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: "./internal/testdata/test_root_ca_cert.pem"},
+			chain:   cert[:1],
+			wantErr: "signed by unknown authority",
+		},
+		{
+			desc:    "unknown-root",
+			cvCfg:   ChainValidationConfig{RootsPEMFile: "./internal/testdata/fake-ca.cert"},
+			chain:   cert,
+			wantErr: "signed by unknown authority",
+		},
+		{
+			desc: "expired",
+			cvCfg: ChainValidationConfig{
+				RootsPEMFile:  "./internal/testdata/test_root_ca_cert.pem",
+				RejectExpired: true,
+			},
+			// The leaf expired in December 2025.
+			chain:   cert,
+			wantErr: "rejecting expired certificate",
+		},
+		{
+			desc: "missing-eku",
+			cvCfg: ChainValidationConfig{
+				RootsPEMFile: "./internal/testdata/test_root_ca_cert.pem",
+				ExtKeyUsages: "CodeSigning",
+			},
+			chain:   cert,
+			wantErr: "rejecting certificate without EKU",
+		},
+		{
+			desc: "revoked-intermediate-file",
+			cvCfg: ChainValidationConfig{
+				RootsPEMFile:                "./internal/testdata/test_root_ca_cert.pem",
+				RevokedIntermediatesPEMFile: "./internal/testdata/test_intermediate_ca_cert.pem",
+			},
+			chain:   cert,
+			wantErr: "revoked intermediate",
+		},
+		{
+			desc: "revoked-intermediate-fingerprint",
+			cvCfg: ChainValidationConfig{
+				RootsPEMFile:         "./internal/testdata/test_root_ca_cert.pem",
+				RevokedIntermediates: intermediateFP,
+			},
+			chain:   cert,
+			wantErr: "revoked intermediate",
+		},
+		{
+			desc:      "precert-as-cert",
+			cvCfg:     ChainValidationConfig{RootsPEMFile: "./internal/testdata/test_root_ca_cert.pem"},
+			chain:     precert,
+			isPrecert: false,
+			wantErr:   "use add-pre-chain",
+		},
+		{
+			desc:    "invalid-config",
+			chain:   cert,
+			wantErr: "empty rootsPemFile",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, gotRoot, err := ValidateChain(tc.cvCfg, tc.chain, tc.isPrecert)
+			if len(tc.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("ValidateChain()=%v, want nil", err)
+				}
+				if len(got) != tc.wantLen {
+					t.Errorf("len(ValidateChain())=%d, want %d", len(got), tc.wantLen)
+				}
+				// All valid chains terminate at the root of the test PKI.
+				if gotRoot == nil || !bytes.Equal(gotRoot.Raw, root) {
+					t.Errorf("ValidateChain() returned a root other than %s", testdata.CACertPEM)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("ValidateChain()=%v, want err containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewMultiLogHandlerErrors(t *testing.T) {
+	newKey := func() crypto.Signer {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("ecdsa.GenerateKey()=%v", err)
+		}
+		return k
+	}
+	k1, k2 := newKey(), newKey()
+
+	for _, tc := range []struct {
+		desc    string
+		logs    []LogConfig
+		hCfg    HandlerConfig
+		wantErr string
+	}{
+		{
+			desc:    "no-logs",
+			wantErr: "no logs configured",
+		},
+		{
+			desc:    "duplicate-origin",
+			logs:    []LogConfig{{Origin: "a.example.com", Signer: k1}, {Origin: "a.example.com", Signer: k2}},
+			wantErr: "duplicate log origin",
+		},
+		{
+			desc:    "duplicate-origin-prefix",
+			logs:    []LogConfig{{Origin: "a.example.com", Signer: k1}, {Origin: "a.example.com/", Signer: k2}},
+			wantErr: "duplicate log origin",
+		},
+		{
+			desc:    "shared-key",
+			logs:    []LogConfig{{Origin: "a.example.com", Signer: k1}, {Origin: "b.example.com", Signer: k1}},
+			wantErr: "share the same signing key",
+		},
+		{
+			desc:    "missing-signer",
+			logs:    []LogConfig{{Origin: "a.example.com", Signer: k1}, {Origin: "b.example.com"}},
+			wantErr: "empty signer",
+		},
+		{
+			desc:    "negative-log-submission-rate",
+			logs:    []LogConfig{{Origin: "b.example.com", Signer: k2, RequestLimits: &RequestLimits{SubmissionRate: -1}}},
+			wantErr: "b.example.com: negative SubmissionRate",
+		},
+		{
+			desc:    "additional-checkpoint-signer-shares-log-key",
+			logs:    []LogConfig{{Origin: "b.example.com", Signer: k2, AdditionalCheckpointSigners: []crypto.Signer{k2}}},
+			wantErr: "b.example.com: invalid AdditionalCheckpointSigners",
+		},
+		{
+			desc:    "mirror-without-origin",
+			logs:    []LogConfig{{Origin: "b.example.com", Signer: k2}},
+			hCfg:    HandlerConfig{Mirrors: []Mirror{{URL: "https://mirror.example.com"}}},
+			wantErr: "b.example.com: invalid Mirrors: empty origin",
+		},
+		{
+			desc:    "duplicate-mirror",
+			logs:    []LogConfig{{Origin: "b.example.com", Signer: k2}},
+			hCfg:    HandlerConfig{Mirrors: []Mirror{{Origin: "mirror.example.com", URL: "https://mirror.example.com"}, {Origin: "mirror.example.com", URL: "https://mirror.example.com"}}},
+			wantErr: "b.example.com: invalid Mirrors: duplicate origin",
+		},
+		{
+			desc:    "mirror-with-relative-url",
+			logs:    []LogConfig{{Origin: "b.example.com", Signer: k2}},
+			hCfg:    HandlerConfig{Mirrors: []Mirror{{Origin: "mirror.example.com", URL: "mirror.example.com"}}},
+			wantErr: "b.example.com: invalid Mirrors: mirror.example.com: URL",
+		},
+		{
+			desc:    "queue-paused-without-queue-dir",
+			logs:    []LogConfig{{Origin: "b.example.com", Signer: k2}},
+			hCfg:    HandlerConfig{QueuePaused: true},
+			wantErr: "b.example.com: QueuePaused requires QueueDir",
+		},
+		{
+			desc:    "same-shard-without-not-after-limit",
+			logs:    []LogConfig{{Origin: "b.example.com", Signer: k2}},
+			hCfg:    HandlerConfig{RequireSameShardAsPrecert: true},
+			wantErr: "b.example.com: RequireSameShardAsPrecert requires NotAfterLimit",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := NewMultiLogHandler(t.Context(), tc.logs, time.Second, false, tc.hCfg)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("NewMultiLogHandler()=%v, want err containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientCertTLSConfig(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		file    string
+		wantErr string
+	}{
+		{
+			desc: "ok",
+			file: "./internal/testdata/fake-ca.cert",
+		},
+		{
+			desc:    "missing-file",
+			file:    "./internal/testdata/bogus.cert",
+			wantErr: "failed to read client CAs",
+		},
+		{
+			desc:    "not-pem",
+			file:    "./internal/testdata/fake-ca.cfg",
+			wantErr: "failed to parse client CAs",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			cfg, err := ClientCertTLSConfig(tc.file)
+			if len(tc.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("ClientCertTLSConfig()=%v, want err containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ClientCertTLSConfig()=%v, want nil", err)
+			}
+			if got, want := cfg.ClientAuth, tls.VerifyClientCertIfGiven; got != want {
+				t.Errorf("ClientAuth=%v, want %v", got, want)
+			}
+			if cfg.ClientCAs == nil {
+				t.Error("ClientCAs is nil")
+			}
+		})
+	}
+}
@@ -16,10 +16,15 @@ package ct
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/asn1"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"math/big"
+	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -27,9 +32,90 @@ import (
 	"github.com/transparency-dev/tesseract/internal/lax509"
 	"github.com/transparency-dev/tesseract/internal/types/rfc6962"
 	"github.com/transparency-dev/tesseract/internal/x509util"
+	"golang.org/x/crypto/cryptobyte"
 	"k8s.io/klog/v2"
 )
 
+var oidExtensionSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+
+var oidExtensionCertificatePolicies = asn1.ObjectIdentifier{2, 5, 29, 32}
+
+// oidPolicyQualifierCPS is the OID of CPS URI policy qualifiers, as per
+// RFC 5280 s4.2.1.4.
+var oidPolicyQualifierCPS = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 2, 1}
+
+// policyInformation is a PolicyInformation of a certificatePolicies
+// extension, as per RFC 5280 s4.2.1.4.
+type policyInformation struct {
+	Policy     asn1.ObjectIdentifier
+	Qualifiers []policyQualifierInfo `asn1:"optional"`
+}
+
+// policyQualifierInfo is a PolicyQualifierInfo, as per RFC 5280 s4.2.1.4.
+type policyQualifierInfo struct {
+	ID        asn1.ObjectIdentifier
+	Qualifier asn1.RawValue
+}
+
+// oidCTExtensionArc is the arc of the OIDs defined by RFC 6962 s3.1 and s3.3.
+var oidCTExtensionArc = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4}
+
+// poisonLookalike returns true if id is in, or below, the arc of RFC 6962
+// OIDs, but is none of those used in certificates. Such extensions are likely
+// mistyped CT poison extensions, which would make precertificates look like
+// final certificates.
+func poisonLookalike(id asn1.ObjectIdentifier) bool {
+	if len(id) < len(oidCTExtensionArc) || !slices.Equal(id[:len(oidCTExtensionArc)], oidCTExtensionArc) {
+		return false
+	}
+	return !id.Equal(rfc6962.OIDExtensionCTPoison) && !id.Equal(rfc6962.OIDExtensionCTSCTList) && !id.Equal(rfc6962.OIDExtKeyUsageCertificateTransparency)
+}
+
+// maxSerialByteRun is the longest run of identical bytes accepted by
+// nonRandomSerial. Random 20-byte serial numbers have such a run with
+// probability 16/256^4, less than 4e-9.
+const maxSerialByteRun = 4
+
+// nonRandomSerial returns true if serial does not look random: if it is not
+// positive, or has a run of more than maxSerialByteRun identical bytes, as
+// left by counters or timestamps followed by zero or constant padding.
+//
+// This is a heuristic: it does not measure the entropy of serial, which is
+// not possible from a single certificate.
+func nonRandomSerial(serial *big.Int) bool {
+	if serial.Sign() <= 0 {
+		return true
+	}
+	b := serial.Bytes()
+	run := 1
+	for i := 1; i < len(b); i++ {
+		if b[i] != b[i-1] {
+			run = 1
+			continue
+		}
+		run++
+		if run > maxSerialByteRun {
+			return true
+		}
+	}
+	return false
+}
+
+// sanDNSNameTag is the tag of dNSName GeneralNames, as per RFC 5280 s4.2.1.6.
+const sanDNSNameTag = 2
+
+// sanTypes are the names of GeneralName choices, indexed by their tag, as per
+// RFC 5280 s4.2.1.6.
+var sanTypes = []string{"otherName", "rfc822Name", "dNSName", "x400Address", "directoryName", "ediPartyName", "uniformResourceIdentifier", "iPAddress", "registeredID"}
+
+// sanTypeName returns the name of the GeneralName choice of san.
+func sanTypeName(san asn1.RawValue) string {
+	if san.Class == asn1.ClassContextSpecific && san.Tag < len(sanTypes) {
+		return sanTypes[san.Tag]
+	}
+	return fmt.Sprintf("class %d tag %d", san.Class, san.Tag)
+}
+
 var stringToKeyUsage = map[string]x509.ExtKeyUsage{
 	"Any":                        x509.ExtKeyUsageAny,
 	"ServerAuth":                 x509.ExtKeyUsageServerAuth,
@@ -85,6 +171,78 @@ func ParseOIDs(oids []string) ([]asn1.ObjectIdentifier, error) {
 	return ret, nil
 }
 
+// ParsePoisonOID parses a string of dot separated numbers into the OID of an
+// extension marking precertificates.
+func ParsePoisonOID(s string) (asn1.ObjectIdentifier, error) {
+	oids, err := ParseOIDs([]string{s})
+	if err != nil {
+		return nil, err
+	}
+	oid := oids[0]
+	if len(oid) < 2 || oid[0] > 2 || (oid[0] < 2 && oid[1] > 39) {
+		return nil, fmt.Errorf("invalid OID %q", s)
+	}
+	for _, n := range oid {
+		if n < 0 {
+			return nil, fmt.Errorf("invalid OID %q", s)
+		}
+	}
+	return oid, nil
+}
+
+// ParseSANTypes parses names of GeneralName choices, as per RFC 5280
+// s4.2.1.6, such as "dNSName" or "iPAddress", into their tags.
+func ParseSANTypes(types []string) ([]int, error) {
+	ret := make([]int, 0, len(types))
+	for _, t := range types {
+		tag := slices.Index(sanTypes, t)
+		if tag < 0 {
+			return nil, fmt.Errorf("unknown SubjectAltName type: %s", t)
+		}
+		ret = append(ret, tag)
+	}
+	return ret, nil
+}
+
+// ParseCPSURIPrefixes checks that prefixes of CPS URIs are absolute http or
+// https URIs.
+func ParseCPSURIPrefixes(prefixes []string) ([]string, error) {
+	for _, p := range prefixes {
+		if err := checkHTTPURI(p); err != nil {
+			return nil, fmt.Errorf("invalid CPS URI prefix %q: %v", p, err)
+		}
+	}
+	return prefixes, nil
+}
+
+// ParseSPKIHashes parses hex encoded SHA-256 hashes of SubjectPublicKeyInfos.
+func ParseSPKIHashes(hashes []string) ([][sha256.Size]byte, error) {
+	return parseSHA256Hashes(hashes, "SPKI hash")
+}
+
+// ParseCertFingerprints parses hex encoded SHA-256 fingerprints of DER
+// certificates.
+func ParseCertFingerprints(fingerprints []string) ([][sha256.Size]byte, error) {
+	return parseSHA256Hashes(fingerprints, "certificate fingerprint")
+}
+
+// parseSHA256Hashes parses hex encoded SHA-256 hashes. kind describes them in
+// errors.
+func parseSHA256Hashes(hashes []string, kind string) ([][sha256.Size]byte, error) {
+	ret := make([][sha256.Size]byte, 0, len(hashes))
+	for _, s := range hashes {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex %s %q: %v", kind, s, err)
+		}
+		if len(b) != sha256.Size {
+			return nil, fmt.Errorf("%s %q has %d bytes, want %d", kind, s, len(b), sha256.Size)
+		}
+		ret = append(ret, [sha256.Size]byte(b))
+	}
+	return ret, nil
+}
+
 // chainValidator contains various parameters for certificate chain validation.
 type chainValidator struct {
 	// trustedRoots is a pool of certificates that defines the roots the CT log will accept.
@@ -95,7 +253,12 @@ type chainValidator struct {
 	currentTime time.Time
 	// rejectExpired indicates that expired certificates will be rejected.
 	rejectExpired bool
+	// rejectExpiredChain indicates that rejectExpired applies to every
+	// certificate of the chain, and not only to the leaf.
+	rejectExpiredChain bool
 	// rejectUnexpired indicates that certificates that are currently valid or not yet valid will be rejected.
+	// Only certificates whose notAfter date is strictly before the current time
+	// are then accepted, within the notAfterStart and notAfterLimit bounds.
 	rejectUnexpired bool
 	// notAfterStart is the earliest notAfter date which will be accepted.
 	// nil means no lower bound on the accepted range.
@@ -104,34 +267,213 @@ type chainValidator struct {
 	// dates strictly *before* notAfterLimit will be accepted.
 	// nil means no upper bound on the accepted range.
 	notAfterLimit *time.Time
+	// notAfterGrace extends notAfterLimit: notAfter dates strictly before
+	// notAfterLimit + notAfterGrace will be accepted.
+	notAfterGrace time.Duration
+	// notBeforeCutoff is the earliest notBefore date which will be accepted.
+	// nil means no lower bound on notBefore dates.
+	notBeforeCutoff *time.Time
 	// extKeyUsages contains the list of EKUs to use during chain verification.
 	extKeyUsages []x509.ExtKeyUsage
 	// rejectExtIds contains a list of X.509 extension IDs to reject during chain verification.
 	rejectExtIds []asn1.ObjectIdentifier
+	// rejectCALeaves indicates that leaves with basicConstraints CA:TRUE will be rejected.
+	rejectCALeaves bool
+	// allowTrustedRootLeaves indicates that trusted roots submitted as leaves
+	// will be accepted, even if rejectCALeaves is set.
+	allowTrustedRootLeaves bool
+	// maxSANs is the maximum number of SubjectAltName entries a leaf can have.
+	// 0 means no limit.
+	maxSANs int
+	// maxSANLength is the maximum length, in bytes, of the value of each
+	// SubjectAltName entry of a leaf. 0 means no limit.
+	maxSANLength int
+	// rejectDuplicateSANs indicates that leaves with duplicate SubjectAltName
+	// entries will be rejected.
+	rejectDuplicateSANs bool
+	// allowedSANTypes contains the tags of the only GeneralName choices
+	// allowed in the SubjectAltName entries of leaves. nil means all are.
+	allowedSANTypes map[int]bool
+	// requireEmbeddedSCTs indicates that final certificates submitted to
+	// add-chain must carry a well-formed embedded SCT list.
+	requireEmbeddedSCTs bool
+	// rejectPrecertsWithSCTs indicates that precertificates carrying an
+	// embedded SCT list extension will be rejected.
+	rejectPrecertsWithSCTs bool
+	// requireRevocationInfo indicates that leaves without CRL distribution
+	// points or OCSP responders will be rejected.
+	requireRevocationInfo bool
+	// requiredCPSURIPrefixes indicates that leaves must have a CPS URI policy
+	// qualifier, and that all their CPS URIs must be well-formed http or
+	// https URIs starting with one of these prefixes. nil means no check.
+	requiredCPSURIPrefixes []string
+	// requireLeafSKI indicates that leaves without a SubjectKeyIdentifier
+	// extension will be rejected.
+	requireLeafSKI bool
+	// requireChainSKI indicates that chains whose leaf or intermediates
+	// lack a SubjectKeyIdentifier extension will be rejected. Trusted roots
+	// are not checked.
+	requireChainSKI bool
+	// deniedSPKIHashes contains the SHA-256 hashes of the SubjectPublicKeyInfos
+	// of leaves that will be rejected.
+	deniedSPKIHashes map[[sha256.Size]byte]bool
+	// revokedIntermediates contains the SHA-256 fingerprints of intermediates
+	// that chains must not go through.
+	revokedIntermediates map[[sha256.Size]byte]bool
+	// sha1Roots contains the SHA-256 fingerprints of the trusted roots of
+	// the only chains in which certificates may be signed with SHA-1.
+	sha1Roots map[[sha256.Size]byte]bool
+	// maxPrecertAge is the maximum time elapsed since the NotBefore date of
+	// precertificates that will be accepted. 0 means no limit.
+	maxPrecertAge time.Duration
+	// minSerialNumberBits is the minimum bit length of the serial number of
+	// leaves. 0 means no limit.
+	minSerialNumberBits int
+	// rejectNonRandomSerials indicates that leaves whose serial number does
+	// not look random will be rejected. See nonRandomSerial.
+	rejectNonRandomSerials bool
+	// requirePositiveSerials indicates that leaves whose serial number is zero
+	// or negative will be rejected.
+	requirePositiveSerials bool
+	// rejectPoisonLookalikes indicates that leaves with an extension whose
+	// OID looks like the CT poison extension OID will be rejected. See
+	// poisonLookalike.
+	rejectPoisonLookalikes bool
+	// poisonOID is the OID of the critical extension marking
+	// precertificates, for experimental variants of the static-ct-api. If
+	// empty, it is the CT poison extension OID. See poison.
+	poisonOID asn1.ObjectIdentifier
+	// ignoreExtraCerts indicates that submitted chains may contain certificates
+	// which are not part of the path to a trusted root. They are left out of
+	// the verified path. Otherwise, such chains are rejected.
+	ignoreExtraCerts bool
+	// pathFirst indicates that Validate verifies the path to a trusted root
+	// before checking the entry type of the leaf, which was the original
+	// order. It is only used to check that both orders make the same
+	// decisions. Only for testing.
+	pathFirst bool
 }
 
-func NewChainValidator(trustedRoots *x509util.PEMCertPool, rejectExpired, rejectUnexpired bool, notAfterStart, notAfterLimit *time.Time, extKeyUsages []x509.ExtKeyUsage, rejectExtIds []asn1.ObjectIdentifier) chainValidator {
+// ChainValidatorOpts holds the parameters of a chainValidator.
+// See chainValidator for the meaning of each field.
+type ChainValidatorOpts struct {
+	RejectExpired          bool
+	RejectExpiredChain     bool
+	RejectUnexpired        bool
+	NotAfterStart          *time.Time
+	NotAfterLimit          *time.Time
+	NotAfterGrace          time.Duration
+	NotBeforeCutoff        *time.Time
+	ExtKeyUsages           []x509.ExtKeyUsage
+	RejectExtIds           []asn1.ObjectIdentifier
+	RejectCALeaves         bool
+	AllowTrustedRootLeaves bool
+	MaxSANs                int
+	MaxSANLength           int
+	RejectDuplicateSANs    bool
+	AllowedSANTypes        []int
+	RequireEmbeddedSCTs    bool
+	RejectPrecertsWithSCTs bool
+	RequireRevocationInfo  bool
+	RequiredCPSURIPrefixes []string
+	RequireLeafSKI         bool
+	RequireChainSKI        bool
+	DeniedSPKIHashes       [][sha256.Size]byte
+	RevokedIntermediates   [][sha256.Size]byte
+	SHA1Roots              [][sha256.Size]byte
+	MaxPrecertAge          time.Duration
+	MinSerialNumberBits    int
+	RejectNonRandomSerials bool
+	RequirePositiveSerials bool
+	RejectPoisonLookalikes bool
+	PoisonOID              asn1.ObjectIdentifier
+	IgnoreExtraCerts       bool
+}
+
+func NewChainValidator(trustedRoots *x509util.PEMCertPool, opts ChainValidatorOpts) chainValidator {
+	var deniedSPKIHashes map[[sha256.Size]byte]bool
+	if len(opts.DeniedSPKIHashes) > 0 {
+		deniedSPKIHashes = make(map[[sha256.Size]byte]bool, len(opts.DeniedSPKIHashes))
+		for _, h := range opts.DeniedSPKIHashes {
+			deniedSPKIHashes[h] = true
+		}
+	}
+	var revokedIntermediates map[[sha256.Size]byte]bool
+	if len(opts.RevokedIntermediates) > 0 {
+		revokedIntermediates = make(map[[sha256.Size]byte]bool, len(opts.RevokedIntermediates))
+		for _, h := range opts.RevokedIntermediates {
+			revokedIntermediates[h] = true
+		}
+	}
+	var sha1Roots map[[sha256.Size]byte]bool
+	if len(opts.SHA1Roots) > 0 {
+		sha1Roots = make(map[[sha256.Size]byte]bool, len(opts.SHA1Roots))
+		for _, h := range opts.SHA1Roots {
+			sha1Roots[h] = true
+		}
+	}
+	var allowedSANTypes map[int]bool
+	if len(opts.AllowedSANTypes) > 0 {
+		allowedSANTypes = make(map[int]bool, len(opts.AllowedSANTypes))
+		for _, t := range opts.AllowedSANTypes {
+			allowedSANTypes[t] = true
+		}
+	}
 	return chainValidator{
-		trustedRoots:    trustedRoots,
-		rejectExpired:   rejectExpired,
-		rejectUnexpired: rejectUnexpired,
-		notAfterStart:   notAfterStart,
-		notAfterLimit:   notAfterLimit,
-		extKeyUsages:    extKeyUsages,
-		rejectExtIds:    rejectExtIds,
+		trustedRoots:           trustedRoots,
+		rejectExpired:          opts.RejectExpired,
+		rejectExpiredChain:     opts.RejectExpiredChain,
+		rejectUnexpired:        opts.RejectUnexpired,
+		notAfterStart:          opts.NotAfterStart,
+		notAfterLimit:          opts.NotAfterLimit,
+		notAfterGrace:          opts.NotAfterGrace,
+		notBeforeCutoff:        opts.NotBeforeCutoff,
+		extKeyUsages:           opts.ExtKeyUsages,
+		rejectExtIds:           opts.RejectExtIds,
+		rejectCALeaves:         opts.RejectCALeaves,
+		allowTrustedRootLeaves: opts.AllowTrustedRootLeaves,
+		maxSANs:                opts.MaxSANs,
+		maxSANLength:           opts.MaxSANLength,
+		rejectDuplicateSANs:    opts.RejectDuplicateSANs,
+		allowedSANTypes:        allowedSANTypes,
+		requireEmbeddedSCTs:    opts.RequireEmbeddedSCTs,
+		rejectPrecertsWithSCTs: opts.RejectPrecertsWithSCTs,
+		requireRevocationInfo:  opts.RequireRevocationInfo,
+		requiredCPSURIPrefixes: opts.RequiredCPSURIPrefixes,
+		requireLeafSKI:         opts.RequireLeafSKI,
+		requireChainSKI:        opts.RequireChainSKI,
+		deniedSPKIHashes:       deniedSPKIHashes,
+		revokedIntermediates:   revokedIntermediates,
+		sha1Roots:              sha1Roots,
+		maxPrecertAge:          opts.MaxPrecertAge,
+		minSerialNumberBits:    opts.MinSerialNumberBits,
+		rejectNonRandomSerials: opts.RejectNonRandomSerials,
+		requirePositiveSerials: opts.RequirePositiveSerials,
+		rejectPoisonLookalikes: opts.RejectPoisonLookalikes,
+		poisonOID:              opts.PoisonOID,
+		ignoreExtraCerts:       opts.IgnoreExtraCerts,
+	}
+}
+
+// poison returns the OID of the extension marking precertificates.
+func (cv chainValidator) poison() asn1.ObjectIdentifier {
+	if len(cv.poisonOID) == 0 {
+		return rfc6962.OIDExtensionCTPoison
 	}
+	return cv.poisonOID
 }
 
-// isPrecertificate tests if a certificate is a pre-certificate as defined in CT.
+// isPrecertificate tests if a certificate is a pre-certificate as defined in CT,
+// marked with the poisonOID extension.
 // An error is returned if the CT extension is present but is not ASN.1 NULL as defined
 // by the spec.
-func isPrecertificate(cert *x509.Certificate) (bool, error) {
+func isPrecertificate(cert *x509.Certificate, poisonOID asn1.ObjectIdentifier) (bool, error) {
 	if cert == nil {
 		return false, errors.New("nil certificate")
 	}
 
 	for _, ext := range cert.Extensions {
-		if rfc6962.OIDExtensionCTPoison.Equal(ext.Id) {
+		if poisonOID.Equal(ext.Id) {
 			if !ext.Critical || !bytes.Equal(asn1.NullBytes, ext.Value) {
 				return false, fmt.Errorf("CT poison ext is not critical or invalid: %v", ext)
 			}
@@ -143,56 +485,302 @@ func isPrecertificate(cert *x509.Certificate) (bool, error) {
 	return false, nil
 }
 
+// hasEmbeddedSCTList returns true if cert has an embedded SCT list extension,
+// whatever its content.
+func hasEmbeddedSCTList(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(rfc6962.OIDExtensionCTSCTList) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEmbeddedSCTList checks that cert has an embedded SCT list extension, as
+// defined in RFC 6962 s3.3, and that this list is structurally valid and non-empty.
+//
+// The SCTs themselves are not verified.
+func checkEmbeddedSCTList(cert *x509.Certificate) error {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(rfc6962.OIDExtensionCTSCTList) {
+			continue
+		}
+		if ext.Critical {
+			return errors.New("embedded SCT list extension is critical")
+		}
+		var raw []byte
+		rest, err := asn1.Unmarshal(ext.Value, &raw)
+		if err != nil {
+			return fmt.Errorf("failed to parse embedded SCT list extension: %v", err)
+		} else if len(rest) != 0 {
+			return errors.New("trailing data after embedded SCT list extension")
+		}
+		var sctList cryptobyte.String
+		s := cryptobyte.String(raw)
+		if !s.ReadUint16LengthPrefixed(&sctList) || !s.Empty() {
+			return errors.New("invalid embedded SCT list")
+		}
+		if sctList.Empty() {
+			return errors.New("empty embedded SCT list")
+		}
+		for !sctList.Empty() {
+			var sct cryptobyte.String
+			if !sctList.ReadUint16LengthPrefixed(&sct) || sct.Empty() {
+				return errors.New("invalid SCT in embedded SCT list")
+			}
+		}
+		return nil
+	}
+	return errors.New("no embedded SCT list")
+}
+
+// subjectAltNames returns the raw GeneralName entries of cert's
+// SubjectAltName extension, or nil if it doesn't have one.
+//
+// Unlike the parsed fields of x509.Certificate, this includes every name form.
+func subjectAltNames(cert *x509.Certificate) ([]asn1.RawValue, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidExtensionSubjectAltName) {
+			continue
+		}
+		var sans []asn1.RawValue
+		rest, err := asn1.Unmarshal(ext.Value, &sans)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SubjectAltName extension: %v", err)
+		} else if len(rest) != 0 {
+			return nil, errors.New("trailing data after SubjectAltName extension")
+		}
+		return sans, nil
+	}
+	return nil, nil
+}
+
+// checkDuplicateSANs returns an error if sans contains the same GeneralName
+// more than once. DNS names are compared case-insensitively.
+func checkDuplicateSANs(sans []asn1.RawValue) error {
+	seen := make(map[string]bool, len(sans))
+	for _, san := range sans {
+		k := string(san.FullBytes)
+		if san.Class == asn1.ClassContextSpecific && san.Tag == sanDNSNameTag {
+			header := san.FullBytes[:len(san.FullBytes)-len(san.Bytes)]
+			k = string(header) + strings.ToLower(string(san.Bytes))
+		}
+		if seen[k] {
+			return fmt.Errorf("rejecting certificate with duplicate SubjectAltName entry %q", san.Bytes)
+		}
+		seen[k] = true
+	}
+	return nil
+}
+
+// cpsURIs returns the CPS URI policy qualifiers of the certificatePolicies
+// extension of cert, or nil if it doesn't have one.
+func cpsURIs(cert *x509.Certificate) ([]string, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidExtensionCertificatePolicies) {
+			continue
+		}
+		var policies []policyInformation
+		rest, err := asn1.Unmarshal(ext.Value, &policies)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificatePolicies extension: %v", err)
+		} else if len(rest) != 0 {
+			return nil, errors.New("trailing data after certificatePolicies extension")
+		}
+		var uris []string
+		for _, p := range policies {
+			for _, q := range p.Qualifiers {
+				if !q.ID.Equal(oidPolicyQualifierCPS) {
+					continue
+				}
+				if q.Qualifier.Class != asn1.ClassUniversal || q.Qualifier.Tag != asn1.TagIA5String {
+					return nil, fmt.Errorf("rejecting certificate with CPS URI of policy %v which is not an IA5String", p.Policy)
+				}
+				uris = append(uris, string(q.Qualifier.Bytes))
+			}
+		}
+		return uris, nil
+	}
+	return nil, nil
+}
+
+// checkCPSURIs returns an error if cert has no CPS URI policy qualifier, or
+// if one of them is not an http or https URI starting with one of prefixes.
+func checkCPSURIs(cert *x509.Certificate, prefixes []string) error {
+	uris, err := cpsURIs(cert)
+	if err != nil {
+		return err
+	}
+	if len(uris) == 0 {
+		return errors.New("rejecting certificate without CPS URI policy qualifier")
+	}
+	for _, u := range uris {
+		if err := checkHTTPURI(u); err != nil {
+			return fmt.Errorf("rejecting certificate with malformed CPS URI %q: %v", u, err)
+		}
+		if !slices.ContainsFunc(prefixes, func(p string) bool { return strings.HasPrefix(u, p) }) {
+			return fmt.Errorf("rejecting certificate with CPS URI %q, which doesn't start with any of %q", u, prefixes)
+		}
+	}
+	return nil
+}
+
+// checkHTTPURI returns an error if u is not an absolute http or https URI.
+func checkHTTPURI(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("scheme %q is not http or https", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return errors.New("no host")
+	}
+	return nil
+}
+
+// now returns the time to validate certificates against.
+func (cv chainValidator) now() time.Time {
+	if cv.currentTime.IsZero() {
+		return time.Now()
+	}
+	return cv.currentTime
+}
+
 // validate takes the certificate chain as it was parsed from a JSON request. Ensures all
 // elements in the chain decode as X.509 certificates. Ensures that there is a valid path from the
 // end entity certificate in the chain to a trusted root cert, possibly using the intermediates
 // supplied in the chain. Then applies the RFC requirement that the path must involve all
 // the submitted chain in the order of submission.
 func (cv chainValidator) validate(rawChain [][]byte) ([]*x509.Certificate, error) {
+	chain, err := parseCerts(rawChain)
+	if err != nil {
+		return nil, err
+	}
+	if err := cv.checkLeaf(chain[0]); err != nil {
+		return nil, err
+	}
+	return cv.verifyPath(chain)
+}
+
+// parseCerts ensures that all the elements of rawChain decode as X.509
+// certificates.
+func parseCerts(rawChain [][]byte) ([]*x509.Certificate, error) {
 	if len(rawChain) == 0 {
 		return nil, errors.New("empty certificate chain")
 	}
-
-	// First make sure the certs parse as X.509
 	chain := make([]*x509.Certificate, 0, len(rawChain))
-	intermediatePool := x509util.NewPEMCertPool()
-
-	for i, certBytes := range rawChain {
+	for _, certBytes := range rawChain {
 		cert, err := x509.ParseCertificate(certBytes)
 		if err != nil {
 			return nil, fmt.Errorf("x509.ParseCertificate(): %v", err)
 		}
-
 		chain = append(chain, cert)
-
-		// All but the first cert form part of the intermediate pool
-		if i > 0 {
-			intermediatePool.AddCert(cert)
-		}
 	}
+	return chain, nil
+}
 
+// checkLeaf applies the checks which only depend on the end entity
+// certificate. They are cheaper than verifying a path to a trusted root, and
+// run first so that bad submissions are rejected early.
+func (cv chainValidator) checkLeaf(cert *x509.Certificate) error {
 	naStart := cv.notAfterStart
 	naLimit := cv.notAfterLimit
-	cert := chain[0]
 
 	// Check whether the expiry date of the cert is within the acceptable range.
 	if naStart != nil && cert.NotAfter.Before(*naStart) {
-		return nil, fmt.Errorf("certificate NotAfter (%v) < %v", cert.NotAfter, *naStart)
+		return fmt.Errorf("certificate NotAfter (%v) < %v", cert.NotAfter, *naStart)
+	}
+	if naLimit != nil {
+		if limit := naLimit.Add(cv.notAfterGrace); !cert.NotAfter.Before(limit) {
+			return fmt.Errorf("certificate NotAfter (%v) >= %v", cert.NotAfter, limit)
+		}
+	}
+
+	// Check whether the certificate was issued after the cutoff.
+	if cv.notBeforeCutoff != nil && cert.NotBefore.Before(*cv.notBeforeCutoff) {
+		return fmt.Errorf("certificate NotBefore (%v) < %v", cert.NotBefore, *cv.notBeforeCutoff)
+	}
+
+	// Check that the leaf is not a CA, unless it is a trusted root and those are allowed.
+	if cv.rejectCALeaves && cert.BasicConstraintsValid && cert.IsCA {
+		if !cv.allowTrustedRootLeaves || !cv.trustedRoots.Included(cert) {
+			return fmt.Errorf("rejecting CA certificate %q submitted as a leaf", cert.Subject)
+		}
+	}
+
+	// Check that the leaf's public key is not denied.
+	if len(cv.deniedSPKIHashes) > 0 {
+		if h := sha256.Sum256(cert.RawSubjectPublicKeyInfo); cv.deniedSPKIHashes[h] {
+			return fmt.Errorf("rejecting certificate with denied public key, SPKI hash %x", h)
+		}
 	}
-	if naLimit != nil && !cert.NotAfter.Before(*naLimit) {
-		return nil, fmt.Errorf("certificate NotAfter (%v) >= %v", cert.NotAfter, *naLimit)
+
+	// Check the SubjectAltName entries, if required.
+	if cv.maxSANs > 0 || cv.maxSANLength > 0 || cv.rejectDuplicateSANs || len(cv.allowedSANTypes) > 0 {
+		sans, err := subjectAltNames(cert)
+		if err != nil {
+			return err
+		}
+		if cv.maxSANs > 0 && len(sans) > cv.maxSANs {
+			return fmt.Errorf("rejecting certificate with %d SubjectAltName entries, more than %d", len(sans), cv.maxSANs)
+		}
+		if cv.maxSANLength > 0 {
+			for _, san := range sans {
+				if len(san.Bytes) > cv.maxSANLength {
+					return fmt.Errorf("rejecting certificate with a %d-byte %s SubjectAltName entry, longer than %d bytes", len(san.Bytes), sanTypeName(san), cv.maxSANLength)
+				}
+			}
+		}
+		if cv.rejectDuplicateSANs {
+			if err := checkDuplicateSANs(sans); err != nil {
+				return err
+			}
+		}
+		if len(cv.allowedSANTypes) > 0 {
+			for _, san := range sans {
+				if san.Class != asn1.ClassContextSpecific || !cv.allowedSANTypes[san.Tag] {
+					return fmt.Errorf("rejecting certificate with %s SubjectAltName entry, which is not allowed", sanTypeName(san))
+				}
+			}
+		}
+	}
+
+	// Check the serial number of the leaf, if required.
+	if cv.requirePositiveSerials && cert.SerialNumber.Sign() <= 0 {
+		return fmt.Errorf("rejecting certificate with non-positive serial number %v", cert.SerialNumber)
+	}
+	if cv.minSerialNumberBits > 0 && cert.SerialNumber.BitLen() < cv.minSerialNumberBits {
+		return fmt.Errorf("rejecting certificate with a %d-bit serial number, shorter than %d bits", cert.SerialNumber.BitLen(), cv.minSerialNumberBits)
+	}
+	if cv.rejectNonRandomSerials && nonRandomSerial(cert.SerialNumber) {
+		return fmt.Errorf("rejecting certificate with non-random serial number %x", cert.SerialNumber)
+	}
+
+	// Check that the leaf carries revocation information, if required.
+	if cv.requireRevocationInfo && len(cert.CRLDistributionPoints) == 0 && len(cert.OCSPServer) == 0 {
+		return errors.New("rejecting certificate without CRL distribution points or OCSP responder")
 	}
 
-	now := cv.currentTime
-	if now.IsZero() {
-		now = time.Now()
+	// Check that the leaf has a SubjectKeyIdentifier, if required.
+	if cv.requireLeafSKI && len(cert.SubjectKeyId) == 0 {
+		return errors.New("rejecting certificate without SubjectKeyIdentifier")
 	}
-	expired := now.After(cert.NotAfter)
+
+	// Check the CPS URI policy qualifiers of the leaf, if required.
+	if len(cv.requiredCPSURIPrefixes) > 0 {
+		if err := checkCPSURIs(cert, cv.requiredCPSURIPrefixes); err != nil {
+			return err
+		}
+	}
+
+	expired := cv.now().After(cert.NotAfter)
 	if cv.rejectExpired && expired {
-		return nil, errors.New("rejecting expired certificate")
+		return errors.New("rejecting expired certificate")
 	}
 	if cv.rejectUnexpired && !expired {
-		return nil, errors.New("rejecting unexpired certificate")
+		return errors.New("rejecting unexpired certificate")
 	}
 
 	// Check for unwanted extension types, if required.
@@ -206,7 +794,17 @@ func (cv chainValidator) validate(rawChain [][]byte) ([]*x509.Certificate, error
 		for idx, ext := range cert.Extensions {
 			extOid := ext.Id.String()
 			if _, ok := badIDs[extOid]; ok {
-				return nil, fmt.Errorf("rejecting certificate containing extension %v at index %d", extOid, idx)
+				return fmt.Errorf("rejecting certificate containing extension %v at index %d", extOid, idx)
+			}
+		}
+	}
+
+	// Check for extensions which look like the CT poison extension, if
+	// required. Precertificates must use its exact OID.
+	if cv.rejectPoisonLookalikes {
+		for idx, ext := range cert.Extensions {
+			if poisonLookalike(ext.Id) && !ext.Id.Equal(cv.poison()) {
+				return fmt.Errorf("rejecting certificate containing extension %v at index %d, which looks like the CT poison extension %v", ext.Id, idx, cv.poison())
 			}
 		}
 	}
@@ -226,7 +824,28 @@ func (cv chainValidator) validate(rawChain [][]byte) ([]*x509.Certificate, error
 			}
 		}
 		if !good {
-			return nil, fmt.Errorf("rejecting certificate without EKU in %v", cv.extKeyUsages)
+			return fmt.Errorf("rejecting certificate without EKU in %v", cv.extKeyUsages)
+		}
+	}
+
+	return nil
+}
+
+// verifyPath ensures that there is a valid path from the end entity certificate
+// of chain to a trusted root cert, possibly using the intermediates supplied in
+// chain. Then applies the RFC requirement that the path must involve all the
+// submitted chain in the order of submission.
+func (cv chainValidator) verifyPath(chain []*x509.Certificate) ([]*x509.Certificate, error) {
+	// All but the first cert form part of the intermediate pool
+	intermediatePool := x509util.NewPEMCertPool()
+	for _, cert := range chain[1:] {
+		intermediatePool.AddCert(cert)
+	}
+
+	// Trusted roots can only terminate a chain, never be used as an intermediate.
+	for i := 1; i < len(chain)-1; i++ {
+		if cv.trustedRoots.Included(chain[i]) {
+			return nil, fmt.Errorf("trusted root %q found at position %d, but roots may only appear at the end of the chain", chain[i].Subject, i)
 		}
 	}
 
@@ -240,8 +859,13 @@ func (cv chainValidator) validate(rawChain [][]byte) ([]*x509.Certificate, error
 		Intermediates: intermediatePool.CertPool(),
 		KeyUsages:     cv.extKeyUsages,
 	}
+	if len(cv.sha1Roots) > 0 {
+		verifyOpts.AllowSHA1Root = func(root *x509.Certificate) bool {
+			return cv.sha1Roots[sha256.Sum256(root.Raw)]
+		}
+	}
 
-	verifiedChains, err := lax509.Verify(cert, verifyOpts)
+	verifiedChains, err := lax509.Verify(chain[0], verifyOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -253,32 +877,210 @@ func (cv chainValidator) validate(rawChain [][]byte) ([]*x509.Certificate, error
 	// Verify might have found multiple paths to roots. Now we check that we have a path that
 	// uses all the certs in the order they were submitted so as to comply with RFC 6962
 	// requirements detailed in Section 3.1.
+	//
+	// If extra certs are ignored, the path may skip some of the submitted certs,
+	// but must still use the others in the order they were submitted.
+	//
+	// If the submitted chain includes its root, only paths ending with this root
+	// are equivalent to it. Otherwise, paths can end with different roots, for
+	// instance when a root has been re-issued with the same key. Pick the root
+	// which comes first in the trusted roots, so that the logged chain doesn't
+	// depend on the order in which Verify returns paths.
+	//
+	// If extra certs are ignored, paths ending with the same root can use
+	// different submitted certs, for instance cross-signed intermediates.
+	// Pick the path using the earliest submitted certs. In particular, the
+	// issuer of the leaf, from which the issuer key hash of precertificate
+	// entries is computed, is the first submitted cert that issues the leaf.
+	//
+	// Paths going through a revoked intermediate are skipped.
+	var validPath []*x509.Certificate
+	var validPathPositions []int
+	validPathRank := 0
+	var pathErr error
 	for _, verifiedChain := range verifiedChains {
-		if chainsEquivalent(chain, verifiedChain) {
-			return verifiedChain, nil
+		if !chainsEquivalent(chain, verifiedChain) && (!cv.ignoreExtraCerts || !chainContainsPath(chain, verifiedChain)) {
+			continue
+		}
+		if err := cv.checkRevokedIntermediates(verifiedChain); err != nil {
+			pathErr = err
+			continue
+		}
+		rank := cv.rootRank(verifiedChain[len(verifiedChain)-1])
+		positions := pathPositions(chain, verifiedChain)
+		if validPath == nil || rank < validPathRank || (rank == validPathRank && slices.Compare(positions, validPathPositions) < 0) {
+			validPath, validPathRank, validPathPositions = verifiedChain, rank, positions
+		}
+	}
+	if validPath == nil {
+		if pathErr != nil {
+			return nil, pathErr
+		}
+		return nil, errors.New("no RFC compliant path to root found when trying to validate chain")
+	}
+
+	if cv.rejectExpired && cv.rejectExpiredChain {
+		if err := cv.checkChainExpiry(validPath); err != nil {
+			return nil, err
+		}
+	}
+	if cv.requireChainSKI {
+		if err := checkChainSKI(validPath); err != nil {
+			return nil, err
+		}
+	}
+	return validPath, nil
+}
+
+// rootRank returns the position of root in the trusted roots, in the order in
+// which they were added.
+func (cv chainValidator) rootRank(root *x509.Certificate) int {
+	roots := cv.trustedRoots.RawCertificates()
+	for i, r := range roots {
+		if r.Equal(root) {
+			return i
 		}
 	}
+	return len(roots)
+}
 
-	return nil, errors.New("no RFC compliant path to root found when trying to validate chain")
+// checkRevokedIntermediates checks that none of the intermediates in a
+// verified chain has been revoked. The leaf and the root are not checked.
+func (cv chainValidator) checkRevokedIntermediates(verifiedChain []*x509.Certificate) error {
+	if len(cv.revokedIntermediates) == 0 {
+		return nil
+	}
+	for i := 1; i < len(verifiedChain)-1; i++ {
+		if fp := sha256.Sum256(verifiedChain[i].Raw); cv.revokedIntermediates[fp] {
+			return fmt.Errorf("rejecting chain through revoked intermediate %q at position %d, fingerprint %x", verifiedChain[i].Subject, i, fp)
+		}
+	}
+	return nil
+}
+
+// checkChainExpiry checks that none of the issuers in a verified chain has
+// expired. The leaf is checked separately.
+func (cv chainValidator) checkChainExpiry(verifiedChain []*x509.Certificate) error {
+	now := cv.now()
+	for i, cert := range verifiedChain[1:] {
+		if now.After(cert.NotAfter) {
+			return fmt.Errorf("rejecting chain with expired certificate %q at position %d", cert.Subject, i+1)
+		}
+	}
+	return nil
+}
+
+// checkChainSKI checks that the leaf and intermediates of a verified chain
+// have a SubjectKeyIdentifier extension.
+func checkChainSKI(verifiedChain []*x509.Certificate) error {
+	for i, cert := range verifiedChain[:len(verifiedChain)-1] {
+		if len(cert.SubjectKeyId) == 0 {
+			return fmt.Errorf("rejecting chain with certificate %q without SubjectKeyIdentifier at position %d", cert.Subject, i)
+		}
+	}
+	return nil
+}
+
+// checkPreIssuerLinkage checks that a precertificate signing certificate in
+// the verified path of a precertificate directly issues the precertificate,
+// and is itself issued by a CA, as required by RFC 6962 section 3.1. Path
+// verification already checks that this CA chains to a trusted root.
+func checkPreIssuerLinkage(validPath []*x509.Certificate) error {
+	for i := 2; i < len(validPath); i++ {
+		if x509util.IsPreIssuer(validPath[i]) {
+			return fmt.Errorf("precertificate signing certificate %q found at position %d, but it may only directly issue precertificates", validPath[i].Subject, i)
+		}
+	}
+	if len(validPath) < 2 || !x509util.IsPreIssuer(validPath[1]) {
+		return nil
+	}
+	if len(validPath) < 3 {
+		return fmt.Errorf("precertificate signing certificate %q is a trusted root, but it must be issued by a CA", validPath[1].Subject)
+	}
+	return nil
+}
+
+// wrongEntryTypeError is returned when a certificate is submitted to
+// add-pre-chain, or a precertificate to add-chain.
+type wrongEntryTypeError struct {
+	isPrecert bool
+}
+
+func (e wrongEntryTypeError) Error() string {
+	if e.isPrecert {
+		return "precertificate submitted to add-chain; use add-pre-chain"
+	}
+	return "certificate submitted to add-pre-chain; use add-chain"
 }
 
 // Validate is used by add-chain and add-pre-chain. It checks that the supplied
 // cert is of the correct type, chains to a trusted root and satisties time
 // constraints.
+//
+// Checks run from cheapest to most expensive, so that bad submissions are
+// rejected before building a path to a trusted root: parsing, then checks on
+// the entry type of the leaf, then other checks on the leaf, and finally path
+// verification.
 // TODO(phbnf): add tests
 // TODO(phbnf): merge with validate
 func (cv chainValidator) Validate(req rfc6962.AddChainRequest, expectingPrecert bool) ([]*x509.Certificate, error) {
 	// We already checked that the chain is not empty so can move on to validation.
-	validPath, err := cv.validate(req.Chain)
+	chain, err := parseCerts(req.Chain)
 	if err != nil {
-		// We rejected it because the cert failed checks or we could not find a path to a root etc.
+		return nil, fmt.Errorf("chain failed to validate: %s", err)
+	}
+
+	var isPrecert bool
+	if !cv.pathFirst {
+		if isPrecert, err = cv.checkEntryType(chain[0], req, expectingPrecert); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cv.checkLeaf(chain[0]); err != nil {
+		return nil, fmt.Errorf("chain failed to validate: %s", err)
+	}
+	validPath, err := cv.verifyPath(chain)
+	if err != nil {
+		// We rejected it because we could not find a path to a root etc.
 		// Lots of possible causes for errors
 		return nil, fmt.Errorf("chain failed to validate: %s", err)
 	}
 
-	isPrecert, err := isPrecertificate(validPath[0])
+	if cv.pathFirst {
+		if isPrecert, err = cv.checkEntryType(chain[0], req, expectingPrecert); err != nil {
+			return nil, err
+		}
+	}
+
+	// Precertificate signing certificates must be issued by the CA which
+	// will sign the final certificate.
+	if isPrecert {
+		if err := checkPreIssuerLinkage(validPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return validPath, nil
+}
+
+// checkEntryType checks that the type of cert, the leaf of req, matches the
+// one the handler expects, and applies the checks specific to this type. It
+// returns whether cert is a precertificate.
+func (cv chainValidator) checkEntryType(cert *x509.Certificate, req rfc6962.AddChainRequest, expectingPrecert bool) (bool, error) {
+	isPrecert, err := isPrecertificate(cert, cv.poison())
 	if err != nil {
-		return nil, fmt.Errorf("precert test failed: %s", err)
+		return false, fmt.Errorf("precert test failed: %s", err)
+	}
+	// Certificates must not carry the CT poison extension if the log marks
+	// precertificates with another one: they would be logged as final
+	// certificates.
+	if poison := cv.poison(); !poison.Equal(rfc6962.OIDExtensionCTPoison) {
+		for _, ext := range cert.Extensions {
+			if ext.Id.Equal(rfc6962.OIDExtensionCTPoison) {
+				return false, fmt.Errorf("rejecting certificate with the CT poison extension %v, precertificates must be marked with %v", rfc6962.OIDExtensionCTPoison, poison)
+			}
+		}
 	}
 
 	// The type of the leaf must match the one the handler expects
@@ -288,10 +1090,30 @@ func (cv chainValidator) Validate(req rfc6962.AddChainRequest, expectingPrecert
 		} else {
 			klog.Warningf("Precert (or cert with invalid CT ext) submitted as cert chain: %q", req.Chain)
 		}
-		return nil, fmt.Errorf("cert / precert mismatch: %T", expectingPrecert)
+		return false, wrongEntryTypeError{isPrecert: isPrecert}
 	}
 
-	return validPath, nil
+	// Precertificates must have been issued recently enough, if required.
+	if isPrecert && cv.maxPrecertAge > 0 {
+		if age := cv.now().Sub(cert.NotBefore); age > cv.maxPrecertAge {
+			return false, fmt.Errorf("rejecting precertificate issued %v ago, more than %v", age, cv.maxPrecertAge)
+		}
+	}
+
+	// Precertificates must not embed SCTs, if required: only final
+	// certificates do.
+	if isPrecert && cv.rejectPrecertsWithSCTs && hasEmbeddedSCTList(cert) {
+		return false, errors.New("rejecting precertificate with an embedded SCT list extension")
+	}
+
+	// Final certificates must embed the SCTs of their precertificate, if required.
+	if !isPrecert && cv.requireEmbeddedSCTs {
+		if err := checkEmbeddedSCTList(cert); err != nil {
+			return false, fmt.Errorf("rejecting final certificate: %v", err)
+		}
+	}
+
+	return isPrecert, nil
 }
 
 func (cv chainValidator) Roots() []*x509.Certificate {
@@ -313,3 +1135,33 @@ func chainsEquivalent(inChain []*x509.Certificate, verifiedChain []*x509.Certifi
 	}
 	return true
 }
+
+// pathPositions returns the position in inChain of each certificate of
+// verifiedChain, or len(inChain) for certificates which are not in inChain,
+// such as a root omitted from inChain.
+func pathPositions(inChain []*x509.Certificate, verifiedChain []*x509.Certificate) []int {
+	positions := make([]int, len(verifiedChain))
+	for i, cert := range verifiedChain {
+		positions[i] = slices.IndexFunc(inChain, cert.Equal)
+		if positions[i] < 0 {
+			positions[i] = len(inChain)
+		}
+	}
+	return positions
+}
+
+// chainContainsPath reports whether the certificates of verifiedChain appear
+// in inChain in the same order, starting with the leaf, possibly separated by
+// other certificates. As in chainsEquivalent, inChain may omit the root.
+func chainContainsPath(inChain []*x509.Certificate, verifiedChain []*x509.Certificate) bool {
+	if len(inChain) == 0 || !inChain[0].Equal(verifiedChain[0]) {
+		return false
+	}
+	matched := 0
+	for _, certInChain := range inChain {
+		if matched < len(verifiedChain) && certInChain.Equal(verifiedChain[matched]) {
+			matched++
+		}
+	}
+	return matched >= len(verifiedChain)-1
+}
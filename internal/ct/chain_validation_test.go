@@ -15,10 +15,20 @@
 package ct
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
+	"encoding/hex"
 	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -26,6 +36,8 @@ import (
 	"github.com/transparency-dev/tesseract/internal/testdata"
 	"github.com/transparency-dev/tesseract/internal/types/rfc6962"
 	"github.com/transparency-dev/tesseract/internal/x509util"
+	"golang.org/x/crypto/cryptobyte"
+	cbasn1 "golang.org/x/crypto/cryptobyte/asn1"
 )
 
 func TestParseExtKeyUsages(t *testing.T) {
@@ -162,6 +174,54 @@ func TestParseOIDs(t *testing.T) {
 	}
 }
 
+func TestParseSPKIHashes(t *testing.T) {
+	h := sha256.Sum256([]byte("spki"))
+	hexHash := hex.EncodeToString(h[:])
+	for _, tc := range []struct {
+		desc       string
+		hashes     []string
+		wantHashes [][sha256.Size]byte
+		wantErr    bool
+	}{
+		{
+			desc:       "empty",
+			hashes:     []string{},
+			wantHashes: [][sha256.Size]byte{},
+		},
+		{
+			desc:       "valid",
+			hashes:     []string{hexHash},
+			wantHashes: [][sha256.Size]byte{h},
+		},
+		{
+			desc:    "invalid-hex",
+			hashes:  []string{hexHash, "not-hex"},
+			wantErr: true,
+		},
+		{
+			desc:    "wrong-length",
+			hashes:  []string{hexHash[:10]},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := ParseSPKIHashes(tc.hashes)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("ParseSPKIHashes(%v) = nil, want error", tc.hashes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSPKIHashes(%v) = %v, want nil", tc.hashes, err)
+			}
+			if !slices.Equal(got, tc.wantHashes) {
+				t.Errorf("ParseSPKIHashes(%v) = %x, want %x", tc.hashes, got, tc.wantHashes)
+			}
+		})
+	}
+}
+
 func wipeExtensions(cert *x509.Certificate) *x509.Certificate {
 	cert.Extensions = cert.Extensions[:0]
 	return cert
@@ -222,7 +282,7 @@ func TestIsPrecertificate(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		gotPrecert, err := isPrecertificate(test.cert)
+		gotPrecert, err := isPrecertificate(test.cert, rfc6962.OIDExtensionCTPoison)
 		t.Run(test.desc, func(t *testing.T) {
 			if err != nil {
 				if !test.wantErr {
@@ -240,6 +300,155 @@ func TestIsPrecertificate(t *testing.T) {
 	}
 }
 
+// testCert is a certificate generated by issueTestCert, with its key.
+type testCert struct {
+	*x509.Certificate
+	key *ecdsa.PrivateKey
+}
+
+// pool returns a pool holding c.
+func (c *testCert) pool() *x509util.PEMCertPool {
+	pool := x509util.NewPEMCertPool()
+	pool.AddCert(c.Certificate)
+	return pool
+}
+
+// issueTestCert returns a certificate for a fresh key, valid from an hour ago
+// to an hour from now. If issuer is nil, the certificate is a self-signed
+// root. Otherwise, it is a leaf.example.com server certificate signed by
+// issuer. If mutate is not nil, it modifies the certificate's template before
+// it is signed.
+func issueTestCert(t *testing.T, issuer *testCert, mutate func(*x509.Certificate)) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "leaf.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if issuer == nil {
+		tmpl = &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "Test Root"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+	}
+	if mutate != nil {
+		mutate(tmpl)
+	}
+	parent, signer := tmpl, key
+	if issuer != nil {
+		parent, signer = issuer.Certificate, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), signer)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate()=%v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("x509.ParseCertificate()=%v", err)
+	}
+	return &testCert{Certificate: cert, key: key}
+}
+
+func TestRejectPoisonLookalikes(t *testing.T) {
+	root := issueTestCert(t, nil, nil)
+	roots := root.pool()
+
+	// leaf returns a chain made of a leaf with a critical NULL extension
+	// with OID id, and the root.
+	leaf := func(id asn1.ObjectIdentifier) [][]byte {
+		t.Helper()
+		cert := issueTestCert(t, root, func(c *x509.Certificate) {
+			c.ExtraExtensions = []pkix.Extension{{Id: id, Critical: true, Value: asn1.NullBytes}}
+		})
+		return [][]byte{cert.Raw, root.Raw}
+	}
+	mutatedPoison := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 33}
+
+	var tests = []struct {
+		desc      string
+		chain     [][]byte
+		isPrecert bool
+		reject    bool
+		wantErr   string
+	}{
+		{
+			desc:      "exact-poison",
+			chain:     leaf(rfc6962.OIDExtensionCTPoison),
+			isPrecert: true,
+			reject:    true,
+		},
+		{
+			desc:      "mutated-poison-not-rejected",
+			chain:     leaf(mutatedPoison),
+			isPrecert: true,
+			wantErr:   "certificate submitted to add-pre-chain",
+		},
+		{
+			// The entry type is checked before other checks on the leaf.
+			desc:      "mutated-poison",
+			chain:     leaf(mutatedPoison),
+			isPrecert: true,
+			reject:    true,
+			wantErr:   "certificate submitted to add-pre-chain",
+		},
+		{
+			desc:  "mutated-poison-final-cert-not-rejected",
+			chain: leaf(mutatedPoison),
+		},
+		{
+			desc:    "mutated-poison-final-cert",
+			chain:   leaf(mutatedPoison),
+			reject:  true,
+			wantErr: "looks like the CT poison extension",
+		},
+		{
+			desc:    "extended-poison",
+			chain:   leaf(append(slices.Clone(rfc6962.OIDExtensionCTPoison), 1)),
+			reject:  true,
+			wantErr: "looks like the CT poison extension",
+		},
+		{
+			desc:   "sct-list",
+			chain:  leaf(rfc6962.OIDExtensionCTSCTList),
+			reject: true,
+		},
+		{
+			desc:   "outside-ct-arc",
+			chain:  leaf(asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 5, 3}),
+			reject: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cv := chainValidator{
+				trustedRoots:           roots,
+				rejectPoisonLookalikes: test.reject,
+			}
+			_, err := cv.Validate(rfc6962.AddChainRequest{Chain: test.chain}, test.isPrecert)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate()=%v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("Validate()=%v, want err containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidateChain(t *testing.T) {
 	fakeCARoots := x509util.NewPEMCertPool()
 	if !fakeCARoots.AppendCertsFromPEM([]byte(testdata.FakeCACertPEM)) {
@@ -492,146 +701,2002 @@ func TestNotAfterRange(t *testing.T) {
 	}
 }
 
-func TestRejectExpiredUnexpired(t *testing.T) {
+func TestNotAfterGrace(t *testing.T) {
 	fakeCARoots := x509util.NewPEMCertPool()
-	// Validity period: Jul 11, 2016 - Jul 11, 2017.
 	if !fakeCARoots.AppendCertsFromPEM([]byte(testdata.FakeCACertPEM)) {
 		t.Fatal("failed to load fake root")
 	}
-	// Validity period: May 13, 2016 - Jul 12, 2019.
 	chain := pemsToDERChain(t, []string{testdata.LeafSignedByFakeIntermediateCertPEM, testdata.FakeIntermediateCertPEM})
-	opts := chainValidator{
-		trustedRoots: fakeCARoots,
-		extKeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	leaf, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		t.Fatalf("x509.ParseCertificate()=%v", err)
 	}
-	beforeValidPeriod := time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC)
-	currentValidPeriod := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
-	afterValidPeriod := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	// The leaf expires exactly at the limit.
+	limit := leaf.NotAfter
 
-	for _, tc := range []struct {
-		desc            string
-		rejectExpired   bool
-		rejectUnexpired bool
-		now             time.Time
-		wantErr         string
+	var tests = []struct {
+		desc    string
+		grace   time.Duration
+		wantErr bool
 	}{
-		// No flags: accept anything.
 		{
-			desc: "no-reject-current",
-			now:  currentValidPeriod,
+			desc:    "at-limit-no-grace",
+			wantErr: true,
 		},
 		{
-			desc: "no-reject-after",
-			now:  afterValidPeriod,
+			desc:  "at-limit-with-grace",
+			grace: time.Hour,
 		},
 		{
-			desc: "no-reject-before",
-			now:  beforeValidPeriod,
+			desc:  "at-limit-with-minimal-grace",
+			grace: time.Nanosecond,
 		},
-		// Reject-Expired: only allow currently-valid and not yet valid
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cv := chainValidator{
+				trustedRoots:  fakeCARoots,
+				notAfterLimit: &limit,
+				notAfterGrace: test.grace,
+			}
+			gotPath, err := cv.validate(chain)
+			if err != nil {
+				if !test.wantErr {
+					t.Errorf("validate()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if test.wantErr {
+				t.Errorf("validate()=%v,%v; want _,non-nil", gotPath, err)
+			}
+		})
+	}
+}
+
+func TestNotBeforeCutoff(t *testing.T) {
+	fakeCARoots := x509util.NewPEMCertPool()
+	if !fakeCARoots.AppendCertsFromPEM([]byte(testdata.FakeCACertPEM)) {
+		t.Fatal("failed to load fake root")
+	}
+	chain := pemsToDERChain(t, []string{testdata.LeafSignedByFakeIntermediateCertPEM, testdata.FakeIntermediateCertPEM})
+	notBefore := pemToCert(t, testdata.LeafSignedByFakeIntermediateCertPEM).NotBefore
+
+	var tests = []struct {
+		desc    string
+		cutoff  time.Time
+		wantErr bool
+	}{
 		{
-			desc:          "reject-expired-current",
-			rejectExpired: true,
-			now:           currentValidPeriod,
+			desc: "no-cutoff",
 		},
 		{
-			desc:          "reject-expired-after",
-			rejectExpired: true,
-			now:           afterValidPeriod,
-			wantErr:       "rejecting expired certificate",
+			desc:   "cutoff-just-before",
+			cutoff: notBefore.Add(-time.Second),
 		},
 		{
-			desc:          "reject-expired-before",
-			rejectExpired: true,
-			now:           beforeValidPeriod,
+			desc:   "cutoff-at",
+			cutoff: notBefore,
 		},
-		// Reject-Unexpired: only allow expired
 		{
-			desc:            "reject-non-expired-after",
-			rejectUnexpired: true,
-			now:             afterValidPeriod,
+			desc:    "cutoff-just-after",
+			cutoff:  notBefore.Add(time.Second),
+			wantErr: true,
 		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			opts := chainValidator{
+				trustedRoots: fakeCARoots,
+			}
+			if !test.cutoff.IsZero() {
+				opts.notBeforeCutoff = &test.cutoff
+			}
+			gotPath, err := opts.validate(chain)
+			if err != nil {
+				if !test.wantErr {
+					t.Errorf("ValidateChain()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if test.wantErr {
+				t.Errorf("ValidateChain()=%v,%v; want _,non-nil", gotPath, err)
+			}
+		})
+	}
+}
+
+func TestRejectCALeaves(t *testing.T) {
+	fakeCARoots := x509util.NewPEMCertPool()
+	if !fakeCARoots.AppendCertsFromPEM([]byte(testdata.FakeCACertPEM)) {
+		t.Fatal("failed to load fake root")
+	}
+
+	var tests = []struct {
+		desc                   string
+		chain                  [][]byte
+		rejectCALeaves         bool
+		allowTrustedRootLeaves bool
+		wantErr                bool
+	}{
 		{
-			desc:            "reject-non-expired-before",
-			rejectUnexpired: true,
-			now:             beforeValidPeriod,
-			wantErr:         "rejecting unexpired certificate",
+			desc:  "ca-leaf-no-reject",
+			chain: pemsToDERChain(t, []string{testdata.FakeIntermediateCertPEM}),
 		},
 		{
-			desc:            "reject-non-expired-current",
-			rejectUnexpired: true,
-			now:             currentValidPeriod,
-			wantErr:         "rejecting unexpired certificate",
+			desc:           "ca-leaf",
+			chain:          pemsToDERChain(t, []string{testdata.FakeIntermediateCertPEM}),
+			rejectCALeaves: true,
+			wantErr:        true,
 		},
-		// Reject-Expired AND Reject-Unexpired: nothing allowed
 		{
-			desc:            "reject-all-after",
-			rejectExpired:   true,
-			rejectUnexpired: true,
-			now:             afterValidPeriod,
-			wantErr:         "rejecting expired certificate",
+			desc:  "ca-leaf-with-root-no-reject",
+			chain: pemsToDERChain(t, []string{testdata.FakeIntermediateCertPEM, testdata.FakeCACertPEM}),
 		},
 		{
-			desc:            "reject-all-before",
-			rejectExpired:   true,
-			rejectUnexpired: true,
-			now:             beforeValidPeriod,
-			wantErr:         "rejecting unexpired certificate",
+			desc:           "ca-leaf-with-root",
+			chain:          pemsToDERChain(t, []string{testdata.FakeIntermediateCertPEM, testdata.FakeCACertPEM}),
+			rejectCALeaves: true,
+			wantErr:        true,
 		},
 		{
-			desc:            "reject-all-current",
-			rejectExpired:   true,
-			rejectUnexpired: true,
-			now:             currentValidPeriod,
-			wantErr:         "rejecting unexpired certificate",
+			desc:           "non-ca-leaf",
+			chain:          pemsToDERChain(t, []string{testdata.LeafSignedByFakeIntermediateCertPEM, testdata.FakeIntermediateCertPEM}),
+			rejectCALeaves: true,
 		},
-	} {
-		t.Run(tc.desc, func(t *testing.T) {
-			opts.currentTime = tc.now
-			opts.rejectExpired = tc.rejectExpired
-			opts.rejectUnexpired = tc.rejectUnexpired
-			_, err := opts.validate(chain)
+		{
+			desc:           "trusted-root-leaf",
+			chain:          pemsToDERChain(t, []string{testdata.FakeCACertPEM}),
+			rejectCALeaves: true,
+			wantErr:        true,
+		},
+		{
+			desc:                   "trusted-root-leaf-allowed",
+			chain:                  pemsToDERChain(t, []string{testdata.FakeCACertPEM}),
+			rejectCALeaves:         true,
+			allowTrustedRootLeaves: true,
+		},
+		{
+			desc:                   "ca-leaf-trusted-roots-allowed",
+			chain:                  pemsToDERChain(t, []string{testdata.FakeIntermediateCertPEM}),
+			rejectCALeaves:         true,
+			allowTrustedRootLeaves: true,
+			wantErr:                true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			opts := chainValidator{
+				trustedRoots:           fakeCARoots,
+				rejectCALeaves:         test.rejectCALeaves,
+				allowTrustedRootLeaves: test.allowTrustedRootLeaves,
+			}
+			gotPath, err := opts.validate(test.chain)
 			if err != nil {
-				if len(tc.wantErr) == 0 {
-					t.Errorf("ValidateChain()=_,%v; want _,nil", err)
-				} else if !strings.Contains(err.Error(), tc.wantErr) {
-					t.Errorf("ValidateChain()=_,%v; want err containing %q", err, tc.wantErr)
+				if !test.wantErr {
+					t.Errorf("ValidateChain()=%v,%v; want _,nil", gotPath, err)
 				}
-			} else if len(tc.wantErr) != 0 {
-				t.Errorf("ValidateChain()=_,nil; want err containing %q", tc.wantErr)
+				return
+			}
+			if test.wantErr {
+				t.Errorf("ValidateChain()=%v,%v; want _,non-nil", gotPath, err)
 			}
 		})
 	}
 }
 
-// Builds a chain of DER-encoded certs.
-// Note: ordering is important
-func pemsToDERChain(t *testing.T, pemCerts []string) [][]byte {
-	t.Helper()
-	chain := make([][]byte, 0, len(pemCerts))
-	for _, pemCert := range pemCerts {
-		cert := pemToCert(t, pemCert)
-		chain = append(chain, cert.Raw)
-	}
-	return chain
-}
+// TestNonCAIntermediates checks that intermediates must have basicConstraints
+// CA:TRUE. This is always enforced by path verification.
+func TestNonCAIntermediates(t *testing.T) {
+	root := issueTestCert(t, nil, nil)
+	roots := root.pool()
 
-func pemToCert(t *testing.T, pemData string) *x509.Certificate {
-	t.Helper()
-	bytes, rest := pem.Decode([]byte(pemData))
-	if len(rest) > 0 {
-		t.Fatalf("Extra data after PEM: %v", rest)
-		return nil
+	// chain returns a leaf, issued by an intermediate with basic constraints
+	// set by setBC, issued by root.
+	chain := func(setBC func(*x509.Certificate)) [][]byte {
+		t.Helper()
+		intermediate := issueTestCert(t, root, func(c *x509.Certificate) {
+			c.Subject = pkix.Name{CommonName: "Intermediate"}
+			c.KeyUsage = x509.KeyUsageCertSign
+			c.ExtKeyUsage = nil
+			setBC(c)
+		})
+		leaf := issueTestCert(t, intermediate, func(c *x509.Certificate) {
+			c.SerialNumber = big.NewInt(3)
+		})
+		return [][]byte{leaf.Raw, intermediate.Raw, root.Raw}
 	}
 
-	cert, err := x509.ParseCertificate(bytes.Bytes)
-	if err != nil {
-		t.Fatalf("x509.ParseCertificate(): %v", err)
+	var tests = []struct {
+		desc    string
+		chain   [][]byte
+		wantErr bool
+	}{
+		{
+			desc: "ca-intermediate",
+			chain: chain(func(c *x509.Certificate) {
+				c.BasicConstraintsValid, c.IsCA = true, true
+			}),
+		},
+		{
+			desc: "non-ca-intermediate",
+			chain: chain(func(c *x509.Certificate) {
+				c.BasicConstraintsValid, c.IsCA = true, false
+			}),
+			wantErr: true,
+		},
+		{
+			desc:    "intermediate-without-basic-constraints",
+			chain:   chain(func(c *x509.Certificate) {}),
+			wantErr: true,
+		},
 	}
-
-	return cert
-}
-
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cv := chainValidator{trustedRoots: roots}
+			gotPath, err := cv.validate(test.chain)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Errorf("validate()=%v,%v; want err: %t", gotPath, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestMaxSANs(t *testing.T) {
+	fakeCARoots := x509util.NewPEMCertPool()
+	if !fakeCARoots.AppendCertsFromPEM([]byte(testdata.FakeCACertPEM)) {
+		t.Fatal("failed to load fake root")
+	}
+	chain := pemsToDERChain(t, []string{testdata.LeafSignedByFakeIntermediateCertPEM, testdata.FakeIntermediateCertPEM})
+	sans, err := subjectAltNames(pemToCert(t, testdata.LeafSignedByFakeIntermediateCertPEM))
+	if err != nil {
+		t.Fatalf("subjectAltNames()=%v", err)
+	}
+	numSANs := len(sans)
+	if numSANs < 2 {
+		t.Fatalf("test leaf has %d SANs, want at least 2", numSANs)
+	}
+
+	var tests = []struct {
+		desc    string
+		maxSANs int
+		wantErr bool
+	}{
+		{
+			desc: "no-limit",
+		},
+		{
+			desc:    "below-limit",
+			maxSANs: numSANs + 1,
+		},
+		{
+			desc:    "at-limit",
+			maxSANs: numSANs,
+		},
+		{
+			desc:    "above-limit",
+			maxSANs: numSANs - 1,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			opts := chainValidator{
+				trustedRoots: fakeCARoots,
+				maxSANs:      test.maxSANs,
+			}
+			gotPath, err := opts.validate(chain)
+			if err != nil {
+				if !test.wantErr {
+					t.Errorf("ValidateChain()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if test.wantErr {
+				t.Errorf("ValidateChain()=%v,%v; want _,non-nil", gotPath, err)
+			}
+		})
+	}
+}
+
+func TestMaxSANLength(t *testing.T) {
+	fakeCARoots := x509util.NewPEMCertPool()
+	if !fakeCARoots.AppendCertsFromPEM([]byte(testdata.FakeCACertPEM)) {
+		t.Fatal("failed to load fake root")
+	}
+	chain := pemsToDERChain(t, []string{testdata.LeafSignedByFakeIntermediateCertPEM, testdata.FakeIntermediateCertPEM})
+	sans, err := subjectAltNames(pemToCert(t, testdata.LeafSignedByFakeIntermediateCertPEM))
+	if err != nil {
+		t.Fatalf("subjectAltNames()=%v", err)
+	}
+	longest := 0
+	for _, san := range sans {
+		longest = max(longest, len(san.Bytes))
+	}
+
+	var tests = []struct {
+		desc         string
+		maxSANLength int
+		wantErr      string
+	}{
+		{
+			desc: "no-limit",
+		},
+		{
+			desc:         "below-limit",
+			maxSANLength: longest + 1,
+		},
+		{
+			desc:         "at-limit",
+			maxSANLength: longest,
+		},
+		{
+			desc:         "overlong-san",
+			maxSANLength: longest - 1,
+			wantErr:      fmt.Sprintf("%d-byte dNSName SubjectAltName entry, longer than %d bytes", longest, longest-1),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			opts := chainValidator{
+				trustedRoots: fakeCARoots,
+				maxSANLength: test.maxSANLength,
+			}
+			gotPath, err := opts.validate(chain)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateChain()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("ValidateChain()=%v,%v; want _,err containing %q", gotPath, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequiredCPSURIPrefixes(t *testing.T) {
+	root := issueTestCert(t, nil, nil)
+	roots := root.pool()
+
+	oidPolicy := asn1.ObjectIdentifier{2, 23, 140, 1, 2, 1}
+	cps := func(uri string) policyQualifierInfo {
+		return policyQualifierInfo{ID: oidPolicyQualifierCPS, Qualifier: asn1.RawValue{Tag: asn1.TagIA5String, Bytes: []byte(uri)}}
+	}
+
+	var tests = []struct {
+		desc     string
+		policies []policyInformation
+		wantErr  string
+	}{
+		{
+			desc:     "compliant",
+			policies: []policyInformation{{Policy: oidPolicy, Qualifiers: []policyQualifierInfo{cps("https://pki.example.com/cps/v2")}}},
+		},
+		{
+			desc:    "no-policies",
+			wantErr: "without CPS URI policy qualifier",
+		},
+		{
+			desc:     "no-qualifier",
+			policies: []policyInformation{{Policy: oidPolicy}},
+			wantErr:  "without CPS URI policy qualifier",
+		},
+		{
+			desc:     "other-prefix",
+			policies: []policyInformation{{Policy: oidPolicy, Qualifiers: []policyQualifierInfo{cps("https://pki.example.org/cps")}}},
+			wantErr:  "doesn't start with any of",
+		},
+		{
+			desc: "one-of-several-non-compliant",
+			policies: []policyInformation{
+				{Policy: oidPolicy, Qualifiers: []policyQualifierInfo{cps("https://pki.example.com/cps")}},
+				{Policy: asn1.ObjectIdentifier{1, 2, 3}, Qualifiers: []policyQualifierInfo{cps("https://pki.example.org/cps")}},
+			},
+			wantErr: "doesn't start with any of",
+		},
+		{
+			desc:     "malformed-uri",
+			policies: []policyInformation{{Policy: oidPolicy, Qualifiers: []policyQualifierInfo{cps("https://pki.example.com/cps%zz")}}},
+			wantErr:  "malformed CPS URI",
+		},
+		{
+			desc: "not-ia5string",
+			policies: []policyInformation{{Policy: oidPolicy, Qualifiers: []policyQualifierInfo{{
+				ID:        oidPolicyQualifierCPS,
+				Qualifier: asn1.RawValue{Tag: asn1.TagUTF8String, Bytes: []byte("https://pki.example.com/cps")},
+			}}}},
+			wantErr: "not an IA5String",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var policies []pkix.Extension
+			if test.policies != nil {
+				ext, err := asn1.Marshal(test.policies)
+				if err != nil {
+					t.Fatalf("asn1.Marshal()=%v", err)
+				}
+				policies = []pkix.Extension{{Id: oidExtensionCertificatePolicies, Value: ext}}
+			}
+			leaf := issueTestCert(t, root, func(c *x509.Certificate) {
+				c.DNSNames = []string{"leaf.example.com"}
+				c.ExtraExtensions = policies
+			})
+			chain := [][]byte{leaf.Raw, root.Raw}
+
+			// CPS URIs are only checked when requiredCPSURIPrefixes is set.
+			cv := chainValidator{trustedRoots: roots}
+			if _, err := cv.validate(chain); err != nil {
+				t.Fatalf("validate() without requiredCPSURIPrefixes=%v, want nil", err)
+			}
+			cv.requiredCPSURIPrefixes = []string{"http://cps.example.com/", "https://pki.example.com/cps"}
+			gotPath, err := cv.validate(chain)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("validate()=%v,%v; want _,err containing %q", gotPath, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseCPSURIPrefixes(t *testing.T) {
+	for _, test := range []struct {
+		prefix  string
+		wantErr bool
+	}{
+		{prefix: "https://pki.example.com/cps"},
+		{prefix: "http://pki.example.com"},
+		{prefix: "ftp://pki.example.com/cps", wantErr: true},
+		{prefix: "pki.example.com/cps", wantErr: true},
+		{prefix: "https://", wantErr: true},
+	} {
+		t.Run(test.prefix, func(t *testing.T) {
+			if _, err := ParseCPSURIPrefixes([]string{test.prefix}); (err != nil) != test.wantErr {
+				t.Errorf("ParseCPSURIPrefixes(%q)=%v, want err %t", test.prefix, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestPoisonOID(t *testing.T) {
+	root := issueTestCert(t, nil, nil)
+	roots := root.pool()
+
+	// altPoisonOID is in the RFC 6962 arc, to check that it isn't rejected
+	// as a poison lookalike.
+	altPoisonOID := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 99}
+	chain := func(poison asn1.ObjectIdentifier) [][]byte {
+		t.Helper()
+		leaf := issueTestCert(t, root, func(c *x509.Certificate) {
+			c.DNSNames = []string{"leaf.example.com"}
+			if poison != nil {
+				c.ExtraExtensions = []pkix.Extension{{Id: poison, Critical: true, Value: asn1.NullBytes}}
+			}
+		})
+		return [][]byte{leaf.Raw, root.Raw}
+	}
+
+	for _, test := range []struct {
+		desc      string
+		poisonOID asn1.ObjectIdentifier
+		leafOID   asn1.ObjectIdentifier
+		precert   bool
+		wantErr   string
+	}{
+		{
+			desc:    "default-ct-poison",
+			leafOID: rfc6962.OIDExtensionCTPoison,
+			precert: true,
+		},
+		{
+			desc:    "default-alt-poison",
+			leafOID: altPoisonOID,
+			precert: true,
+			wantErr: "submitted to add-",
+		},
+		{
+			desc:      "alt-poison",
+			poisonOID: altPoisonOID,
+			leafOID:   altPoisonOID,
+			precert:   true,
+		},
+		{
+			desc:      "alt-poison-as-cert",
+			poisonOID: altPoisonOID,
+			leafOID:   altPoisonOID,
+			wantErr:   "submitted to add-",
+		},
+		{
+			desc:      "alt-ct-poison",
+			poisonOID: altPoisonOID,
+			leafOID:   rfc6962.OIDExtensionCTPoison,
+			precert:   true,
+			wantErr:   "precertificates must be marked with",
+		},
+		{
+			desc:      "alt-ct-poison-as-cert",
+			poisonOID: altPoisonOID,
+			leafOID:   rfc6962.OIDExtensionCTPoison,
+			wantErr:   "precertificates must be marked with",
+		},
+		{
+			desc:      "alt-poison-lookalike",
+			poisonOID: altPoisonOID,
+			leafOID:   asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 98},
+			wantErr:   "looks like the CT poison extension 1.3.6.1.4.1.11129.2.4.99",
+		},
+		{
+			desc:      "alt-cert",
+			poisonOID: altPoisonOID,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			cv := chainValidator{trustedRoots: roots, rejectPoisonLookalikes: test.poisonOID != nil, poisonOID: test.poisonOID}
+			_, err := cv.Validate(rfc6962.AddChainRequest{Chain: chain(test.leafOID)}, test.precert)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate()=%v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("Validate()=%v, want error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestParsePoisonOID(t *testing.T) {
+	for _, test := range []struct {
+		oid     string
+		wantErr bool
+	}{
+		{oid: "1.3.6.1.4.1.11129.2.4.3"},
+		{oid: "1.3.6.1.4.1.99999.1"},
+		{oid: "2.999.1"},
+		{oid: "1", wantErr: true},
+		{oid: "3.1", wantErr: true},
+		{oid: "1.40", wantErr: true},
+		{oid: "1.3.-6", wantErr: true},
+		{oid: "1.3.a", wantErr: true},
+		{oid: "", wantErr: true},
+	} {
+		t.Run(test.oid, func(t *testing.T) {
+			if _, err := ParsePoisonOID(test.oid); (err != nil) != test.wantErr {
+				t.Errorf("ParsePoisonOID(%q)=%v, want err %t", test.oid, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRejectDuplicateSANs(t *testing.T) {
+	root := issueTestCert(t, nil, nil)
+	roots := root.pool()
+
+	var tests = []struct {
+		desc     string
+		dnsNames []string
+		ips      []net.IP
+		wantErr  bool
+	}{
+		{
+			desc:     "unique",
+			dnsNames: []string{"a.example.com", "b.example.com"},
+			ips:      []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2")},
+		},
+		{
+			desc:     "duplicate-dns-name",
+			dnsNames: []string{"a.example.com", "b.example.com", "a.example.com"},
+			wantErr:  true,
+		},
+		{
+			desc:     "duplicate-dns-name-different-case",
+			dnsNames: []string{"a.example.com", "A.Example.COM"},
+			wantErr:  true,
+		},
+		{
+			desc:    "duplicate-ip",
+			ips:     []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.1")},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			leaf := issueTestCert(t, root, func(c *x509.Certificate) {
+				c.DNSNames, c.IPAddresses = test.dnsNames, test.ips
+			})
+			chain := [][]byte{leaf.Raw, root.Raw}
+
+			// Duplicates are only rejected when rejectDuplicateSANs is set.
+			cv := chainValidator{trustedRoots: roots}
+			if _, err := cv.validate(chain); err != nil {
+				t.Fatalf("validate() without rejectDuplicateSANs=%v, want nil", err)
+			}
+			cv.rejectDuplicateSANs = true
+			gotPath, err := cv.validate(chain)
+			if err != nil {
+				if !test.wantErr {
+					t.Errorf("validate()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if test.wantErr {
+				t.Errorf("validate()=%v,%v; want _,non-nil", gotPath, err)
+			}
+		})
+	}
+}
+
+func TestAllowedSANTypes(t *testing.T) {
+	root := issueTestCert(t, nil, nil)
+	roots := root.pool()
+	dnsOnly, err := ParseSANTypes([]string{"dNSName", "iPAddress"})
+	if err != nil {
+		t.Fatalf("ParseSANTypes()=%v", err)
+	}
+	uri, err := url.Parse("https://example.com/id")
+	if err != nil {
+		t.Fatalf("url.Parse()=%v", err)
+	}
+	withOtherName, err := ParseSANTypes([]string{"dNSName", "otherName"})
+	if err != nil {
+		t.Fatalf("ParseSANTypes()=%v", err)
+	}
+	// A critical SubjectAltName extension with a single otherName entry with
+	// a private OID, which the Go x509 library doesn't handle.
+	var b cryptobyte.Builder
+	b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1(cbasn1.Tag(0).ContextSpecific().Constructed(), func(b *cryptobyte.Builder) {
+			b.AddASN1ObjectIdentifier(asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1})
+			b.AddASN1(cbasn1.Tag(0).ContextSpecific().Constructed(), func(b *cryptobyte.Builder) {
+				b.AddASN1(cbasn1.UTF8String, func(b *cryptobyte.Builder) {
+					b.AddBytes([]byte("device-1234"))
+				})
+			})
+		})
+	})
+	otherNameSAN := pkix.Extension{Id: asn1.ObjectIdentifier{2, 5, 29, 17}, Critical: true, Value: b.BytesOrPanic()}
+
+	var tests = []struct {
+		desc    string
+		tmpl    x509.Certificate
+		allowed []int
+		wantErr bool
+	}{
+		{
+			desc: "critical-other-name-no-policy",
+			tmpl: x509.Certificate{ExtraExtensions: []pkix.Extension{otherNameSAN}},
+		},
+		{
+			desc:    "critical-other-name-allowed",
+			tmpl:    x509.Certificate{ExtraExtensions: []pkix.Extension{otherNameSAN}},
+			allowed: withOtherName,
+		},
+		{
+			desc:    "critical-other-name",
+			tmpl:    x509.Certificate{ExtraExtensions: []pkix.Extension{otherNameSAN}},
+			allowed: dnsOnly,
+			wantErr: true,
+		},
+		{
+			desc: "email-no-policy",
+			tmpl: x509.Certificate{EmailAddresses: []string{"admin@example.com"}},
+		},
+		{
+			desc:    "dns-and-ip",
+			tmpl:    x509.Certificate{DNSNames: []string{"a.example.com"}, IPAddresses: []net.IP{net.ParseIP("192.0.2.1")}},
+			allowed: dnsOnly,
+		},
+		{
+			desc:    "no-sans",
+			allowed: dnsOnly,
+		},
+		{
+			desc:    "email",
+			tmpl:    x509.Certificate{DNSNames: []string{"a.example.com"}, EmailAddresses: []string{"admin@example.com"}},
+			allowed: dnsOnly,
+			wantErr: true,
+		},
+		{
+			desc:    "uri",
+			tmpl:    x509.Certificate{URIs: []*url.URL{uri}},
+			allowed: dnsOnly,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			leaf := issueTestCert(t, root, func(c *x509.Certificate) {
+				c.DNSNames, c.IPAddresses = test.tmpl.DNSNames, test.tmpl.IPAddresses
+				c.EmailAddresses, c.URIs = test.tmpl.EmailAddresses, test.tmpl.URIs
+				c.ExtraExtensions = test.tmpl.ExtraExtensions
+			})
+
+			cv := NewChainValidator(roots, ChainValidatorOpts{AllowedSANTypes: test.allowed})
+			gotPath, err := cv.validate([][]byte{leaf.Raw, root.Raw})
+			if err != nil {
+				if !test.wantErr {
+					t.Errorf("validate()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if test.wantErr {
+				t.Errorf("validate()=%v,%v; want _,non-nil", gotPath, err)
+			}
+		})
+	}
+}
+
+func TestParseSANTypes(t *testing.T) {
+	got, err := ParseSANTypes([]string{"rfc822Name", "dNSName", "uniformResourceIdentifier", "iPAddress"})
+	if err != nil {
+		t.Fatalf("ParseSANTypes()=%v", err)
+	}
+	if want := []int{1, 2, 6, 7}; !slices.Equal(got, want) {
+		t.Errorf("ParseSANTypes()=%v, want %v", got, want)
+	}
+	if _, err := ParseSANTypes([]string{"DNS"}); err == nil {
+		t.Error("ParseSANTypes() with an unknown type=nil, want error")
+	}
+}
+
+func TestDeniedSPKIHashes(t *testing.T) {
+	fakeCARoots := x509util.NewPEMCertPool()
+	if !fakeCARoots.AppendCertsFromPEM([]byte(testdata.FakeCACertPEM)) {
+		t.Fatal("failed to load fake root")
+	}
+	chain := pemsToDERChain(t, []string{testdata.LeafSignedByFakeIntermediateCertPEM, testdata.FakeIntermediateCertPEM})
+	leafHash := sha256.Sum256(pemToCert(t, testdata.LeafSignedByFakeIntermediateCertPEM).RawSubjectPublicKeyInfo)
+	intermediateHash := sha256.Sum256(pemToCert(t, testdata.FakeIntermediateCertPEM).RawSubjectPublicKeyInfo)
+	otherHash := sha256.Sum256([]byte("other"))
+
+	var tests = []struct {
+		desc    string
+		denied  [][sha256.Size]byte
+		wantErr bool
+	}{
+		{
+			desc: "no-denylist",
+		},
+		{
+			desc:   "other-key-denied",
+			denied: [][sha256.Size]byte{otherHash},
+		},
+		{
+			desc:   "intermediate-key-denied",
+			denied: [][sha256.Size]byte{intermediateHash},
+		},
+		{
+			desc:    "leaf-key-denied",
+			denied:  [][sha256.Size]byte{otherHash, leafHash},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cv := NewChainValidator(fakeCARoots, ChainValidatorOpts{DeniedSPKIHashes: test.denied})
+			gotPath, err := cv.validate(chain)
+			if err != nil {
+				if !test.wantErr {
+					t.Errorf("ValidateChain()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if test.wantErr {
+				t.Errorf("ValidateChain()=%v,%v; want _,non-nil", gotPath, err)
+			}
+		})
+	}
+}
+
+func TestRevokedIntermediates(t *testing.T) {
+	fakeCARoots := x509util.NewPEMCertPool()
+	if !fakeCARoots.AppendCertsFromPEM([]byte(testdata.FakeCACertPEM)) {
+		t.Fatal("failed to load fake root")
+	}
+	chain := pemsToDERChain(t, []string{testdata.LeafSignedByFakeIntermediateCertPEM, testdata.FakeIntermediateCertPEM})
+	leafFP := sha256.Sum256(pemToCert(t, testdata.LeafSignedByFakeIntermediateCertPEM).Raw)
+	intermediateFP := sha256.Sum256(pemToCert(t, testdata.FakeIntermediateCertPEM).Raw)
+	rootFP := sha256.Sum256(pemToCert(t, testdata.FakeCACertPEM).Raw)
+	otherFP := sha256.Sum256([]byte("other"))
+
+	var tests = []struct {
+		desc    string
+		revoked [][sha256.Size]byte
+		wantErr string
+	}{
+		{
+			desc: "no-revocations",
+		},
+		{
+			desc:    "other-intermediate-revoked",
+			revoked: [][sha256.Size]byte{otherFP},
+		},
+		{
+			desc:    "intermediate-revoked",
+			revoked: [][sha256.Size]byte{otherFP, intermediateFP},
+			wantErr: "revoked intermediate",
+		},
+		{
+			desc:    "leaf-and-root-not-checked",
+			revoked: [][sha256.Size]byte{leafFP, rootFP},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cv := NewChainValidator(fakeCARoots, ChainValidatorOpts{RevokedIntermediates: test.revoked})
+			gotPath, err := cv.validate(chain)
+			if len(test.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("ValidateChain()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("ValidateChain()=%v,%v; want err containing %q", gotPath, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestSHA1Roots(t *testing.T) {
+	now := time.Now()
+	// newRoot returns a self-signed root, signed with SHA-256, and its key.
+	newRoot := func(name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+		t.Helper()
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("ecdsa.GenerateKey()=%v", err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              now.Add(time.Hour),
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+			KeyUsage:              x509.KeyUsageCertSign,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+		if err != nil {
+			t.Fatalf("x509.CreateCertificate()=%v", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("x509.ParseCertificate()=%v", err)
+		}
+		return cert, key
+	}
+	legacyRoot, legacyKey := newRoot("legacy root")
+	otherRoot, otherKey := newRoot("other root")
+	roots := x509util.NewPEMCertPool()
+	roots.AddCert(legacyRoot)
+	roots.AddCert(otherRoot)
+
+	// newCert returns a certificate issued by issuer, signed with sigAlg, and
+	// its key. It is a CA certificate if isCA is set.
+	newCert := func(name string, isCA bool, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey, sigAlg x509.SignatureAlgorithm) (*x509.Certificate, *ecdsa.PrivateKey) {
+		t.Helper()
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("ecdsa.GenerateKey()=%v", err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber:       big.NewInt(2),
+			Subject:            pkix.Name{CommonName: name},
+			NotBefore:          now.Add(-time.Hour),
+			NotAfter:           now.Add(time.Hour),
+			ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			SignatureAlgorithm: sigAlg,
+		}
+		if isCA {
+			tmpl.BasicConstraintsValid = true
+			tmpl.IsCA = true
+			tmpl.KeyUsage = x509.KeyUsageCertSign
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, key.Public(), issuerKey)
+		if err != nil {
+			t.Fatalf("x509.CreateCertificate()=%v", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("x509.ParseCertificate()=%v", err)
+		}
+		return cert, key
+	}
+	sha1Leaf, _ := newCert("leaf", false, legacyRoot, legacyKey, x509.ECDSAWithSHA1)
+	sha256Leaf, _ := newCert("leaf", false, legacyRoot, legacyKey, x509.ECDSAWithSHA256)
+	// sha1Intermediate is signed with SHA-1 by otherRoot, and issues
+	// intermediateLeaf with SHA-256.
+	sha1Intermediate, sha1IntermediateKey := newCert("sha1 intermediate", true, otherRoot, otherKey, x509.ECDSAWithSHA1)
+	intermediateLeaf, _ := newCert("leaf", false, sha1Intermediate, sha1IntermediateKey, x509.ECDSAWithSHA256)
+	legacyFP := sha256.Sum256(legacyRoot.Raw)
+	otherFP := sha256.Sum256(otherRoot.Raw)
+
+	var tests = []struct {
+		desc      string
+		chain     [][]byte
+		sha1Roots [][sha256.Size]byte
+		wantErr   string
+	}{
+		{
+			desc:    "sha1-denied",
+			chain:   [][]byte{sha1Leaf.Raw, legacyRoot.Raw},
+			wantErr: "insecure algorithm",
+		},
+		{
+			desc:      "sha1-allowed-for-root",
+			chain:     [][]byte{sha1Leaf.Raw, legacyRoot.Raw},
+			sha1Roots: [][sha256.Size]byte{legacyFP},
+		},
+		{
+			desc:      "sha1-allowed-for-other-root",
+			chain:     [][]byte{sha1Leaf.Raw, legacyRoot.Raw},
+			sha1Roots: [][sha256.Size]byte{otherFP},
+			wantErr:   "unknown authority",
+		},
+		{
+			desc:    "sha1-intermediate-denied",
+			chain:   [][]byte{intermediateLeaf.Raw, sha1Intermediate.Raw, otherRoot.Raw},
+			wantErr: "insecure algorithm",
+		},
+		{
+			desc:      "sha1-intermediate-allowed-for-root",
+			chain:     [][]byte{intermediateLeaf.Raw, sha1Intermediate.Raw, otherRoot.Raw},
+			sha1Roots: [][sha256.Size]byte{otherFP},
+		},
+		{
+			desc:      "sha1-intermediate-allowed-for-other-root",
+			chain:     [][]byte{intermediateLeaf.Raw, sha1Intermediate.Raw, otherRoot.Raw},
+			sha1Roots: [][sha256.Size]byte{legacyFP},
+			wantErr:   "unknown authority",
+		},
+		{
+			desc:  "sha256",
+			chain: [][]byte{sha256Leaf.Raw, legacyRoot.Raw},
+		},
+		{
+			desc:      "sha256-with-sha1-roots",
+			chain:     [][]byte{sha256Leaf.Raw, legacyRoot.Raw},
+			sha1Roots: [][sha256.Size]byte{otherFP},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cv := NewChainValidator(roots, ChainValidatorOpts{SHA1Roots: test.sha1Roots})
+			gotPath, err := cv.validate(test.chain)
+			if len(test.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("validate()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("validate()=%v,%v; want err containing %q", gotPath, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRootInNonTerminalPosition(t *testing.T) {
+	fakeCARoots := x509util.NewPEMCertPool()
+	if !fakeCARoots.AppendCertsFromPEM([]byte(testdata.FakeCACertPEM)) {
+		t.Fatal("failed to load fake root")
+	}
+	cv := chainValidator{
+		trustedRoots: fakeCARoots,
+	}
+
+	var tests = []struct {
+		desc    string
+		chain   [][]byte
+		wantErr string
+	}{
+		{
+			desc:  "root-at-end",
+			chain: pemsToDERChain(t, []string{testdata.LeafSignedByFakeIntermediateCertPEM, testdata.FakeIntermediateCertPEM, testdata.FakeCACertPEM}),
+		},
+		{
+			desc:  "root-only",
+			chain: pemsToDERChain(t, []string{testdata.FakeCACertPEM}),
+		},
+		{
+			desc:    "root-as-intermediate-and-terminator",
+			chain:   pemsToDERChain(t, []string{testdata.LeafSignedByFakeIntermediateCertPEM, testdata.FakeIntermediateCertPEM, testdata.FakeCACertPEM, testdata.FakeCACertPEM}),
+			wantErr: "found at position 2",
+		},
+		{
+			desc:    "root-before-intermediate",
+			chain:   pemsToDERChain(t, []string{testdata.LeafSignedByFakeIntermediateCertPEM, testdata.FakeCACertPEM, testdata.FakeIntermediateCertPEM}),
+			wantErr: "found at position 1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			gotPath, err := cv.validate(test.chain)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateChain()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("ValidateChain()=%v,%v; want _,err containing %q", gotPath, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestMaxPrecertAge(t *testing.T) {
+	roots := x509util.NewPEMCertPool()
+	if err := roots.AppendCertsFromPEMFile("../testdata/test_root_ca_cert.pem"); err != nil {
+		t.Fatalf("failed to load roots: %v", err)
+	}
+	precertChain := pemsToDERChain(t, []string{testdata.PreCertFromIntermediate, testdata.IntermediateFromRoot})
+	certChain := pemsToDERChain(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot})
+	precertNotBefore := pemToCert(t, testdata.PreCertFromIntermediate).NotBefore
+	certNotBefore := pemToCert(t, testdata.CertFromIntermediate).NotBefore
+	const maxAge = 24 * time.Hour
+
+	var tests = []struct {
+		desc          string
+		chain         [][]byte
+		isPrecert     bool
+		currentTime   time.Time
+		maxPrecertAge time.Duration
+		wantErr       bool
+	}{
+		{
+			desc:        "no-limit",
+			chain:       precertChain,
+			isPrecert:   true,
+			currentTime: precertNotBefore.Add(100 * maxAge),
+		},
+		{
+			desc:          "fresh-precert",
+			chain:         precertChain,
+			isPrecert:     true,
+			currentTime:   precertNotBefore.Add(time.Hour),
+			maxPrecertAge: maxAge,
+		},
+		{
+			desc:          "precert-at-limit",
+			chain:         precertChain,
+			isPrecert:     true,
+			currentTime:   precertNotBefore.Add(maxAge),
+			maxPrecertAge: maxAge,
+		},
+		{
+			desc:          "stale-precert",
+			chain:         precertChain,
+			isPrecert:     true,
+			currentTime:   precertNotBefore.Add(2 * maxAge),
+			maxPrecertAge: maxAge,
+			wantErr:       true,
+		},
+		{
+			desc:          "old-final-cert",
+			chain:         certChain,
+			currentTime:   certNotBefore.Add(2 * maxAge),
+			maxPrecertAge: maxAge,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cv := chainValidator{
+				trustedRoots:  roots,
+				currentTime:   test.currentTime,
+				maxPrecertAge: test.maxPrecertAge,
+			}
+			gotPath, err := cv.Validate(rfc6962.AddChainRequest{Chain: test.chain}, test.isPrecert)
+			if err != nil {
+				if !test.wantErr {
+					t.Errorf("Validate()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if test.wantErr {
+				t.Errorf("Validate()=%v,%v; want _,non-nil", gotPath, err)
+			}
+		})
+	}
+}
+
+func TestRequireEmbeddedSCTs(t *testing.T) {
+	root := issueTestCert(t, nil, nil)
+	roots := root.pool()
+
+	// leaf returns a chain made of a leaf with extensions exts, and the root.
+	leaf := func(exts ...pkix.Extension) [][]byte {
+		t.Helper()
+		cert := issueTestCert(t, root, func(c *x509.Certificate) {
+			c.ExtraExtensions = exts
+		})
+		return [][]byte{cert.Raw, root.Raw}
+	}
+	// sctList returns an embedded SCT list extension holding scts.
+	sctList := func(critical bool, scts ...[]byte) pkix.Extension {
+		t.Helper()
+		var b cryptobyte.Builder
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, sct := range scts {
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(sct)
+				})
+			}
+		})
+		value, err := asn1.Marshal(b.BytesOrPanic())
+		if err != nil {
+			t.Fatalf("asn1.Marshal()=%v", err)
+		}
+		return pkix.Extension{Id: rfc6962.OIDExtensionCTSCTList, Critical: critical, Value: value}
+	}
+	poison := pkix.Extension{Id: rfc6962.OIDExtensionCTPoison, Critical: true, Value: asn1.NullBytes}
+
+	var tests = []struct {
+		desc                string
+		chain               [][]byte
+		isPrecert           bool
+		requireEmbeddedSCTs bool
+		rejectPrecertSCTs   bool
+		wantErr             bool
+	}{
+		{
+			desc:  "no-scts-not-required",
+			chain: leaf(),
+		},
+		{
+			desc:                "scts",
+			chain:               leaf(sctList(false, []byte("sct1"), []byte("sct2"))),
+			requireEmbeddedSCTs: true,
+		},
+		{
+			desc:                "no-scts",
+			chain:               leaf(),
+			requireEmbeddedSCTs: true,
+			wantErr:             true,
+		},
+		{
+			desc:                "empty-sct-list",
+			chain:               leaf(sctList(false)),
+			requireEmbeddedSCTs: true,
+			wantErr:             true,
+		},
+		{
+			desc:                "empty-sct",
+			chain:               leaf(sctList(false, []byte{})),
+			requireEmbeddedSCTs: true,
+			wantErr:             true,
+		},
+		{
+			desc:                "critical-sct-list",
+			chain:               leaf(sctList(true, []byte("sct1"))),
+			requireEmbeddedSCTs: true,
+			wantErr:             true,
+		},
+		{
+			desc:                "not-an-octet-string",
+			chain:               leaf(pkix.Extension{Id: rfc6962.OIDExtensionCTSCTList, Value: asn1.NullBytes}),
+			requireEmbeddedSCTs: true,
+			wantErr:             true,
+		},
+		{
+			desc:                "precert",
+			chain:               leaf(poison),
+			isPrecert:           true,
+			requireEmbeddedSCTs: true,
+		},
+		{
+			desc:      "precert-with-scts-allowed",
+			chain:     leaf(poison, sctList(false, []byte("sct1"))),
+			isPrecert: true,
+		},
+		{
+			desc:              "precert-without-scts",
+			chain:             leaf(poison),
+			isPrecert:         true,
+			rejectPrecertSCTs: true,
+		},
+		{
+			desc:              "precert-with-scts",
+			chain:             leaf(poison, sctList(false, []byte("sct1"))),
+			isPrecert:         true,
+			rejectPrecertSCTs: true,
+			wantErr:           true,
+		},
+		{
+			desc:              "precert-with-empty-sct-list",
+			chain:             leaf(poison, sctList(false)),
+			isPrecert:         true,
+			rejectPrecertSCTs: true,
+			wantErr:           true,
+		},
+		{
+			desc:              "final-cert-with-scts",
+			chain:             leaf(sctList(false, []byte("sct1"))),
+			rejectPrecertSCTs: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cv := chainValidator{
+				trustedRoots:           roots,
+				requireEmbeddedSCTs:    test.requireEmbeddedSCTs,
+				rejectPrecertsWithSCTs: test.rejectPrecertSCTs,
+			}
+			gotPath, err := cv.Validate(rfc6962.AddChainRequest{Chain: test.chain}, test.isPrecert)
+			if err != nil {
+				if !test.wantErr {
+					t.Errorf("Validate()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if test.wantErr {
+				t.Errorf("Validate()=%v,%v; want _,non-nil", gotPath, err)
+			}
+		})
+	}
+}
+
+func TestRequireRevocationInfo(t *testing.T) {
+	root := issueTestCert(t, nil, nil)
+	roots := root.pool()
+
+	// leaf returns a chain made of a leaf with the given CRL distribution
+	// points and OCSP responders, and the root.
+	leaf := func(crlDPs, ocspServers []string) [][]byte {
+		t.Helper()
+		cert := issueTestCert(t, root, func(c *x509.Certificate) {
+			c.CRLDistributionPoints, c.OCSPServer = crlDPs, ocspServers
+		})
+		return [][]byte{cert.Raw, root.Raw}
+	}
+	crlDPs := []string{"http://crl.example.com/ca.crl"}
+	ocspServers := []string{"http://ocsp.example.com"}
+
+	var tests = []struct {
+		desc                  string
+		chain                 [][]byte
+		requireRevocationInfo bool
+		wantErr               bool
+	}{
+		{
+			desc:  "neither-not-required",
+			chain: leaf(nil, nil),
+		},
+		{
+			desc:                  "neither",
+			chain:                 leaf(nil, nil),
+			requireRevocationInfo: true,
+			wantErr:               true,
+		},
+		{
+			desc:                  "crl-only",
+			chain:                 leaf(crlDPs, nil),
+			requireRevocationInfo: true,
+		},
+		{
+			desc:                  "ocsp-only",
+			chain:                 leaf(nil, ocspServers),
+			requireRevocationInfo: true,
+		},
+		{
+			desc:                  "both",
+			chain:                 leaf(crlDPs, ocspServers),
+			requireRevocationInfo: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cv := chainValidator{
+				trustedRoots:          roots,
+				requireRevocationInfo: test.requireRevocationInfo,
+			}
+			gotPath, err := cv.validate(test.chain)
+			if err != nil {
+				if !test.wantErr {
+					t.Errorf("validate()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if test.wantErr {
+				t.Errorf("validate()=%v,%v; want _,non-nil", gotPath, err)
+			}
+		})
+	}
+}
+
+func TestRequireSKI(t *testing.T) {
+	roots := x509util.NewPEMCertPool()
+	if err := roots.AppendCertsFromPEMFile("../testdata/test_root_ca_cert.pem"); err != nil {
+		t.Fatalf("failed to load roots: %v", err)
+	}
+	if !roots.AppendCertsFromPEM([]byte(testdata.FakeCACertPEM)) {
+		t.Fatal("failed to load fake root")
+	}
+	// The leaf of leafWithoutSKI has no SubjectKeyIdentifier, but its
+	// intermediate has one. It's the other way round for
+	// intermediateWithoutSKI.
+	leafWithoutSKI := pemsToDERChain(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot})
+	intermediateWithoutSKI := pemsToDERChain(t, []string{testdata.LeafSignedByFakeIntermediateCertPEM, testdata.FakeIntermediateCertPEM})
+
+	var tests = []struct {
+		desc            string
+		chain           [][]byte
+		requireLeafSKI  bool
+		requireChainSKI bool
+		wantErr         string
+	}{
+		{
+			desc:  "leaf-without-ski-not-required",
+			chain: leafWithoutSKI,
+		},
+		{
+			desc:           "leaf-without-ski-leaf-required",
+			chain:          leafWithoutSKI,
+			requireLeafSKI: true,
+			wantErr:        "certificate without SubjectKeyIdentifier",
+		},
+		{
+			desc:            "leaf-without-ski-chain-required",
+			chain:           leafWithoutSKI,
+			requireChainSKI: true,
+			wantErr:         "without SubjectKeyIdentifier at position 0",
+		},
+		{
+			desc:  "intermediate-without-ski-not-required",
+			chain: intermediateWithoutSKI,
+		},
+		{
+			desc:           "intermediate-without-ski-leaf-required",
+			chain:          intermediateWithoutSKI,
+			requireLeafSKI: true,
+		},
+		{
+			desc:            "intermediate-without-ski-chain-required",
+			chain:           intermediateWithoutSKI,
+			requireChainSKI: true,
+			wantErr:         "without SubjectKeyIdentifier at position 1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cv := chainValidator{
+				trustedRoots:    roots,
+				requireLeafSKI:  test.requireLeafSKI,
+				requireChainSKI: test.requireChainSKI,
+			}
+			gotPath, err := cv.validate(test.chain)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("validate()=%v,%v; want _,err containing %q", gotPath, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestSerialNumberConstraints(t *testing.T) {
+	root := issueTestCert(t, nil, nil)
+	roots := root.pool()
+
+	// leaf returns a chain made of a leaf with the given serial number, and
+	// the root.
+	leaf := func(serial *big.Int) [][]byte {
+		t.Helper()
+		cert := issueTestCert(t, root, func(c *x509.Certificate) {
+			c.SerialNumber = serial
+		})
+		return [][]byte{cert.Raw, root.Raw}
+	}
+	// randomSerial is a 128-bit random serial number.
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("rand.Read()=%v", err)
+	}
+	b[0] |= 0x80
+	randomSerial := new(big.Int).SetBytes(b)
+	shortSerial := big.NewInt(0x1234567890)
+	// paddedSerial is a timestamp followed by zero bytes.
+	paddedSerial := new(big.Int).Lsh(big.NewInt(time.Now().Unix()), 64)
+
+	var tests = []struct {
+		desc                   string
+		chain                  [][]byte
+		minSerialNumberBits    int
+		rejectNonRandomSerials bool
+		requirePositiveSerials bool
+		wantErr                bool
+	}{
+		{
+			desc:  "short-no-limit",
+			chain: leaf(shortSerial),
+		},
+		{
+			desc:                "short",
+			chain:               leaf(shortSerial),
+			minSerialNumberBits: 64,
+			wantErr:             true,
+		},
+		{
+			desc:                "compliant",
+			chain:               leaf(randomSerial),
+			minSerialNumberBits: 64,
+		},
+		{
+			desc:                "at-limit",
+			chain:               leaf(new(big.Int).Lsh(big.NewInt(1), 63)),
+			minSerialNumberBits: 64,
+		},
+		{
+			desc:  "padded-accepted",
+			chain: leaf(paddedSerial),
+		},
+		{
+			desc:                   "padded",
+			chain:                  leaf(paddedSerial),
+			minSerialNumberBits:    64,
+			rejectNonRandomSerials: true,
+			wantErr:                true,
+		},
+		{
+			desc:                   "random",
+			chain:                  leaf(randomSerial),
+			minSerialNumberBits:    64,
+			rejectNonRandomSerials: true,
+		},
+		{
+			desc:  "zero-accepted",
+			chain: leaf(big.NewInt(0)),
+		},
+		{
+			desc:                   "zero",
+			chain:                  leaf(big.NewInt(0)),
+			requirePositiveSerials: true,
+			wantErr:                true,
+		},
+		{
+			desc:                   "positive",
+			chain:                  leaf(shortSerial),
+			requirePositiveSerials: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cv := chainValidator{
+				trustedRoots:           roots,
+				minSerialNumberBits:    test.minSerialNumberBits,
+				rejectNonRandomSerials: test.rejectNonRandomSerials,
+				requirePositiveSerials: test.requirePositiveSerials,
+			}
+			gotPath, err := cv.validate(test.chain)
+			if err != nil {
+				if !test.wantErr {
+					t.Errorf("validate()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if test.wantErr {
+				t.Errorf("validate()=%v,%v; want _,non-nil", gotPath, err)
+			}
+		})
+	}
+}
+
+func TestNonRandomSerial(t *testing.T) {
+	for _, tc := range []struct {
+		desc   string
+		serial *big.Int
+		want   bool
+	}{
+		{desc: "zero", serial: big.NewInt(0), want: true},
+		{desc: "negative", serial: big.NewInt(-42), want: true},
+		{desc: "small", serial: big.NewInt(42)},
+		{desc: "run-at-limit", serial: new(big.Int).SetBytes([]byte{0x12, 0, 0, 0, 0, 0x34})},
+		{desc: "run-above-limit", serial: new(big.Int).SetBytes([]byte{0x12, 0, 0, 0, 0, 0, 0x34}), want: true},
+		{desc: "trailing-run", serial: new(big.Int).SetBytes([]byte{0x12, 0xff, 0xff, 0xff, 0xff, 0xff}), want: true},
+		{desc: "leading-zeros", serial: new(big.Int).SetBytes([]byte{0, 0, 0, 0, 0, 0x12, 0x34})},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := nonRandomSerial(tc.serial); got != tc.want {
+				t.Errorf("nonRandomSerial(%x)=%t, want %t", tc.serial, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRejectExpiredChain(t *testing.T) {
+	now := time.Now()
+	// newCert returns a certificate for subject cn valid until notAfter,
+	// signed by parent, or self-signed if parent is nil.
+	newCert := func(cn string, isCA bool, notAfter time.Time, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+		t.Helper()
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("ecdsa.GenerateKey()=%v", err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(now.UnixNano()),
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             now.Add(-48 * time.Hour),
+			NotAfter:              notAfter,
+			BasicConstraintsValid: true,
+			IsCA:                  isCA,
+		}
+		if isCA {
+			tmpl.KeyUsage = x509.KeyUsageCertSign
+		} else {
+			tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		}
+		if parent == nil {
+			parent, parentKey = tmpl, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+		if err != nil {
+			t.Fatalf("x509.CreateCertificate()=%v", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("x509.ParseCertificate()=%v", err)
+		}
+		return cert, key
+	}
+	root, rootKey := newCert("Expiry Test Root", true, now.Add(time.Hour), nil, nil)
+	roots := x509util.NewPEMCertPool()
+	roots.AddCert(root)
+
+	expiredInt, expiredIntKey := newCert("Expired Intermediate", true, now.Add(-24*time.Hour), root, rootKey)
+	validInt, validIntKey := newCert("Valid Intermediate", true, now.Add(time.Hour), root, rootKey)
+	leafUnderExpired, _ := newCert("leaf.example.com", false, now.Add(time.Hour), expiredInt, expiredIntKey)
+	leafUnderValid, _ := newCert("leaf.example.com", false, now.Add(time.Hour), validInt, validIntKey)
+
+	var tests = []struct {
+		desc               string
+		chain              [][]byte
+		rejectExpiredChain bool
+		wantErr            bool
+	}{
+		{
+			desc:  "expired-intermediate-leaf-only",
+			chain: [][]byte{leafUnderExpired.Raw, expiredInt.Raw, root.Raw},
+		},
+		{
+			desc:               "expired-intermediate-whole-chain",
+			chain:              [][]byte{leafUnderExpired.Raw, expiredInt.Raw, root.Raw},
+			rejectExpiredChain: true,
+			wantErr:            true,
+		},
+		{
+			desc:               "valid-intermediate-whole-chain",
+			chain:              [][]byte{leafUnderValid.Raw, validInt.Raw, root.Raw},
+			rejectExpiredChain: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			cv := chainValidator{
+				trustedRoots:       roots,
+				rejectExpired:      true,
+				rejectExpiredChain: test.rejectExpiredChain,
+				currentTime:        now,
+			}
+			gotPath, err := cv.validate(test.chain)
+			if err != nil {
+				if !test.wantErr {
+					t.Errorf("validate()=%v,%v; want _,nil", gotPath, err)
+				}
+				return
+			}
+			if test.wantErr {
+				t.Errorf("validate()=%v,%v; want _,non-nil", gotPath, err)
+			}
+		})
+	}
+}
+
+func TestValidateMultipleRoots(t *testing.T) {
+	now := time.Now()
+	newKey := func() *ecdsa.PrivateKey {
+		t.Helper()
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("ecdsa.GenerateKey()=%v", err)
+		}
+		return key
+	}
+	newCert := func(tmpl, parent *x509.Certificate, key, parentKey *ecdsa.PrivateKey) *x509.Certificate {
+		t.Helper()
+		tmpl.NotBefore = now.Add(-time.Hour)
+		tmpl.NotAfter = now.Add(time.Hour)
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+		if err != nil {
+			t.Fatalf("x509.CreateCertificate()=%v", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("x509.ParseCertificate()=%v", err)
+		}
+		return cert
+	}
+	caTmpl := func(serial int64, cn string) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: cn},
+			KeyUsage:              x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+	}
+
+	// Two roots with the same subject and key, as when a root is re-issued:
+	// the intermediate chains up to both of them.
+	rootKey := newKey()
+	root1 := newCert(caTmpl(1, "Multiple Roots Test Root"), caTmpl(1, "Multiple Roots Test Root"), rootKey, rootKey)
+	root2 := newCert(caTmpl(2, "Multiple Roots Test Root"), caTmpl(2, "Multiple Roots Test Root"), rootKey, rootKey)
+	intKey := newKey()
+	intermediate := newCert(caTmpl(3, "Multiple Roots Test Intermediate"), root1, intKey, rootKey)
+	leaf := newCert(&x509.Certificate{
+		SerialNumber: big.NewInt(4),
+		Subject:      pkix.Name{CommonName: "leaf.example.com"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, intermediate, newKey(), intKey)
+
+	for _, test := range []struct {
+		desc     string
+		roots    []*x509.Certificate
+		chain    [][]byte
+		wantRoot *x509.Certificate
+	}{
+		{
+			desc:     "first-root",
+			roots:    []*x509.Certificate{root1, root2},
+			chain:    [][]byte{leaf.Raw, intermediate.Raw},
+			wantRoot: root1,
+		},
+		{
+			desc:     "first-root-reversed",
+			roots:    []*x509.Certificate{root2, root1},
+			chain:    [][]byte{leaf.Raw, intermediate.Raw},
+			wantRoot: root2,
+		},
+		{
+			desc:     "submitted-root",
+			roots:    []*x509.Certificate{root1, root2},
+			chain:    [][]byte{leaf.Raw, intermediate.Raw, root2.Raw},
+			wantRoot: root2,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			roots := x509util.NewPEMCertPool()
+			for _, r := range test.roots {
+				roots.AddCert(r)
+			}
+			cv := chainValidator{trustedRoots: roots}
+			// Validate repeatedly, since Verify doesn't guarantee the order
+			// in which it returns paths.
+			for range 20 {
+				gotPath, err := cv.validate(test.chain)
+				if err != nil {
+					t.Fatalf("validate()=%v, want nil", err)
+				}
+				if len(gotPath) != 3 {
+					t.Fatalf("validate() returned a path of length %d, want 3", len(gotPath))
+				}
+				if got := gotPath[2]; !got.Equal(test.wantRoot) {
+					t.Fatalf("validate() returned root with serial %v, want serial %v", got.SerialNumber, test.wantRoot.SerialNumber)
+				}
+			}
+		})
+	}
+}
+
+func TestRejectExpiredUnexpired(t *testing.T) {
+	fakeCARoots := x509util.NewPEMCertPool()
+	// Validity period: Jul 11, 2016 - Jul 11, 2017.
+	if !fakeCARoots.AppendCertsFromPEM([]byte(testdata.FakeCACertPEM)) {
+		t.Fatal("failed to load fake root")
+	}
+	// Validity period: May 13, 2016 - Jul 12, 2019.
+	chain := pemsToDERChain(t, []string{testdata.LeafSignedByFakeIntermediateCertPEM, testdata.FakeIntermediateCertPEM})
+	opts := chainValidator{
+		trustedRoots: fakeCARoots,
+		extKeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	beforeValidPeriod := time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC)
+	currentValidPeriod := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	afterValidPeriod := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	// NotAfter bounds around and after the NotAfter date of the leaf.
+	boundsStart := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	boundsLimit := time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC)
+	laterBoundsStart := time.Date(2019, 8, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		desc            string
+		rejectExpired   bool
+		rejectUnexpired bool
+		notAfterStart   *time.Time
+		notAfterLimit   *time.Time
+		now             time.Time
+		wantErr         string
+	}{
+		// No flags: accept anything.
+		{
+			desc: "no-reject-current",
+			now:  currentValidPeriod,
+		},
+		{
+			desc: "no-reject-after",
+			now:  afterValidPeriod,
+		},
+		{
+			desc: "no-reject-before",
+			now:  beforeValidPeriod,
+		},
+		// Reject-Expired: only allow currently-valid and not yet valid
+		{
+			desc:          "reject-expired-current",
+			rejectExpired: true,
+			now:           currentValidPeriod,
+		},
+		{
+			desc:          "reject-expired-after",
+			rejectExpired: true,
+			now:           afterValidPeriod,
+			wantErr:       "rejecting expired certificate",
+		},
+		{
+			desc:          "reject-expired-before",
+			rejectExpired: true,
+			now:           beforeValidPeriod,
+		},
+		// Reject-Unexpired: only allow expired
+		{
+			desc:            "reject-non-expired-after",
+			rejectUnexpired: true,
+			now:             afterValidPeriod,
+		},
+		{
+			desc:            "reject-non-expired-before",
+			rejectUnexpired: true,
+			now:             beforeValidPeriod,
+			wantErr:         "rejecting unexpired certificate",
+		},
+		{
+			desc:            "reject-non-expired-current",
+			rejectUnexpired: true,
+			now:             currentValidPeriod,
+			wantErr:         "rejecting unexpired certificate",
+		},
+		// Reject-Unexpired with NotAfter bounds: only allow expired, within
+		// the bounds.
+		{
+			desc:            "reject-non-expired-after-within-bounds",
+			rejectUnexpired: true,
+			notAfterStart:   &boundsStart,
+			notAfterLimit:   &boundsLimit,
+			now:             afterValidPeriod,
+		},
+		{
+			desc:            "reject-non-expired-after-outside-bounds",
+			rejectUnexpired: true,
+			notAfterStart:   &laterBoundsStart,
+			now:             afterValidPeriod,
+			wantErr:         "certificate NotAfter",
+		},
+		{
+			desc:            "reject-non-expired-before-within-bounds",
+			rejectUnexpired: true,
+			notAfterStart:   &boundsStart,
+			notAfterLimit:   &boundsLimit,
+			now:             beforeValidPeriod,
+			wantErr:         "rejecting unexpired certificate",
+		},
+		{
+			desc:            "reject-non-expired-current-within-bounds",
+			rejectUnexpired: true,
+			notAfterStart:   &boundsStart,
+			notAfterLimit:   &boundsLimit,
+			now:             currentValidPeriod,
+			wantErr:         "rejecting unexpired certificate",
+		},
+		// Reject-Expired AND Reject-Unexpired: nothing allowed
+		{
+			desc:            "reject-all-after",
+			rejectExpired:   true,
+			rejectUnexpired: true,
+			now:             afterValidPeriod,
+			wantErr:         "rejecting expired certificate",
+		},
+		{
+			desc:            "reject-all-before",
+			rejectExpired:   true,
+			rejectUnexpired: true,
+			now:             beforeValidPeriod,
+			wantErr:         "rejecting unexpired certificate",
+		},
+		{
+			desc:            "reject-all-current",
+			rejectExpired:   true,
+			rejectUnexpired: true,
+			now:             currentValidPeriod,
+			wantErr:         "rejecting unexpired certificate",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts.currentTime = tc.now
+			opts.rejectExpired = tc.rejectExpired
+			opts.rejectUnexpired = tc.rejectUnexpired
+			opts.notAfterStart = tc.notAfterStart
+			opts.notAfterLimit = tc.notAfterLimit
+			_, err := opts.validate(chain)
+			if err != nil {
+				if len(tc.wantErr) == 0 {
+					t.Errorf("ValidateChain()=_,%v; want _,nil", err)
+				} else if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("ValidateChain()=_,%v; want err containing %q", err, tc.wantErr)
+				}
+			} else if len(tc.wantErr) != 0 {
+				t.Errorf("ValidateChain()=_,nil; want err containing %q", tc.wantErr)
+			}
+		})
+	}
+}
+
+// orderTestRoots returns the trusted roots of the chains used to test the
+// order of validation checks.
+func orderTestRoots(t testing.TB) *x509util.PEMCertPool {
+	t.Helper()
+	roots := x509util.NewPEMCertPool()
+	if err := roots.AppendCertsFromPEMFile("../testdata/test_root_ca_cert.pem"); err != nil {
+		t.Fatalf("failed to load roots: %v", err)
+	}
+	if ok := roots.AppendCertsFromPEM([]byte(testdata.CACertPEM)); !ok {
+		t.Fatalf("failed to parse root cert")
+	}
+	return roots
+}
+
+func TestValidateOrder(t *testing.T) {
+	roots := orderTestRoots(t)
+	precertNotBefore := pemToCert(t, testdata.PreCertFromIntermediate).NotBefore
+	past := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	chains := map[string][][]byte{
+		"cert":       pemsToDERChain(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot}),
+		"precert":    pemsToDERChain(t, []string{testdata.PreCertFromIntermediate, testdata.IntermediateFromRoot}),
+		"pre-issued": pemsToDERChain(t, []string{testdata.PreCertFromPreIntermediate, testdata.PreIntermediateFromRoot, testdata.CACertPEM}),
+		"misordered": pemsToDERChain(t, []string{testdata.IntermediateFromRoot, testdata.CertFromIntermediate}),
+		"untrusted":  pemsToDERChain(t, []string{testdata.LeafSignedByFakeIntermediateCertPEM, testdata.FakeIntermediateCertPEM}),
+		"malformed":  {[]byte("not a certificate")},
+	}
+	validators := map[string]chainValidator{
+		"default": {trustedRoots: roots},
+		"max-precert-age": {
+			trustedRoots:  roots,
+			currentTime:   precertNotBefore.Add(48 * time.Hour),
+			maxPrecertAge: 24 * time.Hour,
+		},
+		"not-after-limit":           {trustedRoots: roots, notAfterLimit: &past},
+		"require-embedded-scts":     {trustedRoots: roots, requireEmbeddedSCTs: true},
+		"reject-precerts-with-scts": {trustedRoots: roots, rejectPrecertsWithSCTs: true},
+		"reject-expired":            {trustedRoots: roots, rejectExpired: true},
+	}
+
+	accepted, rejected := 0, 0
+	for cvDesc, cv := range validators {
+		for chainDesc, chain := range chains {
+			for _, isPrecert := range []bool{false, true} {
+				t.Run(fmt.Sprintf("%s/%s/precert=%t", cvDesc, chainDesc, isPrecert), func(t *testing.T) {
+					req := rfc6962.AddChainRequest{Chain: chain}
+					gotPath, gotErr := cv.Validate(req, isPrecert)
+					pathFirst := cv
+					pathFirst.pathFirst = true
+					wantPath, wantErr := pathFirst.Validate(req, isPrecert)
+
+					if (gotErr != nil) != (wantErr != nil) {
+						t.Fatalf("Validate()=%v, with path verification first: %v", gotErr, wantErr)
+					}
+					if !slices.EqualFunc(gotPath, wantPath, (*x509.Certificate).Equal) {
+						t.Errorf("Validate() path differs from the one with path verification first")
+					}
+					if gotErr == nil {
+						accepted++
+					} else {
+						rejected++
+					}
+				})
+			}
+		}
+	}
+	if accepted == 0 || rejected == 0 {
+		t.Errorf("%d chains accepted and %d rejected, want some of both", accepted, rejected)
+	}
+}
+
+func BenchmarkValidate(b *testing.B) {
+	roots := orderTestRoots(b)
+	certChain := pemsToDERChain(b, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot})
+	precertChain := pemsToDERChain(b, []string{testdata.PreCertFromIntermediate, testdata.IntermediateFromRoot})
+	precertNotBefore := pemToCert(b, testdata.PreCertFromIntermediate).NotBefore
+
+	for _, bc := range []struct {
+		desc      string
+		chain     [][]byte
+		isPrecert bool
+	}{
+		{
+			desc:  "valid-cert",
+			chain: certChain,
+		},
+		{
+			desc:  "wrong-entry-type",
+			chain: precertChain,
+		},
+		{
+			desc:      "stale-precert",
+			chain:     precertChain,
+			isPrecert: true,
+		},
+		{
+			desc:  "malformed",
+			chain: [][]byte{[]byte("not a certificate")},
+		},
+	} {
+		for _, pathFirst := range []bool{false, true} {
+			b.Run(fmt.Sprintf("%s/path-first=%t", bc.desc, pathFirst), func(b *testing.B) {
+				cv := chainValidator{
+					trustedRoots:  roots,
+					currentTime:   precertNotBefore.Add(48 * time.Hour),
+					maxPrecertAge: 24 * time.Hour,
+					pathFirst:     pathFirst,
+				}
+				req := rfc6962.AddChainRequest{Chain: bc.chain}
+				for b.Loop() {
+					_, _ = cv.Validate(req, bc.isPrecert)
+				}
+			})
+		}
+	}
+}
+
+// Builds a chain of DER-encoded certs.
+// Note: ordering is important
+func pemsToDERChain(t testing.TB, pemCerts []string) [][]byte {
+	t.Helper()
+	chain := make([][]byte, 0, len(pemCerts))
+	for _, pemCert := range pemCerts {
+		cert := pemToCert(t, pemCert)
+		chain = append(chain, cert.Raw)
+	}
+	return chain
+}
+
+func pemToCert(t testing.TB, pemData string) *x509.Certificate {
+	t.Helper()
+	bytes, rest := pem.Decode([]byte(pemData))
+	if len(rest) > 0 {
+		t.Fatalf("Extra data after PEM: %v", rest)
+		return nil
+	}
+
+	cert, err := x509.ParseCertificate(bytes.Bytes)
+	if err != nil {
+		t.Fatalf("x509.ParseCertificate(): %v", err)
+	}
+
+	return cert
+}
+
 func pemFileToDERChain(t *testing.T, filename string) [][]byte {
 	t.Helper()
 	rawChain, err := x509util.ReadPossiblePEMFile(filename, "CERTIFICATE")
@@ -713,3 +2778,259 @@ func TestPreIssuedCert(t *testing.T) {
 		})
 	}
 }
+
+func TestPreIssuerLinkage(t *testing.T) {
+	preIssuerEKU := pkix.Extension{Id: rfc6962.OIDExtKeyUsageCertificateTransparency}
+	poison := pkix.Extension{Id: rfc6962.OIDExtensionCTPoison, Critical: true, Value: asn1.NullBytes}
+
+	// issue returns a certificate for tmpl, issued by parent with parentKey, or
+	// self-signed if parent is nil, and its private key.
+	issue := func(tmpl, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+		t.Helper()
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("ecdsa.GenerateKey()=%v", err)
+		}
+		if parent == nil {
+			parent, parentKey = tmpl, key
+		}
+		tmpl.NotBefore = time.Now().Add(-time.Hour)
+		tmpl.NotAfter = time.Now().Add(time.Hour)
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+		if err != nil {
+			t.Fatalf("x509.CreateCertificate()=%v", err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("x509.ParseCertificate()=%v", err)
+		}
+		return cert, key
+	}
+	ca := func(serial int64, name string, exts ...pkix.Extension) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: name},
+			KeyUsage:              x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+			ExtraExtensions:       exts,
+		}
+	}
+	precert := &x509.Certificate{
+		SerialNumber:    big.NewInt(100),
+		Subject:         pkix.Name{CommonName: "leaf.example.com"},
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		ExtraExtensions: []pkix.Extension{poison},
+	}
+
+	root, rootKey := issue(ca(1, "Linkage Test Root"), nil, nil)
+	preIssuer, preIssuerKey := issue(ca(2, "Precert Signer", preIssuerEKU), root, rootKey)
+	subPreIssuer, subPreIssuerKey := issue(ca(3, "Sub Precert Signer", preIssuerEKU), preIssuer, preIssuerKey)
+	rootPreIssuer, rootPreIssuerKey := issue(ca(4, "Root Precert Signer", preIssuerEKU), nil, nil)
+
+	roots := x509util.NewPEMCertPool()
+	roots.AddCert(root)
+	roots.AddCert(rootPreIssuer)
+	if ok := roots.AppendCertsFromPEM([]byte(testdata.CACertPEM)); !ok {
+		t.Fatalf("failed to parse root cert")
+	}
+
+	// chain returns a chain made of a precertificate issued by issuer with
+	// key, followed by certs.
+	chain := func(issuer *x509.Certificate, key *ecdsa.PrivateKey, certs ...*x509.Certificate) [][]byte {
+		t.Helper()
+		leaf, _ := issue(precert, issuer, key)
+		raw := [][]byte{leaf.Raw}
+		for _, c := range certs {
+			raw = append(raw, c.Raw)
+		}
+		return raw
+	}
+
+	for _, tc := range []struct {
+		desc    string
+		chain   [][]byte
+		wantErr string
+	}{
+		{
+			desc:  "precert-from-root",
+			chain: chain(root, rootKey),
+		},
+		{
+			desc:  "precert-from-pre-issuer",
+			chain: chain(preIssuer, preIssuerKey, preIssuer, root),
+		},
+		{
+			desc:  "testdata-precert-from-pre-issuer",
+			chain: pemsToDERChain(t, []string{testdata.PreCertFromPreIntermediate, testdata.PreIntermediateFromRoot}),
+		},
+		{
+			desc:    "pre-issuer-is-root",
+			chain:   chain(rootPreIssuer, rootPreIssuerKey, rootPreIssuer),
+			wantErr: "is a trusted root",
+		},
+		{
+			desc:    "pre-issuer-from-pre-issuer",
+			chain:   chain(subPreIssuer, subPreIssuerKey, subPreIssuer, preIssuer, root),
+			wantErr: "may only directly issue precertificates",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			cv := chainValidator{trustedRoots: roots}
+			_, err := cv.Validate(rfc6962.AddChainRequest{Chain: tc.chain}, true)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate()=%v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Validate()=%v, want err containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestIgnoreExtraCerts(t *testing.T) {
+	roots := x509util.NewPEMCertPool()
+	if ok := roots.AppendCertsFromPEM([]byte(testdata.CACertPEM)); !ok {
+		t.Fatalf("failed to parse root cert")
+	}
+	leaf := pemToCert(t, testdata.CertFromIntermediate)
+	intermediate := pemToCert(t, testdata.IntermediateFromRoot)
+	root := pemToCert(t, testdata.CACertPEM)
+	wantPath := []*x509.Certificate{leaf, intermediate, root}
+
+	for _, tc := range []struct {
+		desc        string
+		chain       []string
+		wantStrict  bool
+		wantLenient bool
+	}{
+		{
+			desc:        "no-extra-cert",
+			chain:       []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot},
+			wantStrict:  true,
+			wantLenient: true,
+		},
+		{
+			desc:        "extra-cert-at-end",
+			chain:       []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.FakeIntermediateCertPEM},
+			wantLenient: true,
+		},
+		{
+			desc:        "extra-cert-in-the-middle",
+			chain:       []string{testdata.CertFromIntermediate, testdata.FakeIntermediateCertPEM, testdata.IntermediateFromRoot, testdata.CACertPEM},
+			wantLenient: true,
+		},
+		{
+			desc:  "extra-cert-after-root",
+			chain: []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM, testdata.FakeIntermediateCertPEM},
+		},
+		{
+			desc:  "extra-cert-instead-of-intermediate",
+			chain: []string{testdata.CertFromIntermediate, testdata.FakeIntermediateCertPEM},
+		},
+	} {
+		for _, ignoreExtraCerts := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/ignore-extra-certs=%t", tc.desc, ignoreExtraCerts), func(t *testing.T) {
+				cv := chainValidator{
+					trustedRoots:     roots,
+					ignoreExtraCerts: ignoreExtraCerts,
+				}
+				want := tc.wantStrict
+				if ignoreExtraCerts {
+					want = tc.wantLenient
+				}
+				gotPath, err := cv.validate(pemsToDERChain(t, tc.chain))
+				if !want {
+					if err == nil {
+						t.Errorf("validate()=%v, want error", gotPath)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("validate()=%v, want nil", err)
+				}
+				if !slices.EqualFunc(gotPath, wantPath, (*x509.Certificate).Equal) {
+					t.Errorf("validate() returned a path of %d certs, want the leaf, intermediate and root", len(gotPath))
+				}
+			})
+		}
+	}
+}
+
+func TestCrossSignedIssuerKeyHash(t *testing.T) {
+	root := issueTestCert(t, nil, nil)
+	roots := root.pool()
+
+	// Two intermediates with the same subject and key, issued separately.
+	intermediate := func(c *x509.Certificate) {
+		c.Subject = pkix.Name{CommonName: "Intermediate"}
+		c.KeyUsage, c.ExtKeyUsage = x509.KeyUsageCertSign, nil
+		c.BasicConstraintsValid, c.IsCA = true, true
+	}
+	intA := issueTestCert(t, root, intermediate)
+	intBTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(3 * time.Hour),
+	}
+	intermediate(intBTmpl)
+	intBDER, err := x509.CreateCertificate(rand.Reader, intBTmpl, root.Certificate, intA.key.Public(), root.key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate()=%v", err)
+	}
+	intB, err := x509.ParseCertificate(intBDER)
+	if err != nil {
+		t.Fatalf("x509.ParseCertificate()=%v", err)
+	}
+
+	leaf := issueTestCert(t, intA, func(c *x509.Certificate) {
+		c.SerialNumber = big.NewInt(4)
+		c.ExtraExtensions = []pkix.Extension{{Id: rfc6962.OIDExtensionCTPoison, Critical: true, Value: asn1.NullBytes}}
+	})
+	wantIssuerKeyHash := sha256.Sum256(intA.RawSubjectPublicKeyInfo)
+
+	cv := chainValidator{
+		trustedRoots:     roots,
+		ignoreExtraCerts: true,
+	}
+	for _, tc := range []struct {
+		desc       string
+		chain      [][]byte
+		wantIssuer *x509.Certificate
+	}{
+		{
+			desc:       "a-first",
+			chain:      [][]byte{leaf.Raw, intA.Raw, intB.Raw, root.Raw},
+			wantIssuer: intA.Certificate,
+		},
+		{
+			desc:       "b-first",
+			chain:      [][]byte{leaf.Raw, intB.Raw, intA.Raw, root.Raw},
+			wantIssuer: intB,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			// Verify can return paths in any order: the selected path and
+			// the issuer key hash must not depend on it.
+			for range 20 {
+				chain, err := cv.Validate(rfc6962.AddChainRequest{Chain: tc.chain}, true)
+				if err != nil {
+					t.Fatalf("Validate()=%v, want nil", err)
+				}
+				if !chain[1].Equal(tc.wantIssuer) {
+					t.Fatalf("Validate() picked issuer with serial %v, want %v", chain[1].SerialNumber, tc.wantIssuer.SerialNumber)
+				}
+				entry, err := x509util.EntryFromChain(chain, true, 0)
+				if err != nil {
+					t.Fatalf("EntryFromChain()=%v", err)
+				}
+				if got, want := entry.IssuerKeyHash, wantIssuerKeyHash[:]; !slices.Equal(got, want) {
+					t.Fatalf("IssuerKeyHash=%x, want %x", got, want)
+				}
+			}
+		})
+	}
+}
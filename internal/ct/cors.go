@@ -0,0 +1,74 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+const (
+	corsOriginHeader       = "Origin"
+	corsAllowOriginHeader  = "Access-Control-Allow-Origin"
+	corsAllowMethodsHeader = "Access-Control-Allow-Methods"
+	corsAllowHeadersHeader = "Access-Control-Allow-Headers"
+	// corsAnyOrigin allows cross-origin requests from any origin.
+	corsAnyOrigin = "*"
+)
+
+// ValidateCORSAllowedOrigins checks that the keys of origins are names of
+// entrypoints, such as "GetRoots", and that their origins are not empty.
+func ValidateCORSAllowedOrigins(origins map[string][]string) error {
+	for name, allowed := range origins {
+		if !slices.Contains(entrypoints, name) {
+			return fmt.Errorf("unknown entrypoint: %q", name)
+		}
+		if len(allowed) == 0 || slices.Contains(allowed, "") {
+			return fmt.Errorf("%s: empty origin", name)
+		}
+	}
+	return nil
+}
+
+// setCORSHeaders sets the CORS headers of the response to r, if the
+// entrypoint of a allows cross-origin requests from the origin of r. It
+// returns whether it did.
+func (a appHandler) setCORSHeaders(w http.ResponseWriter, r *http.Request) bool {
+	allowed := a.opts.CORSAllowedOrigins[a.name]
+	if len(allowed) == 0 {
+		return false
+	}
+	if slices.Contains(allowed, corsAnyOrigin) {
+		w.Header().Set(corsAllowOriginHeader, corsAnyOrigin)
+		return true
+	}
+	// The response depends on the origin of the request, caches must not
+	// serve it to other origins.
+	w.Header().Add("Vary", corsOriginHeader)
+	origin := r.Header.Get(corsOriginHeader)
+	if origin == "" || !slices.Contains(allowed, origin) {
+		return false
+	}
+	w.Header().Set(corsAllowOriginHeader, origin)
+	return true
+}
+
+// serveCORSPreflight answers a CORS preflight request to the entrypoint of a.
+func (a appHandler) serveCORSPreflight(w http.ResponseWriter) {
+	w.Header().Set(corsAllowMethodsHeader, a.method)
+	w.Header().Set(corsAllowHeadersHeader, contentTypeHeader)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -4,13 +4,18 @@ import (
 	"context"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"slices"
 
 	"github.com/transparency-dev/tesseract/internal/types/rfc6962"
 	"github.com/transparency-dev/tesseract/storage"
 	"github.com/transparency-dev/tessera/ctonly"
+	"golang.org/x/mod/sumdb/note"
+	"golang.org/x/time/rate"
 	"k8s.io/klog/v2"
 )
 
@@ -20,12 +25,40 @@ type log struct {
 	// origin identifies the log. It will be used in its checkpoint, and
 	// is also its submission prefix, as per https://c2sp.org/static-ct-api.
 	origin string
+	// logID is the SHA-256 hash of the log's public key SPKI, as per RFC 6962 s3.2.
+	logID [sha256.Size]byte
 	// signSCT Signs SCTs.
 	signSCT signSCT
 	// chainValidator validates incoming chains.
 	chainValidator ChainValidator
 	// storage stores certificate data.
 	storage Storage
+	// inflightAdds tracks concurrent calls to storage.Add.
+	inflightAdds inflightTracker
+	// inflightRequests tracks in-flight add-chain and add-pre-chain requests.
+	inflightRequests requestTracker
+	// rejections samples recently rejected submissions. nil if disabled.
+	rejections *rejectionSamples
+	// submissions caches the SCTs issued for recent submissions. nil if
+	// disabled.
+	submissions *submissionCache
+	// precerts holds the NotAfter of recently logged precertificates. nil if
+	// final certificates are not checked against them.
+	precerts *precertCache
+	// intermediates records the intermediates stored in issuer storage, per
+	// root. nil if they are not served by get-roots.
+	intermediates *knownIntermediates
+	// queue holds the submissions waiting to be sequenced in queue mode. nil
+	// if submissions are sequenced as they come.
+	queue *SubmissionQueue
+	// entrypoints lists the public entrypoints served for the log.
+	entrypoints []entrypointInfo
+	// submissionLimiter limits the rate of add-chain and add-pre-chain
+	// requests to the log. nil if disabled.
+	submissionLimiter *rate.Limiter
+	// validations is a semaphore bounding the number of chains being
+	// validated with a timeout. nil if there is no validation timeout.
+	validations chan struct{}
 }
 
 // signSCT builds an SCT for a leaf.
@@ -37,6 +70,13 @@ type Storage interface {
 	Add(context.Context, *ctonly.Entry) (idx uint64, timestamp uint64, err error)
 	// AddIssuerChain stores every the chain certificate in a content-addressable store under their sha256 hash.
 	AddIssuerChain(context.Context, []*x509.Certificate) error
+	// ReadEntry returns the raw static-ct-api entry at index, once it has been integrated.
+	ReadEntry(ctx context.Context, index uint64) ([]byte, error)
+	// ReadCheckpoint returns the latest checkpoint of the log.
+	ReadCheckpoint(ctx context.Context) ([]byte, error)
+	// ReadIssuer returns the DER issuer certificate stored under its SHA-256
+	// fingerprint, or an error wrapping os.ErrNotExist if there is none.
+	ReadIssuer(ctx context.Context, fingerprint []byte) ([]byte, error)
 }
 
 // ChainValidator provides functions to validate incoming chains.
@@ -50,7 +90,11 @@ type ChainValidator interface {
 //   - checkpoint signer
 //   - SCT signer
 //   - storage, used to persist chains
-func NewLog(ctx context.Context, origin string, signer crypto.Signer, cv ChainValidator, cs storage.CreateStorage, ts TimeSource) (*log, error) {
+//
+// Checkpoints are also signed by additionalCpSigners, for instance with both
+// the old and new keys during the overlap window of a checkpoint key rotation.
+// SCTs are only signed by signer.
+func NewLog(ctx context.Context, origin string, signer crypto.Signer, cv ChainValidator, cs storage.CreateStorage, ts TimeSource, additionalCpSigners ...crypto.Signer) (*log, error) {
 	log := &log{}
 
 	if origin == "" {
@@ -68,7 +112,15 @@ func NewLog(ctx context.Context, origin string, signer crypto.Signer, cv ChainVa
 		return nil, fmt.Errorf("unsupported key type: %v", keyType)
 	}
 
-	sctSigner := &sctSigner{signer: signer}
+	logID, err := getCTLogID(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get log ID: %v", err)
+	}
+	log.logID = logID
+	// Log the key fingerprint, so that operators can check the right key is loaded.
+	klog.Infof("%s: signer public key SPKI SHA-256: %x, log ID: %s", origin, logID, base64.StdEncoding.EncodeToString(logID[:]))
+
+	sctSigner := &sctSigner{signer: signer, origin: origin}
 	log.signSCT = sctSigner.Sign
 
 	log.chainValidator = cv
@@ -77,8 +129,19 @@ func NewLog(ctx context.Context, origin string, signer crypto.Signer, cv ChainVa
 	if err != nil {
 		klog.Exitf("failed to create checkpoint Signer: %v", err)
 	}
+	var additionalSigners []note.Signer
+	for _, s := range additionalCpSigners {
+		if _, ok := s.Public().(*ecdsa.PublicKey); !ok {
+			return nil, fmt.Errorf("unsupported additional checkpoint key type: %T", s.Public())
+		}
+		ns, err := NewCpSigner(s, origin, ts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create additional checkpoint signer: %v", err)
+		}
+		additionalSigners = append(additionalSigners, ns)
+	}
 
-	storage, err := cs(ctx, cpSigner)
+	storage, err := cs(ctx, cpSigner, additionalSigners...)
 	if err != nil {
 		klog.Exitf("failed to initiate storage backend: %v", err)
 	}
@@ -86,3 +149,14 @@ func NewLog(ctx context.Context, origin string, signer crypto.Signer, cv ChainVa
 
 	return log, nil
 }
+
+// LogID returns the log ID, which is the SHA-256 hash of the log's public key
+// SPKI.
+func (l *log) LogID() [sha256.Size]byte {
+	return l.logID
+}
+
+// Roots returns a copy of the list of roots accepted by the log.
+func (l *log) Roots() []*x509.Certificate {
+	return slices.Clone(l.chainValidator.Roots())
+}
@@ -1,22 +1,34 @@
 package ct
 
 import (
+	"bytes"
 	"context"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"path"
 	"strings"
 	"testing"
 
+	tfl "github.com/transparency-dev/formats/log"
+	tdnote "github.com/transparency-dev/formats/note"
+	"github.com/transparency-dev/tesseract/internal/types/rfc6962"
 	"github.com/transparency-dev/tesseract/internal/x509util"
 	"github.com/transparency-dev/tesseract/storage"
 	"golang.org/x/mod/sumdb/note"
+	"k8s.io/klog/v2"
 )
 
 func TestNewLog(t *testing.T) {
@@ -73,7 +85,7 @@ func TestNewLog(t *testing.T) {
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
 			log, err := NewLog(ctx, tc.origin, tc.signer, tc.cv,
-				func(_ context.Context, _ note.Signer) (*storage.CTStorage, error) {
+				func(_ context.Context, _ note.Signer, _ ...note.Signer) (*storage.CTStorage, error) {
 					return &storage.CTStorage{}, nil
 				}, &FixedTimeSource{})
 			if len(tc.wantErr) == 0 && err != nil {
@@ -89,6 +101,136 @@ func TestNewLog(t *testing.T) {
 	}
 }
 
+func TestNewLogLogID(t *testing.T) {
+	signer, err := loadPEMPrivateKey("../testdata/test_ct_server_ecdsa_private_key.pem")
+	if err != nil {
+		t.Fatalf("Can't open key: %v", err)
+	}
+	spki, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		t.Fatalf("x509.MarshalPKIXPublicKey()=%v", err)
+	}
+	want := sha256.Sum256(spki)
+
+	var buf bytes.Buffer
+	klog.LogToStderr(false)
+	klog.SetOutput(&buf)
+	defer klog.LogToStderr(true)
+
+	log, err := NewLog(t.Context(), "testlog", signer, chainValidator{},
+		func(_ context.Context, _ note.Signer, _ ...note.Signer) (*storage.CTStorage, error) {
+			return &storage.CTStorage{}, nil
+		}, &FixedTimeSource{})
+	if err != nil {
+		t.Fatalf("NewLog()=%v, want nil", err)
+	}
+	klog.Flush()
+
+	if got := log.LogID(); got != want {
+		t.Errorf("LogID()=%x, want %x", got, want)
+	}
+	if got, want := buf.String(), fmt.Sprintf("SPKI SHA-256: %x, log ID: %s", want, base64.StdEncoding.EncodeToString(want[:])); !strings.Contains(got, want) {
+		t.Errorf("NewLog() logs %q, want them to contain %q", got, want)
+	}
+}
+
+func TestNewLogAdditionalCheckpointSigners(t *testing.T) {
+	const origin = "example.com/log"
+	oldKey, err := loadPEMPrivateKey("../testdata/test_ct_server_ecdsa_private_key.pem")
+	if err != nil {
+		t.Fatalf("Can't open key: %v", err)
+	}
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+
+	// Capture the checkpoint signers, as the storage would use them.
+	var signers []note.Signer
+	_, err = NewLog(t.Context(), origin, oldKey, chainValidator{},
+		func(_ context.Context, signer note.Signer, additionalSigners ...note.Signer) (*storage.CTStorage, error) {
+			signers = append([]note.Signer{signer}, additionalSigners...)
+			return &storage.CTStorage{}, nil
+		}, newFakeTimeSource(fixedTime), newKey)
+	if err != nil {
+		t.Fatalf("NewLog()=%v, want nil", err)
+	}
+	if got, want := len(signers), 2; got != want {
+		t.Fatalf("got %d checkpoint signers, want %d", got, want)
+	}
+
+	cp := tfl.Checkpoint{Origin: origin, Size: 123, Hash: make([]byte, sha256.Size)}
+	msg, err := note.Sign(&note.Note{Text: string(cp.Marshal())}, signers...)
+	if err != nil {
+		t.Fatalf("note.Sign()=%v", err)
+	}
+	// During the overlap window, clients trusting either key must accept the
+	// checkpoint.
+	for i, key := range []crypto.Signer{oldKey, newKey} {
+		ns, err := NewCpSigner(key, origin, newFakeTimeSource(fixedTime))
+		if err != nil {
+			t.Fatalf("NewCpSigner()=%v", err)
+		}
+		verifier, err := tdnote.NewRFC6962Verifier(ns.(*cpSigner).VerifierKey())
+		if err != nil {
+			t.Fatalf("NewRFC6962Verifier()=%v", err)
+		}
+		if _, err := note.Open(msg, note.VerifierList(verifier)); err != nil {
+			t.Errorf("key %d: note.Open()=%v, want nil", i, err)
+		}
+	}
+}
+
+func TestNewLogAdditionalCheckpointSignerType(t *testing.T) {
+	signer, err := loadPEMPrivateKey("../testdata/test_ct_server_ecdsa_private_key.pem")
+	if err != nil {
+		t.Fatalf("Can't open key: %v", err)
+	}
+	rsaSigner, err := loadPEMPrivateKey("../testdata/test_ct_server_rsa_private_key.pem")
+	if err != nil {
+		t.Fatalf("Can't open key: %v", err)
+	}
+
+	_, err = NewLog(t.Context(), "testlog", signer, chainValidator{},
+		func(_ context.Context, _ note.Signer, _ ...note.Signer) (*storage.CTStorage, error) {
+			return &storage.CTStorage{}, nil
+		}, &FixedTimeSource{}, rsaSigner)
+	if err == nil || !strings.Contains(err.Error(), "unsupported additional checkpoint key type") {
+		t.Errorf("NewLog()=%v, want unsupported key type error", err)
+	}
+}
+
+func TestLogRoots(t *testing.T) {
+	log := setupFakeStorageLog(t, &fakeStorage{})
+	server := setupTestServer(t, log, path.Join(prefix, rfc6962.GetRootsPath))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + path.Join(prefix, rfc6962.GetRootsPath))
+	if err != nil {
+		t.Fatalf("Failed to get roots: %v", err)
+	}
+	var rsp rfc6962.GetRootsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	roots := log.Roots()
+	if got, want := len(roots), len(rsp.Certificates); got != want {
+		t.Fatalf("len(Roots())=%d, want %d", got, want)
+	}
+	for i, root := range roots {
+		if got, want := base64.StdEncoding.EncodeToString(root.Raw), rsp.Certificates[i]; got != want {
+			t.Errorf("Roots()[%d]=%s, want %s", i, got, want)
+		}
+	}
+
+	// Modifying the returned list must not modify the log's roots.
+	roots[0] = nil
+	if log.Roots()[0] == nil {
+		t.Error("Roots() returned the log's internal list of roots")
+	}
+}
+
 func loadPEMPrivateKey(path string) (crypto.Signer, error) {
 	keyBytes, err := os.ReadFile(path)
 	if err != nil {
@@ -0,0 +1,58 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"fmt"
+)
+
+// FreeDiskSpaceFunc returns the number of bytes available to unprivileged
+// users on the filesystem holding path.
+type FreeDiskSpaceFunc func(path string) (uint64, error)
+
+// insufficientDiskSpaceError is returned when a filesystem holding log data
+// is running out of space.
+type insufficientDiskSpaceError struct {
+	path      string
+	available uint64
+	min       uint64
+}
+
+func (e insufficientDiskSpaceError) Error() string {
+	return fmt.Sprintf("%d bytes available for %q, want at least %d", e.available, e.path, e.min)
+}
+
+// checkDiskSpace checks that at least opts.MinFreeDiskSpace bytes are
+// available on each of opts.DiskSpacePaths. It returns an
+// insufficientDiskSpaceError if not.
+func (opts *HandlerOptions) checkDiskSpace() error {
+	if opts.MinFreeDiskSpace == 0 {
+		return nil
+	}
+	freeDiskSpace := opts.FreeDiskSpace
+	if freeDiskSpace == nil {
+		freeDiskSpace = statfsFreeDiskSpace
+	}
+	for _, path := range opts.DiskSpacePaths {
+		available, err := freeDiskSpace(path)
+		if err != nil {
+			return fmt.Errorf("failed to get free disk space for %q: %v", path, err)
+		}
+		if available < opts.MinFreeDiskSpace {
+			return insufficientDiskSpaceError{path: path, available: available, min: opts.MinFreeDiskSpace}
+		}
+	}
+	return nil
+}
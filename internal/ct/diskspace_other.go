@@ -0,0 +1,25 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux && !darwin
+
+package ct
+
+import "errors"
+
+// statfsFreeDiskSpace is not supported on this platform: a FreeDiskSpaceFunc
+// must be provided to check free disk space.
+func statfsFreeDiskSpace(_ string) (uint64, error) {
+	return 0, errors.New("free disk space checks are not supported on this platform")
+}
@@ -0,0 +1,32 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"testing"
+)
+
+func TestStatfsFreeDiskSpace(t *testing.T) {
+	free, err := statfsFreeDiskSpace(t.TempDir())
+	if err != nil {
+		t.Skipf("statfsFreeDiskSpace()=%v, free disk space checks unsupported", err)
+	}
+	if free == 0 {
+		t.Errorf("statfsFreeDiskSpace()=0, want available space in a temporary directory")
+	}
+	if _, err := statfsFreeDiskSpace("/does/not/exist"); err == nil {
+		t.Errorf("statfsFreeDiskSpace() on a missing path succeeded, want error")
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/klog/v2"
+)
+
+// staticCTAPIVersion is the version of https://c2sp.org/static-ct-api
+// implemented by the log.
+const staticCTAPIVersion = "v1.0.0"
+
+// entrypointInfo describes an entrypoint served for a log.
+type entrypointInfo struct {
+	Name entrypointName `json:"name"`
+	// Path is relative to the log's prefix.
+	Path   string `json:"path"`
+	Method string `json:"method"`
+}
+
+// getEntrypointsResponse is the response of the get-entrypoints endpoint.
+type getEntrypointsResponse struct {
+	StaticCTAPIVersion string           `json:"static_ct_api_version"`
+	Entrypoints        []entrypointInfo `json:"entrypoints"`
+}
+
+// listEntrypoints returns the entrypoints of ph, whose paths start with
+// prefix, sorted by path.
+func listEntrypoints(prefix string, ph pathHandlers) []entrypointInfo {
+	eps := make([]entrypointInfo, 0, len(ph))
+	for path, h := range ph {
+		path = strings.TrimPrefix(path, prefix)
+		eps = append(eps, entrypointInfo{Name: h.name, Path: path, Method: h.method})
+	}
+	slices.SortFunc(eps, func(a, b entrypointInfo) int { return strings.Compare(a.Path, b.Path) })
+	return eps
+}
+
+// getEntrypoints returns the entrypoints served for the log, and the version
+// of the static-ct-api it implements, for clients to discover its
+// capabilities.
+func getEntrypoints(_ context.Context, _ *HandlerOptions, log *log, w http.ResponseWriter, _ *http.Request) (int, []attribute.KeyValue, error) {
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	rsp := getEntrypointsResponse{StaticCTAPIVersion: staticCTAPIVersion, Entrypoints: log.entrypoints}
+	if err := json.NewEncoder(w).Encode(rsp); err != nil {
+		klog.Warningf("%s: get_entrypoints failed: %v", log.origin, err)
+		return http.StatusInternalServerError, nil, newHandlerError(errCodeWriteResponse, err)
+	}
+	return http.StatusOK, nil, nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errorCodeHeader is the HTTP header used to return error codes to clients.
+const errorCodeHeader = "X-Tesseract-Error-Code"
+
+// errorCode is a stable identifier for a class of handler errors.
+//
+// Error codes are returned to clients alongside error messages, so that they
+// can be localized independently of the server's messages.
+type errorCode string
+
+// Error codes returned by TesseraCT handlers. Values MUST NOT change.
+const (
+	errCodeMethodNotAllowed  errorCode = "method_not_allowed"
+	errCodeClientCert        errorCode = "client_cert_required"
+	errCodeRequestTooLarge   errorCode = "request_too_large"
+	errCodeRateLimited       errorCode = "rate_limited"
+	errCodeLoadShed          errorCode = "load_shed"
+	errCodeNotAfterDrift     errorCode = "not_after_drift"
+	errCodeWrongShard        errorCode = "wrong_shard"
+	errCodeInvalidForm       errorCode = "invalid_form"
+	errCodeInvalidBody       errorCode = "invalid_body"
+	errCodeInvalidChain      errorCode = "invalid_chain"
+	errCodeValidationTimeout errorCode = "validation_timeout"
+	errCodeValidationsFull   errorCode = "too_many_validations"
+	errCodeWrongEntryType    errorCode = "wrong_entry_type"
+	errCodeVetoed            errorCode = "vetoed"
+	errCodeBuildEntry        errorCode = "build_entry"
+	errCodeStoreIssuers      errorCode = "store_issuers"
+	errCodePushback          errorCode = "pushback"
+	errCodeStoreLeaf         errorCode = "store_leaf"
+	errCodeQueueSubmission   errorCode = "queue_submission"
+	errCodeQueueFull         errorCode = "queue_full"
+	errCodeVerifyLeaf        errorCode = "verify_leaf"
+	errCodeReconstructLeaf   errorCode = "reconstruct_leaf"
+	errCodeSignSCT           errorCode = "sign_sct"
+	errCodeSignerUnavailable errorCode = "signer_unavailable"
+	errCodeMirrorSubmit      errorCode = "mirror_submission"
+	errCodeWriteResponse     errorCode = "write_response"
+	errCodeGetRoots          errorCode = "get_roots"
+	errCodeReadCheckpoint    errorCode = "read_checkpoint"
+	errCodeInvalidIssuerHash errorCode = "invalid_issuer_hash"
+	errCodeIssuerNotFound    errorCode = "issuer_not_found"
+	errCodeReadIssuer        errorCode = "read_issuer"
+	errCodeInvalidQueueID    errorCode = "invalid_queue_id"
+	errCodeQueuedNotFound    errorCode = "queued_submission_not_found"
+	errCodeReadQueue         errorCode = "read_queue"
+	errCodeNoDiskSpace       errorCode = "insufficient_storage"
+	errCodeCheckDiskSpace    errorCode = "check_disk_space"
+	errCodeHandlerMisbehaved errorCode = "handler_misbehaved"
+)
+
+// errorCatalog maps error codes to their default, english, message.
+var errorCatalog = map[errorCode]string{
+	errCodeMethodNotAllowed:  "method not allowed",
+	errCodeClientCert:        "TLS client certificate required",
+	errCodeRequestTooLarge:   "request body too large",
+	errCodeRateLimited:       "too many submissions",
+	errCodeLoadShed:          "log overloaded, submission too large",
+	errCodeNotAfterDrift:     "certificate NotAfter does not match its precertificate",
+	errCodeWrongShard:        "certificate NotAfter is in a different shard than its precertificate",
+	errCodeInvalidForm:       "failed to parse form data",
+	errCodeInvalidBody:       "failed to parse add-chain body",
+	errCodeInvalidChain:      "failed to verify add-chain contents",
+	errCodeValidationTimeout: "chain validation took too long",
+	errCodeValidationsFull:   "too many chains being validated",
+	errCodeWrongEntryType:    "wrong entry type",
+	errCodeVetoed:            "submission rejected by log policy",
+	errCodeBuildEntry:        "failed to build MerkleTreeLeaf",
+	errCodeStoreIssuers:      "failed to store issuer chain",
+	errCodePushback:          "received pushback from Tessera sequencer",
+	errCodeStoreLeaf:         "couldn't store the leaf",
+	errCodeQueueSubmission:   "failed to queue submission",
+	errCodeQueueFull:         "submission queue full",
+	errCodeVerifyLeaf:        "failed to verify logged entry",
+	errCodeReconstructLeaf:   "failed to reconstruct MerkleTreeLeaf",
+	errCodeSignSCT:           "failed to generate SCT",
+	errCodeSignerUnavailable: "signer temporarily unavailable",
+	errCodeMirrorSubmit:      "failed to submit to mirror log",
+	errCodeWriteResponse:     "failed to write response",
+	errCodeGetRoots:          "get-roots failed",
+	errCodeReadCheckpoint:    "failed to read checkpoint",
+	errCodeInvalidIssuerHash: "invalid issuer fingerprint",
+	errCodeIssuerNotFound:    "issuer not found",
+	errCodeReadIssuer:        "failed to read issuer",
+	errCodeInvalidQueueID:    "invalid queued submission ID",
+	errCodeQueuedNotFound:    "unknown queued submission",
+	errCodeReadQueue:         "failed to read queued submission",
+	errCodeNoDiskSpace:       "insufficient disk space",
+	errCodeCheckDiskSpace:    "failed to check disk space",
+	errCodeHandlerMisbehaved: "http handler misbehaved",
+}
+
+// handlerError is an error returned by a handler, along with its catalog code.
+type handlerError struct {
+	code errorCode
+	err  error
+}
+
+// newHandlerError wraps err with a catalog error code.
+func newHandlerError(code errorCode, err error) error {
+	return &handlerError{code: code, err: err}
+}
+
+func (e *handlerError) Error() string {
+	return fmt.Sprintf("%s: %v", errorCatalog[e.code], e.err)
+}
+
+func (e *handlerError) Unwrap() error {
+	return e.err
+}
+
+// errorCodeOf returns the catalog code of err, if it has one.
+func errorCodeOf(err error) (errorCode, bool) {
+	var hErr *handlerError
+	if errors.As(err, &hErr) {
+		return hErr.code, true
+	}
+	return "", false
+}
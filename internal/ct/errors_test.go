@@ -0,0 +1,180 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/transparency-dev/tesseract/internal/testdata"
+	"github.com/transparency-dev/tesseract/internal/types/rfc6962"
+)
+
+func TestErrorCatalog(t *testing.T) {
+	codes := []errorCode{
+		errCodeMethodNotAllowed,
+		errCodeClientCert,
+		errCodeRequestTooLarge,
+		errCodeRateLimited,
+		errCodeLoadShed,
+		errCodeNotAfterDrift,
+		errCodeWrongShard,
+		errCodeInvalidForm,
+		errCodeInvalidBody,
+		errCodeInvalidChain,
+		errCodeValidationTimeout,
+		errCodeValidationsFull,
+		errCodeWrongEntryType,
+		errCodeVetoed,
+		errCodeBuildEntry,
+		errCodeStoreIssuers,
+		errCodePushback,
+		errCodeStoreLeaf,
+		errCodeQueueSubmission,
+		errCodeQueueFull,
+		errCodeVerifyLeaf,
+		errCodeReconstructLeaf,
+		errCodeSignSCT,
+		errCodeSignerUnavailable,
+		errCodeMirrorSubmit,
+		errCodeWriteResponse,
+		errCodeGetRoots,
+		errCodeReadCheckpoint,
+		errCodeInvalidIssuerHash,
+		errCodeIssuerNotFound,
+		errCodeReadIssuer,
+		errCodeInvalidQueueID,
+		errCodeQueuedNotFound,
+		errCodeReadQueue,
+		errCodeNoDiskSpace,
+		errCodeCheckDiskSpace,
+		errCodeHandlerMisbehaved,
+	}
+	if got, want := len(errorCatalog), len(codes); got != want {
+		t.Errorf("len(errorCatalog)=%d, want %d", got, want)
+	}
+
+	seenCodes := make(map[errorCode]bool)
+	seenMsgs := make(map[string]errorCode)
+	for _, code := range codes {
+		if seenCodes[code] {
+			t.Errorf("error code %q used for more than one failure class", code)
+		}
+		seenCodes[code] = true
+		msg, ok := errorCatalog[code]
+		if !ok || msg == "" {
+			t.Errorf("error code %q has no catalog message", code)
+			continue
+		}
+		if other, ok := seenMsgs[msg]; ok {
+			t.Errorf("error codes %q and %q have the same message %q", code, other, msg)
+		}
+		seenMsgs[msg] = code
+	}
+}
+
+func TestHandlerError(t *testing.T) {
+	inner := errors.New("boom")
+	err := fmt.Errorf("wrapped: %w", newHandlerError(errCodeStoreLeaf, inner))
+
+	if got, want := err.Error(), "wrapped: couldn't store the leaf: boom"; got != want {
+		t.Errorf("Error()=%q, want %q", got, want)
+	}
+	if !errors.Is(err, inner) {
+		t.Errorf("errors.Is(%v, %v)=false, want true", err, inner)
+	}
+	if code, ok := errorCodeOf(err); !ok || code != errCodeStoreLeaf {
+		t.Errorf("errorCodeOf()=%q,%t, want %q,true", code, ok, errCodeStoreLeaf)
+	}
+	if code, ok := errorCodeOf(inner); ok {
+		t.Errorf("errorCodeOf()=%q,%t, want _,false", code, ok)
+	}
+}
+
+func TestErrorCodeHeader(t *testing.T) {
+	log := setupFakeStorageLog(t, &fakeStorage{})
+	handlers := NewPathHandlers(t.Context(), &hOpts, log)
+	addChainPath := path.Join(prefix, rfc6962.AddChainPath)
+	addPreChainPath := path.Join(prefix, rfc6962.AddPreChainPath)
+
+	for _, tc := range []struct {
+		desc     string
+		path     string
+		method   string
+		body     string
+		wantCode errorCode
+	}{
+		{
+			desc:     "wrong-method",
+			path:     addChainPath,
+			method:   http.MethodGet,
+			wantCode: errCodeMethodNotAllowed,
+		},
+		{
+			desc:     "malformed-json",
+			path:     addChainPath,
+			method:   http.MethodPost,
+			body:     "{ !$%^& not valid json ",
+			wantCode: errCodeInvalidBody,
+		},
+		{
+			desc:     "wrong-entry-type",
+			path:     addPreChainPath,
+			method:   http.MethodPost,
+			body:     chainBody(t, testdata.CertFromIntermediate, testdata.IntermediateFromRoot),
+			wantCode: errCodeWrongEntryType,
+		},
+		{
+			desc:     "invalid-chain",
+			path:     addChainPath,
+			method:   http.MethodPost,
+			body:     chainBody(t, testdata.CertFromIntermediate),
+			wantCode: errCodeInvalidChain,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := httptest.NewServer(handlers[tc.path])
+			defer s.Close()
+
+			req, err := http.NewRequest(tc.method, s.URL+tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("http.NewRequest()=%v", err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("http.Do()=%v", err)
+			}
+			if got, want := resp.Header.Get(errorCodeHeader), string(tc.wantCode); got != want {
+				t.Errorf("%s=%q, want %q", errorCodeHeader, got, want)
+			}
+		})
+	}
+}
+
+// chainBody returns the JSON add-chain body for a chain of PEM certificates.
+func chainBody(t *testing.T, pems ...string) string {
+	t.Helper()
+	b, err := io.ReadAll(createJSONChain(t, *loadCertsIntoPoolOrDie(t, pems)))
+	if err != nil {
+		t.Fatalf("io.ReadAll()=%v", err)
+	}
+	return string(b)
+}
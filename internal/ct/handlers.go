@@ -15,34 +15,95 @@
 package ct
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"math"
+	"mime"
 	"net/http"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	tfl "github.com/transparency-dev/formats/log"
 	"github.com/transparency-dev/tessera"
+	"github.com/transparency-dev/tessera/ctonly"
 	"github.com/transparency-dev/tesseract/internal/otel"
 	"github.com/transparency-dev/tesseract/internal/types/rfc6962"
+	"github.com/transparency-dev/tesseract/internal/types/staticct"
 	"github.com/transparency-dev/tesseract/internal/types/tls"
 	"github.com/transparency-dev/tesseract/internal/x509util"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/crypto/cryptobyte"
+	"golang.org/x/time/rate"
 	"k8s.io/klog/v2"
 )
 
 const (
 	// HTTP content type header
 	contentTypeHeader string = "Content-Type"
+	// HTTP accept header
+	acceptHeader string = "Accept"
+	// HTTP header identifying the node which served a request.
+	nodeHeader string = "X-CT-Node"
+	// HTTP header indicating whether an SCT was returned for an entry which
+	// had already been logged.
+	dedupHeader string = "X-CT-Deduplicated"
+	// HTTP caching headers
+	cacheControlHeader string = "Cache-Control"
+	expiresHeader      string = "Expires"
 	// MIME content type for JSON
 	contentTypeJSON string = "application/json"
+	// MIME content type for binary chains: a concatenation of DER certificates,
+	// each prefixed by its length as a 3-byte big-endian integer.
+	contentTypeDERChain string = "application/vnd.tesseract.der-chain"
+	// MIME content type for binary SCTs: a TLS-encoded
+	// SignedCertificateTimestamp, as per RFC 6962 s3.2.
+	contentTypeSCT string = "application/vnd.tesseract.sct"
+	// MIME content type for binary SCT lists: a SignedCertificateTimestampList,
+	// as per RFC 6962 s3.3, wrapped in an ASN.1 OCTET STRING. This is the
+	// exact value of the X.509 extension embedding SCTs in certificates.
+	contentTypeSCTList string = "application/vnd.tesseract.sct-list"
 	// The name of the JSON response map key in get-roots responses
 	jsonMapKeyCertificates string = "certificates"
+	// Path of the get-tree-head endpoint, which is not part of RFC 6962.
+	getTreeHeadPath string = "/tesseract/v1/get-tree-head"
+	// Path of the get-issuer endpoint, which is not part of RFC 6962.
+	getIssuerPath string = "/tesseract/v1/get-issuer"
+	// The name of the get-issuer parameter holding the hex encoded SHA-256
+	// fingerprint of the issuer to return.
+	getIssuerParamHash string = "hash"
+	// Content type of DER encoded certificates.
+	contentTypePKIXCert string = "application/pkix-cert"
+	// Path of the get-entrypoints endpoint, which is not part of RFC 6962.
+	getEntrypointsPath string = "/tesseract/v1/get-entrypoints"
+	// Path prefix of admin endpoints, which must not be exposed publicly,
+	// see NewAdminPathHandlers.
+	adminPathPrefix string = "/tesseract/v1/admin/"
+	// Path of the admin endpoint listing recently rejected submissions.
+	getRejectedSubmissionsPath string = adminPathPrefix + "get-rejected-submissions"
+	// Paths of the admin endpoints pausing and resuming the sequencing of
+	// queued submissions, in queue mode.
+	pauseQueuePath  string = adminPathPrefix + "pause-queue"
+	resumeQueuePath string = adminPathPrefix + "resume-queue"
+	// Path of the get-queued-sct endpoint, which is not part of RFC 6962.
+	getQueuedSCTPath string = "/tesseract/v1/get-queued-sct"
+	// The name of the get-queued-sct parameter holding the ID of the queued
+	// submission to return the SCTs of.
+	getQueuedSCTParamID string = "id"
 )
 
 // entrypointName identifies a CT entrypoint as defined in section 4 of RFC 6962.
@@ -53,18 +114,33 @@ const (
 	addChainName    = entrypointName("AddChain")
 	addPreChainName = entrypointName("AddPreChain")
 	getRootsName    = entrypointName("GetRoots")
+	getTreeHeadName = entrypointName("GetTreeHead")
+	getIssuerName   = entrypointName("GetIssuer")
+	// getEntrypointsName lists the other entrypoints.
+	getEntrypointsName = entrypointName("GetEntrypoints")
+	// getRejectedSubmissionsName is only served when rejected submissions
+	// are sampled.
+	getRejectedSubmissionsName = entrypointName("GetRejectedSubmissions")
+	// getQueuedSCTName, pauseQueueName and resumeQueueName are only served
+	// in queue mode.
+	getQueuedSCTName = entrypointName("GetQueuedSCT")
+	pauseQueueName   = entrypointName("PauseQueue")
+	resumeQueueName  = entrypointName("ResumeQueue")
 )
 
 var (
 	// Metrics are all per-log (label "origin"), but may also be
 	// per-entrypoint (label "ep") or per-return-code (label "rc").
 	once             sync.Once
-	knownLogs        metric.Int64Gauge       // origin => value (always 1.0)
-	lastSCTIndex     metric.Int64Gauge       // origin => value
-	lastSCTTimestamp metric.Int64Gauge       // origin => value
-	reqCounter       metric.Int64Counter     // origin, op => value
-	rspCounter       metric.Int64Counter     // origin, op, code => value
-	reqDuration      metric.Float64Histogram // origin, op, code => value
+	knownLogs        metric.Int64Gauge           // origin => value (always 1.0)
+	lastSCTIndex     metric.Int64Gauge           // origin => value
+	lastSCTTimestamp metric.Int64Gauge           // origin => value
+	reqCounter       metric.Int64Counter         // origin, op => value
+	rspCounter       metric.Int64Counter         // origin, op, code => value
+	reqDuration      metric.Float64Histogram     // origin, op, code => value
+	inflightAdds     metric.Int64Gauge           // origin => value
+	maxInflightAdds  metric.Int64Gauge           // origin => value
+	oldestAddAge     metric.Int64ObservableGauge // origin => value
 )
 
 // setupMetrics initializes all the exported metrics.
@@ -94,10 +170,119 @@ func setupMetrics() {
 		metric.WithDescription("CT HTTP response duration"),
 		metric.WithUnit("ms"),
 		metric.WithExplicitBucketBoundaries(otel.SubSecondLatencyHistogramBuckets...)))
+
+	inflightAdds = mustCreate(meter.Int64Gauge("tesseract.storage.add.inflight",
+		metric.WithDescription("Number of in-flight storage Add calls"),
+		metric.WithUnit("{call}")))
+
+	maxInflightAdds = mustCreate(meter.Int64Gauge("tesseract.storage.add.inflight.max",
+		metric.WithDescription("Maximum number of concurrent storage Add calls observed"),
+		metric.WithUnit("{call}")))
+
+	oldestAddAge = mustCreate(meter.Int64ObservableGauge("tesseract.http.add.inflight.oldest_age",
+		metric.WithDescription("Age of the oldest in-flight add-chain or add-pre-chain request"),
+		metric.WithUnit("ms"),
+		metric.WithInt64Callback(observeOldestAddAge)))
+}
+
+// inflightTracker counts in-flight calls, and reports them to metrics.
+type inflightTracker struct {
+	// mu guards the counters, and also ensures that gauges are recorded in order.
+	mu  sync.Mutex
+	n   int64
+	max int64
+}
+
+// inc records the start of a call.
+func (t *inflightTracker) inc(ctx context.Context, attrs ...attribute.KeyValue) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.n++
+	inflightAdds.Record(ctx, t.n, metric.WithAttributes(attrs...))
+	if t.n > t.max {
+		t.max = t.n
+		maxInflightAdds.Record(ctx, t.max, metric.WithAttributes(attrs...))
+	}
+}
+
+// dec records the end of a call.
+func (t *inflightTracker) dec(ctx context.Context, attrs ...attribute.KeyValue) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.n--
+	inflightAdds.Record(ctx, t.n, metric.WithAttributes(attrs...))
+}
+
+// requestTracker tracks the start time of in-flight requests.
+type requestTracker struct {
+	mu     sync.Mutex
+	nextID uint64
+	starts map[uint64]time.Time
+}
+
+// start records the start of a request, and returns a function to call when
+// it ends.
+func (t *requestTracker) start() func() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.starts == nil {
+		t.starts = make(map[uint64]time.Time)
+	}
+	id := t.nextID
+	t.nextID++
+	t.starts[id] = time.Now()
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.starts, id)
+	}
+}
+
+// count returns the number of in-flight requests.
+func (t *requestTracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.starts)
+}
+
+// oldestAge returns the age of the oldest in-flight request at now, or 0 if
+// there are none.
+func (t *requestTracker) oldestAge(now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var age time.Duration
+	for _, start := range t.starts {
+		age = max(age, now.Sub(start))
+	}
+	return age
+}
+
+// addTrackers holds the add-chain and add-pre-chain request trackers of the
+// logs served by this process, by origin, so that observeOldestAddAge can
+// report them.
+var addTrackers = struct {
+	mu sync.Mutex
+	m  map[string][]*requestTracker
+}{m: make(map[string][]*requestTracker)}
+
+// observeOldestAddAge reports the age of the oldest in-flight add-chain or
+// add-pre-chain request of each log.
+func observeOldestAddAge(_ context.Context, o metric.Int64Observer) error {
+	addTrackers.mu.Lock()
+	defer addTrackers.mu.Unlock()
+	now := time.Now()
+	for origin, trackers := range addTrackers.m {
+		var age time.Duration
+		for _, t := range trackers {
+			age = max(age, t.oldestAge(now))
+		}
+		o.Observe(age.Milliseconds(), metric.WithAttributes(originKey.String(origin)))
+	}
+	return nil
 }
 
 // entrypoints is a list of entrypoint names as exposed in statistics/logging.
-var entrypoints = []entrypointName{addChainName, addPreChainName, getRootsName}
+var entrypoints = []entrypointName{addChainName, addPreChainName, getRootsName, getTreeHeadName, getIssuerName, getEntrypointsName}
 
 // pathHandlers maps from a path to the relevant AppHandler instance.
 type pathHandlers map[string]appHandler
@@ -110,6 +295,9 @@ type appHandler struct {
 	handler func(context.Context, *HandlerOptions, *log, http.ResponseWriter, *http.Request) (int, []attribute.KeyValue, error)
 	name    entrypointName
 	method  string // http.MethodGet or http.MethodPost
+	// submission indicates that the handler serves add-chain or
+	// add-pre-chain submissions.
+	submission bool
 }
 
 // ServeHTTP for an AppHandler invokes the underlying handler function but
@@ -128,20 +316,54 @@ func (a appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		reqDuration.Record(r.Context(), latency, metric.WithAttributes(attrs...))
 	}()
 
-	klog.V(2).Infof("%s: request %v %q => %s", a.log.origin, r.Method, r.URL, a.name)
+	klog.V(2).InfoS("Request", "origin", a.log.origin, "method", r.Method, "url", r.URL.String(), "handler", a.name)
+	if a.opts.NodeName != "" {
+		w.Header().Set(nodeHeader, a.opts.NodeName)
+	}
+	if a.setCORSHeaders(w, r) && r.Method == http.MethodOptions {
+		a.serveCORSPreflight(w)
+		a.opts.RequestLog.status(logCtx, http.StatusNoContent)
+		return
+	}
 	// TODO(phboneff): add a.Method directly on the handler path and remove this test.
 	if r.Method != a.method {
 		klog.Warningf("%s: %s wrong HTTP method: %v", a.log.origin, a.name, r.Method)
-		a.opts.sendHTTPError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method))
+		a.opts.sendHTTPError(w, http.StatusMethodNotAllowed, newHandlerError(errCodeMethodNotAllowed, errors.New(r.Method)))
 		a.opts.RequestLog.status(logCtx, http.StatusMethodNotAllowed)
 		return
 	}
 
+	// Only verified client certificates are in VerifiedChains: the server's
+	// TLS configuration must verify them.
+	if a.submission && a.opts.RequireClientCert && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+		klog.V(1).Infof("%s: %s request without a verified TLS client certificate from %s", a.log.origin, a.name, r.RemoteAddr)
+		a.opts.sendHTTPError(w, http.StatusForbidden, newHandlerError(errCodeClientCert, errors.New("no verified certificate presented")))
+		a.opts.RequestLog.status(logCtx, http.StatusForbidden)
+		return
+	}
+
+	// Apply the log's submission limits. Bodies sent without a Content-Length
+	// fail to be read once they exceed MaxRequestBytes.
+	if a.submission && a.opts.MaxRequestBytes > 0 {
+		if r.ContentLength > a.opts.MaxRequestBytes {
+			a.opts.sendHTTPError(w, http.StatusRequestEntityTooLarge, newHandlerError(errCodeRequestTooLarge, fmt.Errorf("%d bytes, more than %d", r.ContentLength, a.opts.MaxRequestBytes)))
+			a.opts.RequestLog.status(logCtx, http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, a.opts.MaxRequestBytes)
+	}
+	if a.submission && a.log.submissionLimiter != nil && !a.log.submissionLimiter.Allow() {
+		w.Header().Set("Retry-After", "1")
+		a.opts.sendHTTPError(w, http.StatusTooManyRequests, newHandlerError(errCodeRateLimited, fmt.Errorf("more than %v per second", a.log.submissionLimiter.Limit())))
+		a.opts.RequestLog.status(logCtx, http.StatusTooManyRequests)
+		return
+	}
+
 	// For GET requests all params come as form encoded so we might as well parse them now.
 	// POSTs will decode the raw request body as JSON later.
 	if r.Method == http.MethodGet {
 		if err := r.ParseForm(); err != nil {
-			a.opts.sendHTTPError(w, http.StatusBadRequest, fmt.Errorf("failed to parse form data: %s", err))
+			a.opts.sendHTTPError(w, http.StatusBadRequest, newHandlerError(errCodeInvalidForm, err))
 			a.opts.RequestLog.status(logCtx, http.StatusBadRequest)
 			return
 		}
@@ -152,22 +374,44 @@ func (a appHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(logCtx, a.opts.Deadline)
 	defer cancel()
 
+	// Hash request bodies as they are read, to identify rejected submissions.
+	var bodyHash hash.Hash
+	if a.log.rejections != nil && r.Method == http.MethodPost {
+		bodyHash = sha256.New()
+		r.Body = io.NopCloser(io.TeeReader(r.Body, bodyHash))
+	}
+
 	statusCode, hattrs, err := a.handler(ctx, a.opts, a.log, w, r)
 	attrs = append(attrs, hattrs...)
 	attrs = append(attrs, codeKey.Int(statusCode))
 	a.opts.RequestLog.status(ctx, statusCode)
-	klog.V(2).Infof("%s: %s <= st=%d", a.log.origin, a.name, statusCode)
+	klog.V(2).InfoS("Response", "origin", a.log.origin, "handler", a.name, "status", statusCode)
 	rspCounter.Add(r.Context(), 1, metric.WithAttributes(attrs...))
 	if err != nil {
 		klog.Warningf("%s: %s handler error: %v", a.log.origin, a.name, err)
+		if bodyHash != nil && statusCode >= 400 && statusCode < 500 {
+			a.log.rejections.add(rejectedSubmission{
+				Time:       a.opts.TimeSource.Now(),
+				BodyHash:   bodyHash.Sum(nil),
+				Reason:     err.Error(),
+				RemoteAddr: r.RemoteAddr,
+			})
+		}
+		if errors.Is(err, errResponseStarted) {
+			// The status and part of the body are already out: abort the
+			// connection so that clients don't take a truncated response
+			// for a complete one.
+			panic(http.ErrAbortHandler)
+		}
 		a.opts.sendHTTPError(w, statusCode, err)
 		return
 	}
 
-	// Additional check, for consistency the handler must return an error for non-200 st
-	if statusCode != http.StatusOK {
+	// Additional check, for consistency the handler must return an error for non-200 st.
+	// Queued submissions are acknowledged with http.StatusAccepted.
+	if statusCode != http.StatusOK && statusCode != http.StatusAccepted {
 		klog.Warningf("%s: %s handler non 200 without error: %d %v", a.log.origin, a.name, statusCode, err)
-		a.opts.sendHTTPError(w, http.StatusInternalServerError, fmt.Errorf("http handler misbehaved, st: %d", statusCode))
+		a.opts.sendHTTPError(w, http.StatusInternalServerError, newHandlerError(errCodeHandlerMisbehaved, fmt.Errorf("st: %d", statusCode)))
 		return
 	}
 }
@@ -184,11 +428,166 @@ type HandlerOptions struct {
 	// TimeSource indicated the system time and can be injfected for testing.
 	// TODO(phbnf): hide inside the log
 	TimeSource TimeSource
+	// EntryBuilder builds log entries from validated chains.
+	// If nil, x509util.EntryFromChain is used.
+	EntryBuilder EntryBuilder
+	// Interceptors are called in order on validated submissions, before
+	// they are sequenced, and can reject them.
+	Interceptors []Interceptor
+	// SubmissionQueue, if set, puts add-chain and add-pre-chain in queue
+	// mode: validated submissions are durably queued rather than sequenced,
+	// and acknowledged with http.StatusAccepted and an ID. A background
+	// sequencer drains the queue, and the SCTs of queued submissions are
+	// served by the get-queued-sct endpoint once they are sequenced. This
+	// keeps the log accepting submissions while sequencing is unavailable,
+	// for instance during storage maintenance.
+	SubmissionQueue *SubmissionQueue
+	// SCTSigner issues the log's SCTs, instead of the log's RFC 6962 signer.
+	// If nil, the log's signer is used.
+	SCTSigner SCTSigner
+	// VerifyAfterWrite indicates if newly sequenced entries should be read back
+	// from storage and checked against the submission before returning an SCT.
+	// This waits for entries to be integrated, and will slow down responses.
+	VerifyAfterWrite bool
+	// AcceptDERChains indicates if add-chain and add-pre-chain accept binary
+	// chains, sent with the contentTypeDERChain content type, on top of JSON.
+	AcceptDERChains bool
+	// Mirrors are logs mirroring this one. Sequenced chains are also
+	// submitted to them, and the SCTs they issue are returned in the
+	// additional_scts field of add-chain and add-pre-chain responses.
+	Mirrors []Mirror
+	// GetRootsMaxAge is how long get-roots responses can be cached for.
+	// Caching headers are only set if it is positive.
+	GetRootsMaxAge time.Duration
+	// GetRootsIntermediates indicates if get-roots responses also list each
+	// root together with the intermediates chaining to it, to help clients
+	// build full paths. These are the intermediates of the chains stored in
+	// issuer storage by this instance, up to maxKnownIntermediates, so
+	// responses may vary between instances and restarts.
+	GetRootsIntermediates bool
+	// RejectedSubmissionSamples is the number of recently rejected submissions
+	// kept in memory, and served on the getRejectedSubmissionsPath admin
+	// endpoint, see NewAdminPathHandlers. The endpoint is only served if it
+	// is positive.
+	RejectedSubmissionSamples int
+	// MinFreeDiskSpace is the minimum number of bytes which must be available
+	// on each of DiskSpacePaths for add-chain and add-pre-chain to accept
+	// submissions. They return http.StatusInsufficientStorage otherwise.
+	// The check is disabled if it is 0.
+	MinFreeDiskSpace uint64
+	// DiskSpacePaths are paths on the filesystems holding log data.
+	DiskSpacePaths []string
+	// FreeDiskSpace returns the space available on a filesystem. It can be
+	// injected for testing. If nil, statfs(2) is used.
+	FreeDiskSpace FreeDiskSpaceFunc
+	// SubmissionCacheTTL is how long the SCTs issued for add-chain and
+	// add-pre-chain submissions are kept in memory, keyed by the hash of the
+	// submitted chain, and returned to identical submissions without
+	// validating and sequencing them again. Checks depending on the request,
+	// such as Interceptors, still apply to these submissions. Caching is only
+	// enabled if it is positive.
+	SubmissionCacheTTL time.Duration
+	// StreamJSONChains indicates if add-chain and add-pre-chain decode JSON
+	// chains as the request body is read, rather than buffering the whole
+	// body first.
+	StreamJSONChains bool
+	// MaxChainCerts is the maximum number of certificates in a submitted
+	// chain. Streamed JSON chains are rejected as soon as they exceed it.
+	// There is no limit if it is 0.
+	MaxChainCerts int
+	// MaxChainBytes is the maximum total size of the DER certificates of a
+	// submitted chain. Streamed JSON and binary chains are rejected as soon
+	// as they exceed it. There is no limit if it is 0.
+	MaxChainBytes int
+	// ValidationTimeout is the maximum time add-chain and add-pre-chain
+	// spend validating a chain, independently of Deadline. Requests whose
+	// chain takes longer to validate fail with http.StatusServiceUnavailable.
+	// There is no limit if it is 0.
+	ValidationTimeout time.Duration
+	// MaxValidations is the maximum number of chains being validated at
+	// once with a ValidationTimeout, including chains which timed out and
+	// are still being validated in the background. Requests beyond it fail
+	// with http.StatusServiceUnavailable. defaultMaxValidations is used if
+	// it is 0.
+	MaxValidations int
+	// NodeName identifies the node serving the log, in multi-node
+	// deployments. If set, it is returned in the nodeHeader of all
+	// responses, for instance to find which node issued an SCT.
+	NodeName string
+	// RequireClientCert indicates if add-chain and add-pre-chain require
+	// requests to present a TLS client certificate, which the server's TLS
+	// configuration must verify, for instance with
+	// tls.VerifyClientCertIfGiven. Other endpoints remain open. Requests
+	// without a verified certificate fail with http.StatusForbidden.
+	RequireClientCert bool
+	// MaxRequestBytes is the maximum size of add-chain and add-pre-chain
+	// request bodies. Larger requests fail with
+	// http.StatusRequestEntityTooLarge. There is no limit if it is 0.
+	MaxRequestBytes int64
+	// SubmissionRate is the maximum sustained rate, per second, of add-chain
+	// and add-pre-chain requests to the log, and SubmissionBurst the number
+	// of requests which can be served at once above it. Requests beyond
+	// these limits fail with http.StatusTooManyRequests. There is no limit if
+	// SubmissionRate is 0. If SubmissionBurst is 0, it is SubmissionRate
+	// rounded up.
+	SubmissionRate  float64
+	SubmissionBurst int
+	// ShedLoadThreshold is the number of concurrent add-chain and
+	// add-pre-chain requests to the log above which it sheds load: requests
+	// whose chain is larger than ShedChainBytes fail with
+	// http.StatusServiceUnavailable before being validated, while smaller
+	// requests keep being served. Interceptors are told that the log is
+	// overloaded, so that they can skip optional checks. There is no load
+	// shedding if it is 0.
+	ShedLoadThreshold int
+	// ShedChainBytes is the total size of the DER certificates of a chain
+	// above which requests are shed while the log is overloaded. All
+	// requests are shed if it is 0.
+	ShedChainBytes int
+	// MaxNotAfterDrift is the maximum difference between the NotAfter of a
+	// final certificate submitted to add-chain and the NotAfter of its
+	// precertificate, with the same issuer and serial number, if the log
+	// recently logged it. Larger differences fail with
+	// http.StatusBadRequest. Only the latest maxCachedPrecerts
+	// precertificates logged by this instance are remembered, so this check
+	// is best effort. Any value under a second requires them to match
+	// exactly, since certificate times have a one-second precision.
+	// Final certificates are not checked if it is 0.
+	MaxNotAfterDrift time.Duration
+	// ShardNotAfterLimit, if set, is the NotAfter limit between this shard
+	// and the next one. Final certificates submitted to add-chain fail with
+	// http.StatusBadRequest if their NotAfter and the NotAfter of their
+	// precertificate are on different sides of it, if the log recently
+	// logged the precertificate: one of them belongs to another shard, which
+	// usually indicates a routing mistake. Like MaxNotAfterDrift, this check
+	// is best effort.
+	ShardNotAfterLimit *time.Time
+	// DedupHeader indicates if add-chain and add-pre-chain responses carry
+	// the dedupHeader, set to "true" if the SCT was issued for an entry
+	// which had already been logged, and to "false" if the entry was newly
+	// sequenced.
+	DedupHeader bool
+	// CORSAllowedOrigins maps entrypoint names, such as getRootsName, to the
+	// origins allowed to make cross-origin requests to them, or to
+	// corsAnyOrigin. Their responses carry CORS headers, and they answer
+	// CORS preflight requests. Other entrypoints send no CORS headers.
+	CORSAllowedOrigins map[entrypointName][]string
 }
 
+// EntryBuilder builds the entry to log for a validated chain.
+//
+// chain[0] is the submitted leaf, followed by its issuers up to a trusted root.
+// timestamp is the current time in milliseconds since the Unix epoch.
+type EntryBuilder func(chain []*x509.Certificate, isPrecert bool, timestamp uint64) (*ctonly.Entry, error)
+
 func NewPathHandlers(ctx context.Context, opts *HandlerOptions, log *log) pathHandlers {
 	once.Do(func() { setupMetrics() })
 	knownLogs.Record(ctx, 1, metric.WithAttributes(originKey.String(log.origin)))
+	addTrackers.mu.Lock()
+	if !slices.Contains(addTrackers.m[log.origin], &log.inflightRequests) {
+		addTrackers.m[log.origin] = append(addTrackers.m[log.origin], &log.inflightRequests)
+	}
+	addTrackers.mu.Unlock()
 
 	prefix := strings.TrimRight(log.origin, "/")
 	if !strings.HasPrefix(prefix, "/") {
@@ -198,16 +597,74 @@ func NewPathHandlers(ctx context.Context, opts *HandlerOptions, log *log) pathHa
 	// Bind each endpoint to an appHandler instance.
 	// TODO(phboneff): try and get rid of PathHandlers and appHandler
 	ph := pathHandlers{
-		prefix + rfc6962.AddChainPath:    appHandler{opts: opts, log: log, handler: addChain, name: addChainName, method: http.MethodPost},
-		prefix + rfc6962.AddPreChainPath: appHandler{opts: opts, log: log, handler: addPreChain, name: addPreChainName, method: http.MethodPost},
+		prefix + rfc6962.AddChainPath:    appHandler{opts: opts, log: log, handler: addChain, name: addChainName, method: http.MethodPost, submission: true},
+		prefix + rfc6962.AddPreChainPath: appHandler{opts: opts, log: log, handler: addPreChain, name: addPreChainName, method: http.MethodPost, submission: true},
 		prefix + rfc6962.GetRootsPath:    appHandler{opts: opts, log: log, handler: getRoots, name: getRootsName, method: http.MethodGet},
+		prefix + getTreeHeadPath:         appHandler{opts: opts, log: log, handler: getTreeHead, name: getTreeHeadName, method: http.MethodGet},
+		prefix + getIssuerPath:           appHandler{opts: opts, log: log, handler: getIssuer, name: getIssuerName, method: http.MethodGet},
+		prefix + getEntrypointsPath:      appHandler{opts: opts, log: log, handler: getEntrypoints, name: getEntrypointsName, method: http.MethodGet},
+	}
+	if opts.RejectedSubmissionSamples > 0 {
+		log.rejections = newRejectionSamples(opts.RejectedSubmissionSamples)
+	}
+	if opts.SubmissionQueue != nil {
+		log.queue = opts.SubmissionQueue
+		ph[prefix+getQueuedSCTPath] = appHandler{opts: opts, log: log, handler: getQueuedSCT, name: getQueuedSCTName, method: http.MethodGet}
+		go runQueueSequencer(ctx, opts, log)
 	}
+	if opts.SubmissionCacheTTL > 0 {
+		log.submissions = newSubmissionCache(opts.SubmissionCacheTTL)
+	}
+	if opts.ValidationTimeout > 0 {
+		maxValidations := opts.MaxValidations
+		if maxValidations <= 0 {
+			maxValidations = defaultMaxValidations
+		}
+		log.validations = make(chan struct{}, maxValidations)
+	}
+	if opts.MaxNotAfterDrift > 0 || opts.ShardNotAfterLimit != nil {
+		log.precerts = newPrecertCache(maxCachedPrecerts)
+	}
+	if opts.GetRootsIntermediates {
+		log.intermediates = newKnownIntermediates()
+	}
+	if opts.SubmissionRate > 0 {
+		burst := opts.SubmissionBurst
+		if burst == 0 {
+			burst = int(math.Ceil(opts.SubmissionRate))
+		}
+		log.submissionLimiter = rate.NewLimiter(rate.Limit(opts.SubmissionRate), burst)
+	}
+	log.entrypoints = listEntrypoints(prefix, ph)
 
 	return ph
 }
 
-// sendHTTPError generates a custom error page to give more information on why something didn't work
+// NewAdminPathHandlers returns the handlers of the admin endpoints of log,
+// such as get-rejected-submissions, which must only be served on a private
+// listener. It must be called after NewPathHandlers.
+func NewAdminPathHandlers(opts *HandlerOptions, log *log) pathHandlers {
+	prefix := strings.TrimRight(log.origin, "/")
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	ph := pathHandlers{}
+	if log.rejections != nil {
+		ph[prefix+getRejectedSubmissionsPath] = appHandler{opts: opts, log: log, handler: getRejectedSubmissions, name: getRejectedSubmissionsName, method: http.MethodGet}
+	}
+	if log.queue != nil {
+		ph[prefix+pauseQueuePath] = appHandler{opts: opts, log: log, handler: pauseQueue, name: pauseQueueName, method: http.MethodPost}
+		ph[prefix+resumeQueuePath] = appHandler{opts: opts, log: log, handler: resumeQueue, name: resumeQueueName, method: http.MethodPost}
+	}
+	return ph
+}
+
+// sendHTTPError generates a custom error page to give more information on why something didn't work.
+// If err has a catalog error code, it is returned in the errorCodeHeader header.
 func (opts *HandlerOptions) sendHTTPError(w http.ResponseWriter, statusCode int, err error) {
+	if code, ok := errorCodeOf(err); ok {
+		w.Header().Set(errorCodeHeader, string(code))
+	}
 	errorBody := http.StatusText(statusCode)
 	if !opts.MaskInternalErrors || statusCode != http.StatusInternalServerError {
 		errorBody += fmt.Sprintf("\n%v", err)
@@ -238,6 +695,239 @@ func parseBodyAsJSONChain(r *http.Request) (rfc6962.AddChainRequest, error) {
 	return req, nil
 }
 
+// parseBodyAsJSONChainStream is like parseBodyAsJSONChain, but decodes the
+// chain as the request body is read, rather than buffering the whole body.
+// If maxCerts is positive, it fails as soon as the chain has more than
+// maxCerts certificates, and if maxBytes is positive, as soon as they total
+// more than maxBytes DER bytes.
+func parseBodyAsJSONChainStream(r *http.Request, maxCerts, maxBytes int) (rfc6962.AddChainRequest, error) {
+	req, err := decodeJSONChain(json.NewDecoder(r.Body), maxCerts, maxBytes)
+	if err != nil {
+		klog.V(1).Infof("Failed to parse request body: %v", err)
+		return rfc6962.AddChainRequest{}, err
+	}
+
+	// The cert chain is not allowed to be empty. We'll defer other validation for later
+	if len(req.Chain) == 0 {
+		klog.V(1).Info("Request chain is empty")
+		return rfc6962.AddChainRequest{}, errors.New("cert chain was empty")
+	}
+
+	return req, nil
+}
+
+// decodeJSONChain decodes an rfc6962.AddChainRequest from dec, one
+// certificate at a time, with up to maxCerts certificates and maxBytes DER
+// bytes if they are positive.
+//
+// It accepts the same inputs as json.Unmarshal: object keys are matched
+// case-insensitively, unknown keys are skipped, and trailing data is rejected.
+func decodeJSONChain(dec *json.Decoder, maxCerts, maxBytes int) (rfc6962.AddChainRequest, error) {
+	var req rfc6962.AddChainRequest
+	if err := expectJSONDelim(dec, '{'); err != nil {
+		return rfc6962.AddChainRequest{}, err
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return rfc6962.AddChainRequest{}, err
+		}
+		if key, _ := t.(string); !strings.EqualFold(key, "chain") {
+			if err := skipJSONValue(dec); err != nil {
+				return rfc6962.AddChainRequest{}, err
+			}
+			continue
+		}
+		if req.Chain, err = decodeJSONCerts(dec, maxCerts, maxBytes); err != nil {
+			return rfc6962.AddChainRequest{}, err
+		}
+	}
+	if err := expectJSONDelim(dec, '}'); err != nil {
+		return rfc6962.AddChainRequest{}, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return rfc6962.AddChainRequest{}, errors.New("invalid data after top-level value")
+	}
+	return req, nil
+}
+
+// decodeJSONCerts decodes a JSON array of base64 encoded certificates, or
+// null, from dec. If maxCerts is positive, it fails as soon as the array has
+// more than maxCerts certificates, and if maxBytes is positive, as soon as
+// they total more than maxBytes DER bytes.
+func decodeJSONCerts(dec *json.Decoder, maxCerts, maxBytes int) ([][]byte, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, nil
+	}
+	if t != json.Delim('[') {
+		return nil, fmt.Errorf("chain is %v, want an array", t)
+	}
+	chain := [][]byte{}
+	size := 0
+	for dec.More() {
+		if maxCerts > 0 && len(chain) == maxCerts {
+			return nil, fmt.Errorf("cert chain has more than %d certificates", maxCerts)
+		}
+		var der []byte
+		if err := dec.Decode(&der); err != nil {
+			return nil, fmt.Errorf("invalid certificate at position %d: %v", len(chain), err)
+		}
+		if size += len(der); maxBytes > 0 && size > maxBytes {
+			return nil, fmt.Errorf("cert chain has more than %d bytes", maxBytes)
+		}
+		chain = append(chain, der)
+	}
+	return chain, expectJSONDelim(dec, ']')
+}
+
+// expectJSONDelim reads the next token from dec, and fails if it is not d.
+func expectJSONDelim(dec *json.Decoder, d json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != d {
+		return fmt.Errorf("got %v, want %v", t, d)
+	}
+	return nil
+}
+
+// skipJSONValue reads the next value from dec, one token at a time, and
+// discards it.
+func skipJSONValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// parseBodyAsDERChain tries to extract a cert-chain out of a binary request.
+//
+// The body must be a concatenation of DER certificates, each prefixed by its
+// length as a 3-byte big-endian integer, like ASN.1Cert in RFC 6962 s3.1.
+// If maxBytes is positive, it fails as soon as the certificates total more
+// than maxBytes bytes.
+func parseBodyAsDERChain(r *http.Request, maxBytes int) (rfc6962.AddChainRequest, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		klog.V(1).Infof("Failed to read request body: %v", err)
+		return rfc6962.AddChainRequest{}, err
+	}
+
+	var req rfc6962.AddChainRequest
+	s := cryptobyte.String(body)
+	size := 0
+	for !s.Empty() {
+		var der cryptobyte.String
+		if !s.ReadUint24LengthPrefixed(&der) || der.Empty() {
+			klog.V(1).Infof("Failed to parse request body: invalid certificate at position %d", len(req.Chain))
+			return rfc6962.AddChainRequest{}, fmt.Errorf("invalid length-prefixed certificate at position %d", len(req.Chain))
+		}
+		if size += len(der); maxBytes > 0 && size > maxBytes {
+			return rfc6962.AddChainRequest{}, fmt.Errorf("cert chain has more than %d bytes", maxBytes)
+		}
+		req.Chain = append(req.Chain, der)
+	}
+
+	// The cert chain is not allowed to be empty. We'll defer other validation for later
+	if len(req.Chain) == 0 {
+		klog.V(1).Info("Request chain is empty")
+		return rfc6962.AddChainRequest{}, errors.New("cert chain was empty")
+	}
+
+	return req, nil
+}
+
+// chainBytes returns the total size of the DER certificates of chain.
+func chainBytes(chain [][]byte) int {
+	size := 0
+	for _, der := range chain {
+		size += len(der)
+	}
+	return size
+}
+
+// isDERChainRequest returns true if r has a binary chain content type.
+func isDERChainRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get(contentTypeHeader))
+	return err == nil && mediaType == contentTypeDERChain
+}
+
+// binarySCTContentType returns the first binary SCT content type accepted by
+// r, contentTypeSCT or contentTypeSCTList, or "" if it accepts neither.
+func binarySCTContentType(r *http.Request) string {
+	for _, accept := range r.Header.Values(acceptHeader) {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(mediaRange)
+			if err == nil && (mediaType == contentTypeSCT || mediaType == contentTypeSCTList) {
+				return mediaType
+			}
+		}
+	}
+	return ""
+}
+
+// errValidationTimeout is returned by validateWithTimeout when validation
+// takes too long.
+var errValidationTimeout = errors.New("chain validation timed out")
+
+// defaultMaxValidations is the default value of
+// HandlerOptions.MaxValidations.
+const defaultMaxValidations = 1024
+
+// errTooManyValidations is returned by validateWithTimeout when too many
+// chains are already being validated.
+var errTooManyValidations = errors.New("too many chains being validated")
+
+// validateWithTimeout validates req with cv, and returns errValidationTimeout
+// if this takes longer than timeout. Validation then carries on in the
+// background until it completes, and its result is discarded.
+// There is no timeout if it is 0.
+func validateWithTimeout(cv ChainValidator, req rfc6962.AddChainRequest, isPrecert bool, timeout time.Duration, validations chan struct{}) ([]*x509.Certificate, error) {
+	if timeout <= 0 {
+		return cv.Validate(req, isPrecert)
+	}
+	select {
+	case validations <- struct{}{}:
+	default:
+		return nil, fmt.Errorf("%w: %d", errTooManyValidations, cap(validations))
+	}
+	type result struct {
+		chain []*x509.Certificate
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() { <-validations }()
+		chain, err := cv.Validate(req, isPrecert)
+		done <- result{chain: chain, err: err}
+	}()
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case r := <-done:
+		return r.chain, r.err
+	case <-t.C:
+		return nil, fmt.Errorf("%w after %v", errValidationTimeout, timeout)
+	}
+}
+
 // addChainInternal is called by add-chain and add-pre-chain as the logic involved in
 // processing these requests is almost identical
 func addChainInternal(ctx context.Context, opts *HandlerOptions, log *log, w http.ResponseWriter, r *http.Request, isPrecert bool) (int, []attribute.KeyValue, error) {
@@ -247,83 +937,249 @@ func addChainInternal(ctx context.Context, opts *HandlerOptions, log *log, w htt
 	} else {
 		method = addChainName
 	}
+	defer log.inflightRequests.start()()
+	overloaded := opts.ShedLoadThreshold > 0 && log.inflightRequests.count() > opts.ShedLoadThreshold
 
 	// Check the contents of the request and convert to slice of certificates.
-	addChainReq, err := parseBodyAsJSONChain(r)
+	var addChainReq rfc6962.AddChainRequest
+	var err error
+	switch {
+	case opts.AcceptDERChains && isDERChainRequest(r):
+		addChainReq, err = parseBodyAsDERChain(r, opts.MaxChainBytes)
+	case opts.StreamJSONChains:
+		addChainReq, err = parseBodyAsJSONChainStream(r, opts.MaxChainCerts, opts.MaxChainBytes)
+	default:
+		addChainReq, err = parseBodyAsJSONChain(r)
+	}
 	if err != nil {
-		return http.StatusBadRequest, nil, fmt.Errorf("%s: failed to parse add-chain body: %s", log.origin, err)
+		return http.StatusBadRequest, nil, newHandlerError(errCodeInvalidBody, fmt.Errorf("%s: %s", log.origin, err))
+	}
+	if opts.MaxChainCerts > 0 && len(addChainReq.Chain) > opts.MaxChainCerts {
+		return http.StatusBadRequest, nil, newHandlerError(errCodeInvalidBody, fmt.Errorf("%s: cert chain has %d certificates, more than %d", log.origin, len(addChainReq.Chain), opts.MaxChainCerts))
+	}
+	if size := chainBytes(addChainReq.Chain); opts.MaxChainBytes > 0 && size > opts.MaxChainBytes {
+		return http.StatusBadRequest, nil, newHandlerError(errCodeInvalidBody, fmt.Errorf("%s: cert chain has %d bytes, more than %d", log.origin, size, opts.MaxChainBytes))
 	}
 	// Log the DERs now because they might not parse as valid X.509.
 	for _, der := range addChainReq.Chain {
 		opts.RequestLog.addDERToChain(ctx, der)
 	}
-	chain, err := log.chainValidator.Validate(addChainReq, isPrecert)
-	if err != nil {
-		return http.StatusBadRequest, nil, fmt.Errorf("failed to verify add-chain contents: %s", err)
+
+	// Reuse the outcome of an identical submission recently processed, if
+	// any: hit is set in that case. Otherwise, cached is set if this
+	// submission's outcome must be cached. Queued submissions don't get SCTs,
+	// and are never cached.
+	var hit, cached *cachedSubmission
+	if log.submissions != nil && log.queue == nil {
+		key := submissionKey(isPrecert, addChainReq.Chain)
+		entry, owner := log.submissions.start(key, opts.TimeSource.Now())
+		if !owner {
+			if entry.wait(ctx) {
+				hit = entry
+			}
+			// Otherwise, the identical submission failed: process this one.
+		} else if entry != nil {
+			cached = entry
+			defer func() { log.submissions.finish(key, entry, opts.TimeSource.Now()) }()
+		}
+	}
+
+	var chain []*x509.Certificate
+	if hit != nil {
+		chain = hit.chain
+	} else {
+		if size := chainBytes(addChainReq.Chain); overloaded && size > opts.ShedChainBytes {
+			w.Header().Set("Retry-After", "1")
+			return http.StatusServiceUnavailable, nil, newHandlerError(errCodeLoadShed, fmt.Errorf("%s: more than %d concurrent submissions, shedding chains over %d bytes, got %d", log.origin, opts.ShedLoadThreshold, opts.ShedChainBytes, size))
+		}
+		chain, err = validateWithTimeout(log.chainValidator, addChainReq, isPrecert, opts.ValidationTimeout, log.validations)
+		if err != nil {
+			if errors.Is(err, errValidationTimeout) {
+				return http.StatusServiceUnavailable, nil, newHandlerError(errCodeValidationTimeout, err)
+			}
+			if errors.Is(err, errTooManyValidations) {
+				w.Header().Set("Retry-After", "1")
+				return http.StatusServiceUnavailable, nil, newHandlerError(errCodeValidationsFull, fmt.Errorf("%s: %v", log.origin, err))
+			}
+			if errors.As(err, &wrongEntryTypeError{}) {
+				return http.StatusBadRequest, nil, newHandlerError(errCodeWrongEntryType, err)
+			}
+			return http.StatusBadRequest, nil, newHandlerError(errCodeInvalidChain, err)
+		}
 	}
 	for _, cert := range chain {
 		opts.RequestLog.addCertToChain(ctx, cert)
 	}
+	opts.RequestLog.root(ctx, sha256.Sum256(chain[len(chain)-1].Raw))
+	if log.precerts != nil && !isPrecert && len(chain) > 1 {
+		issuerKeyHash := sha256.Sum256(chain[1].RawSubjectPublicKeyInfo)
+		if notAfter, ok := log.precerts.get(precertKey(issuerKeyHash[:], chain[0].SerialNumber)); ok {
+			if drift := chain[0].NotAfter.Sub(notAfter).Abs(); opts.MaxNotAfterDrift > 0 && drift > opts.MaxNotAfterDrift {
+				return http.StatusBadRequest, nil, newHandlerError(errCodeNotAfterDrift, fmt.Errorf("%s: certificate NotAfter %s is %v away from its precertificate's %s, more than %v", log.origin, chain[0].NotAfter.Format(time.RFC3339), drift, notAfter.Format(time.RFC3339), opts.MaxNotAfterDrift))
+			}
+			if limit := opts.ShardNotAfterLimit; limit != nil && chain[0].NotAfter.Before(*limit) != notAfter.Before(*limit) {
+				return http.StatusBadRequest, nil, newHandlerError(errCodeWrongShard, fmt.Errorf("%s: certificate NotAfter %s and its precertificate's %s are on different sides of the shard limit %s", log.origin, chain[0].NotAfter.Format(time.RFC3339), notAfter.Format(time.RFC3339), limit.Format(time.RFC3339)))
+			}
+		}
+	}
+	if len(opts.Interceptors) > 0 {
+		s := &Submission{Origin: log.origin, Chain: chain, IsPrecert: isPrecert, Request: r, Overloaded: overloaded}
+		for i, ic := range opts.Interceptors {
+			if err := ic.Intercept(ctx, s); err != nil {
+				return http.StatusBadRequest, nil, newHandlerError(errCodeVetoed, fmt.Errorf("%s: interceptor %d: %v", log.origin, i, err))
+			}
+		}
+	}
+	if log.queue != nil {
+		return enqueueChain(opts, log, w, chain, isPrecert)
+	}
+
+	var seq *sequencedChain
+	if hit != nil {
+		klog.V(3).Infof("%s: %s <= cached SCT", log.origin, method)
+		seq = &sequencedChain{sct: hit.sct, mirrorSCTs: hit.mirrorSCTs, isDup: hit.isDup}
+	} else {
+		var statusCode int
+		seq, statusCode, err = sequenceChain(ctx, opts, log, w.Header(), chain, isPrecert)
+		if err != nil {
+			return statusCode, nil, err
+		}
+	}
+	sctBytes, err := tls.Marshal(*seq.sct)
+	if err != nil {
+		return http.StatusInternalServerError, nil, newHandlerError(errCodeSignSCT, fmt.Errorf("failed to marshall SCT: %s", err))
+	}
+	// We could possibly fail to issue the SCT after this but it's v. unlikely.
+	opts.RequestLog.issueSCT(ctx, sctBytes)
+	if opts.DedupHeader {
+		w.Header().Set(dedupHeader, strconv.FormatBool(seq.isDup))
+	}
+	if err := writeAddChainResponse(r, w, seq.sct, seq.mirrorSCTs); err != nil {
+		// reason is logged and http status is already set
+		return http.StatusInternalServerError, nil, newHandlerError(errCodeWriteResponse, err)
+	}
+	if cached != nil {
+		cached.chain, cached.sct, cached.mirrorSCTs, cached.isDup = chain, seq.sct, seq.mirrorSCTs, seq.isDup
+	}
+	klog.V(3).Infof("%s: %s <= SCT", log.origin, method)
+
+	return http.StatusOK, []attribute.KeyValue{duplicateKey.Bool(seq.isDup)}, nil
+}
+
+// sequencedChain is the outcome of sequencing a validated chain.
+type sequencedChain struct {
+	sct        *rfc6962.SignedCertificateTimestamp
+	mirrorSCTs []*rfc6962.SignedCertificateTimestamp
+	// isDup indicates that the chain had already been logged, in which
+	// case the SCTs are for the existing entry.
+	isDup bool
+}
+
+// sequenceChain builds the log entry of a validated chain, sequences it, and
+// issues its SCTs. If it fails, it returns the status of the failed
+// submission, and sets headers asking clients to retry later in h if needed.
+func sequenceChain(ctx context.Context, opts *HandlerOptions, log *log, h http.Header, chain []*x509.Certificate, isPrecert bool) (*sequencedChain, int, error) {
+	var method entrypointName
+	if isPrecert {
+		method = addPreChainName
+	} else {
+		method = addChainName
+	}
+
 	// Get the current time in the form used throughout RFC6962, namely milliseconds since Unix
 	// epoch, and use this throughout.
 	nanosPerMilli := int64(time.Millisecond / time.Nanosecond)
 	timeMillis := uint64(opts.TimeSource.Now().UnixNano() / nanosPerMilli)
 
-	entry, err := x509util.EntryFromChain(chain, isPrecert, timeMillis)
+	buildEntry := EntryBuilder(x509util.EntryFromChain)
+	if opts.EntryBuilder != nil {
+		buildEntry = opts.EntryBuilder
+	}
+	entry, err := buildEntry(chain, isPrecert, timeMillis)
 	if err != nil {
-		return http.StatusBadRequest, nil, fmt.Errorf("failed to build MerkleTreeLeaf: %s", err)
+		return nil, http.StatusBadRequest, newHandlerError(errCodeBuildEntry, err)
+	}
+
+	// Don't write anything if the log is running out of disk space.
+	if err := opts.checkDiskSpace(); err != nil {
+		var dsErr insufficientDiskSpaceError
+		if errors.As(err, &dsErr) {
+			return nil, http.StatusInsufficientStorage, newHandlerError(errCodeNoDiskSpace, err)
+		}
+		return nil, http.StatusInternalServerError, newHandlerError(errCodeCheckDiskSpace, err)
 	}
 
 	if err := log.storage.AddIssuerChain(ctx, chain[1:]); err != nil {
-		return http.StatusInternalServerError, nil, fmt.Errorf("failed to store issuer chain: %s", err)
+		return nil, http.StatusInternalServerError, newHandlerError(errCodeStoreIssuers, err)
+	}
+	if log.intermediates != nil {
+		log.intermediates.add(chain)
 	}
 
 	klog.V(2).Infof("%s: %s => storage.Add", log.origin, method)
+	log.inflightAdds.inc(ctx, originKey.String(log.origin))
 	index, dedupedTimeMillis, err := log.storage.Add(ctx, entry)
+	log.inflightAdds.dec(ctx, originKey.String(log.origin))
 	if err != nil {
 		if errors.Is(err, tessera.ErrPushback) {
-			w.Header().Add("Retry-After", "1")
-			return http.StatusServiceUnavailable, nil, fmt.Errorf("received pushback from Tessera sequencer: %v", err)
+			h.Add("Retry-After", "1")
+			return nil, http.StatusServiceUnavailable, newHandlerError(errCodePushback, err)
 		}
-		return http.StatusInternalServerError, nil, fmt.Errorf("couldn't store the leaf: %v", err)
+		return nil, http.StatusInternalServerError, newHandlerError(errCodeStoreLeaf, err)
 	}
-	isDup := dedupedTimeMillis != timeMillis
-	dedupedAttribute := duplicateKey.Bool(isDup)
+	if log.precerts != nil && isPrecert {
+		log.precerts.add(precertKey(entry.IssuerKeyHash, chain[0].SerialNumber), chain[0].NotAfter)
+	}
+	isDup := dedupedTimeMillis != entry.Timestamp
 	entry.Timestamp = dedupedTimeMillis
 
+	if opts.VerifyAfterWrite {
+		if err := verifyLoggedEntry(ctx, log.storage, entry, index); err != nil {
+			return nil, http.StatusInternalServerError, newHandlerError(errCodeVerifyLeaf, err)
+		}
+	}
+
 	// Always use the returned leaf as the basis for an SCT.
 	var loggedLeaf rfc6962.MerkleTreeLeaf
 	leafValue := entry.MerkleTreeLeaf(index)
 	if rest, err := tls.Unmarshal(leafValue, &loggedLeaf); err != nil {
-		return http.StatusInternalServerError, nil, fmt.Errorf("failed to reconstruct MerkleTreeLeaf: %s", err)
+		return nil, http.StatusInternalServerError, newHandlerError(errCodeReconstructLeaf, err)
 	} else if len(rest) > 0 {
-		return http.StatusInternalServerError, nil, fmt.Errorf("extra data (%d bytes) on reconstructing MerkleTreeLeaf", len(rest))
+		return nil, http.StatusInternalServerError, newHandlerError(errCodeReconstructLeaf, fmt.Errorf("extra data (%d bytes)", len(rest)))
 	}
 
 	// As the Log server has definitely got the Merkle tree leaf, we can
 	// generate an SCT and respond with it.
-	sct, err := log.signSCT(&loggedLeaf)
-	if err != nil {
-		return http.StatusInternalServerError, nil, fmt.Errorf("failed to generate SCT: %s", err)
+	signSCT := log.signSCT
+	if opts.SCTSigner != nil {
+		signSCT = opts.SCTSigner.Sign
 	}
-	sctBytes, err := tls.Marshal(*sct)
+	sct, err := signSCT(&loggedLeaf)
 	if err != nil {
-		return http.StatusInternalServerError, nil, fmt.Errorf("failed to marshall SCT: %s", err)
+		statusCode, err := signErrorStatus(h, err)
+		return nil, statusCode, err
 	}
-	// We could possibly fail to issue the SCT after this but it's v. unlikely.
-	opts.RequestLog.issueSCT(ctx, sctBytes)
-	err = marshalAndWriteAddChainResponse(sct, w)
+	// The chain is logged: resubmissions after mirror failures are deduplicated.
+	mirrorSCTs, err := submitToMirrors(ctx, opts.Mirrors, chain, isPrecert)
 	if err != nil {
-		// reason is logged and http status is already set
-		return http.StatusInternalServerError, nil, fmt.Errorf("failed to write response: %s", err)
+		return nil, http.StatusBadGateway, newHandlerError(errCodeMirrorSubmit, err)
 	}
-	klog.V(3).Infof("%s: %s <= SCT", log.origin, method)
 	if !isDup {
 		lastSCTTimestamp.Record(ctx, otel.Clamp64(sct.Timestamp), metric.WithAttributes(originKey.String(log.origin)))
 		lastSCTIndex.Record(ctx, otel.Clamp64(index), metric.WithAttributes(originKey.String(log.origin)))
 	}
+	return &sequencedChain{sct: sct, mirrorSCTs: mirrorSCTs, isDup: isDup}, http.StatusOK, nil
+}
 
-	return http.StatusOK, []attribute.KeyValue{dedupedAttribute}, nil
+// signErrorStatus returns the status and error of add-chain and add-pre-chain
+// requests which failed to sign an SCT with err. Clients are asked to retry
+// later, in h, if the signer is temporarily unavailable.
+func signErrorStatus(h http.Header, err error) (int, error) {
+	if isSignerUnavailable(err) {
+		h.Set("Retry-After", "1")
+		return http.StatusServiceUnavailable, newHandlerError(errCodeSignerUnavailable, err)
+	}
+	return http.StatusInternalServerError, newHandlerError(errCodeSignSCT, err)
 }
 
 func addChain(ctx context.Context, opts *HandlerOptions, log *log, w http.ResponseWriter, r *http.Request) (int, []attribute.KeyValue, error) {
@@ -344,47 +1200,318 @@ func getRoots(ctx context.Context, opts *HandlerOptions, log *log, w http.Respon
 	_, span := tracer.Start(ctx, "tesseract.getRoots")
 	defer span.End()
 
-	// Pull out the raw certificates from the parsed versions
 	// TODO(phbnf): precompute the answer
-	rawCerts := make([][]byte, 0, len(log.chainValidator.Roots()))
-	for _, cert := range log.chainValidator.Roots() {
-		rawCerts = append(rawCerts, cert.Raw)
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	if opts.GetRootsMaxAge > 0 {
+		w.Header().Set(cacheControlHeader, fmt.Sprintf("public, max-age=%d", int64(opts.GetRootsMaxAge.Seconds())))
+		w.Header().Set(expiresHeader, opts.TimeSource.Now().Add(opts.GetRootsMaxAge).UTC().Format(http.TimeFormat))
 	}
+	write := writeGetRootsResponse
+	if log.intermediates != nil {
+		write = func(w io.Writer, roots []*x509.Certificate) error {
+			return writeGetRootsWithIntermediatesResponse(w, roots, log.intermediates)
+		}
+	}
+	cw := &countingWriter{w: w}
+	if err := write(cw, log.chainValidator.Roots()); err != nil {
+		klog.Warningf("%s: get_roots failed after writing %d bytes: %v", log.origin, cw.n, err)
+		if cw.n > 0 {
+			err = fmt.Errorf("%w: %v", errResponseStarted, err)
+		}
+		return http.StatusInternalServerError, nil, newHandlerError(errCodeGetRoots, err)
+	}
+
+	return http.StatusOK, nil, nil
+}
+
+// getTreeHeadResponse is the JSON response to get-tree-head requests.
+type getTreeHeadResponse struct {
+	TreeSize       uint64 `json:"tree_size"`
+	SHA256RootHash []byte `json:"sha256_root_hash"`
+}
 
-	jsonMap := make(map[string]any)
-	jsonMap[jsonMapKeyCertificates] = rawCerts
-	enc := json.NewEncoder(w)
-	err := enc.Encode(jsonMap)
+// getTreeHead returns the size and root hash of the latest checkpoint.
+//
+// This is meant for lightweight health checks: the checkpoint signature is
+// neither checked nor returned.
+func getTreeHead(ctx context.Context, opts *HandlerOptions, log *log, w http.ResponseWriter, _ *http.Request) (int, []attribute.KeyValue, error) {
+	ctx, span := tracer.Start(ctx, "tesseract.getTreeHead")
+	defer span.End()
+
+	cpRaw, err := log.storage.ReadCheckpoint(ctx)
 	if err != nil {
-		klog.Warningf("%s: get_roots failed: %v", log.origin, err)
-		return http.StatusInternalServerError, nil, fmt.Errorf("get-roots failed with: %s", err)
+		return http.StatusInternalServerError, nil, newHandlerError(errCodeReadCheckpoint, err)
+	}
+	cp := tfl.Checkpoint{}
+	if _, err := cp.Unmarshal(cpRaw); err != nil {
+		return http.StatusInternalServerError, nil, newHandlerError(errCodeReadCheckpoint, fmt.Errorf("failed to parse checkpoint: %v", err))
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	if err := json.NewEncoder(w).Encode(getTreeHeadResponse{TreeSize: cp.Size, SHA256RootHash: cp.Hash}); err != nil {
+		klog.Warningf("%s: get_tree_head failed: %v", log.origin, err)
+		return http.StatusInternalServerError, nil, newHandlerError(errCodeWriteResponse, err)
 	}
 
 	return http.StatusOK, nil, nil
 }
 
-// marshalAndWriteAddChainResponse is used by add-chain and add-pre-chain to create and write
-// the JSON response to the client
-func marshalAndWriteAddChainResponse(sct *rfc6962.SignedCertificateTimestamp, w http.ResponseWriter) error {
-	sig, err := tls.Marshal(sct.Signature)
+// getIssuer returns the DER issuer certificate stored under the SHA-256
+// fingerprint given in the getIssuerParamHash parameter, hex encoded.
+func getIssuer(ctx context.Context, _ *HandlerOptions, log *log, w http.ResponseWriter, r *http.Request) (int, []attribute.KeyValue, error) {
+	ctx, span := tracer.Start(ctx, "tesseract.getIssuer")
+	defer span.End()
+
+	hash, err := hex.DecodeString(r.Form.Get(getIssuerParamHash))
 	if err != nil {
-		return fmt.Errorf("failed to marshal signature: %s", err)
+		return http.StatusBadRequest, nil, newHandlerError(errCodeInvalidIssuerHash, err)
+	}
+	if len(hash) != sha256.Size {
+		return http.StatusBadRequest, nil, newHandlerError(errCodeInvalidIssuerHash, fmt.Errorf("got %d bytes, want %d", len(hash), sha256.Size))
 	}
 
-	rsp := rfc6962.AddChainResponse{
+	der, err := log.storage.ReadIssuer(ctx, hash)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return http.StatusNotFound, nil, newHandlerError(errCodeIssuerNotFound, err)
+		}
+		return http.StatusInternalServerError, nil, newHandlerError(errCodeReadIssuer, err)
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypePKIXCert)
+	if _, err := w.Write(der); err != nil {
+		klog.Warningf("%s: get_issuer failed: %v", log.origin, err)
+		return http.StatusInternalServerError, nil, newHandlerError(errCodeWriteResponse, err)
+	}
+
+	return http.StatusOK, nil, nil
+}
+
+// getRootsWithIntermediatesResponse is the JSON response to get-roots
+// requests when HandlerOptions.GetRootsIntermediates is set. It extends
+// rfc6962.GetRootsResponse with the intermediates chaining to each root.
+type getRootsWithIntermediatesResponse struct {
+	Certificates [][]byte                `json:"certificates"`
+	Roots        []rootWithIntermediates `json:"roots"`
+}
+
+// rootWithIntermediates holds a DER root and the DER intermediates known to
+// chain to it.
+type rootWithIntermediates struct {
+	Certificate   []byte   `json:"certificate"`
+	Intermediates [][]byte `json:"intermediates"`
+}
+
+// writeGetRootsWithIntermediatesResponse writes a JSON encoded
+// getRootsWithIntermediatesResponse to w, with the intermediates of each root
+// recorded in known.
+func writeGetRootsWithIntermediatesResponse(w io.Writer, roots []*x509.Certificate, known *knownIntermediates) error {
+	resp := getRootsWithIntermediatesResponse{
+		Certificates: make([][]byte, 0, len(roots)),
+		Roots:        make([]rootWithIntermediates, 0, len(roots)),
+	}
+	for _, root := range roots {
+		resp.Certificates = append(resp.Certificates, root.Raw)
+		resp.Roots = append(resp.Roots, rootWithIntermediates{Certificate: root.Raw, Intermediates: known.list(root)})
+	}
+	return json.NewEncoder(w).Encode(resp)
+}
+
+// errResponseStarted marks handler errors that happen after part of the
+// response has been written: it is too late to send an error response.
+var errResponseStarted = errors.New("response already started")
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// writeGetRootsResponse streams a JSON encoded rfc6962.GetRootsResponse to w.
+//
+// Certificates are base64 encoded and written one at a time, so that the full
+// response never needs to be held in memory, even for very large root sets.
+func writeGetRootsResponse(w io.Writer, roots []*x509.Certificate) error {
+	if _, err := io.WriteString(w, `{"`+jsonMapKeyCertificates+`":[`); err != nil {
+		return err
+	}
+	for i, cert := range roots {
+		sep := `"`
+		if i > 0 {
+			sep = `,"`
+		}
+		if _, err := io.WriteString(w, sep); err != nil {
+			return err
+		}
+		enc := base64.NewEncoder(base64.StdEncoding, w)
+		if _, err := enc.Write(cert.Raw); err != nil {
+			return err
+		}
+		if err := enc.Close(); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, `"`); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "]}\n")
+	return err
+}
+
+// verifyLoggedEntry reads back the entry stored at index, and checks that it
+// matches want on all the fields covered by an SCT.
+//
+// Other fields, such as the chain fingerprints, might legitimately differ for
+// deduplicated entries.
+func verifyLoggedEntry(ctx context.Context, s Storage, want *ctonly.Entry, index uint64) error {
+	raw, err := s.ReadEntry(ctx, index)
+	if err != nil {
+		return fmt.Errorf("failed to read entry at index %d: %v", index, err)
+	}
+	var got, wantEntry staticct.Entry
+	if err := got.UnmarshalText(raw); err != nil {
+		return fmt.Errorf("failed to parse entry at index %d: %v", index, err)
+	}
+	if err := wantEntry.UnmarshalText(want.LeafData(index)); err != nil {
+		return fmt.Errorf("failed to parse submitted entry: %v", err)
+	}
+
+	switch {
+	case got.LeafIndex != wantEntry.LeafIndex:
+		return fmt.Errorf("logged entry has index %d, want %d", got.LeafIndex, wantEntry.LeafIndex)
+	case got.Timestamp != wantEntry.Timestamp:
+		return fmt.Errorf("logged entry has timestamp %d, want %d", got.Timestamp, wantEntry.Timestamp)
+	case got.IsPrecert != wantEntry.IsPrecert:
+		return fmt.Errorf("logged entry has IsPrecert %t, want %t", got.IsPrecert, wantEntry.IsPrecert)
+	case !bytes.Equal(got.Certificate, wantEntry.Certificate):
+		return errors.New("logged entry certificate mismatch")
+	case !bytes.Equal(got.IssuerKeyHash, wantEntry.IssuerKeyHash):
+		return errors.New("logged entry issuer key hash mismatch")
+	}
+	return nil
+}
+
+// addChainResponse is an rfc6962.AddChainResponse, which can also carry SCTs
+// issued by mirror logs.
+type addChainResponse struct {
+	rfc6962.AddChainResponse
+	AdditionalSCTs []rfc6962.AddChainResponse `json:"additional_scts,omitempty"`
+}
+
+// toAddChainResponse converts an SCT to its add-chain JSON representation.
+func toAddChainResponse(sct *rfc6962.SignedCertificateTimestamp) (rfc6962.AddChainResponse, error) {
+	sig, err := tls.Marshal(sct.Signature)
+	if err != nil {
+		return rfc6962.AddChainResponse{}, fmt.Errorf("failed to marshal signature: %s", err)
+	}
+	return rfc6962.AddChainResponse{
 		SCTVersion: sct.SCTVersion,
 		Timestamp:  sct.Timestamp,
 		ID:         sct.LogID.KeyID[:],
 		Extensions: base64.StdEncoding.EncodeToString(sct.Extensions),
 		Signature:  sig,
+	}, nil
+}
+
+// writeAddChainResponse writes an add-chain or add-pre-chain response carrying
+// sct and mirrorSCTs, in binary if r accepts it, or JSON otherwise.
+//
+// contentTypeSCT responses only hold the log's SCT, and no mirror SCTs.
+// contentTypeSCTList responses hold the log's SCT followed by mirror SCTs.
+func writeAddChainResponse(r *http.Request, w http.ResponseWriter, sct *rfc6962.SignedCertificateTimestamp, mirrorSCTs []*rfc6962.SignedCertificateTimestamp) error {
+	switch binarySCTContentType(r) {
+	case contentTypeSCT:
+		sctBytes, err := tls.Marshal(*sct)
+		if err != nil {
+			return fmt.Errorf("failed to marshal SCT: %s", err)
+		}
+		return writeBinarySCTResponse(contentTypeSCT, sctBytes, w)
+	case contentTypeSCTList:
+		sctList, err := marshalSCTListExtension(append([]*rfc6962.SignedCertificateTimestamp{sct}, mirrorSCTs...))
+		if err != nil {
+			return err
+		}
+		return writeBinarySCTResponse(contentTypeSCTList, sctList, w)
+	default:
+		return marshalAndWriteAddChainResponse(sct, mirrorSCTs, w)
 	}
+}
 
-	w.Header().Set(contentTypeHeader, contentTypeJSON)
+// writeBinarySCTResponse is used by add-chain and add-pre-chain to write
+// binary SCTs of the given contentType, to clients which accept them.
+func writeBinarySCTResponse(contentType string, sctBytes []byte, w http.ResponseWriter) error {
+	w.Header().Set(contentTypeHeader, contentType)
+	if _, err := w.Write(sctBytes); err != nil {
+		return fmt.Errorf("failed to write add-chain resp: %s", err)
+	}
+	return nil
+}
+
+// marshalSCTListExtension returns the value of an X.509 extension embedding
+// scts: their TLS-encoded SignedCertificateTimestampList, as per RFC 6962
+// s3.3, wrapped in an ASN.1 OCTET STRING.
+func marshalSCTListExtension(scts []*rfc6962.SignedCertificateTimestamp) ([]byte, error) {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, sct := range scts {
+			sctBytes, err := tls.Marshal(*sct)
+			if err != nil {
+				b.SetError(fmt.Errorf("failed to marshal SCT: %s", err))
+				return
+			}
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddBytes(sctBytes)
+			})
+		}
+	})
+	sctList, err := b.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build SCT list: %s", err)
+	}
+	ext, err := asn1.Marshal(sctList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal SCT list extension: %s", err)
+	}
+	return ext, nil
+}
+
+// marshalAddChainResponse returns the JSON add-chain response carrying sct
+// and mirrorSCTs.
+func marshalAddChainResponse(sct *rfc6962.SignedCertificateTimestamp, mirrorSCTs []*rfc6962.SignedCertificateTimestamp) ([]byte, error) {
+	r, err := toAddChainResponse(sct)
+	if err != nil {
+		return nil, err
+	}
+	rsp := addChainResponse{AddChainResponse: r}
+	for _, mirrorSCT := range mirrorSCTs {
+		r, err := toAddChainResponse(mirrorSCT)
+		if err != nil {
+			return nil, err
+		}
+		rsp.AdditionalSCTs = append(rsp.AdditionalSCTs, r)
+	}
 	jsonData, err := json.Marshal(&rsp)
 	if err != nil {
-		return fmt.Errorf("failed to marshal add-chain: %s", err)
+		return nil, fmt.Errorf("failed to marshal add-chain: %s", err)
 	}
+	return jsonData, nil
+}
 
+// marshalAndWriteAddChainResponse is used by add-chain and add-pre-chain to create and write
+// the JSON response to the client
+func marshalAndWriteAddChainResponse(sct *rfc6962.SignedCertificateTimestamp, mirrorSCTs []*rfc6962.SignedCertificateTimestamp, w http.ResponseWriter) error {
+	jsonData, err := marshalAddChainResponse(sct, mirrorSCTs)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
 	_, err = w.Write(jsonData)
 	if err != nil {
 		return fmt.Errorf("failed to write add-chain resp: %s", err)
@@ -18,27 +18,46 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/sha256"
+	cryptotls "crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
 	"io"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	tfl "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/merkle/compact"
+	merklerfc6962 "github.com/transparency-dev/merkle/rfc6962"
 	"github.com/transparency-dev/tesseract/internal/testdata"
 	"github.com/transparency-dev/tesseract/internal/testonly/storage/posix"
 	"github.com/transparency-dev/tesseract/internal/types/rfc6962"
 	"github.com/transparency-dev/tesseract/internal/types/staticct"
+	"github.com/transparency-dev/tesseract/internal/types/tls"
 	"github.com/transparency-dev/tesseract/internal/x509util"
 	"github.com/transparency-dev/tesseract/storage"
 	"github.com/transparency-dev/tessera"
@@ -46,6 +65,7 @@ import (
 	"github.com/transparency-dev/tessera/ctonly"
 	posixTessera "github.com/transparency-dev/tessera/storage/posix"
 	badger_as "github.com/transparency-dev/tessera/storage/posix/antispam"
+	"golang.org/x/crypto/cryptobyte"
 	"golang.org/x/mod/sumdb/note"
 	"k8s.io/klog/v2"
 )
@@ -154,7 +174,7 @@ func setupTestServer(t *testing.T, log *log, path string) *httptest.Server {
 func newPOSIXStorageFunc(t *testing.T, root string) storage.CreateStorage {
 	t.Helper()
 
-	return func(ctx context.Context, signer note.Signer) (*storage.CTStorage, error) {
+	return func(ctx context.Context, signer note.Signer, additionalSigners ...note.Signer) (*storage.CTStorage, error) {
 		driver, err := posixTessera.New(ctx, path.Join(root, logDir))
 		if err != nil {
 			klog.Fatalf("Failed to initialize POSIX Tessera storage driver: %v", err)
@@ -170,7 +190,7 @@ func newPOSIXStorageFunc(t *testing.T, root string) storage.CreateStorage {
 		}
 
 		opts := tessera.NewAppendOptions().
-			WithCheckpointSigner(signer).
+			WithCheckpointSigner(signer, additionalSigners...).
 			WithCTLayout().
 			WithAntispam(256, antispam).
 			WithCheckpointInterval(time.Second)
@@ -180,12 +200,12 @@ func newPOSIXStorageFunc(t *testing.T, root string) storage.CreateStorage {
 			klog.Fatalf("Failed to initialize POSIX Tessera appender: %v", err)
 		}
 
-		issuerStorage, err := posix.NewIssuerStorage(path.Join(root, issDir))
+		issuerStorage, err := posix.NewIssuerStorage(path.Join(root, issDir), false)
 		if err != nil {
 			klog.Fatalf("failed to initialize InMemory issuer storage: %v", err)
 		}
 
-		s, err := storage.NewCTStorage(t.Context(), appender, issuerStorage, reader)
+		s, err := storage.NewCTStorage(t.Context(), appender, issuerStorage, reader, storage.CTStorageOpts{Antispam: antispam})
 		if err != nil {
 			klog.Fatalf("Failed to initialize CTStorage: %v", err)
 		}
@@ -193,6 +213,132 @@ func newPOSIXStorageFunc(t *testing.T, root string) storage.CreateStorage {
 	}
 }
 
+// fakeStorage is an in-memory Storage which records the entries it is given.
+type fakeStorage struct {
+	mu      sync.Mutex
+	entries []*ctonly.Entry
+	// issuers holds issuer certificates by SHA-256 fingerprint.
+	issuers map[[sha256.Size]byte][]byte
+	// dedup indicates that entries with the same certificate as a previous
+	// entry are deduplicated to it.
+	dedup bool
+}
+
+func (s *fakeStorage) Add(_ context.Context, e *ctonly.Entry) (uint64, uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dedup {
+		for i, prev := range s.entries {
+			if bytes.Equal(prev.Certificate, e.Certificate) {
+				return uint64(i), prev.Timestamp, nil
+			}
+		}
+	}
+	s.entries = append(s.entries, e)
+	return uint64(len(s.entries) - 1), e.Timestamp, nil
+}
+
+func (s *fakeStorage) AddIssuerChain(_ context.Context, chain []*x509.Certificate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.issuers == nil {
+		s.issuers = make(map[[sha256.Size]byte][]byte)
+	}
+	for _, c := range chain {
+		s.issuers[sha256.Sum256(c.Raw)] = c.Raw
+	}
+	return nil
+}
+
+func (s *fakeStorage) ReadEntry(_ context.Context, index uint64) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index >= uint64(len(s.entries)) {
+		return nil, fmt.Errorf("no entry at index %d", index)
+	}
+	return s.entries[index].LeafData(index), nil
+}
+
+// ReadCheckpoint returns an unsigned checkpoint covering all the entries
+// added so far, since fakeStorage integrates entries as soon as they are added.
+func (s *fakeStorage) ReadCheckpoint(_ context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hasher := merklerfc6962.DefaultHasher
+	r := (&compact.RangeFactory{Hash: hasher.HashChildren}).NewEmptyRange(0)
+	for i, e := range s.entries {
+		if err := r.Append(hasher.HashLeaf(e.MerkleTreeLeaf(uint64(i))), nil); err != nil {
+			return nil, err
+		}
+	}
+	root := hasher.EmptyRoot()
+	if len(s.entries) > 0 {
+		var err error
+		if root, err = r.GetRootHash(nil); err != nil {
+			return nil, err
+		}
+	}
+	return tfl.Checkpoint{Origin: origin, Size: uint64(len(s.entries)), Hash: root}.Marshal(), nil
+}
+
+func (s *fakeStorage) ReadIssuer(_ context.Context, fingerprint []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	der, ok := s.issuers[[sha256.Size]byte(fingerprint)]
+	if !ok {
+		return nil, fmt.Errorf("issuer %x: %w", fingerprint, os.ErrNotExist)
+	}
+	return der, nil
+}
+
+// tamperingStorage is a fakeStorage which reads back entries with a different timestamp.
+type tamperingStorage struct {
+	fakeStorage
+}
+
+func (s *tamperingStorage) ReadEntry(_ context.Context, index uint64) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := *s.entries[index]
+	e.Timestamp++
+	return e.LeafData(index), nil
+}
+
+// slowStorage is a fakeStorage whose Add calls block until release is closed.
+type slowStorage struct {
+	fakeStorage
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *slowStorage) Add(ctx context.Context, e *ctonly.Entry) (uint64, uint64, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return s.fakeStorage.Add(ctx, e)
+}
+
+// setupFakeStorageLog creates a test TesseraCT log backed by s.
+func setupFakeStorageLog(t *testing.T, s Storage) *log {
+	t.Helper()
+
+	sctSigner, err := setupSCTSigner(fakeSignature)
+	if err != nil {
+		t.Fatalf("Failed to create test signer: %v", err)
+	}
+
+	roots := x509util.NewPEMCertPool()
+	if err := roots.AppendCertsFromPEMFile(testRootPath); err != nil {
+		t.Fatalf("Failed to read trusted roots: %v", err)
+	}
+
+	return &log{
+		origin:         origin,
+		signSCT:        sctSigner.Sign,
+		chainValidator: chainValidator{trustedRoots: roots},
+		storage:        s,
+	}
+}
+
 func getHandlers(t *testing.T, handlers pathHandlers) pathHandlers {
 	t.Helper()
 	path := path.Join(prefix, rfc6962.GetRootsPath)
@@ -322,7 +468,7 @@ func TestNewPathHandlers(t *testing.T) {
 			t.Errorf("Handler names mismatch got: %v, want: %v", hNames, entrypoints)
 		}
 
-		entrypaths := []string{prefix + rfc6962.AddChainPath, prefix + rfc6962.AddPreChainPath, prefix + rfc6962.GetRootsPath}
+		entrypaths := []string{prefix + rfc6962.AddChainPath, prefix + rfc6962.AddPreChainPath, prefix + rfc6962.GetRootsPath, prefix + getTreeHeadPath, prefix + getIssuerPath, prefix + getEntrypointsPath}
 		if !cmp.Equal(entrypaths, hPaths, cmpopts.SortSlices(func(n1, n2 string) bool {
 			return n1 < n2
 		})) {
@@ -384,6 +530,535 @@ func TestGetRoots(t *testing.T) {
 	}
 }
 
+func TestGetRootsIntermediates(t *testing.T) {
+	opts := hOpts
+	opts.GetRootsIntermediates = true
+	log := setupFakeStorageLog(t, &fakeStorage{})
+	mux := http.NewServeMux()
+	for p, h := range NewPathHandlers(t.Context(), &opts, log) {
+		mux.Handle(p, h)
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// getRoots returns the raw get-roots response.
+	getRoots := func() []byte {
+		t.Helper()
+		resp, err := http.Get(server.URL + path.Join(prefix, rfc6962.GetRootsPath))
+		if err != nil {
+			t.Fatalf("Failed to get roots: %v", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("get-roots status=%d, want %d", got, want)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("io.ReadAll()=%v", err)
+		}
+		return body
+	}
+
+	root := pemToCert(t, testdata.CACertPEM)
+	intermediate := pemToCert(t, testdata.IntermediateFromRoot)
+	for _, tc := range []struct {
+		desc  string
+		chain []string
+		want  [][]byte
+	}{
+		{
+			desc: "no-submissions",
+			want: [][]byte{},
+		},
+		{
+			desc:  "chain-with-intermediate",
+			chain: []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM},
+			want:  [][]byte{intermediate.Raw},
+		},
+		{
+			desc:  "same-intermediate-again",
+			chain: []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM},
+			want:  [][]byte{intermediate.Raw},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if len(tc.chain) > 0 {
+				pool := loadCertsIntoPoolOrDie(t, tc.chain)
+				resp, err := http.Post(server.URL+path.Join(prefix, rfc6962.AddChainPath), "application/json", createJSONChain(t, *pool))
+				if err != nil {
+					t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+				}
+				if got, want := resp.StatusCode, http.StatusOK; got != want {
+					t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+				}
+			}
+
+			body := getRoots()
+			// Responses must remain valid get-roots responses.
+			var plain rfc6962.GetRootsResponse
+			if err := json.Unmarshal(body, &plain); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if got, want := plain.Certificates, []string{base64.StdEncoding.EncodeToString(root.Raw)}; !slices.Equal(got, want) {
+				t.Errorf("certificates=%v, want %v", got, want)
+			}
+
+			var grouped getRootsWithIntermediatesResponse
+			if err := json.Unmarshal(body, &grouped); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if got, want := len(grouped.Roots), 1; got != want {
+				t.Fatalf("len(roots)=%d, want %d", got, want)
+			}
+			if got, want := grouped.Roots[0].Certificate, root.Raw; !bytes.Equal(got, want) {
+				t.Errorf("roots[0].certificate=%x, want %x", got, want)
+			}
+			if got, want := grouped.Roots[0].Intermediates, tc.want; !slices.EqualFunc(got, want, bytes.Equal) {
+				t.Errorf("roots[0].intermediates=%x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func TestGetRootsCacheHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		desc             string
+		maxAge           time.Duration
+		wantCacheControl string
+		wantExpires      string
+	}{
+		{
+			desc: "disabled",
+		},
+		{
+			desc:             "one-hour",
+			maxAge:           time.Hour,
+			wantCacheControl: "public, max-age=3600",
+			wantExpires:      "Fri, 22 Jul 2016 12:01:13 GMT",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := hOpts
+			opts.TimeSource = newFakeTimeSource(fakeTimeStart)
+			opts.GetRootsMaxAge = tc.maxAge
+			log := setupFakeStorageLog(t, &fakeStorage{})
+			handler := NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.GetRootsPath)]
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			resp, err := http.Get(server.URL + path.Join(prefix, rfc6962.GetRootsPath))
+			if err != nil {
+				t.Fatalf("Failed to get roots: %v", err)
+			}
+			if got, want := resp.StatusCode, http.StatusOK; got != want {
+				t.Errorf("Unexpected status code: got %d, want %d", got, want)
+			}
+			if got, want := resp.Header.Get(cacheControlHeader), tc.wantCacheControl; got != want {
+				t.Errorf("%s=%q, want %q", cacheControlHeader, got, want)
+			}
+			if got, want := resp.Header.Get(expiresHeader), tc.wantExpires; got != want {
+				t.Errorf("%s=%q, want %q", expiresHeader, got, want)
+			}
+		})
+	}
+}
+
+// failingResponseWriter fails writes once more than limit bytes are written.
+type failingResponseWriter struct {
+	*httptest.ResponseRecorder
+	limit int
+}
+
+func (f *failingResponseWriter) Write(p []byte) (int, error) {
+	if f.Body.Len()+len(p) > f.limit {
+		n := 0
+		if left := f.limit - f.Body.Len(); left > 0 {
+			n, _ = f.ResponseRecorder.Write(p[:left])
+		}
+		return n, errors.New("connection reset")
+	}
+	return f.ResponseRecorder.Write(p)
+}
+
+func TestGetRootsWriteFailure(t *testing.T) {
+	log := setupFakeStorageLog(t, &fakeStorage{})
+	handler := NewPathHandlers(t.Context(), &hOpts, log)[path.Join(prefix, rfc6962.GetRootsPath)]
+	for _, tc := range []struct {
+		desc      string
+		limit     int
+		wantAbort bool
+		wantCode  int
+	}{
+		{
+			desc:     "before-first-byte",
+			limit:    0,
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			desc:      "mid-stream",
+			limit:     50,
+			wantAbort: true,
+			wantCode:  http.StatusOK,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			w := &failingResponseWriter{ResponseRecorder: httptest.NewRecorder(), limit: tc.limit}
+			req := httptest.NewRequest(http.MethodGet, path.Join(prefix, rfc6962.GetRootsPath), nil)
+			var recovered any
+			func() {
+				defer func() { recovered = recover() }()
+				handler.ServeHTTP(w, req)
+			}()
+			if tc.wantAbort {
+				if recovered != http.ErrAbortHandler {
+					t.Errorf("ServeHTTP() panicked with %v, want %v", recovered, http.ErrAbortHandler)
+				}
+			} else if recovered != nil {
+				t.Fatalf("ServeHTTP() panicked with %v", recovered)
+			}
+			if got, want := w.Code, tc.wantCode; got != want {
+				t.Errorf("status=%d, want %d", got, want)
+			}
+			if got := w.Result().Header.Get(errorCodeHeader); tc.wantAbort && got != "" {
+				t.Errorf("%s=%q on an aborted response, want none", errorCodeHeader, got)
+			}
+			if got := w.Body.String(); strings.Contains(got, errorCatalog[errCodeGetRoots]) {
+				t.Errorf("body=%q, want no error appended to the partial response", got)
+			}
+		})
+	}
+}
+
+func TestGetTreeHead(t *testing.T) {
+	s := &fakeStorage{}
+	log := setupFakeStorageLog(t, s)
+	handlers := NewPathHandlers(t.Context(), &hOpts, log)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers[r.URL.Path].ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	getTreeHead := func() getTreeHeadResponse {
+		t.Helper()
+		resp, err := http.Get(server.URL + path.Join(prefix, getTreeHeadPath))
+		if err != nil {
+			t.Fatalf("http.Get(%s)=(_,%q); want (_,nil)", getTreeHeadPath, err)
+		}
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("http.Get(%s)=(%d,nil); want (%d,nil)", getTreeHeadPath, got, want)
+		}
+		var th getTreeHeadResponse
+		if err := json.NewDecoder(resp.Body).Decode(&th); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return th
+	}
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	var prevRoot []byte
+	for size := range uint64(3) {
+		th := getTreeHead()
+		if got, want := th.TreeSize, size; got != want {
+			t.Errorf("tree_size=%d, want %d", got, want)
+		}
+		if len(th.SHA256RootHash) != sha256.Size {
+			t.Errorf("len(sha256_root_hash)=%d, want %d", len(th.SHA256RootHash), sha256.Size)
+		}
+		if bytes.Equal(th.SHA256RootHash, prevRoot) {
+			t.Errorf("sha256_root_hash=%x, unchanged after a submission", th.SHA256RootHash)
+		}
+		prevRoot = th.SHA256RootHash
+
+		resp, err := http.Post(server.URL+path.Join(prefix, rfc6962.AddChainPath), "application/json", createJSONChain(t, *pool))
+		if err != nil {
+			t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+		}
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+		}
+	}
+}
+
+func TestGetIssuer(t *testing.T) {
+	log := setupFakeStorageLog(t, &fakeStorage{})
+	handlers := NewPathHandlers(t.Context(), &hOpts, log)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers[r.URL.Path].ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	resp, err := http.Post(server.URL+path.Join(prefix, rfc6962.AddChainPath), "application/json", createJSONChain(t, *pool))
+	if err != nil {
+		t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+	}
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+	}
+	intermediate := pool.RawCertificates()[1]
+	intermediateHash := sha256.Sum256(intermediate.Raw)
+	leafHash := sha256.Sum256(pool.RawCertificates()[0].Raw)
+
+	for _, test := range []struct {
+		desc     string
+		hash     string
+		want     int
+		wantCode errorCode
+		wantDER  []byte
+	}{
+		{
+			desc:    "intermediate",
+			hash:    hex.EncodeToString(intermediateHash[:]),
+			want:    http.StatusOK,
+			wantDER: intermediate.Raw,
+		},
+		{
+			desc:     "leaf-not-stored",
+			hash:     hex.EncodeToString(leafHash[:]),
+			want:     http.StatusNotFound,
+			wantCode: errCodeIssuerNotFound,
+		},
+		{
+			desc:     "not-hex",
+			hash:     "not-hex",
+			want:     http.StatusBadRequest,
+			wantCode: errCodeInvalidIssuerHash,
+		},
+		{
+			desc:     "too-short",
+			hash:     hex.EncodeToString(intermediateHash[:16]),
+			want:     http.StatusBadRequest,
+			wantCode: errCodeInvalidIssuerHash,
+		},
+	} {
+		t.Run(test.desc, func(t *testing.T) {
+			u := server.URL + path.Join(prefix, getIssuerPath) + "?" + url.Values{getIssuerParamHash: {test.hash}}.Encode()
+			resp, err := http.Get(u)
+			if err != nil {
+				t.Fatalf("http.Get(%s)=(_,%q); want (_,nil)", u, err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			if got, want := resp.StatusCode, test.want; got != want {
+				t.Fatalf("http.Get(%s)=(%d,nil); want (%d,nil)", u, got, want)
+			}
+			if got, want := errorCode(resp.Header.Get(errorCodeHeader)), test.wantCode; got != want {
+				t.Errorf("%s=%q, want %q", errorCodeHeader, got, want)
+			}
+			if test.want != http.StatusOK {
+				return
+			}
+			if got, want := resp.Header.Get(contentTypeHeader), contentTypePKIXCert; got != want {
+				t.Errorf("%s=%q, want %q", contentTypeHeader, got, want)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to read response: %v", err)
+			}
+			if !bytes.Equal(body, test.wantDER) {
+				t.Errorf("http.Get(%s) returned %x, want %x", u, body, test.wantDER)
+			}
+		})
+	}
+}
+
+func TestRejectedSubmissions(t *testing.T) {
+	log := setupFakeStorageLog(t, &fakeStorage{})
+	opts := hOpts
+	opts.RejectedSubmissionSamples = 2
+	handlers := NewPathHandlers(t.Context(), &opts, log)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers[r.URL.Path].ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	admin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		NewAdminPathHandlers(&opts, log)[r.URL.Path].ServeHTTP(w, r)
+	}))
+	defer admin.Close()
+
+	// Rejected submissions are not served publicly.
+	if _, ok := handlers[path.Join(prefix, getRejectedSubmissionsPath)]; ok {
+		t.Errorf("%s is served with public handlers", getRejectedSubmissionsPath)
+	}
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	validChain, err := io.ReadAll(createJSONChain(t, *pool))
+	if err != nil {
+		t.Fatalf("io.ReadAll()=%v", err)
+	}
+	for _, sub := range []struct {
+		path string
+		body string
+		want int
+	}{
+		{path: rfc6962.AddChainPath, body: "not json", want: http.StatusBadRequest},
+		{path: rfc6962.AddChainPath, body: `{"chain":["AAAA"]}`, want: http.StatusBadRequest},
+		{path: rfc6962.AddChainPath, body: string(validChain), want: http.StatusOK},
+		{path: rfc6962.AddPreChainPath, body: string(validChain), want: http.StatusBadRequest},
+	} {
+		resp, err := http.Post(server.URL+path.Join(prefix, sub.path), "application/json", strings.NewReader(sub.body))
+		if err != nil {
+			t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", sub.path, err)
+		}
+		if got := resp.StatusCode; got != sub.want {
+			t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", sub.path, got, sub.want)
+		}
+	}
+
+	resp, err := http.Get(admin.URL + path.Join(prefix, getRejectedSubmissionsPath))
+	if err != nil {
+		t.Fatalf("http.Get(%s)=(_,%q); want (_,nil)", getRejectedSubmissionsPath, err)
+	}
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Fatalf("http.Get(%s)=(%d,nil); want (%d,nil)", getRejectedSubmissionsPath, got, want)
+	}
+	var rsp getRejectedSubmissionsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+		t.Fatalf("json.Decode()=%v", err)
+	}
+
+	// Only the two most recent rejections are kept, from oldest to most recent.
+	invalidChainHash := sha256.Sum256([]byte(`{"chain":["AAAA"]}`))
+	validChainHash := sha256.Sum256(validChain)
+	want := []struct {
+		hash   []byte
+		reason string
+	}{
+		{hash: invalidChainHash[:], reason: errorCatalog[errCodeInvalidChain]},
+		{hash: validChainHash[:], reason: errorCatalog[errCodeWrongEntryType]},
+	}
+	if got, want := len(rsp.RejectedSubmissions), len(want); got != want {
+		t.Fatalf("len(rejected_submissions)=%d, want %d", got, want)
+	}
+	for i, w := range want {
+		got := rsp.RejectedSubmissions[i]
+		if !bytes.Equal(got.BodyHash, w.hash) {
+			t.Errorf("rejected_submissions[%d].body_sha256=%x, want %x", i, got.BodyHash, w.hash)
+		}
+		if !strings.Contains(got.Reason, w.reason) {
+			t.Errorf("rejected_submissions[%d].reason=%q, want it to contain %q", i, got.Reason, w.reason)
+		}
+		if got.RemoteAddr == "" {
+			t.Errorf("rejected_submissions[%d].remote_addr is empty", i)
+		}
+		if got, want := got.Time, timeSource.Now(); !got.Equal(want) {
+			t.Errorf("rejected_submissions[%d].time=%v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestGetEntrypoints(t *testing.T) {
+	log := setupFakeStorageLog(t, &fakeStorage{})
+	opts := hOpts
+	opts.RejectedSubmissionSamples = 1
+	handlers := NewPathHandlers(t.Context(), &opts, log)
+	server := httptest.NewServer(handlers[path.Join(prefix, getEntrypointsPath)])
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + path.Join(prefix, getEntrypointsPath))
+	if err != nil {
+		t.Fatalf("http.Get(%s)=(_,%q); want (_,nil)", getEntrypointsPath, err)
+	}
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Fatalf("http.Get(%s)=(%d,nil); want (%d,nil)", getEntrypointsPath, got, want)
+	}
+	var rsp getEntrypointsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+		t.Fatalf("json.Decode()=%v", err)
+	}
+
+	if got, want := rsp.StaticCTAPIVersion, staticCTAPIVersion; got != want {
+		t.Errorf("static_ct_api_version=%q, want %q", got, want)
+	}
+	// All the registered handlers are listed.
+	var want []entrypointInfo
+	for p, h := range handlers {
+		want = append(want, entrypointInfo{Name: h.name, Path: strings.TrimPrefix(p, prefix), Method: h.method})
+	}
+	if len(want) != len(entrypoints) {
+		t.Fatalf("%d public handlers registered, want %d", len(want), len(entrypoints))
+	}
+	if diff := cmp.Diff(want, rsp.Entrypoints, cmpopts.SortSlices(func(a, b entrypointInfo) bool { return a.Path < b.Path })); diff != "" {
+		t.Errorf("entrypoints mismatch (-want +got):\n%s", diff)
+	}
+}
+
+// rootsValidator is a chainValidator which serves an arbitrary list of roots.
+type rootsValidator struct {
+	chainValidator
+	roots []*x509.Certificate
+}
+
+func (v rootsValidator) Roots() []*x509.Certificate {
+	return v.roots
+}
+
+func TestGetRootsStreaming(t *testing.T) {
+	root := pemToCert(t, testdata.CACertPEM)
+	for _, tc := range []struct {
+		desc        string
+		numRoots    int
+		wantChunked bool
+	}{
+		{
+			desc:     "no-roots",
+			numRoots: 0,
+		},
+		{
+			desc:     "one-root",
+			numRoots: 1,
+		},
+		{
+			desc:        "many-roots",
+			numRoots:    5000,
+			wantChunked: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			roots := make([]*x509.Certificate, tc.numRoots)
+			for i := range roots {
+				roots[i] = root
+			}
+			log := setupFakeStorageLog(t, &fakeStorage{})
+			log.chainValidator = rootsValidator{roots: roots}
+			server := setupTestServer(t, log, path.Join(prefix, rfc6962.GetRootsPath))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL + path.Join(prefix, rfc6962.GetRootsPath))
+			if err != nil {
+				t.Fatalf("Failed to get roots: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("Unexpected status code: %v", resp.StatusCode)
+			}
+			if got, want := resp.Header.Get(contentTypeHeader), contentTypeJSON; got != want {
+				t.Errorf("Content-Type=%q; want %q", got, want)
+			}
+			// Large responses are streamed, so their length can't be known in advance.
+			if got := resp.ContentLength == -1; got != tc.wantChunked {
+				t.Errorf("ContentLength=%d; want chunked response: %t", resp.ContentLength, tc.wantChunked)
+			}
+
+			var got rfc6962.GetRootsResponse
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if got.Certificates == nil {
+				t.Errorf("Certificates is null, want a list")
+			}
+			if got, want := len(got.Certificates), tc.numRoots; got != want {
+				t.Fatalf("Unexpected number of certificates: got %d, want %d", got, want)
+			}
+			want := base64.StdEncoding.EncodeToString(root.Raw)
+			for i, c := range got.Certificates {
+				if c != want {
+					t.Fatalf("Unexpected root %d: got %s, want %s", i, c, want)
+				}
+			}
+		})
+	}
+}
+
 // TODO(phboneff): this could just be a parseBodyJSONChain test
 func TestAddChainWhitespace(t *testing.T) {
 	// Throughout we use variants of a hard-coded POST body derived from a chain of:
@@ -753,35 +1428,2669 @@ func TestAddPreChain(t *testing.T) {
 	}
 }
 
-func createJSONChain(t *testing.T, p x509util.PEMCertPool) io.Reader {
-	t.Helper()
-	var req rfc6962.AddChainRequest
-	for _, rawCert := range p.RawCertificates() {
-		req.Chain = append(req.Chain, rawCert.Raw)
+func TestAddChainEntryBuilder(t *testing.T) {
+	fixedTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	opts := hOpts
+	opts.EntryBuilder = func(chain []*x509.Certificate, isPrecert bool, _ uint64) (*ctonly.Entry, error) {
+		return x509util.EntryFromChain(chain, isPrecert, uint64(fixedTime.UnixMilli()))
 	}
 
-	var buffer bytes.Buffer
-	// It's tempting to avoid creating and flushing the intermediate writer but it doesn't work
-	writer := bufio.NewWriter(&buffer)
-	err := json.NewEncoder(writer).Encode(&req)
-	if err := writer.Flush(); err != nil {
-		t.Error(err)
-	}
+	s := &fakeStorage{}
+	log := setupFakeStorageLog(t, s)
+	handler := NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)]
+	server := httptest.NewServer(handler)
+	defer server.Close()
 
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	resp, err := http.Post(server.URL+rfc6962.AddChainPath, "application/json", createJSONChain(t, *pool))
 	if err != nil {
-		t.Fatalf("Failed to create test json: %v", err)
+		t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+	}
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
 	}
 
-	return bufio.NewReader(&buffer)
+	var gotRsp rfc6962.AddChainResponse
+	if err := json.NewDecoder(resp.Body).Decode(&gotRsp); err != nil {
+		t.Fatalf("json.Decode()=%v; want nil", err)
+	}
+	if got, want := gotRsp.Timestamp, uint64(fixedTime.UnixMilli()); got != want {
+		t.Errorf("resp.Timestamp=%d; want %d", got, want)
+	}
+	if got, want := len(s.entries), 1; got != want {
+		t.Fatalf("len(storage.entries)=%d; want %d", got, want)
+	}
+	if got, want := s.entries[0].Timestamp, uint64(fixedTime.UnixMilli()); got != want {
+		t.Errorf("entry.Timestamp=%d; want %d", got, want)
+	}
 }
 
-func loadCertsIntoPoolOrDie(t *testing.T, certs []string) *x509util.PEMCertPool {
-	t.Helper()
-	pool := x509util.NewPEMCertPool()
-	for _, cert := range certs {
+func TestInterceptors(t *testing.T) {
+	// vetoSANs returns an Interceptor rejecting certificates for any of sans.
+	vetoSANs := func(sans ...string) Interceptor {
+		return InterceptorFunc(func(_ context.Context, s *Submission) error {
+			for _, name := range s.Chain[0].DNSNames {
+				if slices.Contains(sans, name) {
+					return fmt.Errorf("%s must not be logged", name)
+				}
+			}
+			return nil
+		})
+	}
+
+	for _, tc := range []struct {
+		desc     string
+		path     string
+		chain    []string
+		vetoed   []string
+		want     int
+		wantMsg  string
+		wantCode errorCode
+	}{
+		{
+			desc:   "accepted",
+			path:   rfc6962.AddChainPath,
+			chain:  []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM},
+			vetoed: []string{"other.transparency.dev"},
+			want:   http.StatusOK,
+		},
+		{
+			desc:     "vetoed-cert",
+			path:     rfc6962.AddChainPath,
+			chain:    []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM},
+			vetoed:   []string{"other.transparency.dev", "test.transparency.dev"},
+			want:     http.StatusBadRequest,
+			wantMsg:  "test.transparency.dev must not be logged",
+			wantCode: errCodeVetoed,
+		},
+		{
+			desc:     "vetoed-precert",
+			path:     rfc6962.AddPreChainPath,
+			chain:    []string{testdata.PreCertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM},
+			vetoed:   []string{"test.transparency.dev"},
+			want:     http.StatusBadRequest,
+			wantMsg:  "test.transparency.dev must not be logged",
+			wantCode: errCodeVetoed,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			// seen records the submissions passed to the last interceptor.
+			var seen []*Submission
+			opts := hOpts
+			opts.Interceptors = []Interceptor{
+				vetoSANs(tc.vetoed...),
+				InterceptorFunc(func(_ context.Context, s *Submission) error {
+					seen = append(seen, s)
+					return nil
+				}),
+			}
+			s := &fakeStorage{}
+			log := setupFakeStorageLog(t, s)
+			p := path.Join(prefix, tc.path)
+			server := httptest.NewServer(NewPathHandlers(t.Context(), &opts, log)[p])
+			defer server.Close()
+
+			pool := loadCertsIntoPoolOrDie(t, tc.chain)
+			resp, err := http.Post(server.URL+p, "application/json", createJSONChain(t, *pool))
+			if err != nil {
+				t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", p, err)
+			}
+			defer resp.Body.Close()
+			if got, want := resp.StatusCode, tc.want; got != want {
+				t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", p, got, want)
+			}
+			if got, want := resp.Header.Get(errorCodeHeader), string(tc.wantCode); got != want {
+				t.Errorf("%s=%q, want %q", errorCodeHeader, got, want)
+			}
+
+			if tc.want != http.StatusOK {
+				body, err := io.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatalf("io.ReadAll()=%v", err)
+				}
+				if !strings.Contains(string(body), tc.wantMsg) {
+					t.Errorf("body=%q, want message containing %q", body, tc.wantMsg)
+				}
+				if len(s.entries) != 0 || len(seen) != 0 {
+					t.Errorf("vetoed submission logged %d times and passed to %d later interceptors, want 0", len(s.entries), len(seen))
+				}
+				return
+			}
+			if got, want := len(s.entries), 1; got != want {
+				t.Errorf("len(storage.entries)=%d; want %d", got, want)
+			}
+			if got, want := len(seen), 1; got != want {
+				t.Fatalf("last interceptor called %d times, want %d", got, want)
+			}
+			if got := seen[0]; got.Origin != log.origin || got.IsPrecert || len(got.Chain) != 3 || got.Request == nil {
+				t.Errorf("interceptor got submission %+v, want origin %q, a final certificate chain of 3 and a request", got, log.origin)
+			}
+		})
+	}
+}
+
+func TestWrongEntryType(t *testing.T) {
+	log := setupFakeStorageLog(t, &fakeStorage{})
+	handlers := NewPathHandlers(t.Context(), &hOpts, log)
+
+	for _, tc := range []struct {
+		desc    string
+		path    string
+		chain   []string
+		wantMsg string
+	}{
+		{
+			desc:    "precert-to-add-chain",
+			path:    rfc6962.AddChainPath,
+			chain:   []string{testdata.PreCertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM},
+			wantMsg: "precertificate submitted to add-chain; use add-pre-chain",
+		},
+		{
+			desc:    "cert-to-add-pre-chain",
+			path:    rfc6962.AddPreChainPath,
+			chain:   []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM},
+			wantMsg: "certificate submitted to add-pre-chain; use add-chain",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			p := path.Join(prefix, tc.path)
+			server := httptest.NewServer(handlers[p])
+			defer server.Close()
+
+			pool := loadCertsIntoPoolOrDie(t, tc.chain)
+			resp, err := http.Post(server.URL+p, "application/json", createJSONChain(t, *pool))
+			if err != nil {
+				t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", tc.path, err)
+			}
+			if got, want := resp.StatusCode, http.StatusBadRequest; got != want {
+				t.Errorf("http.Post(%s)=(%d,nil); want (%d,nil)", tc.path, got, want)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("io.ReadAll()=%v", err)
+			}
+			if !strings.Contains(string(body), tc.wantMsg) {
+				t.Errorf("http.Post(%s) body=%q, want it to contain %q", tc.path, body, tc.wantMsg)
+			}
+			if strings.Contains(string(body), errorCatalog[errCodeInvalidChain]) {
+				t.Errorf("http.Post(%s) body=%q, should not be a generic invalid chain error", tc.path, body)
+			}
+		})
+	}
+}
+
+func TestAddChainVerifyAfterWrite(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		storage Storage
+		want    int
+	}{
+		{
+			desc:    "matching-entry",
+			storage: &fakeStorage{},
+			want:    http.StatusOK,
+		},
+		{
+			desc:    "mismatched-entry",
+			storage: &tamperingStorage{},
+			want:    http.StatusInternalServerError,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := hOpts
+			opts.VerifyAfterWrite = true
+			log := setupFakeStorageLog(t, tc.storage)
+			handler := NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)]
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+			resp, err := http.Post(server.URL+rfc6962.AddChainPath, "application/json", createJSONChain(t, *pool))
+			if err != nil {
+				t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+			}
+			if got, want := resp.StatusCode, tc.want; got != want {
+				t.Errorf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+			}
+		})
+	}
+}
+
+func TestAddChainDiskSpace(t *testing.T) {
+	const minFree = 1 << 30
+	for _, tc := range []struct {
+		desc     string
+		free     map[string]uint64
+		want     int
+		wantCode errorCode
+	}{
+		{
+			desc: "enough-space",
+			free: map[string]uint64{"/log": minFree, "/issuers": 2 * minFree},
+			want: http.StatusOK,
+		},
+		{
+			desc:     "low-on-log",
+			free:     map[string]uint64{"/log": minFree - 1, "/issuers": 2 * minFree},
+			want:     http.StatusInsufficientStorage,
+			wantCode: errCodeNoDiskSpace,
+		},
+		{
+			desc:     "low-on-issuers",
+			free:     map[string]uint64{"/log": minFree, "/issuers": 0},
+			want:     http.StatusInsufficientStorage,
+			wantCode: errCodeNoDiskSpace,
+		},
+		{
+			desc:     "check-fails",
+			free:     map[string]uint64{"/log": minFree},
+			want:     http.StatusInternalServerError,
+			wantCode: errCodeCheckDiskSpace,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := hOpts
+			opts.MinFreeDiskSpace = minFree
+			opts.DiskSpacePaths = []string{"/log", "/issuers"}
+			opts.FreeDiskSpace = func(path string) (uint64, error) {
+				free, ok := tc.free[path]
+				if !ok {
+					return 0, fmt.Errorf("no filesystem at %q", path)
+				}
+				return free, nil
+			}
+			s := &fakeStorage{}
+			log := setupFakeStorageLog(t, s)
+			handler := NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)]
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+			resp, err := http.Post(server.URL+rfc6962.AddChainPath, "application/json", createJSONChain(t, *pool))
+			if err != nil {
+				t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+			}
+			if got, want := resp.StatusCode, tc.want; got != want {
+				t.Errorf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+			}
+			if got, want := errorCode(resp.Header.Get(errorCodeHeader)), tc.wantCode; got != want {
+				t.Errorf("%s=%q, want %q", errorCodeHeader, got, want)
+			}
+			// Nothing must be written when the check fails.
+			if got, want := len(s.entries) > 0 || len(s.issuers) > 0, tc.want == http.StatusOK; got != want {
+				t.Errorf("data written: %t, want %t", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadShedding(t *testing.T) {
+	light := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot})
+	heavy := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	lightBytes := 0
+	for _, cert := range light.RawCertificates() {
+		lightBytes += len(cert.Raw)
+	}
+	const threshold = 4
+
+	for _, tc := range []struct {
+		desc           string
+		inflight       int
+		pool           *x509util.PEMCertPool
+		want           int
+		wantOverloaded bool
+	}{
+		{
+			desc:     "heavy-under-threshold",
+			inflight: threshold - 1,
+			pool:     heavy,
+			want:     http.StatusOK,
+		},
+		{
+			desc:     "heavy-over-threshold",
+			inflight: threshold,
+			pool:     heavy,
+			want:     http.StatusServiceUnavailable,
+		},
+		{
+			desc:     "light-under-threshold",
+			inflight: threshold - 1,
+			pool:     light,
+			want:     http.StatusOK,
+		},
+		{
+			desc:           "light-over-threshold",
+			inflight:       threshold,
+			pool:           light,
+			want:           http.StatusOK,
+			wantOverloaded: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var overloaded bool
+			opts := hOpts
+			opts.ShedLoadThreshold = threshold
+			opts.ShedChainBytes = lightBytes
+			opts.Interceptors = []Interceptor{InterceptorFunc(func(_ context.Context, s *Submission) error {
+				overloaded = s.Overloaded
+				return nil
+			})}
+			s := &fakeStorage{}
+			log := setupFakeStorageLog(t, s)
+			handler := NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)]
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			// Simulate concurrent submissions, still being processed.
+			for range tc.inflight {
+				defer log.inflightRequests.start()()
+			}
+			resp, err := http.Post(server.URL+rfc6962.AddChainPath, contentTypeJSON, createJSONChain(t, *tc.pool))
+			if err != nil {
+				t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+			}
+			if got, want := resp.StatusCode, tc.want; got != want {
+				t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+			}
+			if tc.want != http.StatusOK {
+				if got, want := errorCode(resp.Header.Get(errorCodeHeader)), errCodeLoadShed; got != want {
+					t.Errorf("%s=%q, want %q", errorCodeHeader, got, want)
+				}
+				if got, want := resp.Header.Get("Retry-After"), "1"; got != want {
+					t.Errorf("Retry-After=%q, want %q", got, want)
+				}
+				if got, want := len(s.entries), 0; got != want {
+					t.Errorf("len(storage.entries)=%d; want %d", got, want)
+				}
+				return
+			}
+			if overloaded != tc.wantOverloaded {
+				t.Errorf("Submission.Overloaded=%t, want %t", overloaded, tc.wantOverloaded)
+			}
+		})
+	}
+}
+
+// slowValidator is a ChainValidator which blocks until release is closed
+// before validating chains, if release is not nil.
+type slowValidator struct {
+	ChainValidator
+	release chan struct{}
+}
+
+func (v slowValidator) Validate(req rfc6962.AddChainRequest, expectingPrecert bool) ([]*x509.Certificate, error) {
+	if v.release != nil {
+		<-v.release
+	}
+	return v.ChainValidator.Validate(req, expectingPrecert)
+}
+
+func TestAddChainValidationTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		slow bool
+		// timedOut is the number of requests timing out before the tested
+		// one, whose chains are still validated in the background.
+		timedOut int
+		want     int
+		wantCode errorCode
+	}{
+		{
+			desc: "fast",
+			want: http.StatusOK,
+		},
+		{
+			desc:     "slow",
+			slow:     true,
+			want:     http.StatusServiceUnavailable,
+			wantCode: errCodeValidationTimeout,
+		},
+		{
+			desc:     "too-many-validations",
+			slow:     true,
+			timedOut: 2,
+			want:     http.StatusServiceUnavailable,
+			wantCode: errCodeValidationsFull,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := hOpts
+			// Slow validations never complete in time: only wait for long
+			// for fast ones, which may still be slowed down, e.g. by -race.
+			opts.ValidationTimeout = time.Minute
+			if tc.slow {
+				opts.ValidationTimeout = 100 * time.Millisecond
+			}
+			opts.MaxValidations = 2
+			s := &fakeStorage{}
+			log := setupFakeStorageLog(t, s)
+			v := slowValidator{ChainValidator: log.chainValidator}
+			if tc.slow {
+				v.release = make(chan struct{})
+				defer close(v.release)
+			}
+			log.chainValidator = v
+			handler := NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)]
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+			for range tc.timedOut {
+				resp, err := http.Post(server.URL+rfc6962.AddChainPath, "application/json", createJSONChain(t, *pool))
+				if err != nil {
+					t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+				}
+				resp.Body.Close()
+				if got, want := errorCode(resp.Header.Get(errorCodeHeader)), errCodeValidationTimeout; got != want {
+					t.Fatalf("%s=%q, want %q", errorCodeHeader, got, want)
+				}
+			}
+			resp, err := http.Post(server.URL+rfc6962.AddChainPath, "application/json", createJSONChain(t, *pool))
+			if err != nil {
+				t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+			}
+			if got, want := resp.StatusCode, tc.want; got != want {
+				t.Errorf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+			}
+			if got, want := errorCode(resp.Header.Get(errorCodeHeader)), tc.wantCode; got != want {
+				t.Errorf("%s=%q, want %q", errorCodeHeader, got, want)
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if got, want := len(s.entries) > 0, tc.want == http.StatusOK; got != want {
+				t.Errorf("entry written: %t, want %t", got, want)
+			}
+		})
+	}
+}
+
+func TestNodeHeader(t *testing.T) {
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	validChain, err := io.ReadAll(createJSONChain(t, *pool))
+	if err != nil {
+		t.Fatalf("io.ReadAll()=%v", err)
+	}
+
+	for _, tc := range []struct {
+		desc     string
+		nodeName string
+		body     string
+		want     int
+	}{
+		{
+			desc:     "sct",
+			nodeName: "node-1",
+			body:     string(validChain),
+			want:     http.StatusOK,
+		},
+		{
+			desc:     "error",
+			nodeName: "node-1",
+			body:     "not json",
+			want:     http.StatusBadRequest,
+		},
+		{
+			desc: "unset",
+			body: string(validChain),
+			want: http.StatusOK,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := hOpts
+			opts.NodeName = tc.nodeName
+			log := setupFakeStorageLog(t, &fakeStorage{})
+			handler := NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)]
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			resp, err := http.Post(server.URL+rfc6962.AddChainPath, "application/json", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+			}
+			if got, want := resp.StatusCode, tc.want; got != want {
+				t.Errorf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+			}
+			if got, want := resp.Header.Values(nodeHeader), tc.nodeName; len(got) > 1 || resp.Header.Get(nodeHeader) != want {
+				t.Errorf("%s=%q, want %q", nodeHeader, got, want)
+			}
+		})
+	}
+}
+
+func TestCORSHeaders(t *testing.T) {
+	const allowedOrigin = "https://allowed.example.com"
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	validChain, err := io.ReadAll(createJSONChain(t, *pool))
+	if err != nil {
+		t.Fatalf("io.ReadAll()=%v", err)
+	}
+
+	for _, tc := range []struct {
+		desc       string
+		allowed    map[entrypointName][]string
+		method     string
+		path       string
+		origin     string
+		wantStatus int
+		wantOrigin string
+	}{
+		{
+			desc:       "get-roots-any-origin",
+			allowed:    map[entrypointName][]string{getRootsName: {corsAnyOrigin}},
+			method:     http.MethodGet,
+			path:       rfc6962.GetRootsPath,
+			origin:     allowedOrigin,
+			wantStatus: http.StatusOK,
+			wantOrigin: corsAnyOrigin,
+		},
+		{
+			desc:       "add-chain-not-configured",
+			allowed:    map[entrypointName][]string{getRootsName: {corsAnyOrigin}},
+			method:     http.MethodPost,
+			path:       rfc6962.AddChainPath,
+			origin:     allowedOrigin,
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc:       "get-roots-allowed-origin",
+			allowed:    map[entrypointName][]string{getRootsName: {allowedOrigin}},
+			method:     http.MethodGet,
+			path:       rfc6962.GetRootsPath,
+			origin:     allowedOrigin,
+			wantStatus: http.StatusOK,
+			wantOrigin: allowedOrigin,
+		},
+		{
+			desc:       "get-roots-other-origin",
+			allowed:    map[entrypointName][]string{getRootsName: {allowedOrigin}},
+			method:     http.MethodGet,
+			path:       rfc6962.GetRootsPath,
+			origin:     "https://other.example.com",
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc:       "get-roots-preflight",
+			allowed:    map[entrypointName][]string{getRootsName: {corsAnyOrigin}},
+			method:     http.MethodOptions,
+			path:       rfc6962.GetRootsPath,
+			origin:     allowedOrigin,
+			wantStatus: http.StatusNoContent,
+			wantOrigin: corsAnyOrigin,
+		},
+		{
+			desc:       "add-chain-preflight-not-configured",
+			allowed:    map[entrypointName][]string{getRootsName: {corsAnyOrigin}},
+			method:     http.MethodOptions,
+			path:       rfc6962.AddChainPath,
+			origin:     allowedOrigin,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			desc:       "unset",
+			method:     http.MethodGet,
+			path:       rfc6962.GetRootsPath,
+			origin:     allowedOrigin,
+			wantStatus: http.StatusOK,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := hOpts
+			opts.CORSAllowedOrigins = tc.allowed
+			log := setupFakeStorageLog(t, &fakeStorage{})
+			handler := NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, tc.path)]
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			var body io.Reader
+			if tc.method == http.MethodPost {
+				body = bytes.NewReader(validChain)
+			}
+			req, err := http.NewRequest(tc.method, server.URL+tc.path, body)
+			if err != nil {
+				t.Fatalf("http.NewRequest()=%v", err)
+			}
+			req.Header.Set(corsOriginHeader, tc.origin)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("%s %s=(_,%q); want (_,nil)", tc.method, tc.path, err)
+			}
+			if got, want := resp.StatusCode, tc.wantStatus; got != want {
+				t.Errorf("%s %s=(%d,nil); want (%d,nil)", tc.method, tc.path, got, want)
+			}
+			if got, want := resp.Header.Get(corsAllowOriginHeader), tc.wantOrigin; got != want {
+				t.Errorf("%s=%q, want %q", corsAllowOriginHeader, got, want)
+			}
+			if tc.wantStatus == http.StatusNoContent {
+				if got, want := resp.Header.Get(corsAllowMethodsHeader), http.MethodGet; got != want {
+					t.Errorf("%s=%q, want %q", corsAllowMethodsHeader, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateCORSAllowedOrigins(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		origins map[string][]string
+		wantErr string
+	}{
+		{
+			desc: "unset",
+		},
+		{
+			desc:    "valid",
+			origins: map[string][]string{"GetRoots": {"*"}, "GetTreeHead": {"https://example.com"}},
+		},
+		{
+			desc:    "unknown-entrypoint",
+			origins: map[string][]string{"get-roots": {"*"}},
+			wantErr: "unknown entrypoint",
+		},
+		{
+			desc:    "no-origins",
+			origins: map[string][]string{"GetRoots": {}},
+			wantErr: "empty origin",
+		},
+		{
+			desc:    "empty-origin",
+			origins: map[string][]string{"GetRoots": {"https://example.com", ""}},
+			wantErr: "empty origin",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := ValidateCORSAllowedOrigins(tc.origins)
+			if len(tc.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("ValidateCORSAllowedOrigins()=%v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("ValidateCORSAllowedOrigins()=%v, want err containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequireClientCert(t *testing.T) {
+	// Create a client CA, and a client certificate it issues.
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Client CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate()=%v", err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("x509.ParseCertificate()=%v", err)
+	}
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	clientTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Submitter"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTmpl, ca, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate()=%v", err)
+	}
+	clientCert := cryptotls.Certificate{Certificate: [][]byte{clientDER}, PrivateKey: clientKey}
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	chain, err := io.ReadAll(createJSONChain(t, *pool))
+	if err != nil {
+		t.Fatalf("io.ReadAll()=%v", err)
+	}
+
+	opts := hOpts
+	opts.RequireClientCert = true
+	mux := http.NewServeMux()
+	for p, h := range NewPathHandlers(t.Context(), &opts, setupFakeStorageLog(t, &fakeStorage{})) {
+		mux.Handle(p, h)
+	}
+	server := httptest.NewUnstartedServer(mux)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+	server.TLS = &cryptotls.Config{ClientAuth: cryptotls.VerifyClientCertIfGiven, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	for _, tc := range []struct {
+		desc       string
+		clientCert bool
+		method     string
+		path       string
+		want       int
+	}{
+		{
+			desc:       "add-chain-with-cert",
+			clientCert: true,
+			method:     http.MethodPost,
+			path:       rfc6962.AddChainPath,
+			want:       http.StatusOK,
+		},
+		{
+			desc:   "add-chain-without-cert",
+			method: http.MethodPost,
+			path:   rfc6962.AddChainPath,
+			want:   http.StatusForbidden,
+		},
+		{
+			desc:   "add-pre-chain-without-cert",
+			method: http.MethodPost,
+			path:   rfc6962.AddPreChainPath,
+			want:   http.StatusForbidden,
+		},
+		{
+			desc:   "get-roots-without-cert",
+			method: http.MethodGet,
+			path:   rfc6962.GetRootsPath,
+			want:   http.StatusOK,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			client := server.Client()
+			if tc.clientCert {
+				transport := client.Transport.(*http.Transport).Clone()
+				transport.TLSClientConfig.Certificates = []cryptotls.Certificate{clientCert}
+				client = &http.Client{Transport: transport}
+			}
+			req, err := http.NewRequest(tc.method, server.URL+path.Join(prefix, tc.path), bytes.NewReader(chain))
+			if err != nil {
+				t.Fatalf("http.NewRequest()=%v", err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("client.Do(%s)=(_,%q); want (_,nil)", tc.path, err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			if got, want := resp.StatusCode, tc.want; got != want {
+				t.Errorf("client.Do(%s)=(%d,nil); want (%d,nil)", tc.path, got, want)
+			}
+			if tc.want == http.StatusForbidden {
+				if got, want := resp.Header.Get(errorCodeHeader), string(errCodeClientCert); got != want {
+					t.Errorf("%s=%q, want %q", errorCodeHeader, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPerLogSubmissionLimits(t *testing.T) {
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	chain, err := io.ReadAll(createJSONChain(t, *pool))
+	if err != nil {
+		t.Fatalf("io.ReadAll()=%v", err)
+	}
+	// JSON allows trailing whitespace, which makes chain larger.
+	largeChain := append(slices.Clone(chain), bytes.Repeat([]byte(" "), 100)...)
+
+	// Both logs only allow their burst of submissions during the test.
+	const slow = 1e-6
+	limits := map[string]HandlerOptions{
+		"a.example.com": {SubmissionRate: slow, SubmissionBurst: 1},
+		"b.example.com": {SubmissionRate: slow, SubmissionBurst: 3, MaxRequestBytes: int64(len(chain))},
+	}
+	mux := http.NewServeMux()
+	for o, l := range limits {
+		opts := hOpts
+		opts.SubmissionRate, opts.SubmissionBurst, opts.MaxRequestBytes = l.SubmissionRate, l.SubmissionBurst, l.MaxRequestBytes
+		log := setupFakeStorageLog(t, &fakeStorage{})
+		log.origin = o
+		for p, h := range NewPathHandlers(t.Context(), &opts, log) {
+			mux.Handle(p, h)
+		}
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	for i, step := range []struct {
+		origin   string
+		body     []byte
+		want     int
+		wantCode errorCode
+	}{
+		{origin: "a.example.com", body: chain, want: http.StatusOK},
+		{origin: "a.example.com", body: chain, want: http.StatusTooManyRequests, wantCode: errCodeRateLimited},
+		// b's rate limit is independent from a's.
+		{origin: "b.example.com", body: chain, want: http.StatusOK},
+		{origin: "b.example.com", body: chain, want: http.StatusOK},
+		// Only b limits the size of requests.
+		{origin: "b.example.com", body: largeChain, want: http.StatusRequestEntityTooLarge, wantCode: errCodeRequestTooLarge},
+		{origin: "a.example.com", body: largeChain, want: http.StatusTooManyRequests, wantCode: errCodeRateLimited},
+		// Requests which are too large don't count towards the rate limit.
+		{origin: "b.example.com", body: chain, want: http.StatusOK},
+		{origin: "b.example.com", body: chain, want: http.StatusTooManyRequests, wantCode: errCodeRateLimited},
+	} {
+		url := server.URL + "/" + step.origin + rfc6962.AddChainPath
+		resp, err := http.Post(url, "application/json", bytes.NewReader(step.body))
+		if err != nil {
+			t.Fatalf("%d: http.Post(%s)=(_,%q); want (_,nil)", i, url, err)
+		}
+		if got, want := resp.StatusCode, step.want; got != want {
+			t.Errorf("%d: http.Post(%s)=(%d,nil); want (%d,nil)", i, url, got, want)
+		}
+		if got, want := resp.Header.Get(errorCodeHeader), string(step.wantCode); got != want {
+			t.Errorf("%d: %s=%q, want %q", i, errorCodeHeader, got, want)
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer which can be written to and read from
+// concurrently, for instance to capture klog output written by background
+// goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestNoOpRequestLog(t *testing.T) {
+	reader := testMetricReader()
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	chain, err := io.ReadAll(createJSONChain(t, *pool))
+	if err != nil {
+		t.Fatalf("io.ReadAll()=%v", err)
+	}
+
+	// Log requests at the verbosity of DefaultRequestLog, and capture them.
+	// klog flags are global: restore them for later tests.
+	var fs flag.FlagSet
+	klog.InitFlags(&fs)
+	oldV := fs.Lookup("v").Value.String()
+	if err := fs.Set("v", strconv.Itoa(vLevel)); err != nil {
+		t.Fatalf("Set(v)=%v", err)
+	}
+	t.Cleanup(func() { _ = fs.Set("v", oldV) })
+	// Other goroutines may be logging: only change logtostderr through klog,
+	// which synchronizes it.
+	oldToStderr, err := strconv.ParseBool(fs.Lookup("logtostderr").Value.String())
+	if err != nil {
+		t.Fatalf("ParseBool(logtostderr)=%v", err)
+	}
+	buf := &syncBuffer{}
+	klog.LogToStderr(false)
+	klog.SetOutput(buf)
+	t.Cleanup(func() {
+		klog.SetOutput(os.Stderr)
+		klog.LogToStderr(oldToStderr)
+	})
+
+	for _, tc := range []struct {
+		desc       string
+		origin     string
+		requestLog requestLog
+		wantLogs   bool
+	}{
+		{
+			desc:       "default",
+			origin:     "default-request-log.example.com",
+			requestLog: &DefaultRequestLog{},
+			wantLogs:   true,
+		},
+		{
+			desc:       "no-op",
+			origin:     "no-op-request-log.example.com",
+			requestLog: &NoOpRequestLog{},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			buf.Reset()
+			opts := hOpts
+			opts.RequestLog = tc.requestLog
+			log := setupFakeStorageLog(t, &fakeStorage{})
+			log.origin = tc.origin
+			handler := NewPathHandlers(t.Context(), &opts, log)["/"+tc.origin+rfc6962.AddChainPath]
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			resp, err := http.Post(server.URL+rfc6962.AddChainPath, "application/json", bytes.NewReader(chain))
+			if err != nil {
+				t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+			}
+			if got, want := resp.StatusCode, http.StatusOK; got != want {
+				t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+			}
+			klog.Flush()
+
+			// Background goroutines of other tests may log too: only look for
+			// this log's requests.
+			if got := strings.Contains(buf.String(), "RL: LogOrigin: "+tc.origin); got != tc.wantLogs {
+				t.Errorf("request logged: %t, want %t, logs: %q", got, tc.wantLogs, buf.String())
+			}
+			if got, want := counterValue(t, reader, "tesseract.http.request.count", tc.origin), int64(1); got != want {
+				t.Errorf("tesseract.http.request.count=%d, want %d", got, want)
+			}
+			if got, want := counterValue(t, reader, "tesseract.http.response.count", tc.origin), int64(1); got != want {
+				t.Errorf("tesseract.http.response.count=%d, want %d", got, want)
+			}
+		})
+	}
+}
+
+// notAfterTestChains returns a pool holding a freshly generated root, and a
+// function returning JSON add-chain requests for certificates or
+// precertificates issued by this root.
+func notAfterTestChains(t *testing.T) (*x509util.PEMCertPool, func(serial int64, notAfter time.Time, isPrecert bool) []byte) {
+	t.Helper()
+	root := issueTestCert(t, nil, func(c *x509.Certificate) {
+		c.NotAfter = time.Now().Add(24 * time.Hour)
+	})
+
+	// chain returns a JSON chain made of a leaf issued by root, and root.
+	chain := func(serial int64, notAfter time.Time, isPrecert bool) []byte {
+		t.Helper()
+		leaf := issueTestCert(t, root, func(c *x509.Certificate) {
+			c.SerialNumber, c.NotAfter = big.NewInt(serial), notAfter
+			if isPrecert {
+				c.ExtraExtensions = []pkix.Extension{{Id: rfc6962.OIDExtensionCTPoison, Critical: true, Value: asn1.NullBytes}}
+			}
+		})
+		body, err := json.Marshal(rfc6962.AddChainRequest{Chain: [][]byte{leaf.Raw, root.Raw}})
+		if err != nil {
+			t.Fatalf("json.Marshal()=%v", err)
+		}
+		return body
+	}
+	return root.pool(), chain
+}
+
+func TestMaxNotAfterDrift(t *testing.T) {
+	roots, chain := notAfterTestChains(t)
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	opts := hOpts
+	opts.MaxNotAfterDrift = time.Minute
+	log := setupFakeStorageLog(t, &fakeStorage{})
+	log.chainValidator = chainValidator{trustedRoots: roots}
+	mux := http.NewServeMux()
+	for p, h := range NewPathHandlers(t.Context(), &opts, log) {
+		mux.Handle(p, h)
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	for _, step := range []struct {
+		desc     string
+		path     string
+		body     []byte
+		want     int
+		wantCode errorCode
+	}{
+		{
+			desc: "precert",
+			path: rfc6962.AddPreChainPath,
+			body: chain(42, notAfter, true),
+			want: http.StatusOK,
+		},
+		{
+			desc:     "mismatched-cert",
+			path:     rfc6962.AddChainPath,
+			body:     chain(42, notAfter.Add(time.Hour), false),
+			want:     http.StatusBadRequest,
+			wantCode: errCodeNotAfterDrift,
+		},
+		{
+			desc: "cert-within-drift",
+			path: rfc6962.AddChainPath,
+			body: chain(42, notAfter.Add(time.Minute), false),
+			want: http.StatusOK,
+		},
+		{
+			desc: "cert-without-precert",
+			path: rfc6962.AddChainPath,
+			body: chain(43, notAfter.Add(time.Hour), false),
+			want: http.StatusOK,
+		},
+	} {
+		url := server.URL + prefix + step.path
+		resp, err := http.Post(url, "application/json", bytes.NewReader(step.body))
+		if err != nil {
+			t.Fatalf("%s: http.Post(%s)=(_,%q); want (_,nil)", step.desc, url, err)
+		}
+		if got, want := resp.StatusCode, step.want; got != want {
+			t.Errorf("%s: http.Post(%s)=(%d,nil); want (%d,nil)", step.desc, url, got, want)
+		}
+		if got, want := resp.Header.Get(errorCodeHeader), string(step.wantCode); got != want {
+			t.Errorf("%s: %s=%q, want %q", step.desc, errorCodeHeader, got, want)
+		}
+	}
+}
+
+func TestShardNotAfterLimit(t *testing.T) {
+	roots, chain := notAfterTestChains(t)
+	limit := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+
+	opts := hOpts
+	opts.ShardNotAfterLimit = &limit
+	log := setupFakeStorageLog(t, &fakeStorage{})
+	log.chainValidator = chainValidator{trustedRoots: roots}
+	mux := http.NewServeMux()
+	for p, h := range NewPathHandlers(t.Context(), &opts, log) {
+		mux.Handle(p, h)
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	for _, step := range []struct {
+		desc     string
+		path     string
+		body     []byte
+		want     int
+		wantCode errorCode
+	}{
+		{
+			desc: "precert",
+			path: rfc6962.AddPreChainPath,
+			body: chain(42, limit.Add(-time.Hour), true),
+			want: http.StatusOK,
+		},
+		{
+			desc:     "misrouted-cert",
+			path:     rfc6962.AddChainPath,
+			body:     chain(42, limit.Add(time.Minute), false),
+			want:     http.StatusBadRequest,
+			wantCode: errCodeWrongShard,
+		},
+		{
+			desc:     "misrouted-cert-at-limit",
+			path:     rfc6962.AddChainPath,
+			body:     chain(42, limit, false),
+			want:     http.StatusBadRequest,
+			wantCode: errCodeWrongShard,
+		},
+		{
+			desc: "correctly-routed-cert",
+			path: rfc6962.AddChainPath,
+			body: chain(42, limit.Add(-time.Minute), false),
+			want: http.StatusOK,
+		},
+		{
+			desc: "cert-without-precert",
+			path: rfc6962.AddChainPath,
+			body: chain(43, limit.Add(time.Minute), false),
+			want: http.StatusOK,
+		},
+	} {
+		url := server.URL + prefix + step.path
+		resp, err := http.Post(url, "application/json", bytes.NewReader(step.body))
+		if err != nil {
+			t.Fatalf("%s: http.Post(%s)=(_,%q); want (_,nil)", step.desc, url, err)
+		}
+		if got, want := resp.StatusCode, step.want; got != want {
+			t.Errorf("%s: http.Post(%s)=(%d,nil); want (%d,nil)", step.desc, url, got, want)
+		}
+		if got, want := resp.Header.Get(errorCodeHeader), string(step.wantCode); got != want {
+			t.Errorf("%s: %s=%q, want %q", step.desc, errorCodeHeader, got, want)
+		}
+	}
+}
+
+func TestDedupHeader(t *testing.T) {
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	chain, err := io.ReadAll(createJSONChain(t, *pool))
+	if err != nil {
+		t.Fatalf("io.ReadAll()=%v", err)
+	}
+
+	for _, tc := range []struct {
+		desc        string
+		dedupHeader bool
+		want        []string
+	}{
+		{
+			desc:        "enabled",
+			dedupHeader: true,
+			want:        []string{"false", "true"},
+		},
+		{
+			desc: "disabled",
+			want: []string{"", ""},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := hOpts
+			opts.DedupHeader = tc.dedupHeader
+			s := &fakeStorage{dedup: true}
+			log := setupFakeStorageLog(t, s)
+			handler := NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)]
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			// Submit the same chain twice, at different times.
+			defer timeSource.Reset()
+			for i, want := range tc.want {
+				timeSource.Add1m()
+				resp, err := http.Post(server.URL+rfc6962.AddChainPath, "application/json", bytes.NewReader(chain))
+				if err != nil {
+					t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+				}
+				if got, want := resp.StatusCode, http.StatusOK; got != want {
+					t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+				}
+				if got := resp.Header.Get(dedupHeader); got != want {
+					t.Errorf("submission %d: %s=%q, want %q", i, dedupHeader, got, want)
+				}
+			}
+			if got, want := len(s.entries), 1; got != want {
+				t.Errorf("got %d entries, want %d", got, want)
+			}
+		})
+	}
+}
+func TestAddChainInflightAdds(t *testing.T) {
+	reader := testMetricReader()
+
+	const concurrency = 5
+	s := &slowStorage{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	log := setupFakeStorageLog(t, s)
+	handler := NewPathHandlers(t.Context(), &hOpts, log)[path.Join(prefix, rfc6962.AddChainPath)]
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Post(server.URL+rfc6962.AddChainPath, "application/json", createJSONChain(t, *pool))
+			if err != nil {
+				t.Errorf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+				return
+			}
+			if got, want := resp.StatusCode, http.StatusOK; got != want {
+				t.Errorf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+			}
+		}()
+	}
+	for range concurrency {
+		<-s.started
+	}
+
+	if got, want := gaugeValue(t, reader, "tesseract.storage.add.inflight", origin), int64(concurrency); got != want {
+		t.Errorf("in-flight adds=%d, want %d", got, want)
+	}
+	if got, want := gaugeValue(t, reader, "tesseract.storage.add.inflight.max", origin), int64(concurrency); got != want {
+		t.Errorf("max in-flight adds=%d, want %d", got, want)
+	}
+
+	close(s.release)
+	wg.Wait()
+
+	if got, want := gaugeValue(t, reader, "tesseract.storage.add.inflight", origin), int64(0); got != want {
+		t.Errorf("in-flight adds=%d, want %d", got, want)
+	}
+	if got, want := gaugeValue(t, reader, "tesseract.storage.add.inflight.max", origin), int64(concurrency); got != want {
+		t.Errorf("max in-flight adds=%d, want %d", got, want)
+	}
+}
+
+func TestAddChainOldestInflightAge(t *testing.T) {
+	reader := testMetricReader()
+
+	s := &slowStorage{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	log := setupFakeStorageLog(t, s)
+	handler := NewPathHandlers(t.Context(), &hOpts, log)[path.Join(prefix, rfc6962.AddChainPath)]
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err := http.Post(server.URL+rfc6962.AddChainPath, "application/json", createJSONChain(t, *pool))
+		if err != nil {
+			t.Errorf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+			return
+		}
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Errorf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+		}
+	}()
+	<-s.started
+
+	// The age of the blocked request must grow while it's in flight.
+	first := gaugeValue(t, reader, "tesseract.http.add.inflight.oldest_age", origin)
+	time.Sleep(50 * time.Millisecond)
+	second := gaugeValue(t, reader, "tesseract.http.add.inflight.oldest_age", origin)
+	if second <= first {
+		t.Errorf("oldest in-flight request age went from %dms to %dms, want it to grow", first, second)
+	}
+	if second < 50 {
+		t.Errorf("oldest in-flight request age=%dms, want at least 50ms", second)
+	}
+
+	close(s.release)
+	<-done
+
+	if got, want := gaugeValue(t, reader, "tesseract.http.add.inflight.oldest_age", origin), int64(0); got != want {
+		t.Errorf("oldest in-flight request age=%dms, want %dms", got, want)
+	}
+}
+
+func TestMultiLogSCTs(t *testing.T) {
+	origins := []string{"a.example.com", "b.example.com"}
+	keys := make(map[string]*ecdsa.PrivateKey)
+	signers := make(map[string]crypto.Signer)
+	for _, o := range origins {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("ecdsa.GenerateKey()=%v", err)
+		}
+		keys[o] = k
+		signers[o] = k
+	}
+	if err := VerifySigners(signers); err != nil {
+		t.Fatalf("VerifySigners()=%v", err)
+	}
+
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	for _, o := range origins {
+		t.Run(o, func(t *testing.T) {
+			s := &fakeStorage{}
+			log := setupFakeStorageLog(t, s)
+			log.origin = o
+			log.signSCT = (&sctSigner{signer: keys[o], origin: o}).Sign
+			addChainPath := path.Join("/", o, rfc6962.AddChainPath)
+			server := httptest.NewServer(NewPathHandlers(t.Context(), &hOpts, log)[addChainPath])
+			defer server.Close()
+
+			resp, err := http.Post(server.URL+addChainPath, "application/json", createJSONChain(t, *pool))
+			if err != nil {
+				t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", addChainPath, err)
+			}
+			if got, want := resp.StatusCode, http.StatusOK; got != want {
+				t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", addChainPath, got, want)
+			}
+			var rsp rfc6962.AddChainResponse
+			if err := json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+				t.Fatalf("json.Decode()=%v", err)
+			}
+			for _, other := range origins {
+				want := other == o
+				if got := sctVerifies(t, s.entries[0], 0, rsp, &keys[other].PublicKey); got != want {
+					t.Errorf("SCT from %s verifies under the key of %s: %t, want %t", o, other, got, want)
+				}
+			}
+		})
+	}
+}
+
+// setupMirror serves the add-chain endpoints of a new log with origin o,
+// signing SCTs with key, and returns it, along with its storage.
+func setupMirror(t *testing.T, o string, key *ecdsa.PrivateKey) (Mirror, *fakeStorage) {
+	t.Helper()
+	s := &fakeStorage{}
+	log := setupFakeStorageLog(t, s)
+	log.origin = o
+	log.signSCT = (&sctSigner{signer: key, origin: o}).Sign
+	mux := http.NewServeMux()
+	for p, h := range NewPathHandlers(t.Context(), &hOpts, log) {
+		mux.Handle(p, h)
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return Mirror{Origin: o, URL: server.URL + "/" + o}, s
+}
+
+func TestAddChainMirrorSCTs(t *testing.T) {
+	var keys []*ecdsa.PrivateKey
+	for range 3 {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("ecdsa.GenerateKey()=%v", err)
+		}
+		keys = append(keys, k)
+	}
+	logKey, mirrorKeys := keys[0], keys[1:]
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+
+	for _, tc := range []struct {
+		desc    string
+		mirrors int
+	}{
+		{
+			desc: "no-mirrors",
+		},
+		{
+			desc:    "two-mirrors",
+			mirrors: 2,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var mirrors []Mirror
+			var mirrorStorage []*fakeStorage
+			for i := range tc.mirrors {
+				m, ms := setupMirror(t, fmt.Sprintf("mirror-%d.example.com", i), mirrorKeys[i])
+				mirrors = append(mirrors, m)
+				mirrorStorage = append(mirrorStorage, ms)
+			}
+			s := &fakeStorage{}
+			log := setupFakeStorageLog(t, s)
+			log.signSCT = (&sctSigner{signer: logKey, origin: origin}).Sign
+			opts := hOpts
+			opts.Mirrors = mirrors
+			server := httptest.NewServer(NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)])
+			defer server.Close()
+
+			resp, err := http.Post(server.URL+rfc6962.AddChainPath, "application/json", createJSONChain(t, *pool))
+			if err != nil {
+				t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+			}
+			if got, want := resp.StatusCode, http.StatusOK; got != want {
+				t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+			}
+			var rsp addChainResponse
+			if err := json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+				t.Fatalf("json.Decode()=%v", err)
+			}
+
+			if !sctVerifies(t, s.entries[0], 0, rsp.AddChainResponse, &logKey.PublicKey) {
+				t.Errorf("log SCT doesn't verify under the log key")
+			}
+			if got, want := len(rsp.AdditionalSCTs), len(mirrors); got != want {
+				t.Fatalf("len(additional_scts)=%d, want %d", got, want)
+			}
+			// Mirror SCTs are issued by the mirrors, over their own entries.
+			for i, mirrorSCT := range rsp.AdditionalSCTs {
+				if got, want := len(mirrorStorage[i].entries), 1; got != want {
+					t.Fatalf("mirror %d has %d entries, want %d", i, got, want)
+				}
+				for j, k := range keys {
+					want := j == i+1
+					if got := sctVerifies(t, mirrorStorage[i].entries[0], 0, mirrorSCT, &k.PublicKey); got != want {
+						t.Errorf("mirror SCT %d verifies under key %d: %t, want %t", i, j, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAddChainMirrorFailure(t *testing.T) {
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer mirror.Close()
+
+	s := &fakeStorage{}
+	log := setupFakeStorageLog(t, s)
+	opts := hOpts
+	opts.Mirrors = []Mirror{{Origin: "mirror.example.com", URL: mirror.URL}}
+	server := httptest.NewServer(NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)])
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+rfc6962.AddChainPath, "application/json", createJSONChain(t, *pool))
+	if err != nil {
+		t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+	}
+	if got, want := resp.StatusCode, http.StatusBadGateway; got != want {
+		t.Errorf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+	}
+	if got, want := resp.Header.Get(errorCodeHeader), string(errCodeMirrorSubmit); got != want {
+		t.Errorf("%s=%q, want %q", errorCodeHeader, got, want)
+	}
+}
+
+// stubSCTSigner implements SCTSigner with a stub signature scheme, where
+// signatures are the SHA-256 hash of the RFC 6962 signature input.
+type stubSCTSigner struct{}
+
+func (stubSCTSigner) Sign(leaf *rfc6962.MerkleTreeLeaf) (*rfc6962.SignedCertificateTimestamp, error) {
+	sct := &rfc6962.SignedCertificateTimestamp{
+		SCTVersion: rfc6962.V1,
+		LogID:      rfc6962.LogID{KeyID: sha256.Sum256([]byte("stub"))},
+		Timestamp:  leaf.TimestampedEntry.Timestamp,
+		Extensions: leaf.TimestampedEntry.Extensions,
+	}
+	data, err := serializeSCTSignatureInput(*sct, rfc6962.LogEntry{Leaf: *leaf})
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256(data)
+	sct.Signature = rfc6962.DigitallySigned{
+		Algorithm: tls.SignatureAndHashAlgorithm{Hash: tls.SHA256, Signature: tls.Anonymous},
+		Signature: h[:],
+	}
+	return sct, nil
+}
+
+func TestSignerErrors(t *testing.T) {
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+
+	for _, tc := range []struct {
+		desc           string
+		err            error
+		want           int
+		wantCode       errorCode
+		wantRetryAfter string
+	}{
+		{
+			desc:           "unavailable",
+			err:            fmt.Errorf("KMS overloaded: %w", ErrSignerUnavailable),
+			want:           http.StatusServiceUnavailable,
+			wantCode:       errCodeSignerUnavailable,
+			wantRetryAfter: "1",
+		},
+		{
+			desc:           "deadline-exceeded",
+			err:            context.DeadlineExceeded,
+			want:           http.StatusServiceUnavailable,
+			wantCode:       errCodeSignerUnavailable,
+			wantRetryAfter: "1",
+		},
+		{
+			desc:           "timeout",
+			err:            fmt.Errorf("HSM: %w", os.ErrDeadlineExceeded),
+			want:           http.StatusServiceUnavailable,
+			wantCode:       errCodeSignerUnavailable,
+			wantRetryAfter: "1",
+		},
+		{
+			desc:     "other",
+			err:      errors.New("invalid key"),
+			want:     http.StatusInternalServerError,
+			wantCode: errCodeSignSCT,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			log := setupFakeStorageLog(t, &fakeStorage{})
+			opts := hOpts
+			opts.SCTSigner = &sctSigner{signer: testdata.NewSignerWithErr(nil, tc.err), origin: origin}
+			server := httptest.NewServer(NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)])
+			defer server.Close()
+
+			resp, err := http.Post(server.URL+rfc6962.AddChainPath, "application/json", createJSONChain(t, *pool))
+			if err != nil {
+				t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+			}
+			if got, want := resp.StatusCode, tc.want; got != want {
+				t.Errorf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+			}
+			if got, want := resp.Header.Get(errorCodeHeader), string(tc.wantCode); got != want {
+				t.Errorf("%s=%q, want %q", errorCodeHeader, got, want)
+			}
+			if got, want := resp.Header.Get("Retry-After"), tc.wantRetryAfter; got != want {
+				t.Errorf("Retry-After=%q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestAddChainCustomSCTSigner(t *testing.T) {
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	s := &fakeStorage{}
+	log := setupFakeStorageLog(t, s)
+	opts := hOpts
+	opts.SCTSigner = stubSCTSigner{}
+	server := httptest.NewServer(NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)])
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+rfc6962.AddChainPath, "application/json", createJSONChain(t, *pool))
+	if err != nil {
+		t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+	}
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+	}
+	var rsp addChainResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+		t.Fatalf("json.Decode()=%v", err)
+	}
+	if got, want := rsp.ID, sha256.Sum256([]byte("stub")); !bytes.Equal(got, want[:]) {
+		t.Errorf("id=%x, want %x", got, want)
+	}
+
+	// The stub scheme is deterministic, so the signature can be recomputed.
+	var leaf rfc6962.MerkleTreeLeaf
+	if _, err := tls.Unmarshal(s.entries[0].MerkleTreeLeaf(0), &leaf); err != nil {
+		t.Fatalf("failed to reconstruct MerkleTreeLeaf: %v", err)
+	}
+	data, err := serializeSCTSignatureInput(rfc6962.SignedCertificateTimestamp{SCTVersion: rfc6962.V1, Timestamp: rsp.Timestamp, Extensions: leaf.TimestampedEntry.Extensions}, rfc6962.LogEntry{Leaf: leaf})
+	if err != nil {
+		t.Fatalf("serializeSCTSignatureInput()=%v", err)
+	}
+	h := sha256.Sum256(data)
+	want, err := tls.Marshal(rfc6962.DigitallySigned{
+		Algorithm: tls.SignatureAndHashAlgorithm{Hash: tls.SHA256, Signature: tls.Anonymous},
+		Signature: h[:],
+	})
+	if err != nil {
+		t.Fatalf("tls.Marshal()=%v", err)
+	}
+	if !bytes.Equal(rsp.Signature, want) {
+		t.Errorf("signature=%x, want %x", rsp.Signature, want)
+	}
+}
+
+// sctVerifies returns whether the SCT in rsp, issued for entry at index,
+// verifies under pk.
+func sctVerifies(t *testing.T, entry *ctonly.Entry, index uint64, rsp rfc6962.AddChainResponse, pk *ecdsa.PublicKey) bool {
+	t.Helper()
+	var sig rfc6962.DigitallySigned
+	if rest, err := tls.Unmarshal(rsp.Signature, &sig); err != nil || len(rest) > 0 {
+		t.Fatalf("tls.Unmarshal()=(%d bytes left,%v)", len(rest), err)
+	}
+	var leaf rfc6962.MerkleTreeLeaf
+	if _, err := tls.Unmarshal(entry.MerkleTreeLeaf(index), &leaf); err != nil {
+		t.Fatalf("failed to reconstruct MerkleTreeLeaf: %v", err)
+	}
+	data, err := serializeSCTSignatureInput(rfc6962.SignedCertificateTimestamp{SCTVersion: rfc6962.V1, Timestamp: rsp.Timestamp, Extensions: leaf.TimestampedEntry.Extensions}, rfc6962.LogEntry{Leaf: leaf})
+	if err != nil {
+		t.Fatalf("serializeSCTSignatureInput()=%v", err)
+	}
+	h := sha256.Sum256(data)
+	return ecdsa.VerifyASN1(pk, h[:], sig.Signature)
+}
+
+func TestAddChainBinarySCT(t *testing.T) {
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+
+	// post submits the chain to a new log, with the given Accept header.
+	post := func(accept string) *http.Response {
+		t.Helper()
+		log := setupFakeStorageLog(t, &fakeStorage{})
+		handler := NewPathHandlers(t.Context(), &hOpts, log)[path.Join(prefix, rfc6962.AddChainPath)]
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+
+		req, err := http.NewRequest(http.MethodPost, server.URL+rfc6962.AddChainPath, createJSONChain(t, *pool))
+		if err != nil {
+			t.Fatalf("http.NewRequest()=%v", err)
+		}
+		req.Header.Set(contentTypeHeader, contentTypeJSON)
+		if accept != "" {
+			req.Header.Set(acceptHeader, accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+		}
+		return resp
+	}
+
+	jsonResp := post("")
+	if got, want := jsonResp.Header.Get(contentTypeHeader), contentTypeJSON; got != want {
+		t.Fatalf("%s=%q, want %q", contentTypeHeader, got, want)
+	}
+	var wantRsp rfc6962.AddChainResponse
+	if err := json.NewDecoder(jsonResp.Body).Decode(&wantRsp); err != nil {
+		t.Fatalf("json.Decode()=%v; want nil", err)
+	}
+
+	for _, tc := range []struct {
+		desc       string
+		accept     string
+		wantBinary bool
+	}{
+		{
+			desc:   "json",
+			accept: contentTypeJSON,
+		},
+		{
+			desc:       "binary",
+			accept:     contentTypeSCT,
+			wantBinary: true,
+		},
+		{
+			desc:       "binary-in-list",
+			accept:     contentTypeJSON + ";q=0.5, " + contentTypeSCT,
+			wantBinary: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			resp := post(tc.accept)
+			if !tc.wantBinary {
+				if got, want := resp.Header.Get(contentTypeHeader), contentTypeJSON; got != want {
+					t.Errorf("%s=%q, want %q", contentTypeHeader, got, want)
+				}
+				return
+			}
+			if got, want := resp.Header.Get(contentTypeHeader), contentTypeSCT; got != want {
+				t.Fatalf("%s=%q, want %q", contentTypeHeader, got, want)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to read response: %v", err)
+			}
+			var sct rfc6962.SignedCertificateTimestamp
+			if rest, err := tls.Unmarshal(body, &sct); err != nil {
+				t.Fatalf("tls.Unmarshal()=%v", err)
+			} else if len(rest) > 0 {
+				t.Fatalf("tls.Unmarshal() left %d bytes", len(rest))
+			}
+
+			if got, want := sct.SCTVersion, wantRsp.SCTVersion; got != want {
+				t.Errorf("SCTVersion=%v, want %v", got, want)
+			}
+			if got, want := sct.LogID.KeyID[:], wantRsp.ID; !bytes.Equal(got, want) {
+				t.Errorf("LogID=%x, want %x", got, want)
+			}
+			if got, want := sct.Timestamp, wantRsp.Timestamp; got != want {
+				t.Errorf("Timestamp=%d, want %d", got, want)
+			}
+			if got, want := base64.StdEncoding.EncodeToString(sct.Extensions), wantRsp.Extensions; got != want {
+				t.Errorf("Extensions=%q, want %q", got, want)
+			}
+			sig, err := tls.Marshal(sct.Signature)
+			if err != nil {
+				t.Fatalf("tls.Marshal()=%v", err)
+			}
+			if got, want := sig, wantRsp.Signature; !bytes.Equal(got, want) {
+				t.Errorf("Signature=%x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func TestAddChainSCTList(t *testing.T) {
+	var keys []*ecdsa.PrivateKey
+	for range 2 {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("ecdsa.GenerateKey()=%v", err)
+		}
+		keys = append(keys, k)
+	}
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+
+	s := &fakeStorage{}
+	log := setupFakeStorageLog(t, s)
+	log.signSCT = (&sctSigner{signer: keys[0], origin: origin}).Sign
+	opts := hOpts
+	mirror, mirrorStorage := setupMirror(t, "mirror.example.com", keys[1])
+	opts.Mirrors = []Mirror{mirror}
+	server := httptest.NewServer(NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)])
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+rfc6962.AddChainPath, createJSONChain(t, *pool))
+	if err != nil {
+		t.Fatalf("http.NewRequest()=%v", err)
+	}
+	req.Header.Set(contentTypeHeader, contentTypeJSON)
+	req.Header.Set(acceptHeader, contentTypeSCTList)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+	}
+	if got, want := resp.Header.Get(contentTypeHeader), contentTypeSCTList; got != want {
+		t.Fatalf("%s=%q, want %q", contentTypeHeader, got, want)
+	}
+	ext, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+
+	// Embed the response as is in a certificate, and parse it back.
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: "leaf.example.com"},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: rfc6962.OIDExtensionCTSCTList, Value: ext}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate()=%v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("x509.ParseCertificate()=%v", err)
+	}
+	if err := checkEmbeddedSCTList(cert); err != nil {
+		t.Fatalf("checkEmbeddedSCTList()=%v", err)
+	}
+	var sctList []byte
+	for _, e := range cert.Extensions {
+		if e.Id.Equal(rfc6962.OIDExtensionCTSCTList) {
+			if _, err := asn1.Unmarshal(e.Value, &sctList); err != nil {
+				t.Fatalf("asn1.Unmarshal()=%v", err)
+			}
+		}
+	}
+	l := cryptobyte.String(sctList)
+	var scts cryptobyte.String
+	if !l.ReadUint16LengthPrefixed(&scts) || !l.Empty() {
+		t.Fatalf("invalid SCT list %x", sctList)
+	}
+
+	// The log's SCT comes first, followed by the mirror's.
+	entries := []*ctonly.Entry{s.entries[0], mirrorStorage.entries[0]}
+	for i, k := range keys {
+		var sctBytes cryptobyte.String
+		if !scts.ReadUint16LengthPrefixed(&sctBytes) {
+			t.Fatalf("SCT list has %d SCTs, want %d", i, len(keys))
+		}
+		var sct rfc6962.SignedCertificateTimestamp
+		if rest, err := tls.Unmarshal(sctBytes, &sct); err != nil || len(rest) > 0 {
+			t.Fatalf("tls.Unmarshal(SCT %d)=(%d bytes left,%v)", i, len(rest), err)
+		}
+		rsp, err := toAddChainResponse(&sct)
+		if err != nil {
+			t.Fatalf("toAddChainResponse()=%v", err)
+		}
+		if !sctVerifies(t, entries[i], 0, rsp, &k.PublicKey) {
+			t.Errorf("SCT %d doesn't verify under key %d", i, i)
+		}
+	}
+	if !scts.Empty() {
+		t.Errorf("SCT list has more than %d SCTs", len(keys))
+	}
+}
+
+func TestQueueMode(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	queue, err := NewSubmissionQueue(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewSubmissionQueue()=%v", err)
+	}
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+
+	s := &fakeStorage{}
+	log := setupFakeStorageLog(t, s)
+	log.signSCT = (&sctSigner{signer: key, origin: origin}).Sign
+	opts := hOpts
+	opts.SubmissionQueue = queue
+	mux := http.NewServeMux()
+	for p, h := range NewPathHandlers(t.Context(), &opts, log) {
+		mux.Handle(p, h)
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+path.Join(prefix, rfc6962.AddChainPath), contentTypeJSON, createJSONChain(t, *pool))
+	if err != nil {
+		t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusAccepted; got != want {
+		t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+	}
+	var queued queuedSubmissionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&queued); err != nil {
+		t.Fatalf("Failed to decode queued submission response: %v", err)
+	}
+	s.mu.Lock()
+	if len(s.entries) != 0 {
+		t.Errorf("%d entries sequenced by add-chain in queue mode, want 0", len(s.entries))
+	}
+	s.mu.Unlock()
+
+	getQueued := func(id string) *http.Response {
+		t.Helper()
+		resp, err := http.Get(server.URL + path.Join(prefix, getQueuedSCTPath) + "?" + url.Values{getQueuedSCTParamID: {id}}.Encode())
+		if err != nil {
+			t.Fatalf("http.Get(%s)=(_,%q); want (_,nil)", getQueuedSCTPath, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	if got, want := getQueued("not-an-id").StatusCode, http.StatusBadRequest; got != want {
+		t.Errorf("get-queued-sct with an invalid ID: status=%d, want %d", got, want)
+	}
+	if got, want := getQueued(strings.Repeat("00", sha256.Size)).StatusCode, http.StatusNotFound; got != want {
+		t.Errorf("get-queued-sct with an unknown ID: status=%d, want %d", got, want)
+	}
+
+	// The background sequencer eventually sequences the submission.
+	deadline := time.Now().Add(10 * time.Second)
+	resp = getQueued(queued.ID)
+	for resp.StatusCode == http.StatusAccepted && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		resp = getQueued(queued.ID)
+	}
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Fatalf("get-queued-sct: status=%d, want %d", got, want)
+	}
+	var rsp addChainResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+		t.Fatalf("Failed to decode add-chain response: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) != 1 {
+		t.Fatalf("%d entries sequenced, want 1", len(s.entries))
+	}
+	if !sctVerifies(t, s.entries[0], 0, rsp.AddChainResponse, &key.PublicKey) {
+		t.Error("SCT of queued submission doesn't verify")
+	}
+}
+
+// setupQueueModeLog serves the public and admin endpoints of a log in queue
+// mode, and returns the log along with the URLs of both servers.
+func setupQueueModeLog(t *testing.T, opts *HandlerOptions, s Storage) (*log, string, string) {
+	t.Helper()
+	log := setupFakeStorageLog(t, s)
+	mux := http.NewServeMux()
+	for p, h := range NewPathHandlers(t.Context(), opts, log) {
+		mux.Handle(p, h)
+	}
+	adminMux := http.NewServeMux()
+	for p, h := range NewAdminPathHandlers(opts, log) {
+		adminMux.Handle(p, h)
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	admin := httptest.NewServer(adminMux)
+	t.Cleanup(admin.Close)
+	return log, server.URL, admin.URL
+}
+
+// queueChain submits the test chain to a log in queue mode, and returns the
+// queued submission ID.
+func queueChain(t *testing.T, serverURL string) string {
+	t.Helper()
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	resp, err := http.Post(serverURL+path.Join(prefix, rfc6962.AddChainPath), contentTypeJSON, createJSONChain(t, *pool))
+	if err != nil {
+		t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if got, want := resp.StatusCode, http.StatusAccepted; got != want {
+		t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+	}
+	var queued queuedSubmissionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&queued); err != nil {
+		t.Fatalf("Failed to decode queued submission response: %v", err)
+	}
+	return queued.ID
+}
+
+// waitForQueued polls get-queued-sct for id until it is no longer pending,
+// and returns the response.
+func waitForQueued(t *testing.T, serverURL, id string) *http.Response {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		resp, err := http.Get(serverURL + path.Join(prefix, getQueuedSCTPath) + "?" + url.Values{getQueuedSCTParamID: {id}}.Encode())
+		if err != nil {
+			t.Fatalf("http.Get(%s)=(_,%q); want (_,nil)", getQueuedSCTPath, err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		if resp.StatusCode != http.StatusAccepted || time.Now().After(deadline) {
+			return resp
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func TestQueuePause(t *testing.T) {
+	queue, err := NewSubmissionQueue(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewSubmissionQueue()=%v", err)
+	}
+	queue.SetPaused(true)
+	s := &fakeStorage{}
+	opts := hOpts
+	opts.SubmissionQueue = queue
+	log, serverURL, adminURL := setupQueueModeLog(t, &opts, s)
+
+	// setPaused pauses or resumes the queue on the admin endpoint.
+	setPaused := func(paused bool) queueStatusResponse {
+		t.Helper()
+		p := resumeQueuePath
+		if paused {
+			p = pauseQueuePath
+		}
+		resp, err := http.Post(adminURL+path.Join(prefix, p), contentTypeJSON, nil)
+		if err != nil {
+			t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", p, err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", p, got, want)
+		}
+		var rsp queueStatusResponse
+		if err := json.NewDecoder(resp.Body).Decode(&rsp); err != nil {
+			t.Fatalf("Failed to decode queue status: %v", err)
+		}
+		return rsp
+	}
+
+	id := queueChain(t, serverURL)
+	// Draining a paused queue doesn't sequence anything.
+	if err := drainQueue(t.Context(), &opts, log); err != nil {
+		t.Fatalf("drainQueue()=%v", err)
+	}
+	s.mu.Lock()
+	if got := len(s.entries); got != 0 {
+		t.Errorf("%d entries sequenced while the queue is paused, want 0", got)
+	}
+	s.mu.Unlock()
+	if got, want := setPaused(true), (queueStatusResponse{Paused: true, Pending: 1}); got != want {
+		t.Errorf("pause-queue=%+v, want %+v", got, want)
+	}
+
+	if got, want := setPaused(false), (queueStatusResponse{Paused: false, Pending: 1}); got != want {
+		t.Errorf("resume-queue=%+v, want %+v", got, want)
+	}
+	if got, want := waitForQueued(t, serverURL, id).StatusCode, http.StatusOK; got != want {
+		t.Fatalf("get-queued-sct once resumed: status=%d, want %d", got, want)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if got, want := len(s.entries), 1; got != want {
+		t.Errorf("%d entries sequenced once resumed, want %d", got, want)
+	}
+}
+
+func TestQueueDroppedSubmission(t *testing.T) {
+	queue, err := NewSubmissionQueue(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewSubmissionQueue()=%v", err)
+	}
+	opts := hOpts
+	opts.SubmissionQueue = queue
+	opts.EntryBuilder = func([]*x509.Certificate, bool, uint64) (*ctonly.Entry, error) {
+		return nil, errors.New("unsupported certificate")
+	}
+	_, serverURL, _ := setupQueueModeLog(t, &opts, &fakeStorage{})
+
+	// The submission can't be sequenced: clients get the error it was
+	// dropped with.
+	resp := waitForQueued(t, serverURL, queueChain(t, serverURL))
+	if got, want := resp.StatusCode, http.StatusBadRequest; got != want {
+		t.Errorf("get-queued-sct: status=%d, want %d", got, want)
+	}
+	if got, want := resp.Header.Get(errorCodeHeader), string(errCodeBuildEntry); got != want {
+		t.Errorf("%s=%q, want %q", errorCodeHeader, got, want)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("io.ReadAll()=%v", err)
+	}
+	if !strings.Contains(string(body), "unsupported certificate") {
+		t.Errorf("get-queued-sct body=%q, want the error the submission was dropped with", body)
+	}
+}
+
+func TestSubmissionQueue(t *testing.T) {
+	dir := t.TempDir()
+	queue, err := NewSubmissionQueue(dir, 2)
+	if err != nil {
+		t.Fatalf("NewSubmissionQueue()=%v", err)
+	}
+	chain := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM}).RawCertificates()
+
+	var ids []string
+	for i, c := range [][]*x509.Certificate{chain, chain[1:]} {
+		id, err := queue.enqueue(c, false, fakeTimeStart.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatalf("enqueue()=%v", err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := queue.enqueue(chain, false, fakeTimeStart); err != nil {
+		t.Errorf("enqueue() of a pending submission in a full queue=%v, want nil", err)
+	}
+	if _, err := queue.enqueue(chain[2:], false, fakeTimeStart); !errors.Is(err, errQueueFull) {
+		t.Errorf("enqueue() in a full queue=%v, want %v", err, errQueueFull)
+	}
+
+	// Pending submissions are indexed again, in order, on startup.
+	queue, err = NewSubmissionQueue(dir, 2)
+	if err != nil {
+		t.Fatalf("NewSubmissionQueue()=%v", err)
+	}
+	if got := queue.pendingList(); !slices.Equal(got, ids) {
+		t.Errorf("pendingList()=%v, want %v", got, ids)
+	}
+
+	if err := queue.complete(ids[0], []byte("{}")); err != nil {
+		t.Fatalf("complete()=%v", err)
+	}
+	if got, want := queue.pendingList(), ids[1:]; !slices.Equal(got, want) {
+		t.Errorf("pendingList()=%v, want %v", got, want)
+	}
+	if _, err := queue.enqueue(chain[2:], false, fakeTimeStart); err != nil {
+		t.Errorf("enqueue() once the queue has room=%v, want nil", err)
+	}
+}
+
+func TestAddChainSubmissionCache(t *testing.T) {
+	const concurrency = 10
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	body, err := io.ReadAll(createJSONChain(t, *pool))
+	if err != nil {
+		t.Fatalf("io.ReadAll()=%v", err)
+	}
+
+	ts := newFakeTimeSource(fakeTimeStart)
+	opts := hOpts
+	opts.TimeSource = ts
+	opts.SubmissionCacheTTL = time.Minute
+	s := &slowStorage{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	log := setupFakeStorageLog(t, s)
+	handler := NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)]
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// post submits body, and returns the response body.
+	post := func(body []byte) []byte {
+		resp, err := http.Post(server.URL+rfc6962.AddChainPath, contentTypeJSON, bytes.NewReader(body))
+		if err != nil {
+			t.Errorf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+			return nil
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Errorf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+		}
+		rsp, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Errorf("io.ReadAll()=%v", err)
+		}
+		return rsp
+	}
+
+	// Fire identical submissions while the first one is being sequenced.
+	rsps := make([][]byte, concurrency)
+	var wg sync.WaitGroup
+	for i := range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rsps[i] = post(body)
+		}()
+	}
+	<-s.started
+	close(s.release)
+	wg.Wait()
+
+	if got, want := len(s.entries), 1; got != want {
+		t.Fatalf("len(storage.entries)=%d; want %d", got, want)
+	}
+	for i, rsp := range rsps {
+		if !bytes.Equal(rsp, rsps[0]) {
+			t.Errorf("response %d=%s; want %s", i, rsp, rsps[0])
+		}
+	}
+
+	// Identical submissions are sequenced again once the cache entry expires.
+	s.release = make(chan struct{})
+	close(s.release)
+	go func() { <-s.started }()
+	ts.Add1m()
+	if rsp := post(body); bytes.Equal(rsp, rsps[0]) {
+		t.Errorf("response after expiry=%s; want a new SCT", rsp)
+	}
+	if got, want := len(s.entries), 2; got != want {
+		t.Errorf("len(storage.entries)=%d; want %d", got, want)
+	}
+
+	// The cache is keyed on chains: equivalent submissions are not sequenced.
+	post(append(body, ' '))
+	if got, want := len(s.entries), 2; got != want {
+		t.Errorf("len(storage.entries)=%d; want %d", got, want)
+	}
+}
+
+func TestAddChainSubmissionCacheChecks(t *testing.T) {
+	const vetoHeader = "X-Veto"
+	pool := loadCertsIntoPoolOrDie(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM})
+	body, err := io.ReadAll(createJSONChain(t, *pool))
+	if err != nil {
+		t.Fatalf("io.ReadAll()=%v", err)
+	}
+
+	opts := hOpts
+	opts.SubmissionCacheTTL = time.Minute
+	opts.DedupHeader = true
+	// Interceptors decide on request metadata, which differs between
+	// identical submissions.
+	opts.Interceptors = []Interceptor{InterceptorFunc(func(_ context.Context, s *Submission) error {
+		if s.Request.Header.Get(vetoHeader) != "" {
+			return errors.New("vetoed by header")
+		}
+		return nil
+	})}
+	s := &fakeStorage{}
+	log := setupFakeStorageLog(t, s)
+	handler := NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)]
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	for _, tc := range []struct {
+		desc      string
+		veto      bool
+		want      int
+		wantDedup string
+	}{
+		{desc: "first", want: http.StatusOK, wantDedup: "false"},
+		{desc: "cached", want: http.StatusOK, wantDedup: "false"},
+		{desc: "cached-vetoed", veto: true, want: http.StatusBadRequest},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, server.URL+rfc6962.AddChainPath, bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("http.NewRequest()=%v", err)
+			}
+			req.Header.Set("Content-Type", contentTypeJSON)
+			if tc.veto {
+				req.Header.Set(vetoHeader, "true")
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			if got, want := resp.StatusCode, tc.want; got != want {
+				t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+			}
+			if got, want := resp.Header.Get(dedupHeader), tc.wantDedup; got != want {
+				t.Errorf("%s=%q, want %q", dedupHeader, got, want)
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if got, want := len(s.entries), 1; got != want {
+				t.Errorf("len(storage.entries)=%d; want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestSubmissionCacheEviction(t *testing.T) {
+	const ttl = time.Minute
+	c := newSubmissionCache(ttl)
+	// add caches a successful submission of chain at now.
+	add := func(chain string, now time.Time) [sha256.Size]byte {
+		t.Helper()
+		key := submissionKey(false, [][]byte{[]byte(chain)})
+		e, owner := c.start(key, now)
+		if !owner || e == nil {
+			t.Fatalf("start(%s)=(%v, %t), want a new entry", chain, e, owner)
+		}
+		e.sct = &rfc6962.SignedCertificateTimestamp{}
+		c.finish(key, e, now)
+		return key
+	}
+	first := add("first", fakeTimeStart)
+	second := add("second", fakeTimeStart.Add(ttl/2))
+	pending := submissionKey(false, [][]byte{[]byte("pending")})
+	if _, owner := c.start(pending, fakeTimeStart); !owner {
+		t.Fatal("start(pending) didn't return a new entry")
+	}
+
+	// Only the first submission has expired: it is evicted on the next
+	// lookup, without visiting the others.
+	now := fakeTimeStart.Add(ttl)
+	if e, owner := c.start(second, now); owner || !e.wait(t.Context()) {
+		t.Errorf("start(second)=(_, %t), want the cached entry", owner)
+	}
+	if _, ok := c.entries[first]; ok {
+		t.Error("expired entry wasn't evicted")
+	}
+	if got, want := c.expiries.Len(), 1; got != want {
+		t.Errorf("%d entries waiting to expire, want %d", got, want)
+	}
+	// Entries being processed never expire.
+	if _, owner := c.start(pending, now.Add(ttl)); owner {
+		t.Error("start(pending) returned a new entry for a submission being processed")
+	}
+	if got, want := len(c.entries), 1; got != want {
+		t.Errorf("%d cached entries, want %d", got, want)
+	}
+}
+
+func TestAddChainDERChain(t *testing.T) {
+	chainPEMs := []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM}
+	pool := loadCertsIntoPoolOrDie(t, chainPEMs)
+
+	for _, tc := range []struct {
+		desc            string
+		acceptDERChains bool
+		contentType     string
+		body            io.Reader
+		want            int
+	}{
+		{
+			desc:            "json",
+			acceptDERChains: true,
+			contentType:     contentTypeJSON,
+			body:            createJSONChain(t, *pool),
+			want:            http.StatusOK,
+		},
+		{
+			desc:            "der",
+			acceptDERChains: true,
+			contentType:     contentTypeDERChain,
+			body:            createDERChain(t, *pool),
+			want:            http.StatusOK,
+		},
+		{
+			desc:            "der-with-params",
+			acceptDERChains: true,
+			contentType:     contentTypeDERChain + "; charset=binary",
+			body:            createDERChain(t, *pool),
+			want:            http.StatusOK,
+		},
+		{
+			desc:            "der-disabled",
+			acceptDERChains: false,
+			contentType:     contentTypeDERChain,
+			body:            createDERChain(t, *pool),
+			want:            http.StatusBadRequest,
+		},
+		{
+			desc:            "der-truncated",
+			acceptDERChains: true,
+			contentType:     contentTypeDERChain,
+			body:            io.LimitReader(createDERChain(t, *pool), 100),
+			want:            http.StatusBadRequest,
+		},
+		{
+			desc:            "der-empty",
+			acceptDERChains: true,
+			contentType:     contentTypeDERChain,
+			body:            strings.NewReader(""),
+			want:            http.StatusBadRequest,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := hOpts
+			opts.AcceptDERChains = tc.acceptDERChains
+			s := &fakeStorage{}
+			log := setupFakeStorageLog(t, s)
+			handler := NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)]
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			resp, err := http.Post(server.URL+rfc6962.AddChainPath, tc.contentType, tc.body)
+			if err != nil {
+				t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+			}
+			if got, want := resp.StatusCode, tc.want; got != want {
+				t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+			}
+			if tc.want != http.StatusOK {
+				return
+			}
+
+			// Binary and JSON submissions of the same chain must log the same entry.
+			wantEntry, _ := parseChain(t, false, chainPEMs, log.chainValidator.Roots()[0], timeSource.Now())
+			if got, want := len(s.entries), 1; got != want {
+				t.Fatalf("len(storage.entries)=%d; want %d", got, want)
+			}
+			if diff := cmp.Diff(wantEntry.LeafData(0), s.entries[0].LeafData(0)); diff != "" {
+				t.Errorf("Logged entry mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONChain(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		body string
+	}{
+		{desc: "chain", body: `{"chain": ["AQI=", "AwQ="]}`},
+		{desc: "key-case", body: `{"Chain": ["AQI="]}`},
+		{desc: "unknown-keys", body: `{"a": {"b": [1, {"c": "d"}]}, "chain": ["AQI="], "e": null}`},
+		{desc: "duplicate-keys", body: `{"chain": ["AQI="], "chain": ["AwQ="]}`},
+		{desc: "null-chain", body: `{"chain": null}`},
+		{desc: "empty-chain", body: `{"chain": []}`},
+		{desc: "empty-object", body: `{}`},
+		{desc: "trailing-whitespace", body: `{"chain": ["AQI="]}` + "\n"},
+		{desc: "trailing-data", body: `{"chain": ["AQI="]} {}`},
+		{desc: "not-an-object", body: `["AQI="]`},
+		{desc: "chain-not-an-array", body: `{"chain": "AQI="}`},
+		{desc: "invalid-base64", body: `{"chain": ["!!"]}`},
+		{desc: "truncated", body: `{"chain": ["AQI="`},
+		{desc: "empty", body: ``},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var want rfc6962.AddChainRequest
+			wantErr := json.Unmarshal([]byte(tc.body), &want)
+
+			got, err := decodeJSONChain(json.NewDecoder(strings.NewReader(tc.body)), 0, 0)
+			if gotErr, wantErr := err != nil, wantErr != nil; gotErr != wantErr {
+				t.Fatalf("decodeJSONChain()=%v, want error: %t", err, wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("decodeJSONChain() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestParseBodyAsJSONChainStreamLargeChain(t *testing.T) {
+	const numCerts = 1000
+	var req rfc6962.AddChainRequest
+	for range numCerts {
+		req.Chain = append(req.Chain, bytes.Repeat([]byte{0x42}, 1024))
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("json.Marshal()=%v", err)
+	}
+
+	for _, tc := range []struct {
+		desc     string
+		maxCerts int
+		wantErr  bool
+		// wantMaxRead is the maximum number of bytes which may be read.
+		wantMaxRead int
+	}{
+		{
+			desc:        "no-limit",
+			wantMaxRead: len(body),
+		},
+		{
+			desc:        "below-limit",
+			maxCerts:    numCerts,
+			wantMaxRead: len(body),
+		},
+		{
+			desc:     "above-limit",
+			maxCerts: 10,
+			wantErr:  true,
+			// Only the first certificates are read, regardless of the body size.
+			wantMaxRead: len(body) / 10,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			cr := &countingReader{r: bytes.NewReader(body)}
+			r := httptest.NewRequest(http.MethodPost, rfc6962.AddChainPath, cr)
+
+			got, err := parseBodyAsJSONChainStream(r, tc.maxCerts, 0)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("parseBodyAsJSONChainStream()=%v, want error: %t", err, tc.wantErr)
+			}
+			if cr.n > tc.wantMaxRead {
+				t.Errorf("read %d bytes, want at most %d", cr.n, tc.wantMaxRead)
+			}
+			if err != nil {
+				return
+			}
+			if !slices.EqualFunc(req.Chain, got.Chain, bytes.Equal) {
+				t.Errorf("parseBodyAsJSONChainStream() returned %d certificates, which differ from the %d submitted", len(got.Chain), len(req.Chain))
+			}
+		})
+	}
+}
+
+func TestAddChainStreamJSONChains(t *testing.T) {
+	chainPEMs := []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM}
+	pool := loadCertsIntoPoolOrDie(t, chainPEMs)
+
+	for _, tc := range []struct {
+		desc          string
+		maxChainCerts int
+		body          io.Reader
+		want          int
+	}{
+		{
+			desc: "chain",
+			body: createJSONChain(t, *pool),
+			want: http.StatusOK,
+		},
+		{
+			desc:          "at-limit",
+			maxChainCerts: len(chainPEMs),
+			body:          createJSONChain(t, *pool),
+			want:          http.StatusOK,
+		},
+		{
+			desc:          "above-limit",
+			maxChainCerts: len(chainPEMs) - 1,
+			body:          createJSONChain(t, *pool),
+			want:          http.StatusBadRequest,
+		},
+		{
+			desc: "invalid-json",
+			body: strings.NewReader(`{"chain": [`),
+			want: http.StatusBadRequest,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := hOpts
+			opts.StreamJSONChains = true
+			opts.MaxChainCerts = tc.maxChainCerts
+			s := &fakeStorage{}
+			log := setupFakeStorageLog(t, s)
+			handler := NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)]
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			resp, err := http.Post(server.URL+rfc6962.AddChainPath, contentTypeJSON, tc.body)
+			if err != nil {
+				t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+			}
+			if got, want := resp.StatusCode, tc.want; got != want {
+				t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+			}
+			if got, want := len(s.entries), 0; tc.want != http.StatusOK && got != want {
+				t.Errorf("len(storage.entries)=%d; want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestMaxChainBytes(t *testing.T) {
+	chainPEMs := []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM}
+	pool := loadCertsIntoPoolOrDie(t, chainPEMs)
+	size := 0
+	for _, cert := range pool.RawCertificates() {
+		size += len(cert.Raw)
+	}
+
+	for _, format := range []struct {
+		desc        string
+		contentType string
+		body        func() io.Reader
+		stream      bool
+	}{
+		{
+			desc:        "json",
+			contentType: contentTypeJSON,
+			body:        func() io.Reader { return createJSONChain(t, *pool) },
+		},
+		{
+			desc:        "streamed-json",
+			contentType: contentTypeJSON,
+			body:        func() io.Reader { return createJSONChain(t, *pool) },
+			stream:      true,
+		},
+		{
+			desc:        "der",
+			contentType: contentTypeDERChain,
+			body:        func() io.Reader { return createDERChain(t, *pool) },
+		},
+	} {
+		for _, tc := range []struct {
+			desc          string
+			maxChainBytes int
+			want          int
+		}{
+			{
+				desc: "no-limit",
+				want: http.StatusOK,
+			},
+			{
+				desc:          "under-limit",
+				maxChainBytes: size + 1,
+				want:          http.StatusOK,
+			},
+			{
+				desc:          "at-limit",
+				maxChainBytes: size,
+				want:          http.StatusOK,
+			},
+			{
+				desc:          "over-limit",
+				maxChainBytes: size - 1,
+				want:          http.StatusBadRequest,
+			},
+		} {
+			t.Run(format.desc+"/"+tc.desc, func(t *testing.T) {
+				opts := hOpts
+				opts.AcceptDERChains = true
+				opts.StreamJSONChains = format.stream
+				opts.MaxChainBytes = tc.maxChainBytes
+				s := &fakeStorage{}
+				log := setupFakeStorageLog(t, s)
+				handler := NewPathHandlers(t.Context(), &opts, log)[path.Join(prefix, rfc6962.AddChainPath)]
+				server := httptest.NewServer(handler)
+				defer server.Close()
+
+				resp, err := http.Post(server.URL+rfc6962.AddChainPath, format.contentType, format.body())
+				if err != nil {
+					t.Fatalf("http.Post(%s)=(_,%q); want (_,nil)", rfc6962.AddChainPath, err)
+				}
+				if got, want := resp.StatusCode, tc.want; got != want {
+					t.Fatalf("http.Post(%s)=(%d,nil); want (%d,nil)", rfc6962.AddChainPath, got, want)
+				}
+				if tc.want != http.StatusOK {
+					if got, want := resp.Header.Get(errorCodeHeader), string(errCodeInvalidBody); got != want {
+						t.Errorf("%s=%q, want %q", errorCodeHeader, got, want)
+					}
+					if got, want := len(s.entries), 0; got != want {
+						t.Errorf("len(storage.entries)=%d; want %d", got, want)
+					}
+				}
+			})
+		}
+	}
+}
+
+// createDERChain builds a binary chain of length-prefixed DER certificates.
+func createDERChain(t *testing.T, p x509util.PEMCertPool) io.Reader {
+	t.Helper()
+	b := cryptobyte.NewBuilder(nil)
+	for _, rawCert := range p.RawCertificates() {
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(rawCert.Raw)
+		})
+	}
+	body, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("Failed to create test DER chain: %v", err)
+	}
+	return bytes.NewReader(body)
+}
+
+func createJSONChain(t *testing.T, p x509util.PEMCertPool) io.Reader {
+	t.Helper()
+	var req rfc6962.AddChainRequest
+	for _, rawCert := range p.RawCertificates() {
+		req.Chain = append(req.Chain, rawCert.Raw)
+	}
+
+	var buffer bytes.Buffer
+	// It's tempting to avoid creating and flushing the intermediate writer but it doesn't work
+	writer := bufio.NewWriter(&buffer)
+	err := json.NewEncoder(writer).Encode(&req)
+	if err := writer.Flush(); err != nil {
+		t.Error(err)
+	}
+
+	if err != nil {
+		t.Fatalf("Failed to create test json: %v", err)
+	}
+
+	return bufio.NewReader(&buffer)
+}
+
+func loadCertsIntoPoolOrDie(t *testing.T, certs []string) *x509util.PEMCertPool {
+	t.Helper()
+	pool := x509util.NewPEMCertPool()
+	for _, cert := range certs {
 		if !pool.AppendCertsFromPEM([]byte(cert)) {
 			t.Fatalf("couldn't parse test certs: %v", certs)
 		}
 	}
 	return pool
 }
+
+func TestPOSIXStorageBackends(t *testing.T) {
+	log, _ := setupTestLog(t)
+	s, ok := log.storage.(*storage.CTStorage)
+	if !ok {
+		t.Fatalf("log storage is a %T, want *storage.CTStorage", log.storage)
+	}
+
+	want := storage.Backends{
+		Log:      "posix",
+		Issuers:  "posix",
+		Antispam: "badger",
+	}
+	if got := s.Backends(); got != want {
+		t.Errorf("Backends()=%+v, want %+v", got, want)
+	}
+}
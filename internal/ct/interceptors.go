@@ -0,0 +1,64 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+)
+
+// Submission describes an add-chain or add-pre-chain submission which passed
+// validation, and which has not been sequenced yet.
+type Submission struct {
+	// Origin is the origin of the log the chain was submitted to.
+	Origin string
+	// Chain is the validated chain, starting with the submitted certificate
+	// or precertificate, and ending with a trusted root.
+	Chain []*x509.Certificate
+	// IsPrecert indicates if the chain was submitted to add-pre-chain.
+	IsPrecert bool
+	// Request is the submission request, for instance to read its headers,
+	// remote address or TLS connection state. Its body has already been read.
+	Request *http.Request
+	// Overloaded indicates that the log is shedding load, see
+	// HandlerOptions.ShedLoadThreshold. Interceptors should then skip
+	// expensive checks which are not required to accept submissions.
+	Overloaded bool
+}
+
+// Interceptor observes submissions after they pass validation, before they
+// are sequenced, and can veto them. For instance, a moderation layer can
+// reject certificates for names which must not be logged.
+//
+// Interceptors are called in order for each submission. An interceptor
+// accepts the submission by returning nil, in which case it is passed on to
+// the next interceptor. Returning an error rejects it: the request fails with
+// http.StatusBadRequest and the error message, and later interceptors are not
+// called. Submissions accepted by all interceptors might still fail to be
+// sequenced.
+//
+// Interceptors are called concurrently, and must not modify submissions.
+type Interceptor interface {
+	Intercept(ctx context.Context, s *Submission) error
+}
+
+// InterceptorFunc adapts a function to an Interceptor.
+type InterceptorFunc func(ctx context.Context, s *Submission) error
+
+// Intercept calls f(ctx, s).
+func (f InterceptorFunc) Intercept(ctx context.Context, s *Submission) error {
+	return f(ctx, s)
+}
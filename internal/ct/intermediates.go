@@ -0,0 +1,80 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"sync"
+)
+
+// maxKnownIntermediates bounds the number of intermediates held by a
+// knownIntermediates. Intermediates are not recorded once it is full.
+const maxKnownIntermediates = 1 << 12
+
+// knownIntermediates records the intermediates of the chains stored in issuer
+// storage, grouped by the root that these chains lead to. It is safe for
+// concurrent use.
+type knownIntermediates struct {
+	mu sync.Mutex
+	// byRoot maps the SHA-256 fingerprint of roots to the DER intermediates
+	// chaining to them, in the order they were first seen.
+	byRoot map[[sha256.Size]byte][][]byte
+	// seen holds the fingerprints of the recorded intermediates, per root.
+	seen map[[sha256.Size]byte]map[[sha256.Size]byte]bool
+	// n is the total number of recorded intermediates.
+	n int
+}
+
+// newKnownIntermediates returns an empty knownIntermediates.
+func newKnownIntermediates() *knownIntermediates {
+	return &knownIntermediates{
+		byRoot: make(map[[sha256.Size]byte][][]byte),
+		seen:   make(map[[sha256.Size]byte]map[[sha256.Size]byte]bool),
+	}
+}
+
+// add records the intermediates of chain, which must go from a leaf up to a
+// root.
+func (k *knownIntermediates) add(chain []*x509.Certificate) {
+	if len(chain) < 3 {
+		return
+	}
+	root := sha256.Sum256(chain[len(chain)-1].Raw)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, cert := range chain[1 : len(chain)-1] {
+		fp := sha256.Sum256(cert.Raw)
+		if k.seen[root][fp] {
+			continue
+		}
+		if k.n >= maxKnownIntermediates {
+			return
+		}
+		if k.seen[root] == nil {
+			k.seen[root] = make(map[[sha256.Size]byte]bool)
+		}
+		k.seen[root][fp] = true
+		k.byRoot[root] = append(k.byRoot[root], cert.Raw)
+		k.n++
+	}
+}
+
+// list returns the DER intermediates recorded for root.
+func (k *knownIntermediates) list(root *x509.Certificate) [][]byte {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return append([][]byte{}, k.byRoot[sha256.Sum256(root.Raw)]...)
+}
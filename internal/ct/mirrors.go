@@ -0,0 +1,163 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/transparency-dev/tesseract/internal/types/rfc6962"
+	"github.com/transparency-dev/tesseract/internal/types/tls"
+)
+
+// maxMirrorResponseBytes bounds the size of add-chain responses read from
+// mirror logs.
+const maxMirrorResponseBytes = 64 << 10
+
+// Mirror is a log mirroring this one.
+//
+// Chains sequenced by this log are also submitted to its mirrors, which issue
+// their own SCTs, over their own entries.
+type Mirror struct {
+	// Origin is the origin of the mirror log.
+	Origin string
+	// URL is the URL prefix of the mirror log's submission API, such that
+	// its add-chain endpoint is at URL+"/ct/v1/add-chain".
+	URL string
+}
+
+// ValidateMirrors checks that mirrors have distinct, non-empty, origins, and
+// absolute HTTP or HTTPS URLs.
+func ValidateMirrors(mirrors []Mirror) error {
+	origins := make(map[string]bool, len(mirrors))
+	for _, m := range mirrors {
+		if m.Origin == "" {
+			return errors.New("empty origin")
+		}
+		if origins[m.Origin] {
+			return fmt.Errorf("duplicate origin %q", m.Origin)
+		}
+		origins[m.Origin] = true
+		u, err := url.Parse(m.URL)
+		if err != nil {
+			return fmt.Errorf("%s: invalid URL: %v", m.Origin, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s: URL %q is not an absolute HTTP(S) URL", m.Origin, m.URL)
+		}
+	}
+	return nil
+}
+
+// submitToMirrors submits chain to all mirrors concurrently, and returns the
+// SCTs they issued, in the same order as mirrors. It fails if any mirror does.
+func submitToMirrors(ctx context.Context, mirrors []Mirror, chain []*x509.Certificate, isPrecert bool) ([]*rfc6962.SignedCertificateTimestamp, error) {
+	if len(mirrors) == 0 {
+		return nil, nil
+	}
+	req := rfc6962.AddChainRequest{Chain: make([][]byte, 0, len(chain))}
+	for _, cert := range chain {
+		req.Chain = append(req.Chain, cert.Raw)
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal add-chain request: %v", err)
+	}
+	endpoint := rfc6962.AddChainPath
+	if isPrecert {
+		endpoint = rfc6962.AddPreChainPath
+	}
+
+	scts := make([]*rfc6962.SignedCertificateTimestamp, len(mirrors))
+	errs := make([]error, len(mirrors))
+	var wg sync.WaitGroup
+	for i, m := range mirrors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			scts[i], errs[i] = submitToMirror(ctx, strings.TrimSuffix(m.URL, "/")+endpoint, body)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("mirror %s: %v", m.Origin, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return scts, nil
+}
+
+// submitToMirror posts an add-chain request body to url, and returns the SCT
+// in the response.
+func submitToMirror(ctx context.Context, url string, body []byte) (*rfc6962.SignedCertificateTimestamp, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(contentTypeHeader, contentTypeJSON)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	rspBody, err := io.ReadAll(io.LimitReader(resp.Body, maxMirrorResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: status %d: %q", url, resp.StatusCode, rspBody)
+	}
+	var rsp rfc6962.AddChainResponse
+	if err := json.Unmarshal(rspBody, &rsp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	return fromAddChainResponse(rsp)
+}
+
+// fromAddChainResponse converts the add-chain JSON representation of an SCT
+// back to an SCT. It is the inverse of toAddChainResponse.
+func fromAddChainResponse(rsp rfc6962.AddChainResponse) (*rfc6962.SignedCertificateTimestamp, error) {
+	sct := &rfc6962.SignedCertificateTimestamp{
+		SCTVersion: rsp.SCTVersion,
+		Timestamp:  rsp.Timestamp,
+	}
+	if len(rsp.ID) != sha256.Size {
+		return nil, fmt.Errorf("log ID has %d bytes, want %d", len(rsp.ID), sha256.Size)
+	}
+	copy(sct.LogID.KeyID[:], rsp.ID)
+	ext, err := base64.StdEncoding.DecodeString(rsp.Extensions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode extensions: %v", err)
+	}
+	sct.Extensions = ext
+	if rest, err := tls.Unmarshal(rsp.Signature, &sct.Signature); err != nil {
+		return nil, fmt.Errorf("failed to parse signature: %v", err)
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("extra data (%d bytes) after signature", len(rest))
+	}
+	return sct, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// testMetricReader returns a reader for the metrics recorded by this package.
+//
+// Instruments created from the global meter provider are only delegated to the
+// first provider that is set, so this reader is shared by all tests.
+var testMetricReader = sync.OnceValue(func() sdkmetric.Reader {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	return reader
+})
+
+// findMetric collects metrics from reader, and returns the data of the named
+// metric for the datapoint labeled with the given origin.
+func findMetric[T any](t *testing.T, reader sdkmetric.Reader, name, origin string, dataPoints func(metricdata.Aggregation) ([]T, bool), attrs func(T) attribute.Set) T {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(t.Context(), &rm); err != nil {
+		t.Fatalf("Collect()=%v", err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			dps, ok := dataPoints(m.Data)
+			if !ok {
+				t.Fatalf("metric %q has unexpected type %T", name, m.Data)
+			}
+			for _, dp := range dps {
+				set := attrs(dp)
+				if v, ok := set.Value(originKey); ok && v.AsString() == origin {
+					return dp
+				}
+			}
+		}
+	}
+	t.Fatalf("no value for metric %q with origin %q", name, origin)
+	var zero T
+	return zero
+}
+
+// gaugeValue returns the value of the named int64 gauge for origin.
+func gaugeValue(t *testing.T, reader sdkmetric.Reader, name, origin string) int64 {
+	t.Helper()
+	dp := findMetric(t, reader, name, origin,
+		func(a metricdata.Aggregation) ([]metricdata.DataPoint[int64], bool) {
+			g, ok := a.(metricdata.Gauge[int64])
+			return g.DataPoints, ok
+		},
+		func(dp metricdata.DataPoint[int64]) attribute.Set { return dp.Attributes })
+	return dp.Value
+}
+
+// counterValue returns the value of the named int64 counter for origin.
+func counterValue(t *testing.T, reader sdkmetric.Reader, name, origin string) int64 {
+	t.Helper()
+	dp := findMetric(t, reader, name, origin,
+		func(a metricdata.Aggregation) ([]metricdata.DataPoint[int64], bool) {
+			s, ok := a.(metricdata.Sum[int64])
+			return s.DataPoints, ok
+		},
+		func(dp metricdata.DataPoint[int64]) attribute.Set { return dp.Attributes })
+	return dp.Value
+}
+
+// histogramValue returns the count and sum of the named float64 histogram for origin.
+func histogramValue(t *testing.T, reader sdkmetric.Reader, name, origin string) (uint64, float64) {
+	t.Helper()
+	dp := findMetric(t, reader, name, origin,
+		func(a metricdata.Aggregation) ([]metricdata.HistogramDataPoint[float64], bool) {
+			h, ok := a.(metricdata.Histogram[float64])
+			return h.DataPoints, ok
+		},
+		func(dp metricdata.HistogramDataPoint[float64]) attribute.Set { return dp.Attributes })
+	return dp.Count, dp.Sum
+}
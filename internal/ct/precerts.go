@@ -0,0 +1,79 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// maxCachedPrecerts bounds the number of precertificates held by a
+// precertCache. The oldest ones are evicted when it is full.
+const maxCachedPrecerts = 1 << 16
+
+// precertCache holds the NotAfter of recently logged precertificates, keyed by
+// their issuer and serial number, so that the corresponding final
+// certificates can be checked against them. It is safe for concurrent use.
+type precertCache struct {
+	mu       sync.Mutex
+	notAfter map[[sha256.Size]byte]time.Time
+	// keys holds the keys of notAfter in insertion order, in a ring buffer.
+	keys [][sha256.Size]byte
+	// next is the position of the next key in keys.
+	next int
+}
+
+// newPrecertCache returns a precertCache holding up to size precertificates.
+func newPrecertCache(size int) *precertCache {
+	return &precertCache{
+		notAfter: make(map[[sha256.Size]byte]time.Time, size),
+		keys:     make([][sha256.Size]byte, size),
+	}
+}
+
+// precertKey returns the cache key of a certificate with the given serial
+// number, issued by the key with the given SHA-256 hash.
+func precertKey(issuerKeyHash []byte, serial *big.Int) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(issuerKeyHash)
+	h.Write(serial.Bytes())
+	return [sha256.Size]byte(h.Sum(nil))
+}
+
+// add records notAfter for the precertificate with the given key, evicting
+// the oldest precertificate if the cache is full.
+func (c *precertCache) add(key [sha256.Size]byte, notAfter time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.notAfter[key]; !ok {
+		if len(c.notAfter) == len(c.keys) {
+			delete(c.notAfter, c.keys[c.next])
+		}
+		c.keys[c.next] = key
+		c.next = (c.next + 1) % len(c.keys)
+	}
+	c.notAfter[key] = notAfter
+}
+
+// get returns the NotAfter of the precertificate with the given key, and
+// false if it is not in the cache.
+func (c *precertCache) get(key [sha256.Size]byte) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	notAfter, ok := c.notAfter[key]
+	return notAfter, ok
+}
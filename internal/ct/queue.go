@@ -0,0 +1,550 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// queueDrainInterval is how often queued submissions are sequenced.
+	queueDrainInterval = time.Second
+	// queueDrainConcurrency is the maximum number of queued submissions
+	// being sequenced at once.
+	queueDrainConcurrency = 64
+	// queuedResultTTL is how long the responses of sequenced submissions
+	// are kept, for clients to fetch them with get-queued-sct.
+	queuedResultTTL = 24 * time.Hour
+	// Subdirectories of the queue directory, holding pending submissions,
+	// the add-chain responses of sequenced ones, and the errors of those
+	// which can't be sequenced.
+	queuePendingDir = "pending"
+	queueDoneDir    = "done"
+	queueFailedDir  = "failed"
+)
+
+// queuedSubmission is a validated submission waiting to be sequenced, as
+// stored in the queue.
+type queuedSubmission struct {
+	// Chain holds the DER certificates of the validated chain.
+	Chain     [][]byte  `json:"chain"`
+	IsPrecert bool      `json:"is_precert"`
+	QueuedAt  time.Time `json:"queued_at"`
+
+	// id identifies the submission in the queue.
+	id string
+}
+
+// queuedSubmissionResponse acknowledges a queued submission.
+type queuedSubmissionResponse struct {
+	// ID identifies the submission on the getQueuedSCTPath endpoint.
+	ID string `json:"id"`
+}
+
+// queuedFailure records why a queued submission can't be sequenced.
+type queuedFailure struct {
+	// Status is the HTTP status the submission failed with.
+	Status int       `json:"status"`
+	Code   errorCode `json:"code"`
+	Error  string    `json:"error"`
+}
+
+// errQueueFull is returned when enqueuing a submission in a full queue.
+var errQueueFull = errors.New("submission queue is full")
+
+// SubmissionQueue durably stores validated submissions in a local directory
+// until they are sequenced, in queue mode. See HandlerOptions.SubmissionQueue.
+//
+// Pending submissions are stored in the pending subdirectory, the add-chain
+// responses of sequenced ones in the done subdirectory, and the errors of
+// those which can't be sequenced in the failed subdirectory, all under the
+// submission ID. Files are synced and renamed into place, so that they
+// survive crashes and are never read partially written. The IDs of pending
+// submissions are also indexed in memory, so that the directory is only
+// listed on startup.
+type SubmissionQueue struct {
+	dir string
+	// maxSize is the maximum number of pending submissions, if positive.
+	maxSize int
+
+	// mu guards the index of pending submissions, and serializes enqueue
+	// and complete, so that submissions being completed are not queued
+	// again.
+	mu sync.Mutex
+	// pendingIDs holds the IDs of pending submissions, oldest first. It may
+	// still hold completed submissions, which are not in isPending.
+	pendingIDs []string
+	// isPending is the set of IDs of pending submissions.
+	isPending map[string]bool
+	// paused holds back the sequencing of pending submissions.
+	paused atomic.Bool
+}
+
+// NewSubmissionQueue returns a SubmissionQueue storing submissions in dir,
+// which is created if needed. Submissions which were already queued in dir
+// are sequenced as well. If maxSize is positive, submissions are rejected
+// once maxSize of them are pending.
+func NewSubmissionQueue(dir string, maxSize int) (*SubmissionQueue, error) {
+	for _, d := range []string{queuePendingDir, queueDoneDir, queueFailedDir} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create queue directory: %v", err)
+		}
+	}
+	q := &SubmissionQueue{dir: dir, maxSize: maxSize, isPending: map[string]bool{}}
+	files, err := os.ReadDir(filepath.Join(dir, queuePendingDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queued submissions: %v", err)
+	}
+	var subs []queuedSubmission
+	for _, f := range files {
+		if !validQueueID(f.Name()) {
+			continue
+		}
+		s, err := q.read(f.Name())
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, s)
+	}
+	slices.SortStableFunc(subs, func(a, b queuedSubmission) int { return a.QueuedAt.Compare(b.QueuedAt) })
+	for _, s := range subs {
+		q.pendingIDs = append(q.pendingIDs, s.id)
+		q.isPending[s.id] = true
+	}
+	return q, nil
+}
+
+// queueID returns the ID of a submission, which is the same for identical
+// chains.
+func queueID(chain []*x509.Certificate, isPrecert bool) string {
+	h := sha256.New()
+	if isPrecert {
+		h.Write([]byte{1})
+	} else {
+		h.Write([]byte{0})
+	}
+	for _, cert := range chain {
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(cert.Raw))))
+		h.Write(cert.Raw)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// validQueueID returns true if id has the format of queue IDs.
+func validQueueID(id string) bool {
+	b, err := hex.DecodeString(id)
+	return err == nil && len(b) == sha256.Size
+}
+
+func (q *SubmissionQueue) pendingPath(id string) string {
+	return filepath.Join(q.dir, queuePendingDir, id)
+}
+
+func (q *SubmissionQueue) donePath(id string) string {
+	return filepath.Join(q.dir, queueDoneDir, id)
+}
+
+func (q *SubmissionQueue) failedPath(id string) string {
+	return filepath.Join(q.dir, queueFailedDir, id)
+}
+
+// SetPaused pauses or resumes the sequencing of pending submissions.
+// Submissions are still queued while sequencing is paused.
+func (q *SubmissionQueue) SetPaused(paused bool) {
+	q.paused.Store(paused)
+}
+
+// Paused returns whether the sequencing of pending submissions is paused.
+func (q *SubmissionQueue) Paused() bool {
+	return q.paused.Load()
+}
+
+// enqueue durably queues chain, unless it is already queued, or was
+// sequenced or dropped, and returns its ID. It returns errQueueFull if the queue is
+// full.
+func (q *SubmissionQueue) enqueue(chain []*x509.Certificate, isPrecert bool, now time.Time) (string, error) {
+	id := queueID(chain, isPrecert)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.isPending[id] {
+		return id, nil
+	}
+	for _, path := range []string{q.donePath(id), q.failedPath(id)} {
+		if _, err := os.Stat(path); err == nil {
+			return id, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	if q.maxSize > 0 && len(q.isPending) >= q.maxSize {
+		return "", errQueueFull
+	}
+	s := queuedSubmission{IsPrecert: isPrecert, QueuedAt: now}
+	for _, cert := range chain {
+		s.Chain = append(s.Chain, cert.Raw)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal queued submission: %v", err)
+	}
+	if err := writeFileDurably(q.pendingPath(id), data); err != nil {
+		return "", err
+	}
+	q.pendingIDs = append(q.pendingIDs, id)
+	q.isPending[id] = true
+	return id, nil
+}
+
+// read reads the pending submission id.
+func (q *SubmissionQueue) read(id string) (queuedSubmission, error) {
+	data, err := os.ReadFile(q.pendingPath(id))
+	if err != nil {
+		return queuedSubmission{}, fmt.Errorf("failed to read queued submission: %v", err)
+	}
+	var s queuedSubmission
+	if err := json.Unmarshal(data, &s); err != nil {
+		return queuedSubmission{}, fmt.Errorf("failed to parse queued submission %s: %v", id, err)
+	}
+	s.id = id
+	return s, nil
+}
+
+// pendingList returns the IDs of the submissions which have not been
+// sequenced yet, oldest first.
+func (q *SubmissionQueue) pendingList() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pendingIDs = slices.DeleteFunc(q.pendingIDs, func(id string) bool { return !q.isPending[id] })
+	return slices.Clone(q.pendingIDs)
+}
+
+// complete durably stores the add-chain response of a sequenced submission,
+// and removes it from pending submissions.
+func (q *SubmissionQueue) complete(id string, rsp []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := writeFileDurably(q.donePath(id), rsp); err != nil {
+		return err
+	}
+	if err := os.Remove(q.pendingPath(id)); err != nil {
+		return fmt.Errorf("failed to remove sequenced submission: %v", err)
+	}
+	delete(q.isPending, id)
+	return nil
+}
+
+// fail durably records why a pending submission can't be sequenced, and
+// removes it from pending submissions.
+func (q *SubmissionQueue) fail(id string, f queuedFailure) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("failed to marshal queued submission failure: %v", err)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := writeFileDurably(q.failedPath(id), data); err != nil {
+		return err
+	}
+	if err := os.Remove(q.pendingPath(id)); err != nil {
+		return fmt.Errorf("failed to remove queued submission: %v", err)
+	}
+	delete(q.isPending, id)
+	return nil
+}
+
+// result returns the add-chain response of a sequenced submission, or why
+// it was dropped. It returns neither if the submission is still pending, and
+// an error wrapping os.ErrNotExist if it is unknown.
+func (q *SubmissionQueue) result(id string) ([]byte, *queuedFailure, error) {
+	// Hold mu, so that the submission isn't completed in between checks.
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	rsp, err := os.ReadFile(q.donePath(id))
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return rsp, nil, err
+	}
+	data, err := os.ReadFile(q.failedPath(id))
+	if err == nil {
+		var f queuedFailure
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse queued submission failure %s: %v", id, err)
+		}
+		return nil, &f, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) || !q.isPending[id] {
+		return nil, nil, err
+	}
+	return nil, nil, nil
+}
+
+// prune removes the responses of submissions sequenced or dropped before
+// cutoff.
+func (q *SubmissionQueue) prune(cutoff time.Time) error {
+	for _, d := range []string{queueDoneDir, queueFailedDir} {
+		dir := filepath.Join(q.dir, d)
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("failed to list sequenced submissions: %v", err)
+		}
+		for _, f := range files {
+			info, err := f.Info()
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return err
+			}
+			if info.ModTime().Before(cutoff) {
+				if err := os.Remove(filepath.Join(dir, f.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// writeFileDurably writes data to path through a temporary file, which is
+// synced and renamed to path, so that path is either missing or complete.
+func writeFileDurably(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %v", f.Name(), err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to sync %s: %v", f.Name(), err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %v", f.Name(), err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename %s: %v", f.Name(), err)
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", dir, err)
+	}
+	defer func() { _ = d.Close() }()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %v", dir, err)
+	}
+	return nil
+}
+
+// enqueueChain queues a validated chain, for the background sequencer to
+// sequence it, and acknowledges it with the ID to fetch its SCTs with.
+func enqueueChain(opts *HandlerOptions, log *log, w http.ResponseWriter, chain []*x509.Certificate, isPrecert bool) (int, []attribute.KeyValue, error) {
+	id, err := log.queue.enqueue(chain, isPrecert, opts.TimeSource.Now())
+	if errors.Is(err, errQueueFull) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(queueDrainInterval.Seconds())))
+		return http.StatusServiceUnavailable, nil, newHandlerError(errCodeQueueFull, fmt.Errorf("%s: %v", log.origin, err))
+	}
+	if err != nil {
+		return http.StatusInternalServerError, nil, newHandlerError(errCodeQueueSubmission, fmt.Errorf("%s: %v", log.origin, err))
+	}
+	klog.V(3).Infof("%s: queued submission %s", log.origin, id)
+	return writeQueuedSubmissionResponse(w, id)
+}
+
+// writeQueuedSubmissionResponse acknowledges the pending submission id.
+func writeQueuedSubmissionResponse(w http.ResponseWriter, id string) (int, []attribute.KeyValue, error) {
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	w.Header().Set("Retry-After", strconv.Itoa(int(queueDrainInterval.Seconds())))
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(queuedSubmissionResponse{ID: id}); err != nil {
+		// The status has already been written.
+		klog.Warningf("Failed to write queued submission response: %v", err)
+	}
+	return http.StatusAccepted, nil, nil
+}
+
+// getQueuedSCT returns the add-chain response of a queued submission, once
+// it has been sequenced. Pending submissions are acknowledged again with
+// http.StatusAccepted, and dropped ones fail with the error they were
+// dropped with.
+func getQueuedSCT(_ context.Context, _ *HandlerOptions, log *log, w http.ResponseWriter, r *http.Request) (int, []attribute.KeyValue, error) {
+	id := strings.ToLower(r.FormValue(getQueuedSCTParamID))
+	if !validQueueID(id) {
+		return http.StatusBadRequest, nil, newHandlerError(errCodeInvalidQueueID, fmt.Errorf("%q", id))
+	}
+	rsp, failure, err := log.queue.result(id)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return http.StatusNotFound, nil, newHandlerError(errCodeQueuedNotFound, fmt.Errorf("%s: no queued submission %s", log.origin, id))
+	case err != nil:
+		return http.StatusInternalServerError, nil, newHandlerError(errCodeReadQueue, err)
+	case failure != nil:
+		return failure.Status, nil, newHandlerError(failure.Code, fmt.Errorf("%s: queued submission %s was dropped: %s", log.origin, id, failure.Error))
+	case rsp == nil:
+		return writeQueuedSubmissionResponse(w, id)
+	}
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	if _, err := w.Write(rsp); err != nil {
+		return http.StatusInternalServerError, nil, newHandlerError(errCodeWriteResponse, err)
+	}
+	return http.StatusOK, nil, nil
+}
+
+// queueStatusResponse is the response of the pause-queue and resume-queue
+// admin endpoints.
+type queueStatusResponse struct {
+	Paused bool `json:"paused"`
+	// Pending is the number of submissions waiting to be sequenced.
+	Pending int `json:"pending"`
+}
+
+// pauseQueue pauses the sequencing of queued submissions, which are still
+// accepted and queued. Submissions being sequenced are not interrupted.
+//
+// This is an admin endpoint, which must not be exposed publicly.
+func pauseQueue(_ context.Context, _ *HandlerOptions, log *log, w http.ResponseWriter, _ *http.Request) (int, []attribute.KeyValue, error) {
+	log.queue.SetPaused(true)
+	klog.Infof("%s: paused the sequencing of queued submissions", log.origin)
+	return writeQueueStatusResponse(log, w)
+}
+
+// resumeQueue resumes the sequencing of queued submissions.
+//
+// This is an admin endpoint, which must not be exposed publicly.
+func resumeQueue(_ context.Context, _ *HandlerOptions, log *log, w http.ResponseWriter, _ *http.Request) (int, []attribute.KeyValue, error) {
+	log.queue.SetPaused(false)
+	klog.Infof("%s: resumed the sequencing of queued submissions", log.origin)
+	return writeQueueStatusResponse(log, w)
+}
+
+func writeQueueStatusResponse(log *log, w http.ResponseWriter) (int, []attribute.KeyValue, error) {
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	rsp := queueStatusResponse{Paused: log.queue.Paused(), Pending: len(log.queue.pendingList())}
+	if err := json.NewEncoder(w).Encode(rsp); err != nil {
+		return http.StatusInternalServerError, nil, newHandlerError(errCodeWriteResponse, err)
+	}
+	return http.StatusOK, nil, nil
+}
+
+// runQueueSequencer sequences the submissions queued for log, until ctx is
+// done. Nothing is sequenced while the queue is paused.
+func runQueueSequencer(ctx context.Context, opts *HandlerOptions, log *log) {
+	t := time.NewTicker(queueDrainInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if err := drainQueue(ctx, opts, log); err != nil {
+			klog.Warningf("%s: failed to sequence queued submissions, retrying in %v: %v", log.origin, queueDrainInterval, err)
+		}
+		if err := log.queue.prune(opts.TimeSource.Now().Add(-queuedResultTTL)); err != nil {
+			klog.Warningf("%s: failed to prune sequenced submissions: %v", log.origin, err)
+		}
+	}
+}
+
+// drainQueue sequences pending submissions concurrently, and stores their
+// add-chain responses. Submissions which fail to be sequenced are retried
+// later, without holding back the others. It stops sequencing submissions
+// as soon as the queue is paused.
+func drainQueue(ctx context.Context, opts *HandlerOptions, log *log) error {
+	ids := log.queue.pendingList()
+	errs := make([]error, len(ids))
+	sem := make(chan struct{}, queueDrainConcurrency)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		if log.queue.Paused() {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := sequenceQueued(ctx, opts, log, id); err != nil {
+				errs[i] = fmt.Errorf("submission %s: %v", id, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// sequenceQueued sequences the pending submission id, and stores its
+// add-chain response. Submissions which can't be sequenced are dropped, and
+// the error they failed with is stored instead.
+func sequenceQueued(ctx context.Context, opts *HandlerOptions, log *log, id string) error {
+	s, err := log.queue.read(id)
+	if err != nil {
+		return err
+	}
+	chain := make([]*x509.Certificate, 0, len(s.Chain))
+	for _, der := range s.Chain {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("x509.ParseCertificate(): %v", err)
+		}
+		chain = append(chain, cert)
+	}
+	seq, statusCode, err := sequenceChain(ctx, opts, log, http.Header{}, chain, s.IsPrecert)
+	if err != nil && statusCode < http.StatusInternalServerError {
+		// Retrying would fail again: drop the submission.
+		klog.Warningf("%s: dropping queued submission %s which can't be sequenced: %v", log.origin, id, err)
+		f := queuedFailure{Status: statusCode, Code: errCodeHandlerMisbehaved, Error: err.Error()}
+		var hErr *handlerError
+		if errors.As(err, &hErr) {
+			f.Code, f.Error = hErr.code, hErr.err.Error()
+		}
+		return log.queue.fail(id, f)
+	}
+	if err != nil {
+		return err
+	}
+	rsp, err := marshalAddChainResponse(seq.sct, seq.mirrorSCTs)
+	if err != nil {
+		return err
+	}
+	if err := log.queue.complete(id, rsp); err != nil {
+		return err
+	}
+	klog.V(3).Infof("%s: sequenced queued submission %s", log.origin, id)
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/klog/v2"
+)
+
+// rejectedSubmission describes a submission rejected by add-chain or
+// add-pre-chain.
+type rejectedSubmission struct {
+	Time time.Time `json:"time"`
+	// BodyHash is the SHA-256 hash of the request body.
+	BodyHash   []byte `json:"body_sha256"`
+	Reason     string `json:"reason"`
+	RemoteAddr string `json:"remote_addr"`
+}
+
+// getRejectedSubmissionsResponse is the response of the
+// get-rejected-submissions endpoint.
+type getRejectedSubmissionsResponse struct {
+	RejectedSubmissions []rejectedSubmission `json:"rejected_submissions"`
+}
+
+// rejectionSamples is a bounded ring buffer of the most recent rejected
+// submissions. It is safe for concurrent use.
+type rejectionSamples struct {
+	mu      sync.Mutex
+	samples []rejectedSubmission
+	// next is the position of the next sample in samples.
+	next int
+	// full indicates that samples has wrapped around at least once.
+	full bool
+}
+
+// newRejectionSamples returns a rejectionSamples holding up to size samples.
+func newRejectionSamples(size int) *rejectionSamples {
+	return &rejectionSamples{samples: make([]rejectedSubmission, size)}
+}
+
+// add records s, evicting the oldest sample if the buffer is full.
+func (r *rejectionSamples) add(s rejectedSubmission) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples[r.next] = s
+	r.next++
+	if r.next == len(r.samples) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// list returns a copy of the samples, from oldest to most recent.
+func (r *rejectionSamples) list() []rejectedSubmission {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]rejectedSubmission{}, r.samples[:r.next]...)
+	}
+	return append(append([]rejectedSubmission{}, r.samples[r.next:]...), r.samples[:r.next]...)
+}
+
+// getRejectedSubmissions returns the most recent submissions rejected by
+// the log, from oldest to most recent.
+//
+// This is an admin endpoint: responses contain client addresses, and it must
+// not be exposed publicly.
+func getRejectedSubmissions(_ context.Context, _ *HandlerOptions, log *log, w http.ResponseWriter, _ *http.Request) (int, []attribute.KeyValue, error) {
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	if err := json.NewEncoder(w).Encode(getRejectedSubmissionsResponse{RejectedSubmissions: log.rejections.list()}); err != nil {
+		klog.Warningf("%s: get_rejected_submissions failed: %v", log.origin, err)
+		return http.StatusInternalServerError, nil, newHandlerError(errCodeWriteResponse, err)
+	}
+	return http.StatusOK, nil, nil
+}
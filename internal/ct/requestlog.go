@@ -16,6 +16,7 @@ package ct
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/hex"
 	"time"
@@ -48,6 +49,9 @@ type requestLog interface {
 	// after it has been parsed and verified. Calls will be in order of the
 	// certificates as presented in the request with the root last.
 	addCertToChain(context.Context, *x509.Certificate)
+	// root will be called once with the SHA-256 fingerprint of the trusted
+	// root that the chain was verified to, after the calls to addCertToChain.
+	root(context.Context, [sha256.Size]byte)
 	// issueSCT will be called once when the server is about to issue an SCT to a
 	// client. This should not be called if the submission process fails before an
 	// SCT could be presented to a client, even if this is unrelated to
@@ -91,6 +95,12 @@ func (dlr *DefaultRequestLog) addCertToChain(_ context.Context, cert *x509.Certi
 		cert.NotAfter.Format(time.RFC1123Z))
 }
 
+// root logs the fingerprint of the trusted root a submitted chain was
+// verified to.
+func (dlr *DefaultRequestLog) root(_ context.Context, fp [sha256.Size]byte) {
+	klog.V(vLevel).Infof("RL: Root: %x", fp)
+}
+
 // issueSCT logs an SCT that will be issued to a client.
 func (dlr *DefaultRequestLog) issueSCT(_ context.Context, sct []byte) {
 	klog.V(vLevel).Infof("RL: Issuing SCT: %x", sct)
@@ -100,3 +110,17 @@ func (dlr *DefaultRequestLog) issueSCT(_ context.Context, sct []byte) {
 func (dlr *DefaultRequestLog) status(_ context.Context, s int) {
 	klog.V(vLevel).Infof("RL: Status: %d", s)
 }
+
+// NoOpRequestLog is an implementation of RequestLog that records nothing, for
+// operators who don't want requests to be logged. Request metrics are still
+// recorded.
+type NoOpRequestLog struct {
+}
+
+func (nlr *NoOpRequestLog) start(ctx context.Context) context.Context         { return ctx }
+func (nlr *NoOpRequestLog) origin(context.Context, string)                    {}
+func (nlr *NoOpRequestLog) addDERToChain(context.Context, []byte)             {}
+func (nlr *NoOpRequestLog) addCertToChain(context.Context, *x509.Certificate) {}
+func (nlr *NoOpRequestLog) root(context.Context, [sha256.Size]byte)           {}
+func (nlr *NoOpRequestLog) issueSCT(context.Context, []byte)                  {}
+func (nlr *NoOpRequestLog) status(context.Context, int)                       {}
@@ -0,0 +1,130 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/transparency-dev/tesseract/internal/types/rfc6962"
+	"github.com/transparency-dev/tesseract/internal/x509util"
+	"k8s.io/klog/v2"
+)
+
+// maxRootsSize is the maximum size of the PEM roots fetched from a URL.
+const maxRootsSize = 32 << 20
+
+// urlRootsValidator is a ChainValidator whose roots are periodically replaced
+// by roots fetched from a URL. It is safe for concurrent use.
+type urlRootsValidator struct {
+	url    string
+	client *http.Client
+	// maxRoots is the maximum number of fetched roots. 0 means no limit.
+	maxRoots int
+	// cv validates chains with the latest roots. It is replaced as a whole,
+	// so that each validation uses a consistent set of roots.
+	cv atomic.Pointer[chainValidator]
+}
+
+// NewURLRootsValidator returns a ChainValidator which validates chains like
+// cv, with roots fetched from url every interval, for instance from a CCADB
+// export. cv must have been created by NewChainValidator.
+//
+// Fetched roots replace the current ones only if they all parse, and if there
+// are at most maxRoots of them, unless maxRoots is 0. cv's roots remain in use
+// until roots are successfully fetched. Fetches stop when ctx is done.
+func NewURLRootsValidator(ctx context.Context, cv ChainValidator, url string, interval time.Duration, maxRoots int, client *http.Client) (ChainValidator, error) {
+	base, ok := cv.(*chainValidator)
+	if !ok {
+		return nil, fmt.Errorf("unsupported ChainValidator %T", cv)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("non-positive roots refresh interval: %v", interval)
+	}
+	v := &urlRootsValidator{url: url, client: client, maxRoots: maxRoots}
+	v.cv.Store(base)
+
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			if err := v.refresh(ctx); err != nil {
+				klog.Warningf("Failed to refresh roots from %s, keeping %d current roots: %v", url, len(v.Roots()), err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	return v, nil
+}
+
+// refresh fetches roots from v.url, and swaps them in if they are valid.
+func (v *urlRootsValidator) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, nil)
+	if err != nil {
+		return err
+	}
+	rsp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := rsp.Body.Close(); err != nil {
+			klog.Warningf("Failed to close roots response body: %v", err)
+		}
+	}()
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", rsp.Status)
+	}
+	pemRoots, err := io.ReadAll(io.LimitReader(rsp.Body, maxRootsSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read roots: %v", err)
+	}
+	if len(pemRoots) > maxRootsSize {
+		return fmt.Errorf("roots larger than %d bytes", maxRootsSize)
+	}
+	roots := x509util.NewPEMCertPool()
+	if !roots.AppendCertsFromPEM(pemRoots) {
+		return errors.New("failed to parse roots")
+	}
+	if n := len(roots.RawCertificates()); v.maxRoots > 0 && n > v.maxRoots {
+		return fmt.Errorf("got %d roots, more than %d", n, v.maxRoots)
+	}
+
+	next := *v.cv.Load()
+	next.trustedRoots = roots
+	v.cv.Store(&next)
+	klog.Infof("Loaded %d roots from %s", len(roots.RawCertificates()), v.url)
+	return nil
+}
+
+// Validate validates req with the latest roots.
+func (v *urlRootsValidator) Validate(req rfc6962.AddChainRequest, expectingPrecert bool) ([]*x509.Certificate, error) {
+	return v.cv.Load().Validate(req, expectingPrecert)
+}
+
+// Roots returns the latest roots.
+func (v *urlRootsValidator) Roots() []*x509.Certificate {
+	return v.cv.Load().Roots()
+}
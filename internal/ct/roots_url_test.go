@@ -0,0 +1,105 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/transparency-dev/tesseract/internal/testdata"
+	"github.com/transparency-dev/tesseract/internal/types/rfc6962"
+	"github.com/transparency-dev/tesseract/internal/x509util"
+)
+
+// rootsServer serves PEM roots, which can be changed by tests.
+type rootsServer struct {
+	mu     sync.Mutex
+	roots  string
+	status int
+}
+
+func (s *rootsServer) set(roots string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roots, s.status = roots, status
+}
+
+func (s *rootsServer) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.WriteHeader(s.status)
+	_, _ = w.Write([]byte(s.roots))
+}
+
+func TestURLRootsValidator(t *testing.T) {
+	initialRoots := x509util.NewPEMCertPool()
+	if ok := initialRoots.AppendCertsFromPEM([]byte(testdata.FakeCACertPEM)); !ok {
+		t.Fatal("failed to parse initial root")
+	}
+	fetchedRoot := pemToCert(t, testdata.CACertPEM)
+	chain := rfc6962.AddChainRequest{Chain: pemsToDERChain(t, []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot})}
+
+	rs := &rootsServer{roots: testdata.CACertPEM, status: http.StatusOK}
+	server := httptest.NewServer(rs)
+	defer server.Close()
+
+	cv := NewChainValidator(initialRoots, ChainValidatorOpts{})
+	if _, err := cv.Validate(chain, false); err == nil {
+		t.Fatal("Validate() with initial roots succeeded, want error")
+	}
+	v, err := NewURLRootsValidator(t.Context(), &cv, server.URL, time.Hour, 1, server.Client())
+	if err != nil {
+		t.Fatalf("NewURLRootsValidator()=%v", err)
+	}
+
+	// Roots are fetched in the background on startup.
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if roots := v.Roots(); len(roots) == 1 && roots[0].Equal(fetchedRoot) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Roots()=%v, want fetched root", v.Roots())
+		}
+	}
+	if _, err := v.Validate(chain, false); err != nil {
+		t.Errorf("Validate() with fetched roots=%v, want nil", err)
+	}
+
+	// Invalid roots are not swapped in.
+	uv := v.(*urlRootsValidator)
+	for _, tc := range []struct {
+		desc   string
+		roots  string
+		status int
+	}{
+		{desc: "server-error", roots: testdata.FakeCACertPEM, status: http.StatusInternalServerError},
+		{desc: "no-roots", roots: "", status: http.StatusOK},
+		{desc: "invalid-pem", roots: "-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n", status: http.StatusOK},
+		{desc: "too-many-roots", roots: testdata.CACertPEM + testdata.FakeCACertPEM, status: http.StatusOK},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			rs.set(tc.roots, tc.status)
+			if err := uv.refresh(t.Context()); err == nil {
+				t.Error("refresh()=nil, want error")
+			}
+			if roots := v.Roots(); len(roots) != 1 || !roots[0].Equal(fetchedRoot) {
+				t.Errorf("Roots()=%v, want fetched root", roots)
+			}
+		})
+	}
+}
@@ -15,24 +15,84 @@
 package ct
 
 import (
+	"context"
 	"crypto"
+	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"time"
 
 	tfl "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/tesseract/internal/otel"
 	"github.com/transparency-dev/tesseract/internal/types/rfc6962"
 	"github.com/transparency-dev/tesseract/internal/types/tls"
+	"github.com/transparency-dev/tesseract/internal/x509util"
+	"go.opentelemetry.io/otel/metric"
 	"golang.org/x/mod/sumdb/note"
 )
 
 const nanosPerMilli int64 = int64(time.Millisecond / time.Nanosecond)
 
+// rfc6962NoteSignatureType identifies RFC 6962 checkpoint signatures in note
+// key hashes and verifier keys, as defined in https://c2sp.org/signed-note.
+const rfc6962NoteSignatureType = 0x05
+
+var (
+	// Signers can be backed by slow remote services, such as KMS or HSMs.
+	// These are created at package initialization since checkpoints may be
+	// signed before any handler is set up.
+	sctSignDuration = mustCreate(meter.Float64Histogram("tesseract.signer.sct.duration",
+		metric.WithDescription("Duration of SCT signing"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(otel.SubSecondLatencyHistogramBuckets...)))
+
+	cpSignDuration = mustCreate(meter.Float64Histogram("tesseract.signer.checkpoint.duration",
+		metric.WithDescription("Duration of checkpoint signing"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(otel.SubSecondLatencyHistogramBuckets...)))
+)
+
+// ErrSignerUnavailable can be wrapped by signers to indicate that they are
+// temporarily unavailable, for instance because a remote KMS or HSM is
+// overloaded, and that requests should be retried later.
+var ErrSignerUnavailable = errors.New("signer temporarily unavailable")
+
+// isSignerUnavailable returns true if err indicates that a signer is
+// temporarily unavailable: it wraps ErrSignerUnavailable, or a timeout.
+func isSignerUnavailable(err error) bool {
+	if errors.Is(err, ErrSignerUnavailable) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var t interface{ Timeout() bool }
+	return errors.As(err, &t) && t.Timeout()
+}
+
+// SCTSigner issues SCTs for leaves added to a log.
+//
+// sctSigner, the default implementation, issues RFC 6962 SCTs signed with the
+// log's key. Other implementations can issue SCTs with a different signature
+// scheme, for instance to experiment with post-quantum signatures in a
+// research log.
+//
+// Errors wrapping ErrSignerUnavailable, or timeouts, make add-chain and
+// add-pre-chain requests fail with http.StatusServiceUnavailable, rather than
+// http.StatusInternalServerError, so that clients retry them.
+type SCTSigner interface {
+	Sign(leaf *rfc6962.MerkleTreeLeaf) (*rfc6962.SignedCertificateTimestamp, error)
+}
+
+// sctSigner implements SCTSigner with RFC 6962 signatures.
 type sctSigner struct {
 	signer crypto.Signer
+	// origin is only used to label metrics.
+	origin string
 }
 
 // serializeSCTSignatureInput serializes the passed in sct and log entry into
@@ -65,6 +125,10 @@ func serializeSCTSignatureInput(sct rfc6962.SignedCertificateTimestamp, entry rf
 }
 
 func (sctSigner *sctSigner) Sign(leaf *rfc6962.MerkleTreeLeaf) (*rfc6962.SignedCertificateTimestamp, error) {
+	defer func(start time.Time) {
+		sctSignDuration.Record(context.Background(), time.Since(start).Seconds(), metric.WithAttributes(originKey.String(sctSigner.origin)))
+	}(time.Now())
+
 	// Serialize SCT signature input to get the bytes that need to be signed
 	sctInput := rfc6962.SignedCertificateTimestamp{
 		SCTVersion: rfc6962.V1,
@@ -79,7 +143,7 @@ func (sctSigner *sctSigner) Sign(leaf *rfc6962.MerkleTreeLeaf) (*rfc6962.SignedC
 	h := sha256.Sum256(data)
 	signature, err := sctSigner.signer.Sign(rand.Reader, h[:], crypto.SHA256)
 	if err != nil {
-		return nil, fmt.Errorf("failed to sign SCT data: %v", err)
+		return nil, fmt.Errorf("failed to sign SCT data: %w", err)
 	}
 
 	digitallySigned := rfc6962.DigitallySigned{
@@ -104,6 +168,32 @@ func (sctSigner *sctSigner) Sign(leaf *rfc6962.MerkleTreeLeaf) (*rfc6962.SignedC
 	}, nil
 }
 
+// ComputeSCT returns the TLS-encoded RFC 6962 SCT which a log signing with
+// signer issues for chain at timestamp, in milliseconds since the Unix epoch,
+// for an entry at index. chain must have been validated, and go from the
+// leaf up to a trusted root.
+//
+// Nothing is stored, which makes it suitable for offline SCT generation and
+// testing. Static CT API SCTs include the index of their entry in a
+// leaf_index extension, so an SCT only matches a logged entry at index.
+func ComputeSCT(signer crypto.Signer, chain []*x509.Certificate, isPrecert bool, timestamp, index uint64) ([]byte, error) {
+	entry, err := x509util.EntryFromChain(chain, isPrecert, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build entry: %v", err)
+	}
+	var leaf rfc6962.MerkleTreeLeaf
+	if rest, err := tls.Unmarshal(entry.MerkleTreeLeaf(index), &leaf); err != nil {
+		return nil, fmt.Errorf("failed to reconstruct MerkleTreeLeaf: %v", err)
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("extra data (%d bytes) on reconstructing MerkleTreeLeaf", len(rest))
+	}
+	sct, err := (&sctSigner{signer: signer}).Sign(&leaf)
+	if err != nil {
+		return nil, err
+	}
+	return tls.Marshal(*sct)
+}
+
 type rfc6962NoteSignature struct {
 	Timestamp uint64
 	Signature rfc6962.DigitallySigned
@@ -172,9 +262,11 @@ func buildCp(signer crypto.Signer, size uint64, timeMilli uint64, hash []byte) (
 
 // cpSigner implements note.Signer. It can generate https://c2sp.org/static-ct-api checkpoints.
 type cpSigner struct {
-	sthSigner  crypto.Signer
-	origin     string
-	keyHash    uint32
+	sthSigner crypto.Signer
+	origin    string
+	keyHash   uint32
+	// spki is the DER encoded SubjectPublicKeyInfo of sthSigner.
+	spki       []byte
 	timeSource TimeSource
 }
 
@@ -183,6 +275,10 @@ type cpSigner struct {
 // checkpoint origin doesn't match with the Signer's origin.
 // TODO(phboneff): add tests
 func (cts *cpSigner) Sign(msg []byte) ([]byte, error) {
+	defer func(start time.Time) {
+		cpSignDuration.Record(context.Background(), time.Since(start).Seconds(), metric.WithAttributes(originKey.String(cts.origin)))
+	}(time.Now())
+
 	ckpt := &tfl.Checkpoint{}
 	rest, err := ckpt.Unmarshal(msg)
 
@@ -210,18 +306,29 @@ func (cts *cpSigner) KeyHash() uint32 {
 	return cts.keyHash
 }
 
+// VerifierKey returns the note verifier key of the signer, in the
+// <name>+<hash>+<keydata> format of https://c2sp.org/signed-note, where
+// keydata is the RFC 6962 signature type followed by the signer's public key.
+// Clients can use it to verify checkpoints, for instance with
+// github.com/transparency-dev/formats/note.NewRFC6962Verifier.
+func (cts *cpSigner) VerifierKey() string {
+	keyData := append([]byte{rfc6962NoteSignatureType}, cts.spki...)
+	return fmt.Sprintf("%s+%08x+%s", cts.origin, cts.keyHash, base64.StdEncoding.EncodeToString(keyData))
+}
+
 // NewCpSigner returns a new note signer that can sign https://c2sp.org/static-ct-api checkpoints.
-// TODO(phboneff): add tests
+// Its verifier key is returned by its VerifierKey method.
 func NewCpSigner(cs crypto.Signer, origin string, timeSource TimeSource) (note.Signer, error) {
-	logID, err := getCTLogID(cs.Public())
+	spki, err := x509.MarshalPKIXPublicKey(cs.Public())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get logID for signing: %v", err)
 	}
+	logID := sha256.Sum256(spki)
 
 	h := sha256.New()
 	h.Write([]byte(origin))
-	h.Write([]byte{0x0A}) // newline
-	h.Write([]byte{0x05}) // signature type
+	h.Write([]byte{0x0A})                     // newline
+	h.Write([]byte{rfc6962NoteSignatureType}) // signature type
 	h.Write(logID[:])
 	sum := h.Sum(nil)
 
@@ -229,12 +336,57 @@ func NewCpSigner(cs crypto.Signer, origin string, timeSource TimeSource) (note.S
 		sthSigner:  cs,
 		origin:     origin,
 		keyHash:    binary.BigEndian.Uint32(sum),
+		spki:       spki,
 		timeSource: timeSource,
 	}
 
 	return ns, nil
 }
 
+// VerifySigners checks that every log has a valid signer, and that no two logs
+// share the same signing key. signers maps log origins to their signer.
+//
+// It is meant to be called at startup by servers hosting multiple logs.
+func VerifySigners(signers map[string]crypto.Signer) error {
+	keyOrigins := make(map[[sha256.Size]byte]string)
+	for _, origin := range slices.Sorted(maps.Keys(signers)) {
+		signer := signers[origin]
+		if signer == nil {
+			return fmt.Errorf("%s: empty signer", origin)
+		}
+		if err := checkSigner(signer); err != nil {
+			return fmt.Errorf("%s: invalid signer: %v", origin, err)
+		}
+		logID, err := getCTLogID(signer.Public())
+		if err != nil {
+			return fmt.Errorf("%s: failed to get logID: %v", origin, err)
+		}
+		if other, ok := keyOrigins[logID]; ok {
+			return fmt.Errorf("logs %s and %s share the same signing key", other, origin)
+		}
+		keyOrigins[logID] = origin
+	}
+	return nil
+}
+
+// checkSigner checks that signer is an ECDSA signer, and that the signatures
+// it produces verify under its public key.
+func checkSigner(signer crypto.Signer) error {
+	pk, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("unsupported key type: %T", signer.Public())
+	}
+	h := sha256.Sum256([]byte("TesseraCT signer check"))
+	sig, err := signer.Sign(rand.Reader, h[:], crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("failed to sign: %v", err)
+	}
+	if !ecdsa.VerifyASN1(pk, h[:], sig) {
+		return errors.New("signature does not verify under the signer's public key")
+	}
+	return nil
+}
+
 // getCTLogID takes a log public key and returns the LogID. (see RFC 6962 S3.2)
 // In CT V1 the log id is a hash of the public key.
 func getCTLogID(pk crypto.PublicKey) ([sha256.Size]byte, error) {
@@ -16,19 +16,28 @@ package ct
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/hex"
 	"encoding/pem"
+	"io"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/kylelemons/godebug/pretty"
+	tfl "github.com/transparency-dev/formats/log"
+	tdnote "github.com/transparency-dev/formats/note"
 	"github.com/transparency-dev/tesseract/internal/testdata"
 	"github.com/transparency-dev/tesseract/internal/types/rfc6962"
 	"github.com/transparency-dev/tesseract/internal/types/tls"
 	"github.com/transparency-dev/tesseract/internal/x509util"
+	"golang.org/x/mod/sumdb/note"
 )
 
 var (
@@ -373,6 +382,75 @@ func TestSignV1SCTForPrecertificate(t *testing.T) {
 	}
 }
 
+func TestComputeSCT(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	logID, err := getCTLogID(key.Public())
+	if err != nil {
+		t.Fatalf("getCTLogID()=%v", err)
+	}
+
+	for _, tc := range []struct {
+		desc      string
+		chain     []string
+		isPrecert bool
+	}{
+		{
+			desc:  "cert",
+			chain: []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM},
+		},
+		{
+			desc:      "precert",
+			chain:     []string{testdata.PreCertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM},
+			isPrecert: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var chain []*x509.Certificate
+			for _, p := range tc.chain {
+				chain = append(chain, pemToCert(t, p))
+			}
+			sctBytes, err := ComputeSCT(key, chain, tc.isPrecert, fixedTimeMillis, uint64(fakeIndex))
+			if err != nil {
+				t.Fatalf("ComputeSCT()=%v", err)
+			}
+			var sct rfc6962.SignedCertificateTimestamp
+			if rest, err := tls.Unmarshal(sctBytes, &sct); err != nil || len(rest) > 0 {
+				t.Fatalf("tls.Unmarshal()=%d bytes left, %v; want 0, nil", len(rest), err)
+			}
+			if got, want := sct.LogID.KeyID, logID; got != want {
+				t.Errorf("LogID=%x, want %x", got, want)
+			}
+			if got, want := sct.Timestamp, fixedTimeMillis; got != want {
+				t.Errorf("Timestamp=%d, want %d", got, want)
+			}
+			if got, want := []byte(sct.Extensions), fakeExtension; !bytes.Equal(got, want) {
+				t.Errorf("Extensions=%x, want %x", got, want)
+			}
+
+			// Verify the SCT over the entry it was issued for.
+			entry, err := x509util.EntryFromChain(chain, tc.isPrecert, fixedTimeMillis)
+			if err != nil {
+				t.Fatalf("EntryFromChain()=%v", err)
+			}
+			var leaf rfc6962.MerkleTreeLeaf
+			if _, err := tls.Unmarshal(entry.MerkleTreeLeaf(uint64(fakeIndex)), &leaf); err != nil {
+				t.Fatalf("tls.Unmarshal()=%v", err)
+			}
+			data, err := serializeSCTSignatureInput(sct, rfc6962.LogEntry{Leaf: leaf})
+			if err != nil {
+				t.Fatalf("serializeSCTSignatureInput()=%v", err)
+			}
+			h := sha256.Sum256(data)
+			if !ecdsa.VerifyASN1(&key.PublicKey, h[:], sct.Signature.Signature) {
+				t.Error("SCT signature does not verify")
+			}
+		})
+	}
+}
+
 func TestGetCTLogID(t *testing.T) {
 	block, _ := pem.Decode([]byte(testdata.DemoPublicKey))
 	pk, err := x509.ParsePKIXPublicKey(block.Bytes)
@@ -399,7 +477,7 @@ func setupSCTSigner(fakeSig []byte) (*sctSigner, error) {
 		return nil, err
 	}
 
-	return &sctSigner{testdata.NewSignerWithFixedSig(key, fakeSig)}, nil
+	return &sctSigner{signer: testdata.NewSignerWithFixedSig(key, fakeSig)}, nil
 }
 
 func TestBuildCp(t *testing.T) {
@@ -454,3 +532,178 @@ func TestBuildCp(t *testing.T) {
 		t.Errorf("buildCp returned an invalid signature")
 	}
 }
+
+func TestCpSignerVerifierKey(t *testing.T) {
+	const origin = "example.com/log"
+	ecdsaSigner, err := loadPEMPrivateKey("../testdata/test_ct_server_ecdsa_private_key.pem")
+	if err != nil {
+		t.Fatalf("Can't open key: %v", err)
+	}
+	signer, err := NewCpSigner(ecdsaSigner, origin, newFakeTimeSource(fixedTime))
+	if err != nil {
+		t.Fatalf("NewCpSigner()=%v", err)
+	}
+	vkey := signer.(*cpSigner).VerifierKey()
+	verifier, err := tdnote.NewRFC6962Verifier(vkey)
+	if err != nil {
+		t.Fatalf("NewRFC6962Verifier(%q)=%v", vkey, err)
+	}
+	if got, want := verifier.Name(), signer.Name(); got != want {
+		t.Errorf("verifier.Name()=%q, want %q", got, want)
+	}
+	if got, want := verifier.KeyHash(), signer.KeyHash(); got != want {
+		t.Errorf("verifier.KeyHash()=%08x, want %08x", got, want)
+	}
+
+	cp := tfl.Checkpoint{Origin: origin, Size: 123, Hash: make([]byte, sha256.Size)}
+	msg, err := note.Sign(&note.Note{Text: string(cp.Marshal())}, signer)
+	if err != nil {
+		t.Fatalf("note.Sign()=%v", err)
+	}
+	if _, err := note.Open(msg, note.VerifierList(verifier)); err != nil {
+		t.Errorf("note.Open()=%v, want nil", err)
+	}
+
+	// A verifier for another key must not validate the checkpoint.
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	otherSigner, err := NewCpSigner(otherKey, origin, newFakeTimeSource(fixedTime))
+	if err != nil {
+		t.Fatalf("NewCpSigner()=%v", err)
+	}
+	otherVerifier, err := tdnote.NewRFC6962Verifier(otherSigner.(*cpSigner).VerifierKey())
+	if err != nil {
+		t.Fatalf("NewRFC6962Verifier()=%v", err)
+	}
+	if _, err := note.Open(msg, note.VerifierList(otherVerifier)); err == nil {
+		t.Error("note.Open() with another key's verifier=nil, want error")
+	}
+}
+
+// slowSigner is a crypto.Signer which takes at least delay to sign.
+type slowSigner struct {
+	crypto.Signer
+	delay time.Duration
+}
+
+func (s *slowSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	time.Sleep(s.delay)
+	return s.Signer.Sign(rand, digest, opts)
+}
+
+func TestSignerLatency(t *testing.T) {
+	reader := testMetricReader()
+	const origin = "slow.example.com"
+	const delay = 50 * time.Millisecond
+
+	ecdsaSigner, err := loadPEMPrivateKey("../testdata/test_ct_server_ecdsa_private_key.pem")
+	if err != nil {
+		t.Fatalf("Can't open key: %v", err)
+	}
+	signer := &slowSigner{Signer: ecdsaSigner, delay: delay}
+
+	t.Run("sct", func(t *testing.T) {
+		sctSigner := &sctSigner{signer: signer, origin: origin}
+		leaf := defaultCertificateLogEntry().Leaf
+		if _, err := sctSigner.Sign(&leaf); err != nil {
+			t.Fatalf("Sign()=%v", err)
+		}
+		count, sum := histogramValue(t, reader, "tesseract.signer.sct.duration", origin)
+		if count != 1 {
+			t.Errorf("SCT signing duration count=%d, want 1", count)
+		}
+		if sum < delay.Seconds() {
+			t.Errorf("SCT signing duration=%vs, want >= %vs", sum, delay.Seconds())
+		}
+	})
+
+	t.Run("checkpoint", func(t *testing.T) {
+		cpSigner, err := NewCpSigner(signer, origin, newFakeTimeSource(fixedTime))
+		if err != nil {
+			t.Fatalf("NewCpSigner()=%v", err)
+		}
+		cp := tfl.Checkpoint{Origin: origin, Size: 123, Hash: make([]byte, sha256.Size)}
+		if _, err := cpSigner.Sign(cp.Marshal()); err != nil {
+			t.Fatalf("Sign()=%v", err)
+		}
+		count, sum := histogramValue(t, reader, "tesseract.signer.checkpoint.duration", origin)
+		if count != 1 {
+			t.Errorf("checkpoint signing duration count=%d, want 1", count)
+		}
+		if sum < delay.Seconds() {
+			t.Errorf("checkpoint signing duration=%vs, want >= %vs", sum, delay.Seconds())
+		}
+	})
+}
+
+// mismatchedSigner is a crypto.Signer which signs with a different key than
+// the one it advertises.
+type mismatchedSigner struct {
+	crypto.Signer
+	public crypto.PublicKey
+}
+
+func (s *mismatchedSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+func TestVerifySigners(t *testing.T) {
+	newKey := func() *ecdsa.PrivateKey {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("ecdsa.GenerateKey()=%v", err)
+		}
+		return k
+	}
+	k1, k2 := newKey(), newKey()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey()=%v", err)
+	}
+
+	for _, tc := range []struct {
+		desc    string
+		signers map[string]crypto.Signer
+		wantErr string
+	}{
+		{
+			desc:    "distinct-keys",
+			signers: map[string]crypto.Signer{"a.example.com": k1, "b.example.com": k2},
+		},
+		{
+			desc:    "shared-key",
+			signers: map[string]crypto.Signer{"a.example.com": k1, "b.example.com": k1},
+			wantErr: "logs a.example.com and b.example.com share the same signing key",
+		},
+		{
+			desc:    "nil-signer",
+			signers: map[string]crypto.Signer{"a.example.com": k1, "b.example.com": nil},
+			wantErr: "b.example.com: empty signer",
+		},
+		{
+			desc:    "rsa-signer",
+			signers: map[string]crypto.Signer{"a.example.com": rsaKey},
+			wantErr: "unsupported key type",
+		},
+		{
+			desc:    "mismatched-public-key",
+			signers: map[string]crypto.Signer{"a.example.com": &mismatchedSigner{Signer: k1, public: k2.Public()}},
+			wantErr: "does not verify",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := VerifySigners(tc.signers)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("VerifySigners()=%v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("VerifySigners()=%v, want err containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
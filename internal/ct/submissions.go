@@ -0,0 +1,155 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/transparency-dev/tesseract/internal/types/rfc6962"
+)
+
+// maxCachedSubmissions bounds the number of submissions held by a
+// submissionCache. Submissions are not cached when it is full.
+const maxCachedSubmissions = 1 << 16
+
+// submissionCache holds the SCTs recently issued for submissions, keyed by the
+// hash of their submitted chain, so that identical submissions retried in a
+// short window get the same SCTs without being validated and sequenced again.
+// The checks which depend on the request rather than on the chain, such as
+// interceptors, must still be applied to cached submissions.
+//
+// Identical submissions received while the first one is being processed wait
+// for its result. It is safe for concurrent use.
+type submissionCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*cachedSubmission
+	// expiries holds the keys of processed entries, in the order they
+	// expire, since they all live for ttl.
+	expiries *list.List
+}
+
+// cachedSubmission holds the outcome of a submission.
+type cachedSubmission struct {
+	// done is closed once the submission has been processed.
+	done chan struct{}
+	// The following fields are only set once done is closed, and sct is nil
+	// if processing failed.
+	// chain is the validated chain of the submission.
+	chain []*x509.Certificate
+	// sct and mirrorSCTs are the SCTs issued for the submission.
+	sct        *rfc6962.SignedCertificateTimestamp
+	mirrorSCTs []*rfc6962.SignedCertificateTimestamp
+	// isDup indicates that the submission had already been logged.
+	isDup bool
+	// expiry is the time after which the entry must not be used.
+	expiry time.Time
+	// elem is the element of the entry in submissionCache.expiries, once
+	// processed.
+	elem *list.Element
+}
+
+// newSubmissionCache returns a submissionCache holding SCTs for ttl.
+func newSubmissionCache(ttl time.Duration) *submissionCache {
+	return &submissionCache{ttl: ttl, entries: make(map[[sha256.Size]byte]*cachedSubmission), expiries: list.New()}
+}
+
+// submissionKey returns the cache key of a submission to add-chain, or
+// add-pre-chain if isPrecert is true, with the given DER certificates.
+func submissionKey(isPrecert bool, chain [][]byte) [sha256.Size]byte {
+	h := sha256.New()
+	if isPrecert {
+		h.Write([]byte{1})
+	} else {
+		h.Write([]byte{0})
+	}
+	for _, der := range chain {
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(len(der))))
+		h.Write(der)
+	}
+	return [sha256.Size]byte(h.Sum(nil))
+}
+
+// start looks up the submission with the given key at now.
+//
+// If the submission is being, or has recently been, processed, it returns
+// its entry and false: the caller must wait for the entry to be done.
+// Otherwise it returns a new entry and true: the caller must process the
+// submission and call finish with this entry. The returned entry is nil if
+// the cache is full, in which case the submission is not cached.
+func (c *submissionCache) start(key [sha256.Size]byte, now time.Time) (*cachedSubmission, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.evictExpired(now)
+	if e, ok := c.entries[key]; ok {
+		if e.expiry.IsZero() || now.Before(e.expiry) {
+			return e, false
+		}
+		// Only reachable if time went backwards.
+		c.expiries.Remove(e.elem)
+		delete(c.entries, key)
+	}
+	if len(c.entries) >= maxCachedSubmissions {
+		return nil, true
+	}
+	e := &cachedSubmission{done: make(chan struct{})}
+	c.entries[key] = e
+	return e, true
+}
+
+// finish records the SCTs issued for the submission with the given key, at
+// now. e.sct must be nil if processing failed, in which case e is evicted.
+func (c *submissionCache) finish(key [sha256.Size]byte, e *cachedSubmission, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e.sct == nil {
+		delete(c.entries, key)
+	} else {
+		e.expiry = now.Add(c.ttl)
+		e.elem = c.expiries.PushBack(key)
+	}
+	close(e.done)
+}
+
+// evictExpired removes expired entries, oldest first, and stops at the first
+// entry which has not expired. c.mu must be held.
+func (c *submissionCache) evictExpired(now time.Time) {
+	for elem := c.expiries.Front(); elem != nil; elem = c.expiries.Front() {
+		key := elem.Value.([sha256.Size]byte)
+		if now.Before(c.entries[key].expiry) {
+			return
+		}
+		c.expiries.Remove(elem)
+		delete(c.entries, key)
+	}
+}
+
+// wait waits for e to be done. It returns false if processing failed, or ctx
+// is done first. Otherwise, the fields of e can be read.
+func (e *cachedSubmission) wait(ctx context.Context) bool {
+	select {
+	case <-e.done:
+		return e.sct != nil
+	case <-ctx.Done():
+		return false
+	}
+}
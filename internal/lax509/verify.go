@@ -59,6 +59,10 @@ type VerifyOptions struct {
 	// chain is accepted if it allows any of the listed values. An empty list
 	// means ExtKeyUsageServerAuth. To accept any key usage, include ExtKeyUsageAny.
 	KeyUsages []x509.ExtKeyUsage
+	// AllowSHA1Root, if set, allows certificates signed with SHA1WithRSA or
+	// ECDSAWithSHA1 to be used in chains to the roots for which it returns
+	// true. Chains to other roots are not built through such certificates.
+	AllowSHA1Root func(root *x509.Certificate) bool
 }
 
 const (
@@ -151,7 +155,8 @@ func isValid(c *x509.Certificate, certType int, currentChain []*x509.Certificate
 // the types of certificates a CA can issue.)
 //
 // Certificates that use SHA1WithRSA and ECDSAWithSHA1 signatures are not supported,
-// and will not be used to build chains.
+// and will not be used to build chains, unless opts.AllowSHA1Root allows the
+// root of the chain.
 //
 // Certificates other than c in the returned chains should not be modified.
 //
@@ -186,7 +191,7 @@ func Verify(c *x509.Certificate, opts VerifyOptions) (chains [][]*x509.Certifica
 	if opts.Roots.contains(c) {
 		candidateChains = [][]*x509.Certificate{{c}}
 	} else {
-		candidateChains, err = buildChains(c, []*x509.Certificate{c}, nil, &opts)
+		candidateChains, err = buildChains(c, []*x509.Certificate{c}, false, nil, &opts)
 		if err != nil {
 			return nil, err
 		}
@@ -262,13 +267,37 @@ func alreadyInChain(candidate *x509.Certificate, chain []*x509.Certificate) bool
 	return false
 }
 
+// checkSignatureFrom verifies that the signature on c is a valid signature
+// from parent, like c.CheckSignatureFrom. If allowSHA1 is set, it also accepts
+// SHA-1 signatures, which c.CheckSignatureFrom rejects as insecure.
+func checkSignatureFrom(c, parent *x509.Certificate, allowSHA1 bool) error {
+	err := c.CheckSignatureFrom(parent)
+	var insecureErr x509.InsecureAlgorithmError
+	if !allowSHA1 || !errors.As(err, &insecureErr) || !isSHA1Signed(c) {
+		return err
+	}
+	// CheckSignatureFrom checks the constraints of parent before the
+	// signature algorithm, so only the signature remains to be verified.
+	// Unlike CheckSignatureFrom, CheckSignature accepts SHA-1 signatures.
+	return parent.CheckSignature(c.SignatureAlgorithm, c.RawTBSCertificate, c.Signature)
+}
+
+// isSHA1Signed returns whether c is signed with SHA1WithRSA or ECDSAWithSHA1.
+func isSHA1Signed(c *x509.Certificate) bool {
+	return c.SignatureAlgorithm == x509.SHA1WithRSA || c.SignatureAlgorithm == x509.ECDSAWithSHA1
+}
+
 // maxChainSignatureChecks is the maximum number of CheckSignatureFrom calls
 // that an invocation of buildChains will (transitively) make. Most chains are
 // less than 15 certificates long, so this leaves space for multiple chains and
 // for failed checks due to different intermediates having the same Subject.
 const maxChainSignatureChecks = 100
 
-func buildChains(c *x509.Certificate, currentChain []*x509.Certificate, sigChecks *int, opts *VerifyOptions) (chains [][]*x509.Certificate, err error) {
+// buildChains returns the chains from c to opts.Roots, continuing
+// currentChain. sha1Signed is set if a certificate in currentChain other than
+// c is signed with SHA-1, in which case chains can only end at roots allowed
+// by opts.AllowSHA1Root.
+func buildChains(c *x509.Certificate, currentChain []*x509.Certificate, sha1Signed bool, sigChecks *int, opts *VerifyOptions) (chains [][]*x509.Certificate, err error) {
 	var (
 		hintErr  error
 		hintCert *x509.Certificate
@@ -288,7 +317,7 @@ func buildChains(c *x509.Certificate, currentChain []*x509.Certificate, sigCheck
 			return
 		}
 
-		if err := c.CheckSignatureFrom(candidate.cert); err != nil {
+		if err := checkSignatureFrom(c, candidate.cert, opts.AllowSHA1Root != nil); err != nil {
 			if hintErr == nil {
 				hintErr = err
 				hintCert = candidate.cert
@@ -315,12 +344,22 @@ func buildChains(c *x509.Certificate, currentChain []*x509.Certificate, sigCheck
 			}
 		}
 
+		// If c is signed with SHA-1, checkSignatureFrom only accepted it
+		// because opts.AllowSHA1Root is set.
+		sha1Signed := sha1Signed || isSHA1Signed(c)
 		switch certType {
 		case rootCertificate:
+			if sha1Signed && !opts.AllowSHA1Root(candidate.cert) {
+				if hintErr == nil {
+					hintErr = errors.New("x509: SHA-1 signature in a chain to a root which doesn't allow SHA-1")
+					hintCert = candidate.cert
+				}
+				return
+			}
 			chains = append(chains, appendToFreshChain(currentChain, candidate.cert))
 		case intermediateCertificate:
 			var childChains [][]*x509.Certificate
-			childChains, err = buildChains(candidate.cert, appendToFreshChain(currentChain, candidate.cert), sigChecks, opts)
+			childChains, err = buildChains(candidate.cert, appendToFreshChain(currentChain, candidate.cert), sha1Signed, sigChecks, opts)
 			chains = append(chains, childChains...)
 		}
 	}
@@ -1782,7 +1782,7 @@ func TestVerifyNilPubKey(t *testing.T) {
 	}
 	opts.Roots.AddCert(r)
 
-	_, err := buildChains(c, []*x509.Certificate{r}, nil, opts)
+	_, err := buildChains(c, []*x509.Certificate{r}, false, nil, opts)
 	if _, ok := err.(UnknownAuthorityError); !ok {
 		t.Fatalf("buildChains returned unexpected error, got: %v, want %v", err, UnknownAuthorityError{})
 	}
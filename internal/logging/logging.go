@@ -0,0 +1,53 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logging configures the format of klog output.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// FormatText is klog's default, human readable, format.
+	FormatText = "text"
+	// FormatJSON emits one JSON object per line, with key/value pairs of
+	// structured log calls as top-level fields.
+	FormatJSON = "json"
+)
+
+// SetFormat configures klog to emit lines in format, one of FormatText and
+// FormatJSON. JSON lines are written to w.
+//
+// Verbosity is still controlled by klog's -v flag. JSON lines of V-level calls
+// have a level below INFO: V(2) lines have level DEBUG+2.
+func SetFormat(format string, w io.Writer) error {
+	switch format {
+	case FormatText:
+		klog.ClearLogger()
+	case FormatJSON:
+		// klog filters lines by verbosity before they reach the handler, which
+		// must therefore accept all levels.
+		h := slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.Level(math.MinInt)})
+		klog.SetSlogLogger(slog.New(h))
+	default:
+		return fmt.Errorf("unknown log format %q, want %q or %q", format, FormatText, FormatJSON)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"strings"
+	"testing"
+
+	"k8s.io/klog/v2"
+)
+
+func TestSetFormatJSON(t *testing.T) {
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	if err := fs.Set("v", "2"); err != nil {
+		t.Fatalf("Failed to set verbosity: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = fs.Set("v", "0")
+		klog.ClearLogger()
+	})
+
+	var buf bytes.Buffer
+	if err := SetFormat(FormatJSON, &buf); err != nil {
+		t.Fatalf("SetFormat()=%v", err)
+	}
+	klog.V(2).InfoS("Request", "origin", "example.com/log", "method", "POST", "url", "/ct/v1/add-chain")
+	klog.Infof("%s: formatted", "example.com/log")
+	klog.V(3).InfoS("Too verbose", "origin", "example.com/log")
+	klog.Flush()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if got, want := len(lines), 2; got != want {
+		t.Fatalf("got %d lines, want %d:\n%s", got, want, buf.String())
+	}
+	for i, want := range []map[string]any{
+		{"msg": "Request", "origin": "example.com/log", "method": "POST", "url": "/ct/v1/add-chain", "level": "DEBUG+2"},
+		{"msg": "example.com/log: formatted", "level": "INFO"},
+	} {
+		var got map[string]any
+		if err := json.Unmarshal([]byte(lines[i]), &got); err != nil {
+			t.Fatalf("line %d is not JSON: %v: %s", i, err, lines[i])
+		}
+		if _, ok := got["time"]; !ok {
+			t.Errorf("line %d has no time field: %s", i, lines[i])
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("line %d: %s=%v, want %v", i, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestSetFormatUnknown(t *testing.T) {
+	if err := SetFormat("xml", &bytes.Buffer{}); err == nil {
+		t.Error("SetFormat(xml)=nil, want error")
+	}
+}
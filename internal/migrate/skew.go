@@ -0,0 +1,60 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package migrate contains helpers to import entries from a source static-ct
+// log into a TesseraCT log.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/transparency-dev/tesseract/internal/types/staticct"
+)
+
+// ReadEntryBundleFunc reads the entry bundle at index i, with partial width p,
+// from a source log.
+type ReadEntryBundleFunc func(ctx context.Context, i uint64, p uint8) ([]byte, error)
+
+// CheckTimestampSkew wraps readEntryBundle so that it fails to read bundles
+// with an entry whose SCT timestamp is more than maxSkew before or after the
+// import time, as returned by now. Such timestamps are implausible, and
+// indicate a corrupt or misbehaving source log.
+//
+// Importing a whole log requires maxSkew to be longer than the age of its
+// oldest entry.
+func CheckTimestampSkew(readEntryBundle ReadEntryBundleFunc, maxSkew time.Duration, now func() time.Time) ReadEntryBundleFunc {
+	return func(ctx context.Context, i uint64, p uint8) ([]byte, error) {
+		raw, err := readEntryBundle(ctx, i, p)
+		if err != nil {
+			return nil, err
+		}
+		eb := staticct.EntryBundle{}
+		if err := eb.UnmarshalText(raw); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal entry bundle at index %d: %v", i, err)
+		}
+		t := now()
+		for j, e := range eb.Entries {
+			ts, err := staticct.UnmarshalTimestamp(e)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d of bundle %d: %v", j, i, err)
+			}
+			if skew := t.Sub(time.UnixMilli(int64(ts))).Abs(); skew > maxSkew {
+				return nil, fmt.Errorf("entry %d of bundle %d has timestamp %v, %v away from import time %v, more than %v", j, i, time.UnixMilli(int64(ts)).UTC(), skew, t.UTC(), maxSkew)
+			}
+		}
+		return raw, nil
+	}
+}
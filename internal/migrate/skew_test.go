@@ -0,0 +1,71 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/transparency-dev/tessera/ctonly"
+)
+
+func TestCheckTimestampSkew(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	const maxSkew = 24 * time.Hour
+
+	for _, tc := range []struct {
+		desc       string
+		timestamps []time.Time
+		wantErr    string
+	}{
+		{
+			desc:       "in-range",
+			timestamps: []time.Time{now.Add(-maxSkew), now.Add(-time.Hour), now, now.Add(maxSkew)},
+		},
+		{
+			desc:       "too-old",
+			timestamps: []time.Time{now.Add(-time.Hour), now.Add(-maxSkew - time.Millisecond)},
+			wantErr:    "entry 1 of bundle 3",
+		},
+		{
+			desc:       "in-the-future",
+			timestamps: []time.Time{now.Add(maxSkew + time.Hour), now},
+			wantErr:    "entry 0 of bundle 3",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var bundle []byte
+			for i, ts := range tc.timestamps {
+				e := ctonly.Entry{Timestamp: uint64(ts.UnixMilli()), Certificate: []byte("certificate")}
+				bundle = append(bundle, e.LeafData(uint64(i))...)
+			}
+			read := func(context.Context, uint64, uint8) ([]byte, error) { return bundle, nil }
+
+			got, err := CheckTimestampSkew(read, maxSkew, func() time.Time { return now })(t.Context(), 3, 0)
+			if tc.wantErr == "" {
+				if err != nil || !bytes.Equal(got, bundle) {
+					t.Errorf("readEntryBundle()=%x, %v, want %x, nil", got, err, bundle)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("readEntryBundle()=%v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
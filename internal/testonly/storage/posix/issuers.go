@@ -18,9 +18,11 @@ package posix
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"strings"
@@ -30,17 +32,29 @@ import (
 )
 
 // IssuersStorage is a key value store backed by the local filesystem to store issuer chains.
-type IssuersStorage string
+type IssuersStorage struct {
+	path string
+	// compress indicates that values are written gzip-compressed.
+	compress bool
+}
+
+// gzipMagic starts gzip streams, as per RFC 1952 s2.3.1. DER certificates
+// start with a SEQUENCE tag instead, 0x30, so compressed and uncompressed
+// values can be told apart.
+var gzipMagic = []byte{0x1f, 0x8b}
 
 // NewIssuerStorage creates a new IssuerStorage.
 //
 // It creates the underying directory if it does not exist already.
-func NewIssuerStorage(path string) (IssuersStorage, error) {
+// If compress is true, values are gzip-compressed on disk to save space.
+// Compressed values are transparently decompressed on read, whether compress
+// is set or not, so that it can be changed for an existing directory.
+func NewIssuerStorage(path string, compress bool) (IssuersStorage, error) {
 	// Does nothing if the dictory already exists.
 	if err := os.MkdirAll(path, 0755); err != nil {
-		return "", fmt.Errorf("failed to create path %q: %v", path, err)
+		return IssuersStorage{}, fmt.Errorf("failed to create path %q: %v", path, err)
 	}
-	return IssuersStorage(path), nil
+	return IssuersStorage{path: path, compress: compress}, nil
 }
 
 // keyToObjName converts bytes to filesystem path.
@@ -55,7 +69,40 @@ func (s IssuersStorage) keyToObjName(key []byte) (string, error) {
 	if strings.Contains(string(key), string(os.PathSeparator)) {
 		return "", fmt.Errorf("key %q cannot contain '/'", string(key))
 	}
-	return path.Join(string(s), string(key)), nil
+	return path.Join(s.path, string(key)), nil
+}
+
+// readObject returns the value stored in objName, decompressed if needed.
+func readObject(objName string) ([]byte, error) {
+	v, err := os.ReadFile(objName)
+	if err != nil || !bytes.HasPrefix(v, gzipMagic) {
+		return v, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(v))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress object %q: %v", objName, err)
+	}
+	v, err = io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress object %q: %v", objName, err)
+	}
+	return v, nil
+}
+
+// writeObject writes v to objName, compressed if s.compress is set.
+func (s IssuersStorage) writeObject(objName string, v []byte) error {
+	if s.compress {
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		if _, err := w.Write(v); err != nil {
+			return fmt.Errorf("failed to compress object %q: %v", objName, err)
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to compress object %q: %v", objName, err)
+		}
+		v = b.Bytes()
+	}
+	return os.WriteFile(objName, v, 0644)
 }
 
 // AddIssuers stores Issuers values under their Key if there isn't an object under Key already.
@@ -66,9 +113,9 @@ func (s IssuersStorage) AddIssuersIfNotExist(_ context.Context, kv []storage.KV)
 			return fmt.Errorf("failed to convert key to object name: %v", err)
 		}
 		// We first try and see if this issuer cert has already been stored.
-		if f, err := os.ReadFile(objName); err != nil {
+		if f, err := readObject(objName); err != nil {
 			if errors.Is(err, os.ErrNotExist) {
-				if err := os.WriteFile(objName, kv.V, 0644); err != nil {
+				if err := s.writeObject(objName, kv.V); err != nil {
 					return fmt.Errorf("failed to write object %q: %v", objName, err)
 				}
 				klog.V(2).Infof("AddIssuersIfNotExist: added %q", objName)
@@ -83,3 +130,16 @@ func (s IssuersStorage) AddIssuersIfNotExist(_ context.Context, kv []storage.KV)
 	}
 	return nil
 }
+
+// GetIssuer returns the value stored under key.
+func (s IssuersStorage) GetIssuer(_ context.Context, key []byte) ([]byte, error) {
+	objName, err := s.keyToObjName(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert key to object name: %v", err)
+	}
+	v, err := readObject(objName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %q: %w", objName, err)
+	}
+	return v, nil
+}
@@ -15,13 +15,18 @@
 package posix
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
 
+	"github.com/transparency-dev/tesseract/internal/testdata"
 	"github.com/transparency-dev/tesseract/storage"
 )
 
@@ -47,7 +52,7 @@ func TestNewIssuerStorage(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := NewIssuerStorage(tt.path)
+			_, err := NewIssuerStorage(tt.path, false)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewIssuerStorage() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -58,7 +63,7 @@ func TestNewIssuerStorage(t *testing.T) {
 
 func TestKeyToObjName(t *testing.T) {
 	tmpDir := t.TempDir()
-	s := IssuersStorage(tmpDir)
+	s := IssuersStorage{path: tmpDir}
 
 	tests := []struct {
 		name    string
@@ -106,7 +111,7 @@ func TestKeyToObjName(t *testing.T) {
 
 func TestAddIssuersIfNotExist(t *testing.T) {
 	tmpDir := t.TempDir()
-	s, err := NewIssuerStorage(tmpDir)
+	s, err := NewIssuerStorage(tmpDir, false)
 	if err != nil {
 		t.Fatalf("NewIssuerStorage() failed: %v", err)
 	}
@@ -213,3 +218,117 @@ func TestAddIssuersIfNotExist(t *testing.T) {
 		})
 	}
 }
+
+func TestGetIssuer(t *testing.T) {
+	s, err := NewIssuerStorage(t.TempDir(), false)
+	if err != nil {
+		t.Fatalf("NewIssuerStorage() failed: %v", err)
+	}
+	if err := s.AddIssuersIfNotExist(context.Background(), []storage.KV{{K: []byte("issuer1"), V: []byte("issuer1 data")}}); err != nil {
+		t.Fatalf("AddIssuersIfNotExist() failed: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		key          string
+		want         []byte
+		wantErr      bool
+		wantNotExist bool
+	}{
+		{
+			name: "existing issuer",
+			key:  "issuer1",
+			want: []byte("issuer1 data"),
+		},
+		{
+			name:         "missing issuer",
+			key:          "issuer2",
+			wantErr:      true,
+			wantNotExist: true,
+		},
+		{
+			name:    "invalid path",
+			key:     "dir1/issuer1",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.GetIssuer(context.Background(), []byte(tt.key))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetIssuer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got, want := errors.Is(err, os.ErrNotExist), tt.wantNotExist; got != want {
+				t.Errorf("errors.Is(GetIssuer() error, os.ErrNotExist) = %v, want %v", got, want)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetIssuer() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompressedIssuerStorage(t *testing.T) {
+	tmpDir := t.TempDir()
+	s, err := NewIssuerStorage(tmpDir, true)
+	if err != nil {
+		t.Fatalf("NewIssuerStorage() failed: %v", err)
+	}
+
+	var kvs []storage.KV
+	for _, p := range []string{testdata.CertFromIntermediate, testdata.IntermediateFromRoot, testdata.CACertPEM} {
+		b, _ := pem.Decode([]byte(p))
+		if b == nil {
+			t.Fatalf("Failed to decode PEM certificate")
+		}
+		k := sha256.Sum256(b.Bytes)
+		kvs = append(kvs, storage.KV{K: fmt.Appendf(nil, "%x", k), V: b.Bytes})
+	}
+	if err := s.AddIssuersIfNotExist(context.Background(), kvs); err != nil {
+		t.Fatalf("AddIssuersIfNotExist() failed: %v", err)
+	}
+	// Adding the same chain again must succeed.
+	if err := s.AddIssuersIfNotExist(context.Background(), kvs); err != nil {
+		t.Fatalf("AddIssuersIfNotExist() with identical values failed: %v", err)
+	}
+
+	for _, kv := range kvs {
+		objName, err := s.keyToObjName(kv.K)
+		if err != nil {
+			t.Fatalf("Failed to convert key %q to object name: %v", kv.K, err)
+		}
+		onDisk, err := os.ReadFile(objName)
+		if err != nil {
+			t.Fatalf("Failed to read object %q: %v", objName, err)
+		}
+		if !bytes.HasPrefix(onDisk, gzipMagic) {
+			t.Errorf("Object %q is not gzip-compressed", objName)
+		}
+
+		got, err := s.GetIssuer(context.Background(), kv.K)
+		if err != nil {
+			t.Fatalf("GetIssuer(%q) failed: %v", kv.K, err)
+		}
+		if !bytes.Equal(got, kv.V) {
+			t.Errorf("GetIssuer(%q) = %x, want %x", kv.K, got, kv.V)
+		}
+	}
+
+	// Uncompressed storage must read compressed values, and must not accept
+	// different content under the same key.
+	u, err := NewIssuerStorage(tmpDir, false)
+	if err != nil {
+		t.Fatalf("NewIssuerStorage() failed: %v", err)
+	}
+	got, err := u.GetIssuer(context.Background(), kvs[0].K)
+	if err != nil {
+		t.Fatalf("GetIssuer(%q) failed: %v", kvs[0].K, err)
+	}
+	if !bytes.Equal(got, kvs[0].V) {
+		t.Errorf("GetIssuer(%q) = %x, want %x", kvs[0].K, got, kvs[0].V)
+	}
+	if err := u.AddIssuersIfNotExist(context.Background(), []storage.KV{{K: kvs[0].K, V: kvs[1].V}}); err == nil {
+		t.Error("AddIssuersIfNotExist() with different content succeeded, want error")
+	}
+}
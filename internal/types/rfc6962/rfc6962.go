@@ -43,10 +43,11 @@ const (
 	TreeNodePrefix = byte(0x01)
 )
 
-// Defined in RFC 6962 s3.1.
+// Defined in RFC 6962 s3.1 and s3.3.
 var (
 	OIDExtensionCTPoison                  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
 	OIDExtKeyUsageCertificateTransparency = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 4}
+	OIDExtensionCTSCTList                 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
 )
 
 // MerkleLeafType represents the MerkleLeafType enum from section 3.4:
@@ -110,7 +110,14 @@ func removeExtension(tbsData []byte, oid asn1.ObjectIdentifier) ([]byte, error)
 //   - The precert's AuthorityKeyId is changed to the AuthorityKeyId of the
 //     intermediate.
 func BuildPrecertTBS(tbsData []byte, preIssuer *x509.Certificate) ([]byte, error) {
-	data, err := removeExtension(tbsData, oidExtensionCTPoison)
+	return BuildPrecertTBSWithPoison(tbsData, preIssuer, oidExtensionCTPoison)
+}
+
+// BuildPrecertTBSWithPoison is like BuildPrecertTBS, but removes the
+// extension with poisonOID instead of the CT poison extension, for
+// precertificates marked with an alternate poison extension.
+func BuildPrecertTBSWithPoison(tbsData []byte, preIssuer *x509.Certificate, poisonOID asn1.ObjectIdentifier) ([]byte, error) {
+	data, err := removeExtension(tbsData, poisonOID)
 	if err != nil {
 		return nil, err
 	}
@@ -198,8 +205,20 @@ func RemoveCTPoison(tbsData []byte) ([]byte, error) {
 
 // EntryFromChain generates an Entry from a chain and timestamp.
 // copied from certificate-transparency-go/serialization.go
+//
+// For precertificates, the issuer key hash is the SHA-256 hash of the
+// SubjectPublicKeyInfo of chain[1], or of chain[2] if chain[1] is a
+// precertificate signing certificate. Cross-signed versions of this issuer
+// share its key, so whichever of them is in chain, the issuer key hash is the
+// same as long as they encode the key identically.
 // TODO(phboneff): add tests
 func EntryFromChain(chain []*x509.Certificate, isPrecert bool, timestamp uint64) (*ctonly.Entry, error) {
+	return EntryFromChainWithPoison(chain, isPrecert, timestamp, oidExtensionCTPoison)
+}
+
+// EntryFromChainWithPoison is like EntryFromChain, but for precertificates
+// marked with the extension with poisonOID instead of the CT poison extension.
+func EntryFromChainWithPoison(chain []*x509.Certificate, isPrecert bool, timestamp uint64, poisonOID asn1.ObjectIdentifier) (*ctonly.Entry, error) {
 	leaf := ctonly.Entry{
 		IsPrecert: isPrecert,
 		Timestamp: timestamp,
@@ -227,7 +246,7 @@ func EntryFromChain(chain []*x509.Certificate, isPrecert bool, timestamp uint64)
 	cert := chain[0]
 
 	var preIssuer *x509.Certificate
-	if isPreIssuer(issuer) {
+	if IsPreIssuer(issuer) {
 		// Replace the cert's issuance information with details from the pre-issuer.
 		preIssuer = issuer
 
@@ -241,7 +260,7 @@ func EntryFromChain(chain []*x509.Certificate, isPrecert bool, timestamp uint64)
 
 	// Next, post-process the DER-encoded TBSCertificate, to remove the CT poison
 	// extension and possibly update the issuer field.
-	defangedTBS, err := BuildPrecertTBS(cert.RawTBSCertificate, preIssuer)
+	defangedTBS, err := BuildPrecertTBSWithPoison(cert.RawTBSCertificate, preIssuer, poisonOID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to remove poison extension: %v", err)
 	}
@@ -256,9 +275,9 @@ func EntryFromChain(chain []*x509.Certificate, isPrecert bool, timestamp uint64)
 	return &leaf, nil
 }
 
-// isPreIssuer indicates whether a certificate is a pre-cert issuer with the specific
+// IsPreIssuer indicates whether a certificate is a pre-cert issuer with the specific
 // certificate transparency extended key usage.
-func isPreIssuer(cert *x509.Certificate) bool {
+func IsPreIssuer(cert *x509.Certificate) bool {
 	// Look for the extension in the Extensions field and not ExtKeyUsage
 	// since crypto/x509 does not recognize this extension as an ExtKeyUsage.
 	for _, ext := range cert.Extensions {
@@ -309,6 +309,40 @@ const (
 		"06010401d6790205013008060667810c010202"
 )
 
+func TestBuildPrecertTBSWithPoison(t *testing.T) {
+	altPoisonOID := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}
+	template := x509.Certificate{
+		Version:      3,
+		SerialNumber: big.NewInt(123),
+		Issuer:       pkix.Name{CommonName: "precert Issuer"},
+		Subject:      pkix.Name{CommonName: "precert subject"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(3 * time.Hour),
+	}
+	want := makeCert(t, &template, &template).RawTBSCertificate
+	template.ExtraExtensions = []pkix.Extension{{Id: altPoisonOID, Critical: true, Value: asn1.NullBytes}}
+	precert := makeCert(t, &template, &template)
+
+	got, err := BuildPrecertTBSWithPoison(precert.RawTBSCertificate, nil, altPoisonOID)
+	if err != nil {
+		t.Fatalf("BuildPrecertTBSWithPoison()=nil,%q; want _,nil", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("BuildPrecertTBSWithPoison()=%x; want %x", got, want)
+	}
+	if _, err := BuildPrecertTBS(precert.RawTBSCertificate, nil); err == nil {
+		t.Error("BuildPrecertTBS() with an alternate poison extension=_,nil; want error")
+	}
+
+	entry, err := EntryFromChainWithPoison([]*x509.Certificate{precert, precert}, true, 0, altPoisonOID)
+	if err != nil {
+		t.Fatalf("EntryFromChainWithPoison()=nil,%q; want _,nil", err)
+	}
+	if !bytes.Equal(entry.Certificate, want) {
+		t.Errorf("EntryFromChainWithPoison().Certificate=%x; want %x", entry.Certificate, want)
+	}
+}
+
 func TestRemoveCTPoison(t *testing.T) {
 	var tests = []struct {
 		name   string // for human consumption
@@ -0,0 +1,92 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tesseract
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SecurityTxtPath is the path at which security contact metadata is served,
+// as per RFC 9116.
+const SecurityTxtPath = "/.well-known/security.txt"
+
+// securityTxtExpiry is how far in the future the Expires field of
+// security.txt responses is. The metadata is generated from the configuration
+// on each request, so it never goes stale.
+const securityTxtExpiry = 7 * 24 * time.Hour
+
+// SecurityTxtConfig contains the metadata served by NewSecurityTxtHandler.
+type SecurityTxtConfig struct {
+	// Contact lists the URIs where security issues and abuse of the log can
+	// be reported, in order of preference, e.g. "mailto:abuse@example.com"
+	// or "https://example.com/report". At least one is required.
+	Contact []string
+	// Policy is the URL of the log's policy, e.g. its acceptance criteria
+	// and operational commitments. Leaving this unset omits it.
+	Policy string
+}
+
+// NewSecurityTxtHandler returns a handler serving the contact and policy
+// metadata of cfg in the security.txt format of RFC 9116, so that operators
+// can be reached by machine-readable means. It is independent of the CT
+// endpoints, and is meant to be served at SecurityTxtPath.
+func NewSecurityTxtHandler(cfg SecurityTxtConfig) (http.Handler, error) {
+	if len(cfg.Contact) == 0 {
+		return nil, errors.New("no security.txt contact")
+	}
+	var b strings.Builder
+	for _, c := range cfg.Contact {
+		if err := checkSecurityTxtURI(c); err != nil {
+			return nil, fmt.Errorf("invalid security.txt contact %q: %v", c, err)
+		}
+		fmt.Fprintf(&b, "Contact: %s\n", c)
+	}
+	if cfg.Policy != "" {
+		if err := checkSecurityTxtURI(cfg.Policy); err != nil {
+			return nil, fmt.Errorf("invalid security.txt policy %q: %v", cfg.Policy, err)
+		}
+		fmt.Fprintf(&b, "Policy: %s\n", cfg.Policy)
+	}
+	fields := b.String()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		expires := time.Now().Add(securityTxtExpiry).UTC().Truncate(time.Second)
+		fmt.Fprintf(w, "%sExpires: %s\n", fields, expires.Format(time.RFC3339))
+	}), nil
+}
+
+// checkSecurityTxtURI returns an error if u is not an absolute URI, which
+// security.txt fields hold.
+func checkSecurityTxtURI(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if !parsed.IsAbs() {
+		return errors.New("not an absolute URI")
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tesseract
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecurityTxtHandler(t *testing.T) {
+	h, err := NewSecurityTxtHandler(SecurityTxtConfig{
+		Contact: []string{"mailto:abuse@example.com", "https://example.com/report"},
+		Policy:  "https://example.com/ct-policy",
+	})
+	if err != nil {
+		t.Fatalf("NewSecurityTxtHandler()=%v", err)
+	}
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + SecurityTxtPath)
+	if err != nil {
+		t.Fatalf("http.Get(%s)=%v", SecurityTxtPath, err)
+	}
+	defer resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		t.Fatalf("http.Get(%s) status=%d, want %d", SecurityTxtPath, got, want)
+	}
+	if got, want := resp.Header.Get("Content-Type"), "text/plain; charset=utf-8"; got != want {
+		t.Errorf("Content-Type=%q, want %q", got, want)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+
+	want := "Contact: mailto:abuse@example.com\nContact: https://example.com/report\nPolicy: https://example.com/ct-policy\nExpires: "
+	got, expires, ok := strings.Cut(string(body), "Expires: ")
+	if !ok || got+"Expires: " != want {
+		t.Fatalf("security.txt=%q, want prefix %q", body, want)
+	}
+	e, err := time.Parse(time.RFC3339, strings.TrimSuffix(expires, "\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse Expires: %v", err)
+	}
+	if !e.After(time.Now()) {
+		t.Errorf("Expires=%v, want a future date", e)
+	}
+
+	resp, err = http.Post(server.URL+SecurityTxtPath, "text/plain", nil)
+	if err != nil {
+		t.Fatalf("http.Post(%s)=%v", SecurityTxtPath, err)
+	}
+	resp.Body.Close()
+	if got, want := resp.StatusCode, http.StatusMethodNotAllowed; got != want {
+		t.Errorf("http.Post(%s) status=%d, want %d", SecurityTxtPath, got, want)
+	}
+}
+
+func TestSecurityTxtHandlerErrors(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		cfg  SecurityTxtConfig
+	}{
+		{
+			desc: "no-contact",
+			cfg:  SecurityTxtConfig{Policy: "https://example.com/ct-policy"},
+		},
+		{
+			desc: "relative-contact",
+			cfg:  SecurityTxtConfig{Contact: []string{"abuse@example.com"}},
+		},
+		{
+			desc: "contact-with-newline",
+			cfg:  SecurityTxtConfig{Contact: []string{"mailto:abuse@example.com\nPolicy: https://evil.example"}},
+		},
+		{
+			desc: "relative-policy",
+			cfg:  SecurityTxtConfig{Contact: []string{"mailto:abuse@example.com"}, Policy: "/ct-policy"},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if _, err := NewSecurityTxtHandler(tc.cfg); err == nil {
+				t.Errorf("NewSecurityTxtHandler(%+v)=nil, want error", tc.cfg)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/transparency-dev/tessera"
+	"k8s.io/klog/v2"
+)
+
+// failOpenAntispam is a tessera.Antispam which sequences entries without
+// deduplication when the underlying Antispam fails to look them up.
+type failOpenAntispam struct {
+	as tessera.Antispam
+}
+
+// FailOpenAntispam wraps as so that entries are sequenced without
+// deduplication, rather than rejected, when as fails to look them up, for
+// instance because its database is unavailable. This favours availability
+// over deduplication: duplicate entries may be sequenced while as is failing.
+//
+// Pushback errors are still returned, so that the antispam index can catch up
+// with the log.
+//
+// Deduplication happens in the Tessera appender, before entries reach
+// CTStorage, so the returned Antispam must be passed to the appender options,
+// with tessera.AppendOptions.WithAntispam.
+func FailOpenAntispam(as tessera.Antispam) tessera.Antispam {
+	return &failOpenAntispam{as: as}
+}
+
+// Decorator returns a decorator which delegates to the underlying Antispam's
+// decorator, and falls back to the undecorated Add function if it fails before
+// delegating.
+func (f *failOpenAntispam) Decorator() func(tessera.AddFn) tessera.AddFn {
+	decorator := f.as.Decorator()
+	return func(delegate tessera.AddFn) tessera.AddFn {
+		return func(ctx context.Context, e *tessera.Entry) tessera.IndexFuture {
+			// delegated records whether the underlying Antispam has passed
+			// e on to delegate, in which case errors come from delegate.
+			var delegated atomic.Bool
+			future := decorator(func(ctx context.Context, e *tessera.Entry) tessera.IndexFuture {
+				delegated.Store(true)
+				return delegate(ctx, e)
+			})(ctx, e)
+			return func() (tessera.Index, error) {
+				idx, err := future()
+				if err == nil || delegated.Load() || errors.Is(err, tessera.ErrPushback) {
+					return idx, err
+				}
+				klog.Warningf("Antispam lookup failed, sequencing entry without deduplication: %v", err)
+				return delegate(ctx, e)()
+			}
+		}
+	}
+}
+
+// Follower returns the underlying Antispam's follower.
+func (f *failOpenAntispam) Follower(b func([]byte) ([][]byte, error)) tessera.Follower {
+	return f.as.Follower(b)
+}
@@ -0,0 +1,124 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/transparency-dev/tessera"
+)
+
+// fakeAntispam is a tessera.Antispam which fails lookups with err, or
+// finds every entry at index dupIndex if err is nil.
+type fakeAntispam struct {
+	err      error
+	dupIndex uint64
+}
+
+func (a fakeAntispam) Decorator() func(tessera.AddFn) tessera.AddFn {
+	return func(_ tessera.AddFn) tessera.AddFn {
+		return func(_ context.Context, _ *tessera.Entry) tessera.IndexFuture {
+			return func() (tessera.Index, error) {
+				if a.err != nil {
+					return tessera.Index{}, a.err
+				}
+				return tessera.Index{Index: a.dupIndex, IsDup: true}, nil
+			}
+		}
+	}
+}
+
+func (a fakeAntispam) Follower(_ func([]byte) ([][]byte, error)) tessera.Follower {
+	return nil
+}
+
+// passThroughAntispam is a tessera.Antispam which never finds duplicates.
+type passThroughAntispam struct{}
+
+func (passThroughAntispam) Decorator() func(tessera.AddFn) tessera.AddFn {
+	return func(delegate tessera.AddFn) tessera.AddFn { return delegate }
+}
+
+func (passThroughAntispam) Follower(_ func([]byte) ([][]byte, error)) tessera.Follower {
+	return nil
+}
+
+func TestFailOpenAntispam(t *testing.T) {
+	errDB := errors.New("antispam database unavailable")
+	errStorage := errors.New("storage unavailable")
+
+	for _, tc := range []struct {
+		desc       string
+		as         tessera.Antispam
+		storageErr error
+		want       tessera.Index
+		wantErr    error
+	}{
+		{
+			desc: "lookup-fails",
+			as:   fakeAntispam{err: errDB},
+			want: tessera.Index{Index: 42},
+		},
+		{
+			desc:    "pushback",
+			as:      fakeAntispam{err: fmt.Errorf("antispam %w", tessera.ErrPushback)},
+			wantErr: tessera.ErrPushback,
+		},
+		{
+			desc: "duplicate",
+			as:   fakeAntispam{dupIndex: 7},
+			want: tessera.Index{Index: 7, IsDup: true},
+		},
+		{
+			desc: "not-a-duplicate",
+			as:   passThroughAntispam{},
+			want: tessera.Index{Index: 42},
+		},
+		{
+			desc:       "storage-fails",
+			as:         passThroughAntispam{},
+			storageErr: errStorage,
+			wantErr:    errStorage,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			sequenced := 0
+			delegate := func(_ context.Context, _ *tessera.Entry) tessera.IndexFuture {
+				sequenced++
+				return func() (tessera.Index, error) {
+					if tc.storageErr != nil {
+						return tessera.Index{}, tc.storageErr
+					}
+					return tessera.Index{Index: 42}, nil
+				}
+			}
+			add := FailOpenAntispam(tc.as).Decorator()(delegate)
+
+			got, err := add(t.Context(), tessera.NewEntry([]byte("entry")))()
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Add()=%v, want %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Add()=%+v, want %+v", got, tc.want)
+			}
+			if sequenced > 1 {
+				t.Errorf("entry sequenced %d times, want at most once", sequenced)
+			}
+		})
+	}
+}
@@ -19,11 +19,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"path"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
 	"github.com/transparency-dev/tesseract/storage"
 	"k8s.io/klog/v2"
@@ -90,3 +93,29 @@ func (s *IssuersStorage) AddIssuersIfNotExist(ctx context.Context, kv []storage.
 	}
 	return nil
 }
+
+// GetIssuer returns the value stored under key.
+func (s *IssuersStorage) GetIssuer(ctx context.Context, key []byte) ([]byte, error) {
+	objName := s.keyToObjName(key)
+	out, err := s.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(objName),
+	})
+	if err != nil {
+		var nsk *types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, fmt.Errorf("object %q not found in bucket %q: %w", objName, s.bucket, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("failed to read object %q from bucket %q: %v", objName, s.bucket, err)
+	}
+	defer func() {
+		if err := out.Body.Close(); err != nil {
+			klog.Warningf("GetIssuer: failed to close body of %q: %v", objName, err)
+		}
+	}()
+	v, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %q from bucket %q: %v", objName, s.bucket, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/transparency-dev/tessera"
+	"github.com/transparency-dev/tessera/ctonly"
+)
+
+// coalescer buffers entries, and hands them to an add function together, so
+// that they are sequenced in the same Tessera append cycle.
+//
+// Buffered entries are handed over together, once maxAge has elapsed since
+// the first of them was buffered, or as soon as maxSize entries are buffered
+// if maxSize is positive. They are handed over concurrently: Tessera's add
+// function can block, for instance to look entries up in the antispam index,
+// and handing them over one at a time would spread them over several append
+// cycles. Entries of a batch might therefore be sequenced in any order. Each
+// caller still gets the index assigned to its own entry.
+type coalescer struct {
+	add     func(context.Context, *ctonly.Entry) tessera.IndexFuture
+	maxSize int
+	maxAge  time.Duration
+
+	mu      sync.Mutex
+	pending []*pendingAdd
+	timer   *time.Timer
+}
+
+// pendingAdd is an entry buffered by a coalescer.
+type pendingAdd struct {
+	ctx   context.Context
+	entry *ctonly.Entry
+	// done is closed once entry has been handed to the add function, which
+	// returned future.
+	done   chan struct{}
+	future tessera.IndexFuture
+}
+
+// newCoalescer returns a coalescer handing entries to add.
+func newCoalescer(add func(context.Context, *ctonly.Entry) tessera.IndexFuture, maxSize int, maxAge time.Duration) *coalescer {
+	return &coalescer{add: add, maxSize: maxSize, maxAge: maxAge}
+}
+
+// Add buffers e, and returns a future resolving to the index assigned to e.
+func (c *coalescer) Add(ctx context.Context, e *ctonly.Entry) tessera.IndexFuture {
+	p := &pendingAdd{ctx: ctx, entry: e, done: make(chan struct{})}
+	c.mu.Lock()
+	c.pending = append(c.pending, p)
+	switch {
+	case c.maxSize > 0 && len(c.pending) >= c.maxSize:
+		batch := c.takeLocked()
+		c.mu.Unlock()
+		c.handOver(batch)
+	case len(c.pending) == 1:
+		c.timer = time.AfterFunc(c.maxAge, c.flush)
+		c.mu.Unlock()
+	default:
+		c.mu.Unlock()
+	}
+	return func() (tessera.Index, error) {
+		select {
+		case <-p.done:
+			return p.future()
+		case <-ctx.Done():
+			return tessera.Index{}, ctx.Err()
+		}
+	}
+}
+
+// flush hands the buffered entries over.
+func (c *coalescer) flush() {
+	c.mu.Lock()
+	batch := c.takeLocked()
+	c.mu.Unlock()
+	c.handOver(batch)
+}
+
+// takeLocked returns the buffered entries, and empties the buffer.
+// c.mu must be held.
+func (c *coalescer) takeLocked() []*pendingAdd {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	batch := c.pending
+	c.pending = nil
+	return batch
+}
+
+// handOver hands the entries of batch to the add function concurrently, and
+// releases each caller once its entry has been handed over.
+func (c *coalescer) handOver(batch []*pendingAdd) {
+	for _, p := range batch {
+		go func() {
+			p.future = c.add(p.ctx, p.entry)
+			close(p.done)
+		}()
+	}
+}
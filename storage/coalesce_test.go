@@ -0,0 +1,205 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/transparency-dev/tessera"
+	"github.com/transparency-dev/tessera/ctonly"
+)
+
+// fakeAppender sequences entries in the order they are added.
+type fakeAppender struct {
+	mu      sync.Mutex
+	entries []*ctonly.Entry
+}
+
+func (a *fakeAppender) add(_ context.Context, e *ctonly.Entry) tessera.IndexFuture {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	idx := uint64(len(a.entries))
+	a.entries = append(a.entries, e)
+	return func() (tessera.Index, error) {
+		return tessera.Index{Index: idx}, nil
+	}
+}
+
+func (a *fakeAppender) len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.entries)
+}
+
+// checkFutures resolves futures, and checks that each of them resolves to
+// the index of the matching entry in a.
+func (a *fakeAppender) checkFutures(t *testing.T, entries []*ctonly.Entry, futures []tessera.IndexFuture) {
+	t.Helper()
+	for i, f := range futures {
+		idx, err := f()
+		if err != nil {
+			t.Fatalf("future %d: %v", i, err)
+		}
+		a.mu.Lock()
+		got := a.entries[idx.Index]
+		a.mu.Unlock()
+		if got != entries[i] {
+			t.Errorf("future %d resolved to index %d, which holds another entry", i, idx.Index)
+		}
+	}
+}
+
+func newTestEntry(i int) *ctonly.Entry {
+	return &ctonly.Entry{Certificate: fmt.Appendf(nil, "cert-%d", i)}
+}
+
+func TestCoalescerMaxSize(t *testing.T) {
+	const maxSize = 10
+	a := &fakeAppender{}
+	c := newCoalescer(a.add, maxSize, time.Hour)
+
+	var entries []*ctonly.Entry
+	var futures []tessera.IndexFuture
+	for i := range maxSize {
+		if i == maxSize-1 {
+			if got := a.len(); got != 0 {
+				t.Fatalf("%d entries sequenced before the batch is full, want 0", got)
+			}
+		}
+		entries = append(entries, newTestEntry(i))
+		futures = append(futures, c.Add(t.Context(), entries[i]))
+	}
+	a.checkFutures(t, entries, futures)
+	if got, want := a.len(), maxSize; got != want {
+		t.Fatalf("%d entries sequenced once the batch is full, want %d", got, want)
+	}
+}
+
+func TestCoalescerMaxAge(t *testing.T) {
+	const maxAge = 50 * time.Millisecond
+	a := &fakeAppender{}
+	c := newCoalescer(a.add, 0, maxAge)
+
+	start := time.Now()
+	var entries []*ctonly.Entry
+	var futures []tessera.IndexFuture
+	for i := range 5 {
+		entries = append(entries, newTestEntry(i))
+		futures = append(futures, c.Add(t.Context(), entries[i]))
+	}
+	a.checkFutures(t, entries, futures)
+	if elapsed := time.Since(start); elapsed < maxAge {
+		t.Errorf("entries sequenced after %v, want at least %v", elapsed, maxAge)
+	}
+	if got, want := a.len(), len(futures); got != want {
+		t.Errorf("%d entries sequenced, want %d", got, want)
+	}
+}
+
+func TestCoalescerContextCancelled(t *testing.T) {
+	a := &fakeAppender{}
+	c := newCoalescer(a.add, 0, time.Hour)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	f := c.Add(ctx, newTestEntry(0))
+	cancel()
+	if _, err := f(); err != context.Canceled {
+		t.Errorf("future()=%v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCoalescerConcurrency(t *testing.T) {
+	const maxSize = 16
+	const numAdds = 64 * maxSize
+	a := &fakeAppender{}
+	// Entries are only handed over in full batches, since maxAge is never
+	// reached.
+	c := newCoalescer(a.add, maxSize, time.Hour)
+
+	entries := make([]*ctonly.Entry, numAdds)
+	indices := make([]uint64, numAdds)
+	var wg sync.WaitGroup
+	for i := range numAdds {
+		entries[i] = newTestEntry(i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			idx, err := c.Add(t.Context(), entries[i])()
+			if err != nil {
+				t.Errorf("future %d: %v", i, err)
+				return
+			}
+			indices[i] = idx.Index
+		}()
+	}
+	wg.Wait()
+
+	if got, want := a.len(), numAdds; got != want {
+		t.Fatalf("%d entries sequenced, want %d", got, want)
+	}
+	// Each caller must get the distinct index of its own entry.
+	seen := make(map[uint64]bool)
+	for i, idx := range indices {
+		if seen[idx] {
+			t.Errorf("index %d assigned more than once", idx)
+		}
+		seen[idx] = true
+		if a.entries[idx] != entries[i] {
+			t.Errorf("entry %d got index %d, which holds another entry", i, idx)
+		}
+	}
+}
+
+// slowAppender is a fakeAppender whose add function blocks for lookupDelay
+// before sequencing entries, like Tessera's does while looking entries up in
+// the antispam index.
+type slowAppender struct {
+	fakeAppender
+	lookupDelay time.Duration
+}
+
+func (a *slowAppender) add(ctx context.Context, e *ctonly.Entry) tessera.IndexFuture {
+	time.Sleep(a.lookupDelay)
+	return a.fakeAppender.add(ctx, e)
+}
+
+func BenchmarkCoalescer(b *testing.B) {
+	const batchSize = 64
+	for _, coalesce := range []bool{false, true} {
+		b.Run(fmt.Sprintf("coalesce=%t", coalesce), func(b *testing.B) {
+			a := &slowAppender{lookupDelay: time.Millisecond}
+			add := a.add
+			if coalesce {
+				add = newCoalescer(a.add, batchSize, time.Hour).Add
+			}
+			futures := make([]tessera.IndexFuture, batchSize)
+			for b.Loop() {
+				// Without the coalescer, each add blocks on its lookup.
+				for i := range batchSize {
+					futures[i] = add(b.Context(), newTestEntry(i))
+				}
+				for _, f := range futures {
+					if _, err := f(); err != nil {
+						b.Fatalf("future: %v", err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -16,8 +16,11 @@ package gcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"path"
 
 	gcs "cloud.google.com/go/storage"
@@ -89,3 +92,25 @@ func (s *IssuersStorage) AddIssuersIfNotExist(ctx context.Context, kv []storage.
 	}
 	return nil
 }
+
+// GetIssuer returns the value stored under key.
+func (s *IssuersStorage) GetIssuer(ctx context.Context, key []byte) ([]byte, error) {
+	objName := s.keyToObjName(key)
+	r, err := s.bucket.Object(objName).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, gcs.ErrObjectNotExist) {
+			return nil, fmt.Errorf("object %q not found in bucket %q: %w", objName, s.bucket.BucketName(), os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("failed to read object %q from bucket %q: %v", objName, s.bucket.BucketName(), err)
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			klog.Warningf("GetIssuer: failed to close reader on %q: %v", objName, err)
+		}
+	}()
+	v, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %q from bucket %q: %v", objName, s.bucket.BucketName(), err)
+	}
+	return v, nil
+}
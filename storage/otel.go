@@ -15,11 +15,60 @@
 package storage
 
 import (
+	"context"
+
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"k8s.io/klog/v2"
 )
 
 const name = "github.com/transparency-dev/tesseract/storage"
 
 var (
+	meter  = otel.Meter(name)
 	tracer = otel.Tracer(name)
 )
+
+var (
+	backendKey = attribute.Key("tesseract.storage.backend")
+)
+
+// Backends called by CTStorage, as exposed in metrics.
+const (
+	// backendTesseraAdd is the Tessera appender, including its antispam
+	// lookups.
+	backendTesseraAdd = "tessera_add"
+	// backendDedupRead reads back the entries that duplicate submissions.
+	backendDedupRead = "dedup_read"
+	// backendIssuerWrite stores issuer certificates.
+	backendIssuerWrite = "issuer_write"
+)
+
+var (
+	backendCalls = mustCreate(meter.Int64Counter("tesseract.storage.backend.calls",
+		metric.WithDescription("Calls to storage backends"),
+		metric.WithUnit("{call}")))
+	backendErrors = mustCreate(meter.Int64Counter("tesseract.storage.backend.errors",
+		metric.WithDescription("Failed calls to storage backends"),
+		metric.WithUnit("{call}")))
+	dedupCollisions = mustCreate(meter.Int64Counter("tesseract.storage.dedup.collisions",
+		metric.WithDescription("Duplicate entries whose stored entry has a different identity"),
+		metric.WithUnit("{entry}")))
+)
+
+// recordBackendCall records a call to backend, which failed if err is not nil.
+func recordBackendCall(ctx context.Context, backend string, err error) {
+	attrs := metric.WithAttributes(backendKey.String(backend))
+	backendCalls.Add(ctx, 1, attrs)
+	if err != nil {
+		backendErrors.Add(ctx, 1, attrs)
+	}
+}
+
+func mustCreate[T any](t T, err error) T {
+	if err != nil {
+		klog.Exit(err.Error())
+	}
+	return t
+}
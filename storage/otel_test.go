@@ -0,0 +1,61 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// testMetricReader returns a reader for the metrics recorded by this package.
+//
+// Instruments created from the global meter provider are only delegated to the
+// first provider that is set, so this reader is shared by all tests.
+var testMetricReader = sync.OnceValue(func() sdkmetric.Reader {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	return reader
+})
+
+// counterValue returns the value of the named int64 counter for backend, or
+// without a backend if backend is empty, or 0 if it has not been recorded.
+func counterValue(t *testing.T, reader sdkmetric.Reader, name, backend string) int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(t.Context(), &rm); err != nil {
+		t.Fatalf("Collect()=%v", err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			s, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				t.Fatalf("metric %q has unexpected type %T", name, m.Data)
+			}
+			for _, dp := range s.DataPoints {
+				if v, ok := dp.Attributes.Value(backendKey); ok && v.AsString() == backend || !ok && backend == "" {
+					return dp.Value
+				}
+			}
+		}
+	}
+	return 0
+}
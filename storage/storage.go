@@ -23,8 +23,10 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/transparency-dev/tesseract/internal/types/staticct"
@@ -36,13 +38,46 @@ import (
 )
 
 // CreateStorage instantiates a Tessera storage implementation with a signer option.
-type CreateStorage func(context.Context, note.Signer) (*CTStorage, error)
+// Checkpoints are also signed by additionalSigners, if any, for instance to
+// publish checkpoints signed by both the old and new keys while rotating the
+// checkpoint signing key. All signers must have the same name.
+type CreateStorage func(ctx context.Context, signer note.Signer, additionalSigners ...note.Signer) (*CTStorage, error)
+
+// maxCreateBackoff caps the backoff between attempts of RetryCreateStorage.
+const maxCreateBackoff = time.Minute
+
+// RetryCreateStorage returns a CreateStorage which calls cs up to attempts
+// times until it succeeds, for backends which may not be ready yet when the
+// log starts, such as network filesystems or object stores. It waits for
+// backoff after the first failure, and doubles the wait after each following
+// one, up to a minute.
+func RetryCreateStorage(cs CreateStorage, attempts int, backoff time.Duration) CreateStorage {
+	return func(ctx context.Context, signer note.Signer, additionalSigners ...note.Signer) (*CTStorage, error) {
+		for i := 1; ; i++ {
+			s, err := cs(ctx, signer, additionalSigners...)
+			if err == nil || i >= attempts {
+				return s, err
+			}
+			klog.Warningf("Failed to create storage, attempt %d/%d, retrying in %v: %v", i, attempts, backoff, err)
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("failed to create storage: %v, gave up: %w", err, ctx.Err())
+			case <-time.After(backoff):
+			}
+			backoff = min(2*backoff, maxCreateBackoff)
+		}
+	}
+}
 
 const (
 	// Each key is 64 bytes long, so this will take up to 64MB.
 	// A CT log references ~15k unique issuer certifiates in 2024, so this gives plenty of space
 	// if we ever run into this limit, we should re-think how it works.
 	maxCachedIssuerKeys = 1 << 20
+
+	// dedupCacheSize is the number of slots of the dedup cache. Each one
+	// takes about 56 bytes, so this takes up to about 4MB.
+	dedupCacheSize = 1 << 16
 )
 
 type KV struct {
@@ -53,34 +88,229 @@ type KV struct {
 // IssuerStorage issuer certificates under their hex encoded sha256.
 type IssuerStorage interface {
 	AddIssuersIfNotExist(ctx context.Context, kv []KV) error
+	// GetIssuer returns the value stored under key. It returns an error
+	// wrapping os.ErrNotExist if there is none.
+	GetIssuer(ctx context.Context, key []byte) ([]byte, error)
 }
 
 // CTStorage implements ct.Storage and tessera.LogReader.
 type CTStorage struct {
 	storeData    func(context.Context, *ctonly.Entry) tessera.IndexFuture
 	storeIssuers func(context.Context, []KV) error
+	issuers      IssuerStorage
 	reader       tessera.LogReader
 	awaiter      *tessera.PublicationAwaiter
+	backends     Backends
+	// dedupSlots holds a token for each duplicate entry being read back, if
+	// CTStorageOpts.MaxPendingDedupReads is positive. nil otherwise.
+	dedupSlots chan struct{}
+	// dedupCollisions counts the duplicate entries whose stored entry turned
+	// out to be different, see CTStorageOpts.DedupCollisionAlertThreshold.
+	dedupCollisions              atomic.Int64
+	dedupCollisionAlertThreshold int
+	// dedupCache holds the identity and timestamp of recently added or
+	// deduplicated entries, so that duplicates of these entries don't need
+	// to be read back from the log.
+	dedupCache *dedupCache
+}
+
+// dedupCacheEntry is the identity and timestamp of the entry at index.
+type dedupCacheEntry struct {
+	index     uint64
+	identity  [sha256.Size]byte
+	timestamp uint64
+	ok        bool
+}
+
+// dedupCache is a fixed size cache of dedupCacheEntry, where the entry at an
+// index may only be cached in slot index%dedupCacheSize, and overwrites the
+// entry previously cached in that slot, if any.
+type dedupCache struct {
+	mu    sync.Mutex
+	slots [dedupCacheSize]dedupCacheEntry
+}
+
+// get returns the cached entry at index, if any.
+func (c *dedupCache) get(index uint64) (dedupCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.slots[index%dedupCacheSize]
+	return e, e.ok && e.index == index
+}
+
+// add caches the identity and timestamp of the entry at index.
+func (c *dedupCache) add(index uint64, identity []byte, timestamp uint64) {
+	e := dedupCacheEntry{index: index, timestamp: timestamp, ok: true}
+	copy(e.identity[:], identity)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.slots[index%dedupCacheSize] = e
+}
+
+// Backends describes the concrete storage backends behind a CTStorage, so
+// that operators can check which ones were wired. Known backends have stable
+// names, such as "posix", "gcs" or "badger", see backendNames. Other
+// backends are described by their Go type.
+type Backends struct {
+	// Log is the name of the Tessera log reader, which depends on the
+	// Tessera storage driver.
+	Log string
+	// Issuers is the name of the IssuerStorage.
+	Issuers string
+	// Antispam is the name of CTStorageOpts.Antispam, or "none" if unset.
+	Antispam string
+}
+
+// backendNames maps the import paths of the packages implementing known
+// storage backends to their name.
+var backendNames = map[string]string{
+	"github.com/transparency-dev/tessera/storage/posix":                     "posix",
+	"github.com/transparency-dev/tessera/storage/posix/antispam":            "badger",
+	"github.com/transparency-dev/tessera/storage/gcp":                       "gcs",
+	"github.com/transparency-dev/tessera/storage/gcp/antispam":              "spanner",
+	"github.com/transparency-dev/tessera/storage/aws":                       "s3",
+	"github.com/transparency-dev/tessera/storage/aws/antispam":              "mysql",
+	"github.com/transparency-dev/tessera/storage/mysql":                     "mysql",
+	"github.com/transparency-dev/tesseract/storage/gcp":                     "gcs",
+	"github.com/transparency-dev/tesseract/storage/aws":                     "s3",
+	"github.com/transparency-dev/tesseract/internal/testonly/storage/posix": "posix",
+}
+
+// backendName returns the name of the backend implemented by v, or its type
+// if it is not a known backend.
+func backendName(v any) string {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return "none"
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if name, ok := backendNames[t.PkgPath()]; ok {
+		return name
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func (b Backends) String() string {
+	return fmt.Sprintf("log: %s, issuers: %s, antispam: %s", b.Log, b.Issuers, b.Antispam)
+}
+
+// CTStorageOpts holds optional parameters of a CTStorage.
+type CTStorageOpts struct {
+	// IssuerWriteConcurrency is the maximum number of issuer certificates
+	// written to the IssuerStorage in parallel. 0 and 1 write them serially.
+	IssuerWriteConcurrency int
+	// VerifyTreeOnStartup controls if NewCTStorage checks that the latest
+	// checkpoint matches the stored tiles, and fails if they don't.
+	VerifyTreeOnStartup bool
+	// CoalesceMaxAge is how long entries are buffered before being handed
+	// to Tessera together, to be sequenced in the same append cycle.
+	// Leaving this unset, or 0, hands entries over as soon as they are added.
+	CoalesceMaxAge time.Duration
+	// CoalesceMaxSize is the number of buffered entries which are handed
+	// to Tessera without waiting for CoalesceMaxAge. 0 means no limit.
+	CoalesceMaxSize int
+	// Antispam is the antispam given to the Tessera appender, if any. It is
+	// only used to report the storage backends, deduplication happens in
+	// the appender.
+	Antispam tessera.Antispam
+	// MaxPendingDedupReads is the maximum number of duplicate entries
+	// waiting for their original entry to be integrated and read back, so
+	// that their SCT can be reissued. Duplicates added on top of these fail
+	// with an error wrapping tessera.ErrPushback, rather than piling up when
+	// integration lags behind sequencing. 0 means no limit.
+	//
+	// This does not bound writes to the antispam index, which happen in the
+	// background, as the antispam follower catches up with the log. These
+	// are bounded by the antispam implementation: Tessera's antispam
+	// storages return tessera.ErrPushback for all new entries once their
+	// follower is more than their AntispamOpts.PushbackThreshold entries
+	// behind the log.
+	MaxPendingDedupReads int
+	// DedupCollisionAlertThreshold is the number of dedup collisions after
+	// which each further one is logged as an alert, at error level, rather
+	// than as a warning. A dedup collision is a submission found to be a
+	// duplicate of a stored entry which is actually different, which should
+	// never happen, and indicates a bug or a corrupt antispam index. Such
+	// submissions are always rejected rather than given the stored entry's
+	// SCT, and counted in the tesseract.storage.dedup.collisions metric.
+	// Leaving this unset, or 0, alerts on the first collision.
+	DedupCollisionAlertThreshold int
 }
 
 // NewCTStorage instantiates a CTStorage object.
-func NewCTStorage(ctx context.Context, logStorage *tessera.Appender, issuerStorage IssuerStorage, reader tessera.LogReader) (*CTStorage, error) {
+func NewCTStorage(ctx context.Context, logStorage *tessera.Appender, issuerStorage IssuerStorage, reader tessera.LogReader, opts CTStorageOpts) (*CTStorage, error) {
+	if opts.IssuerWriteConcurrency < 0 {
+		return nil, fmt.Errorf("negative IssuerWriteConcurrency: %d", opts.IssuerWriteConcurrency)
+	}
+	if opts.CoalesceMaxAge < 0 {
+		return nil, fmt.Errorf("negative CoalesceMaxAge: %v", opts.CoalesceMaxAge)
+	}
+	if opts.CoalesceMaxSize < 0 {
+		return nil, fmt.Errorf("negative CoalesceMaxSize: %d", opts.CoalesceMaxSize)
+	}
+	if opts.MaxPendingDedupReads < 0 {
+		return nil, fmt.Errorf("negative MaxPendingDedupReads: %d", opts.MaxPendingDedupReads)
+	}
+	if opts.DedupCollisionAlertThreshold < 0 {
+		return nil, fmt.Errorf("negative DedupCollisionAlertThreshold: %d", opts.DedupCollisionAlertThreshold)
+	}
+	if opts.VerifyTreeOnStartup {
+		if err := VerifyTree(ctx, reader); err != nil {
+			return nil, fmt.Errorf("log tree verification failed: %v", err)
+		}
+	}
 	awaiter := tessera.NewPublicationAwaiter(ctx, reader.ReadCheckpoint, 200*time.Millisecond)
+	storeData := tessera.NewCertificateTransparencyAppender(logStorage)
+	if opts.CoalesceMaxAge > 0 {
+		storeData = newCoalescer(storeData, opts.CoalesceMaxSize, opts.CoalesceMaxAge).Add
+	}
 	ctStorage := &CTStorage{
-		storeData:    tessera.NewCertificateTransparencyAppender(logStorage),
-		storeIssuers: cachedStoreIssuers(issuerStorage),
-		reader:       reader,
-		awaiter:      awaiter,
+		storeData:                    storeData,
+		storeIssuers:                 cachedStoreIssuers(issuerStorage, opts.IssuerWriteConcurrency),
+		issuers:                      issuerStorage,
+		reader:                       reader,
+		awaiter:                      awaiter,
+		dedupCollisionAlertThreshold: opts.DedupCollisionAlertThreshold,
+		dedupCache:                   &dedupCache{},
+		backends: Backends{
+			Log:      backendName(reader),
+			Issuers:  backendName(issuerStorage),
+			Antispam: antispamName(opts.Antispam),
+		},
 	}
+	if opts.MaxPendingDedupReads > 0 {
+		ctStorage.dedupSlots = make(chan struct{}, opts.MaxPendingDedupReads)
+	}
+	klog.Infof("Storage backends: %s", ctStorage.backends)
 	return ctStorage, nil
 }
 
+// Backends returns the concrete storage backends behind cts.
+func (cts *CTStorage) Backends() Backends {
+	return cts.backends
+}
+
+// antispamName returns the name of as, or of the Antispam it wraps if it was
+// returned by FailOpenAntispam.
+func antispamName(as tessera.Antispam) string {
+	if as, ok := as.(*failOpenAntispam); ok {
+		return backendName(as.as) + " (fail open)"
+	}
+	return backendName(as)
+}
+
 func (cts *CTStorage) ReadCheckpoint(ctx context.Context) ([]byte, error) {
 	return cts.reader.ReadCheckpoint(ctx)
 }
 
-// TODO(phbnf): cache timestamps (or more) to avoid reparsing the entire leaf bundle
-func (cts *CTStorage) dedupFuture(ctx context.Context, f tessera.IndexFuture) (index, timestamp uint64, err error) {
+// dedupFuture waits for the entry deduplicated by f to be integrated, and
+// returns its index and timestamp.
+//
+// The stored entry is only read back from the log if it is not in the dedup
+// cache, and is then checked to have the same identity as entry.
+func (cts *CTStorage) dedupFuture(ctx context.Context, entry *ctonly.Entry, f tessera.IndexFuture) (index, timestamp uint64, err error) {
 	ctx, span := tracer.Start(ctx, "tesseract.storage.dedupFuture")
 	defer span.End()
 
@@ -89,40 +319,105 @@ func (cts *CTStorage) dedupFuture(ctx context.Context, f tessera.IndexFuture) (i
 		return 0, 0, fmt.Errorf("error waiting for Tessera future and its integration: %v", err)
 	}
 
+	want := entry.Identity()
+	if c, ok := cts.dedupCache.get(idx.Index); ok {
+		if !bytes.Equal(c.identity[:], want) {
+			cts.recordDedupCollision(ctx, idx.Index, c.identity[:], want)
+			return 0, 0, fmt.Errorf("dedup collision: entry %d has identity %x, want %x", idx.Index, c.identity, want)
+		}
+		return idx.Index, c.timestamp, nil
+	}
+
+	e, err := cts.readIntegratedEntry(ctx, idx.Index, cpRaw)
+	if err != nil {
+		return 0, 0, err
+	}
+	stored := staticct.Entry{}
+	if err := stored.UnmarshalText(e); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse entry %d: %v", idx.Index, err)
+	}
+	got := entryIdentity(&stored)
+	cts.dedupCache.add(idx.Index, got, stored.Timestamp)
+	if !bytes.Equal(got, want) {
+		cts.recordDedupCollision(ctx, idx.Index, got, want)
+		return 0, 0, fmt.Errorf("dedup collision: entry %d has identity %x, want %x", idx.Index, got, want)
+	}
+
+	return idx.Index, stored.Timestamp, nil
+}
+
+// entryIdentity returns the identity of a stored entry, which Tessera
+// deduplicates entries by. See ctonly.Entry.Identity.
+func entryIdentity(e *staticct.Entry) []byte {
+	var id [sha256.Size]byte
+	if e.IsPrecert {
+		id = sha256.Sum256(e.Precertificate)
+	} else {
+		id = sha256.Sum256(e.Certificate)
+	}
+	return id[:]
+}
+
+// recordDedupCollision logs and counts a duplicate entry whose stored entry
+// at index has a different identity. It is logged as an alert once there have
+// been more than DedupCollisionAlertThreshold collisions.
+func (cts *CTStorage) recordDedupCollision(ctx context.Context, index uint64, stored, submitted []byte) {
+	dedupCollisions.Add(ctx, 1)
+	if n := cts.dedupCollisions.Add(1); n > int64(cts.dedupCollisionAlertThreshold) {
+		klog.Errorf("ALERT: dedup collision %d, over the threshold of %d, the antispam index may be corrupt: entry %d has identity %x, but was returned for a submission with identity %x", n, cts.dedupCollisionAlertThreshold, index, stored, submitted)
+		return
+	}
+	klog.Warningf("Dedup collision: entry %d has identity %x, but was returned for a submission with identity %x", index, stored, submitted)
+}
+
+// ReadEntry returns the raw static-ct-api entry stored at index.
+//
+// It blocks until the entry has been integrated in the log.
+func (cts *CTStorage) ReadEntry(ctx context.Context, index uint64) ([]byte, error) {
+	ctx, span := tracer.Start(ctx, "tesseract.storage.ReadEntry")
+	defer span.End()
+
+	f := func() (tessera.Index, error) {
+		return tessera.Index{Index: index}, nil
+	}
+	_, cpRaw, err := cts.awaiter.Await(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("error waiting for entry %d to be integrated: %v", index, err)
+	}
+	return cts.readIntegratedEntry(ctx, index, cpRaw)
+}
+
+// readIntegratedEntry returns the raw entry at index, from a log whose
+// checkpoint is cpRaw.
+func (cts *CTStorage) readIntegratedEntry(ctx context.Context, index uint64, cpRaw []byte) ([]byte, error) {
 	// A https://c2sp.org/static-ct-api logsize is on the second line
 	l := bytes.SplitN(cpRaw, []byte("\n"), 3)
 	if len(l) < 2 {
-		return 0, 0, errors.New("invalid checkpoint - no size")
+		return nil, errors.New("invalid checkpoint - no size")
 	}
 	ckptSize, err := strconv.ParseUint(string(l[1]), 10, 64)
 	if err != nil {
-		return 0, 0, fmt.Errorf("invalid checkpoint - can't extract size: %v", err)
+		return nil, fmt.Errorf("invalid checkpoint - can't extract size: %v", err)
 	}
 
-	eBIdx := idx.Index / layout.EntryBundleWidth
+	eBIdx := index / layout.EntryBundleWidth
 	eBRaw, err := cts.reader.ReadEntryBundle(ctx, eBIdx, layout.PartialTileSize(0, eBIdx, ckptSize))
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return 0, 0, fmt.Errorf("leaf bundle at index %d not found: %v", eBIdx, err)
+			return nil, fmt.Errorf("leaf bundle at index %d not found: %v", eBIdx, err)
 		}
-		return 0, 0, fmt.Errorf("failed to fetch entry bundle at index %d: %v", eBIdx, err)
+		return nil, fmt.Errorf("failed to fetch entry bundle at index %d: %v", eBIdx, err)
 	}
 	eb := staticct.EntryBundle{}
 	if err := eb.UnmarshalText(eBRaw); err != nil {
-		return 0, 0, fmt.Errorf("failed to unmarshal entry bundle at index %d: %v", eBIdx, err)
+		return nil, fmt.Errorf("failed to unmarshal entry bundle at index %d: %v", eBIdx, err)
 	}
 
-	eIdx := idx.Index % layout.EntryBundleWidth
+	eIdx := index % layout.EntryBundleWidth
 	if uint64(len(eb.Entries)) <= eIdx {
-		return 0, 0, fmt.Errorf("entry bundle at index %d has only %d entries, but wanted at least %d", eBIdx, eIdx, eBIdx)
+		return nil, fmt.Errorf("entry bundle at index %d has only %d entries, but wanted at least %d", eBIdx, len(eb.Entries), eIdx+1)
 	}
-	e := staticct.Entry{}
-	t, err := staticct.UnmarshalTimestamp([]byte(eb.Entries[eIdx]))
-	if err != nil {
-		return 0, 0, fmt.Errorf("failed to extract timestamp from entry %d in entry bundle %d: %v", eIdx, eBIdx, e)
-	}
-
-	return idx.Index, t, nil
+	return eb.Entries[eIdx], nil
 }
 
 // Add stores CT entries.
@@ -132,12 +427,24 @@ func (cts *CTStorage) Add(ctx context.Context, entry *ctonly.Entry) (uint64, uin
 
 	future := cts.storeData(ctx, entry)
 	idx, err := future()
+	recordBackendCall(ctx, backendTesseraAdd, err)
 	if err != nil {
 		return 0, 0, fmt.Errorf("error waiting for Tessera future: %v", err)
 	}
 	if idx.IsDup {
-		return cts.dedupFuture(ctx, future)
+		if cts.dedupSlots != nil {
+			select {
+			case cts.dedupSlots <- struct{}{}:
+				defer func() { <-cts.dedupSlots }()
+			default:
+				return 0, 0, fmt.Errorf("%d duplicate entries already being read back: %w", cap(cts.dedupSlots), tessera.ErrPushback)
+			}
+		}
+		index, timestamp, err := cts.dedupFuture(ctx, entry, future)
+		recordBackendCall(ctx, backendDedupRead, err)
+		return index, timestamp, err
 	}
+	cts.dedupCache.add(idx.Index, entry.Identity(), entry.Timestamp)
 	return idx.Index, entry.Timestamp, nil
 
 }
@@ -155,17 +462,29 @@ func (cts *CTStorage) AddIssuerChain(ctx context.Context, chain []*x509.Certific
 		key := []byte(hex.EncodeToString(id[:]))
 		kvs = append(kvs, KV{K: key, V: c.Raw})
 	}
-	if err := cts.storeIssuers(ctx, kvs); err != nil {
+	err := cts.storeIssuers(ctx, kvs)
+	recordBackendCall(ctx, backendIssuerWrite, err)
+	if err != nil {
 		return fmt.Errorf("error storing intermediates: %v", err)
 	}
 	return nil
 }
 
+// ReadIssuer returns the issuer certificate stored under its SHA-256
+// fingerprint. It returns an error wrapping os.ErrNotExist if there is none.
+func (cts *CTStorage) ReadIssuer(ctx context.Context, fingerprint []byte) ([]byte, error) {
+	ctx, span := tracer.Start(ctx, "tesseract.storage.ReadIssuer")
+	defer span.End()
+
+	return cts.issuers.GetIssuer(ctx, []byte(hex.EncodeToString(fingerprint)))
+}
+
 // cachedStoreIssuers returns a caching wrapper for an IssuerStorage
 //
 // This is intended to make querying faster. It does not keep a copy of the certs, only sha256.
 // Only up to maxCachedIssuerKeys keys will be stored locally.
-func cachedStoreIssuers(s IssuerStorage) func(context.Context, []KV) error {
+// Up to concurrency issuers are written to s in parallel.
+func cachedStoreIssuers(s IssuerStorage, concurrency int) func(context.Context, []KV) error {
 	var mu sync.RWMutex
 	m := make(map[string]struct{})
 	return func(ctx context.Context, kv []KV) error {
@@ -180,7 +499,7 @@ func cachedStoreIssuers(s IssuerStorage) func(context.Context, []KV) error {
 			}
 			req = append(req, kv)
 		}
-		if err := s.AddIssuersIfNotExist(ctx, req); err != nil {
+		if err := addIssuers(ctx, s, req, concurrency); err != nil {
 			return fmt.Errorf("AddIssuersIfNotExist()s: error storing issuer data in the underlying IssuerStorage: %v", err)
 		}
 		for _, kv := range req {
@@ -195,3 +514,27 @@ func cachedStoreIssuers(s IssuerStorage) func(context.Context, []KV) error {
 		return nil
 	}
 }
+
+// addIssuers stores kvs in s, with up to concurrency parallel writes of a
+// single issuer each. Every write is attempted, and their errors are joined.
+func addIssuers(ctx context.Context, s IssuerStorage, kvs []KV, concurrency int) error {
+	if concurrency <= 1 || len(kvs) <= 1 {
+		return s.AddIssuersIfNotExist(ctx, kvs)
+	}
+	errs := make([]error, len(kvs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, kv := range kvs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := s.AddIssuersIfNotExist(ctx, []KV{kv}); err != nil {
+				errs[i] = fmt.Errorf("%s: %v", kv.K, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,549 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/transparency-dev/tessera"
+	"github.com/transparency-dev/tessera/api/layout"
+	"github.com/transparency-dev/tessera/ctonly"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// fakeIssuerStorage is an in-memory IssuerStorage, which records the maximum
+// number of concurrent writes, and fails writes to keys in fail.
+//
+// If release is set, writes are held until barrier of them are in flight, at
+// which point release is closed.
+type fakeIssuerStorage struct {
+	fail    map[string]bool
+	barrier int
+	release chan struct{}
+
+	mu          sync.Mutex
+	kvs         map[string][]byte
+	inflight    int
+	maxInflight int
+	released    bool
+}
+
+func (s *fakeIssuerStorage) AddIssuersIfNotExist(ctx context.Context, kvs []KV) error {
+	s.mu.Lock()
+	s.inflight++
+	s.maxInflight = max(s.maxInflight, s.inflight)
+	if s.release != nil && s.inflight == s.barrier && !s.released {
+		close(s.release)
+		s.released = true
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inflight--
+		s.mu.Unlock()
+	}()
+
+	if s.release != nil {
+		select {
+		case <-s.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	for _, kv := range kvs {
+		if s.fail[string(kv.K)] {
+			return errors.New("boom")
+		}
+		s.mu.Lock()
+		s.kvs[string(kv.K)] = kv.V
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+func (s *fakeIssuerStorage) GetIssuer(_ context.Context, key []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.kvs[string(key)]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, os.ErrNotExist)
+	}
+	return v, nil
+}
+
+func TestCachedStoreIssuersConcurrency(t *testing.T) {
+	var kvs []KV
+	for i := range 5 {
+		kvs = append(kvs, KV{K: fmt.Appendf(nil, "intermediate-%d", i), V: fmt.Appendf(nil, "der-%d", i)})
+	}
+
+	for _, tc := range []struct {
+		desc            string
+		concurrency     int
+		fail            []string
+		wantMaxInflight int
+		wantErrs        []string
+	}{
+		{
+			desc:            "serial",
+			concurrency:     0,
+			wantMaxInflight: 1,
+		},
+		{
+			desc:            "parallel",
+			concurrency:     3,
+			wantMaxInflight: 3,
+		},
+		{
+			desc:            "more-workers-than-issuers",
+			concurrency:     10,
+			wantMaxInflight: len(kvs),
+		},
+		{
+			desc:            "errors",
+			concurrency:     3,
+			fail:            []string{"intermediate-1", "intermediate-3"},
+			wantMaxInflight: 3,
+			wantErrs:        []string{"intermediate-1", "intermediate-3"},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			// Hold writes until the expected number of them are in flight,
+			// so that none completes before the others start.
+			s := &fakeIssuerStorage{fail: map[string]bool{}, kvs: map[string][]byte{}, barrier: tc.wantMaxInflight, release: make(chan struct{})}
+			for _, k := range tc.fail {
+				s.fail[k] = true
+			}
+			ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+			defer cancel()
+			err := cachedStoreIssuers(s, tc.concurrency)(ctx, kvs)
+
+			if len(tc.wantErrs) == 0 && err != nil {
+				t.Fatalf("storeIssuers()=%v, want nil", err)
+			}
+			for _, want := range tc.wantErrs {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("storeIssuers()=%v, want err containing %q", err, want)
+				}
+			}
+			if got, want := s.maxInflight, tc.wantMaxInflight; got != want {
+				t.Errorf("max concurrent writes=%d, want %d", got, want)
+			}
+			// Issuers that didn't fail must have been written, even if others failed.
+			for _, kv := range kvs {
+				_, written := s.kvs[string(kv.K)]
+				if want := !s.fail[string(kv.K)]; written != want {
+					t.Errorf("%s written: %t, want %t", kv.K, written, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBackendErrorMetrics(t *testing.T) {
+	reader := testMetricReader()
+	errBackend := errors.New("backend unavailable")
+	chain := []*x509.Certificate{{Raw: []byte("intermediate")}}
+
+	for _, tc := range []struct {
+		desc    string
+		cts     *CTStorage
+		call    func(*CTStorage) error
+		backend string
+	}{
+		{
+			desc: "tessera-add",
+			cts: &CTStorage{
+				storeData: func(context.Context, *ctonly.Entry) tessera.IndexFuture {
+					return func() (tessera.Index, error) { return tessera.Index{}, errBackend }
+				},
+			},
+			call: func(cts *CTStorage) error {
+				_, _, err := cts.Add(t.Context(), &ctonly.Entry{})
+				return err
+			},
+			backend: backendTesseraAdd,
+		},
+		{
+			desc: "dedup-read",
+			cts: &CTStorage{
+				storeData: func(context.Context, *ctonly.Entry) tessera.IndexFuture {
+					return func() (tessera.Index, error) { return tessera.Index{Index: 1, IsDup: true}, nil }
+				},
+				awaiter: tessera.NewPublicationAwaiter(t.Context(), func(context.Context) ([]byte, error) {
+					return nil, errBackend
+				}, time.Millisecond),
+			},
+			call: func(cts *CTStorage) error {
+				_, _, err := cts.Add(t.Context(), &ctonly.Entry{})
+				return err
+			},
+			backend: backendDedupRead,
+		},
+		{
+			desc: "issuer-write",
+			cts: &CTStorage{
+				storeIssuers: func(context.Context, []KV) error { return errBackend },
+			},
+			call: func(cts *CTStorage) error {
+				return cts.AddIssuerChain(t.Context(), chain)
+			},
+			backend: backendIssuerWrite,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			calls := counterValue(t, reader, "tesseract.storage.backend.calls", tc.backend)
+			errs := counterValue(t, reader, "tesseract.storage.backend.errors", tc.backend)
+
+			if err := tc.call(tc.cts); err == nil {
+				t.Fatal("call to failing backend succeeded, want error")
+			}
+
+			if got, want := counterValue(t, reader, "tesseract.storage.backend.calls", tc.backend), calls+1; got != want {
+				t.Errorf("%s calls=%d, want %d", tc.backend, got, want)
+			}
+			if got, want := counterValue(t, reader, "tesseract.storage.backend.errors", tc.backend), errs+1; got != want {
+				t.Errorf("%s errors=%d, want %d", tc.backend, got, want)
+			}
+		})
+	}
+}
+
+func TestBackends(t *testing.T) {
+	r := newFakeLogReader(t, 0)
+	issuers := &fakeIssuerStorage{}
+
+	for _, tc := range []struct {
+		desc string
+		as   tessera.Antispam
+		want Backends
+	}{
+		{
+			desc: "no-antispam",
+			want: Backends{Log: "*storage.fakeLogReader", Issuers: "*storage.fakeIssuerStorage", Antispam: "none"},
+		},
+		{
+			desc: "antispam",
+			as:   passThroughAntispam{},
+			want: Backends{Log: "*storage.fakeLogReader", Issuers: "*storage.fakeIssuerStorage", Antispam: "storage.passThroughAntispam"},
+		},
+		{
+			desc: "fail-open-antispam",
+			as:   FailOpenAntispam(passThroughAntispam{}),
+			want: Backends{Log: "*storage.fakeLogReader", Issuers: "*storage.fakeIssuerStorage", Antispam: "storage.passThroughAntispam (fail open)"},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			cts, err := NewCTStorage(t.Context(), nil, issuers, r, CTStorageOpts{Antispam: tc.as})
+			if err != nil {
+				t.Fatalf("NewCTStorage()=%v", err)
+			}
+			if got := cts.Backends(); got != tc.want {
+				t.Errorf("Backends()=%+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMaxPendingDedupReads(t *testing.T) {
+	const maxPending = 2
+	// The log is empty and never grows, so duplicates stay pending until
+	// their context is done.
+	cts, err := NewCTStorage(t.Context(), nil, &fakeIssuerStorage{}, newFakeLogReader(t, 0), CTStorageOpts{MaxPendingDedupReads: maxPending})
+	if err != nil {
+		t.Fatalf("NewCTStorage()=%v", err)
+	}
+	cts.storeData = func(context.Context, *ctonly.Entry) tessera.IndexFuture {
+		return func() (tessera.Index, error) { return tessera.Index{IsDup: true}, nil }
+	}
+
+	// Saturate pending duplicates.
+	ctx, cancel := context.WithCancel(t.Context())
+	errs := make(chan error, maxPending)
+	for range maxPending {
+		go func() {
+			_, _, err := cts.Add(ctx, &ctonly.Entry{})
+			errs <- err
+		}()
+	}
+	for len(cts.dedupSlots) < maxPending {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, _, err := cts.Add(t.Context(), &ctonly.Entry{}); !errors.Is(err, tessera.ErrPushback) {
+		t.Errorf("Add()=%v with %d pending duplicates, want %v", err, maxPending, tessera.ErrPushback)
+	}
+
+	// Duplicates release their slot once they're done waiting.
+	cancel()
+	for range maxPending {
+		if err := <-errs; err == nil || errors.Is(err, tessera.ErrPushback) {
+			t.Errorf("pending Add()=%v, want context error", err)
+		}
+	}
+	ctx, cancel = context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := cts.Add(ctx, &ctonly.Entry{}); err == nil || errors.Is(err, tessera.ErrPushback) {
+		t.Errorf("Add()=%v after pending duplicates were done, want context error", err)
+	}
+}
+
+func TestDedupCollision(t *testing.T) {
+	reader := testMetricReader()
+	stored := &ctonly.Entry{Timestamp: 1234, Certificate: []byte("stored certificate")}
+	r := newFakeLogReader(t, 1)
+	r.bundles = map[uint64][]byte{0: stored.LeafData(0)}
+
+	for _, tc := range []struct {
+		desc          string
+		entry         *ctonly.Entry
+		wantCollision bool
+	}{
+		{
+			desc:  "duplicate",
+			entry: &ctonly.Entry{Timestamp: 5678, Certificate: []byte("stored certificate")},
+		},
+		{
+			desc:          "different-certificate",
+			entry:         &ctonly.Entry{Timestamp: 5678, Certificate: []byte("other certificate")},
+			wantCollision: true,
+		},
+		{
+			desc:          "precertificate",
+			entry:         &ctonly.Entry{Timestamp: 5678, IsPrecert: true, Certificate: []byte("stored certificate"), Precertificate: []byte("precertificate")},
+			wantCollision: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			cts, err := NewCTStorage(t.Context(), nil, &fakeIssuerStorage{}, r, CTStorageOpts{})
+			if err != nil {
+				t.Fatalf("NewCTStorage()=%v", err)
+			}
+			// Deduplicate every entry to the stored one.
+			cts.storeData = func(context.Context, *ctonly.Entry) tessera.IndexFuture {
+				return func() (tessera.Index, error) { return tessera.Index{Index: 0, IsDup: true}, nil }
+			}
+			collisions := counterValue(t, reader, "tesseract.storage.dedup.collisions", "")
+
+			index, timestamp, err := cts.Add(t.Context(), tc.entry)
+			if tc.wantCollision {
+				if err == nil || !strings.Contains(err.Error(), "dedup collision") {
+					t.Errorf("Add()=%d, %d, %v, want dedup collision error", index, timestamp, err)
+				}
+			} else if err != nil || index != 0 || timestamp != stored.Timestamp {
+				t.Errorf("Add()=%d, %d, %v, want 0, %d, nil", index, timestamp, err, stored.Timestamp)
+			}
+
+			want := int64(0)
+			if tc.wantCollision {
+				want = 1
+			}
+			if got := counterValue(t, reader, "tesseract.storage.dedup.collisions", "") - collisions; got != want {
+				t.Errorf("dedup collisions metric increased by %d, want %d", got, want)
+			}
+			if got := cts.dedupCollisions.Load(); got != want {
+				t.Errorf("dedup collisions=%d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestDedupCache(t *testing.T) {
+	stored := &ctonly.Entry{Timestamp: 1234, Certificate: []byte("stored certificate")}
+	r := newFakeLogReader(t, 2)
+	r.bundles = map[uint64][]byte{0: append(stored.LeafData(0), stored.LeafData(1)...)}
+	cts, err := NewCTStorage(t.Context(), nil, &fakeIssuerStorage{}, r, CTStorageOpts{})
+	if err != nil {
+		t.Fatalf("NewCTStorage()=%v", err)
+	}
+	next := tessera.Index{Index: 1}
+	cts.storeData = func(context.Context, *ctonly.Entry) tessera.IndexFuture {
+		return func() (tessera.Index, error) { return next, nil }
+	}
+
+	for _, tc := range []struct {
+		desc          string
+		idx           tessera.Index
+		entry         *ctonly.Entry
+		wantTimestamp uint64
+		wantErr       bool
+		wantReads     int64
+	}{
+		{
+			desc:          "duplicate-read-back",
+			idx:           tessera.Index{Index: 0, IsDup: true},
+			entry:         &ctonly.Entry{Timestamp: 5678, Certificate: []byte("stored certificate")},
+			wantTimestamp: 1234,
+			wantReads:     1,
+		},
+		{
+			desc:          "duplicate-cached-on-read",
+			idx:           tessera.Index{Index: 0, IsDup: true},
+			entry:         &ctonly.Entry{Timestamp: 5678, Certificate: []byte("stored certificate")},
+			wantTimestamp: 1234,
+		},
+		{
+			desc:    "collision-cached-on-read",
+			idx:     tessera.Index{Index: 0, IsDup: true},
+			entry:   &ctonly.Entry{Timestamp: 5678, Certificate: []byte("other certificate")},
+			wantErr: true,
+		},
+		{
+			desc:          "new",
+			idx:           tessera.Index{Index: 1},
+			entry:         &ctonly.Entry{Timestamp: 4321, Certificate: []byte("new certificate")},
+			wantTimestamp: 4321,
+		},
+		{
+			desc:          "duplicate-cached-on-add",
+			idx:           tessera.Index{Index: 1, IsDup: true},
+			entry:         &ctonly.Entry{Timestamp: 5678, Certificate: []byte("new certificate")},
+			wantTimestamp: 4321,
+		},
+		{
+			desc:    "collision-cached-on-add",
+			idx:     tessera.Index{Index: 1, IsDup: true},
+			entry:   &ctonly.Entry{Timestamp: 5678, Certificate: []byte("stored certificate")},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			next = tc.idx
+			reads := r.bundleReads.Load()
+			index, timestamp, err := cts.Add(t.Context(), tc.entry)
+			if tc.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "dedup collision") {
+					t.Errorf("Add()=%d, %d, %v, want dedup collision error", index, timestamp, err)
+				}
+			} else if err != nil || index != tc.idx.Index || timestamp != tc.wantTimestamp {
+				t.Errorf("Add()=%d, %d, %v, want %d, %d, nil", index, timestamp, err, tc.idx.Index, tc.wantTimestamp)
+			}
+			if got := r.bundleReads.Load() - reads; got != tc.wantReads {
+				t.Errorf("read %d entry bundles, want %d", got, tc.wantReads)
+			}
+		})
+	}
+}
+
+// BenchmarkDedup measures adding duplicates of an entry, which are either
+// read back from the log, or found in the dedup cache.
+func BenchmarkDedup(b *testing.B) {
+	stored := &ctonly.Entry{Timestamp: 1234, Certificate: make([]byte, 2048)}
+	r := newFakeLogReader(b, layout.EntryBundleWidth)
+	var bundle []byte
+	for i := range uint64(layout.EntryBundleWidth) {
+		bundle = append(bundle, stored.LeafData(i)...)
+	}
+	r.bundles = map[uint64][]byte{0: bundle}
+
+	for _, cached := range []bool{false, true} {
+		b.Run(fmt.Sprintf("cached=%t", cached), func(b *testing.B) {
+			cts, err := NewCTStorage(b.Context(), nil, &fakeIssuerStorage{}, r, CTStorageOpts{})
+			if err != nil {
+				b.Fatalf("NewCTStorage()=%v", err)
+			}
+			cts.storeData = func(context.Context, *ctonly.Entry) tessera.IndexFuture {
+				return func() (tessera.Index, error) { return tessera.Index{Index: 0, IsDup: true}, nil }
+			}
+			for b.Loop() {
+				if !cached {
+					cts.dedupCache.slots[0] = dedupCacheEntry{}
+				}
+				if _, _, err := cts.Add(b.Context(), stored); err != nil {
+					b.Fatalf("Add()=%v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryCreateStorage(t *testing.T) {
+	errNotReady := errors.New("backend not ready")
+
+	for _, tc := range []struct {
+		desc      string
+		attempts  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			desc:      "succeeds-after-retries",
+			attempts:  3,
+			wantCalls: 3,
+		},
+		{
+			desc:      "more-attempts-than-needed",
+			attempts:  10,
+			wantCalls: 3,
+		},
+		{
+			desc:      "gives-up",
+			attempts:  2,
+			wantCalls: 2,
+			wantErr:   true,
+		},
+		{
+			desc:      "no-retries",
+			attempts:  0,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			calls := 0
+			want := &CTStorage{}
+			// cs fails twice, then succeeds.
+			cs := func(context.Context, note.Signer, ...note.Signer) (*CTStorage, error) {
+				calls++
+				if calls <= 2 {
+					return nil, errNotReady
+				}
+				return want, nil
+			}
+
+			got, err := RetryCreateStorage(cs, tc.attempts, time.Millisecond)(t.Context(), nil)
+			if tc.wantErr {
+				if !errors.Is(err, errNotReady) {
+					t.Errorf("RetryCreateStorage()()=%v, want %v", err, errNotReady)
+				}
+			} else if err != nil || got != want {
+				t.Errorf("RetryCreateStorage()()=%v, %v, want storage, nil", got, err)
+			}
+			if calls != tc.wantCalls {
+				t.Errorf("storage created %d times, want %d", calls, tc.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryCreateStorageContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cs := func(context.Context, note.Signer, ...note.Signer) (*CTStorage, error) {
+		cancel()
+		return nil, errors.New("backend not ready")
+	}
+
+	if _, err := RetryCreateStorage(cs, 3, time.Hour)(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("RetryCreateStorage()()=%v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	tfl "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/tessera"
+	"github.com/transparency-dev/tessera/client"
+	"k8s.io/klog/v2"
+)
+
+// VerifyTree checks that the root hash of the latest checkpoint of a log
+// matches the root hash computed from its stored tiles.
+//
+// Only the tiles on the right edge of the tree are read, so this is cheap
+// enough to run on startup, even for large logs. Logs without a checkpoint yet
+// are considered valid.
+func VerifyTree(ctx context.Context, reader tessera.LogReader) error {
+	ctx, span := tracer.Start(ctx, "tesseract.storage.VerifyTree")
+	defer span.End()
+
+	cpRaw, err := reader.ReadCheckpoint(ctx)
+	if errors.Is(err, os.ErrNotExist) {
+		klog.Info("VerifyTree: no checkpoint yet, nothing to verify")
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	cp := tfl.Checkpoint{}
+	if _, err := cp.Unmarshal(cpRaw); err != nil {
+		return fmt.Errorf("failed to parse checkpoint: %v", err)
+	}
+
+	root := rfc6962.DefaultHasher.EmptyRoot()
+	if cp.Size > 0 {
+		nodes, err := client.FetchRangeNodes(ctx, cp.Size, reader.ReadTile)
+		if err != nil {
+			return fmt.Errorf("failed to read tiles for tree size %d: %v", cp.Size, err)
+		}
+		rf := compact.RangeFactory{Hash: rfc6962.DefaultHasher.HashChildren}
+		r, err := rf.NewRange(0, cp.Size, nodes)
+		if err != nil {
+			return fmt.Errorf("failed to build compact range for tree size %d: %v", cp.Size, err)
+		}
+		if root, err = r.GetRootHash(nil); err != nil {
+			return fmt.Errorf("failed to compute root hash for tree size %d: %v", cp.Size, err)
+		}
+	}
+	if !bytes.Equal(root, cp.Hash) {
+		return fmt.Errorf("checkpoint root hash %x at size %d doesn't match root hash %x computed from tiles", cp.Hash, cp.Size, root)
+	}
+	klog.Infof("VerifyTree: checkpoint at size %d matches the stored tiles", cp.Size)
+	return nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	tfl "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/tessera"
+	"github.com/transparency-dev/tessera/api/layout"
+)
+
+// fakeLogReader is an in-memory tessera.LogReader serving a checkpoint, hash
+// tiles and entry bundles. Other methods are not implemented.
+type fakeLogReader struct {
+	tessera.LogReader
+	checkpoint []byte
+	// tiles maps tile coordinates to full tiles, which are truncated to
+	// their partial width when read.
+	tiles map[[2]uint64][][]byte
+	// bundles maps indices to entry bundles, which are returned as is.
+	bundles map[uint64][]byte
+	// bundleReads counts the entry bundles read.
+	bundleReads atomic.Int64
+}
+
+func (r *fakeLogReader) ReadCheckpoint(_ context.Context) ([]byte, error) {
+	if r.checkpoint == nil {
+		return nil, os.ErrNotExist
+	}
+	return r.checkpoint, nil
+}
+
+func (r *fakeLogReader) ReadTile(_ context.Context, level, index uint64, p uint8) ([]byte, error) {
+	nodes, ok := r.tiles[[2]uint64{level, index}]
+	if !ok {
+		return nil, fmt.Errorf("tile %d/%d: %w", level, index, os.ErrNotExist)
+	}
+	if p > 0 {
+		nodes = nodes[:p]
+	}
+	var tile []byte
+	for _, n := range nodes {
+		tile = append(tile, n...)
+	}
+	return tile, nil
+}
+
+func (r *fakeLogReader) ReadEntryBundle(_ context.Context, index uint64, _ uint8) ([]byte, error) {
+	r.bundleReads.Add(1)
+	b, ok := r.bundles[index]
+	if !ok {
+		return nil, fmt.Errorf("entry bundle %d: %w", index, os.ErrNotExist)
+	}
+	return b, nil
+}
+
+// newFakeLogReader returns a fakeLogReader for a tree of size leaves.
+func newFakeLogReader(t testing.TB, size uint64) *fakeLogReader {
+	t.Helper()
+	h := rfc6962.DefaultHasher
+	// rows[l] holds the hashes of the complete subtrees at level l.
+	rows := [][][]byte{{}}
+	for i := range size {
+		rows[0] = append(rows[0], h.HashLeaf(fmt.Appendf(nil, "leaf %d", i)))
+	}
+	for l := 0; len(rows[l]) > 1; l++ {
+		var row [][]byte
+		for i := 0; i+1 < len(rows[l]); i += 2 {
+			row = append(row, h.HashChildren(rows[l][i], rows[l][i+1]))
+		}
+		rows = append(rows, row)
+	}
+
+	r := &fakeLogReader{tiles: map[[2]uint64][][]byte{}}
+	for level := 0; level*layout.TileHeight < len(rows); level++ {
+		row := rows[level*layout.TileHeight]
+		for i := 0; i*layout.TileWidth < len(row); i++ {
+			r.tiles[[2]uint64{uint64(level), uint64(i)}] = row[i*layout.TileWidth : min((i+1)*layout.TileWidth, len(row))]
+		}
+	}
+
+	root := h.EmptyRoot()
+	if size > 0 {
+		// Hash the perfect subtrees of the tree, from the smallest one on the
+		// right, to the largest one on the left.
+		root = nil
+		for l := range len(rows) {
+			if size&(1<<l) == 0 {
+				continue
+			}
+			n := rows[l][len(rows[l])-1]
+			if root == nil {
+				root = n
+			} else {
+				root = h.HashChildren(n, root)
+			}
+		}
+	}
+	r.checkpoint = []byte(tfl.Checkpoint{Origin: "example.com/log", Size: size, Hash: root}.Marshal())
+	return r
+}
+
+func TestVerifyTree(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		size    uint64
+		corrupt func(r *fakeLogReader)
+		wantErr string
+	}{
+		{
+			desc: "no-checkpoint",
+			corrupt: func(r *fakeLogReader) {
+				r.checkpoint = nil
+			},
+		},
+		{
+			desc: "empty-tree",
+		},
+		{
+			desc: "one-leaf",
+			size: 1,
+		},
+		{
+			desc: "partial-tiles",
+			size: 300,
+		},
+		{
+			desc: "multiple-tile-levels",
+			size: 70000,
+		},
+		{
+			desc: "corrupted-leaf-tile",
+			size: 300,
+			corrupt: func(r *fakeLogReader) {
+				r.tiles[[2]uint64{0, 1}][3] = rfc6962.DefaultHasher.HashLeaf([]byte("corrupted"))
+			},
+			wantErr: "doesn't match root hash",
+		},
+		{
+			desc: "corrupted-checkpoint",
+			size: 300,
+			corrupt: func(r *fakeLogReader) {
+				r.checkpoint = []byte(tfl.Checkpoint{Origin: "example.com/log", Size: 300, Hash: rfc6962.DefaultHasher.EmptyRoot()}.Marshal())
+			},
+			wantErr: "doesn't match root hash",
+		},
+		{
+			desc: "missing-tile",
+			size: 300,
+			corrupt: func(r *fakeLogReader) {
+				delete(r.tiles, [2]uint64{0, 1})
+			},
+			wantErr: "failed to read tiles",
+		},
+		{
+			desc: "invalid-checkpoint",
+			corrupt: func(r *fakeLogReader) {
+				r.checkpoint = []byte("not a checkpoint")
+			},
+			wantErr: "failed to parse checkpoint",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := newFakeLogReader(t, tc.size)
+			if tc.corrupt != nil {
+				tc.corrupt(r)
+			}
+			err := VerifyTree(t.Context(), r)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("VerifyTree()=%v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("VerifyTree()=%v, want err containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewCTStorageVerifyTree(t *testing.T) {
+	r := newFakeLogReader(t, 300)
+	r.tiles[[2]uint64{0, 1}][0] = rfc6962.DefaultHasher.HashLeaf([]byte("corrupted"))
+
+	if _, err := NewCTStorage(t.Context(), nil, nil, r, CTStorageOpts{}); err != nil {
+		t.Errorf("NewCTStorage() without verification=%v, want nil", err)
+	}
+	if _, err := NewCTStorage(t.Context(), nil, nil, r, CTStorageOpts{VerifyTreeOnStartup: true}); err == nil {
+		t.Errorf("NewCTStorage() with verification of a corrupted tree=nil, want error")
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package testonly contains helpers for the tests of projects using
+// TesseraCT. It must not be used in production.
+package testonly
+
+import (
+	"crypto"
+	"io"
+	"time"
+
+	tfl "github.com/transparency-dev/formats/log"
+	"github.com/transparency-dev/tesseract/internal/ct"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// Checkpoint returns a https://c2sp.org/static-ct-api checkpoint note, for
+// the log with the given origin, committing to a tree of size entries with
+// the given root hash, and signed by signer at time now.
+//
+// The output is byte-for-byte reproducible, for instance to compare it with
+// golden files, as long as signer produces deterministic signatures when
+// given a nil source of randomness. ECDSA private keys do, as per RFC 6979.
+func Checkpoint(origin string, size uint64, hash []byte, signer crypto.Signer, now time.Time) ([]byte, error) {
+	cpSigner, err := ct.NewCpSigner(deterministicSigner{signer}, origin, fixedTimeSource(now))
+	if err != nil {
+		return nil, err
+	}
+	cp := tfl.Checkpoint{Origin: origin, Size: size, Hash: hash}
+	return note.Sign(&note.Note{Text: string(cp.Marshal())}, cpSigner)
+}
+
+// fixedTimeSource is a ct.TimeSource which always returns the same time.
+type fixedTimeSource time.Time
+
+func (t fixedTimeSource) Now() time.Time {
+	return time.Time(t)
+}
+
+// deterministicSigner is a crypto.Signer which signs without randomness.
+type deterministicSigner struct {
+	crypto.Signer
+}
+
+func (s deterministicSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.Signer.Sign(nil, digest, opts)
+}
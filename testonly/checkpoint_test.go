@@ -0,0 +1,83 @@
+// Copyright 2025 The Tessera authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testonly
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	tdnote "github.com/transparency-dev/formats/note"
+	"github.com/transparency-dev/tesseract/internal/ct"
+	"golang.org/x/mod/sumdb/note"
+)
+
+func TestCheckpoint(t *testing.T) {
+	const origin = "example.com/log"
+	keyPEM, err := os.ReadFile("../internal/testdata/test_ct_server_ecdsa_private_key.pem")
+	if err != nil {
+		t.Fatalf("Can't open key: %v", err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		t.Fatal("pem.Decode() failed")
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("x509.ParseECPrivateKey()=%v", err)
+	}
+	hash := sha256.Sum256([]byte("root"))
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	got, err := Checkpoint(origin, 123, hash[:], key, now)
+	if err != nil {
+		t.Fatalf("Checkpoint()=%v", err)
+	}
+	again, err := Checkpoint(origin, 123, hash[:], key, now)
+	if err != nil {
+		t.Fatalf("Checkpoint()=%v", err)
+	}
+	if string(got) != string(again) {
+		t.Errorf("Checkpoint() is not reproducible: got %q, then %q", got, again)
+	}
+	// Output must be stable across runs, and Go versions.
+	if want := goldenCheckpoint; string(got) != want {
+		t.Errorf("Checkpoint()=%q, want %q", got, want)
+	}
+
+	cpSigner, err := ct.NewCpSigner(key, origin, fixedTimeSource(now))
+	if err != nil {
+		t.Fatalf("NewCpSigner()=%v", err)
+	}
+	verifier, err := tdnote.NewRFC6962Verifier(cpSigner.(interface{ VerifierKey() string }).VerifierKey())
+	if err != nil {
+		t.Fatalf("NewRFC6962Verifier()=%v", err)
+	}
+	n, err := note.Open(got, note.VerifierList(verifier))
+	if err != nil {
+		t.Fatalf("note.Open()=%v, want nil", err)
+	}
+	if !strings.HasPrefix(n.Text, origin+"\n123\n") {
+		t.Errorf("checkpoint text=%q, want origin and size %d", n.Text, 123)
+	}
+}
+
+// goldenCheckpoint is the checkpoint of TestCheckpoint.
+const goldenCheckpoint = "example.com/log\n123\nSBNJTRN+FjG7owHVrKtue7eqdM4RhdRWVl71HXN2d7I=\n\n" +
+	"— example.com/log +3x4CwAAAZQfKXwABAMAZjBkAjAeELHCetq+rDGAnYWrbnNhEj7P5utEp8/qx4oHVWV99+QP6Yb6pVAue62kq7/I5fYCMC+uz/xeD+T3+dlvOFYDGHRq1J2CI6LbHNixGQCJ6LwACadRAM90H2s5HrEumEeTuw==\n"